@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Alpha channel (alpha=auto|keep|drop)
+// ======================
+
+// Transparency survives only in a few codec/container pairs: VP9 in
+// WebM/MKV, ProRes 4444 in MOV/MKV and FFV1 in MKV. Everything else gets
+// flattened, which used to happen silently.
+
+var alphaPixFmtRe = regexp.MustCompile(`^(yuva|gbrap|rgba|bgra|argb|abgr|ya[0-9])`)
+
+// alphaContainers maps a codec to the containers that keep its alpha plane.
+var alphaContainers = map[string][]string{
+	"vp9":    {".webm", ".mkv"},
+	"prores": {".mov", ".mkv"},
+	"ffv1":   {".mkv"},
+}
+
+// hasAlpha reports whether the first video stream carries transparency:
+// an alpha pixel format, or VP8/VP9 with WebM's alpha_mode side channel.
+func (p *probeResult) hasAlpha() bool {
+	if p == nil {
+		return false
+	}
+	vs := p.firstStream("video")
+	return vs != nil && (alphaPixFmtRe.MatchString(vs.PixFmt) || vpxAlpha(vs))
+}
+
+// vpxAlpha: the native VP8/VP9 decoders ignore the alpha side channel, so
+// such inputs must be decoded with libvpx.
+func vpxAlpha(vs *probeStream) bool {
+	return (vs.CodecName == "vp9" || vs.CodecName == "vp8") &&
+		(vs.Tags["alpha_mode"] == "1" || vs.Tags["ALPHA_MODE"] == "1")
+}
+
+func carriesAlpha(codec, ext string) bool {
+	for _, e := range alphaContainers[codecFamily(codec)] {
+		if e == strings.ToLower(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAlpha decides whether the encode keeps the source's alpha plane
+// (o.Alpha becomes "keep" or "drop") and returns the adjustments made.
+func (o *compressOpts) resolveAlpha() ([]string, error) {
+	mode := o.Alpha
+	o.Alpha = "drop"
+	if !o.Source.hasAlpha() || o.Codec == "copy" || o.AudioOut != nil {
+		return nil, nil
+	}
+	if mode == "drop" {
+		return []string{"alpha channel flattened (alpha=drop)"}, nil
+	}
+	if carriesAlpha(o.Codec, o.OutExt) {
+		o.Alpha = "keep"
+		o.HWDecode = "none" // hwaccel surfaces have no alpha plane
+		return nil, nil
+	}
+	if mode != "keep" {
+		return []string{fmt.Sprintf("source has an alpha channel; flattened for %s in %s (alpha=keep switches to VP9/WebM or ProRes 4444/MOV)", o.Codec, o.OutExt)}, nil
+	}
+	if o.Compat == "strict" {
+		return nil, &compatError{Container: o.OutExt, Conflicts: []string{
+			fmt.Sprintf("alpha=keep: %s cannot carry transparency in %s (use vp9 in .webm/.mkv or prores in .mov)", o.Codec, o.OutExt)}}
+	}
+	from := o.Codec + " in " + o.OutExt
+	switch strings.ToLower(o.OutExt) {
+	case ".mov":
+		o.Codec = "prores"
+	case ".webm", ".mkv":
+		o.Codec = "vp9"
+	default:
+		o.Codec, o.OutExt = "vp9", ".webm"
+	}
+	o.Alpha = "keep"
+	o.HWDecode = "none"
+	return []string{fmt.Sprintf("alpha=keep: %s → %s in %s", from, o.Codec, o.OutExt)}, nil
+}
+
+// alphaInputArgs selects the libvpx decoder for VP8/VP9 alpha sources.
+func alphaInputArgs(o compressOpts) []string {
+	if o.Alpha != "keep" || o.Source == nil {
+		return nil
+	}
+	vs := o.Source.firstStream("video")
+	if vs == nil || !vpxAlpha(vs) {
+		return nil
+	}
+	if vs.CodecName == "vp8" {
+		return []string{"-c:v", "libvpx"}
+	}
+	return []string{"-c:v", "libvpx-vp9"}
+}
+
+// alphaPixFmt is the transparent pixel format for vcodec.
+func alphaPixFmt(vcodec string) string {
+	switch vcodec {
+	case "prores_ks":
+		return "yuva444p10le"
+	}
+	return "yuva420p" // libvpx-vp9, ffv1
+}
+
+// proresArgs maps CRF onto prores_ks quantizer; 4444 when keeping alpha,
+// HQ otherwise.
+func proresArgs(crf int, alpha bool, pixFmt string) []string {
+	q := crf / 3
+	if q < 2 {
+		q = 2
+	}
+	profile := "hq"
+	switch {
+	case alpha:
+		profile, pixFmt = "4444", alphaPixFmt("prores_ks")
+	case pixFmt == "yuv444p10le":
+		profile = "4444"
+	default:
+		pixFmt = "yuv422p10le"
+	}
+	return []string{"-profile:v", profile, "-vendor", "apl0", "-qscale:v", strconv.Itoa(q), "-pix_fmt", pixFmt}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ======================
+// Archive mode (speed=archive)
+// ======================
+
+// Archive encodes are for long-term storage, not quick sharing: slow-preset
+// H.265 (or AV1 when asked for, or in .webm) at CRF 20, 10-bit, keyframes
+// wherever the encoder finds scene cuts up to a long maximum GOP, the
+// original resolution and frame rate, the source audio untouched, and every
+// tag, chapter and capture time carried over.
+
+const gopArchiveSec = 10.0
+
+// applyArchive picks the archive codec and settings. It returns the
+// adjustments.
+func (o *compressOpts) applyArchive() []string {
+	var notes []string
+	want := "h265"
+	if o.Codec == "av1" || strings.ToLower(o.OutExt) == ".webm" {
+		want = "av1"
+	}
+	if o.Codec != want {
+		if o.Codec != "h264" { // h264 is the default, not a choice worth reporting
+			notes = append(notes, fmt.Sprintf("codec %s replaced by %s in archive mode", o.Codec, want))
+		}
+		o.Codec = want
+	}
+	if strings.ToLower(o.HW) != "none" {
+		notes = append(notes, "hardware encoders have no slow presets; using CPU for archive mode")
+		o.HW = "none"
+	}
+	if o.Resolution != "original" || o.Scale != "" {
+		notes = append(notes, fmt.Sprintf("resolution %s ignored in archive mode", o.Resolution))
+		o.Resolution, o.Scale = "original", ""
+	}
+	if o.MaxLandscape != (resCap{}) || o.MaxPortrait != (resCap{}) {
+		notes = append(notes, "max_landscape/max_portrait ignored in archive mode")
+		o.MaxLandscape, o.MaxPortrait = resCap{}, resCap{}
+	}
+	if o.FPS > 0 {
+		notes = append(notes, "fps ignored in archive mode")
+		o.FPS = 0
+	}
+	if o.BitDepth == 0 {
+		o.BitDepth = 10
+	}
+	o.Audio = "copy"
+	if !o.StripMetadata {
+		o.PreserveMetadata = true
+	}
+	o.PreserveCapture = true
+	return notes
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Audio-only output (output=audio)
+// ======================
+
+// Lecture and meeting recordings are often uploaded only for their sound.
+// output=audio drops the picture and encodes the first audio track on its
+// own: Opus in an .opus (Ogg) file by default, audio=aac for .m4a, or
+// audio=copy to keep the track as it is in .mka. Without ab the bitrate
+// follows the speed mode with speech in mind; the smallest modes go mono.
+
+type audioOutSpec struct {
+	Codec   string // opus|aac|copy
+	Bitrate string // ab; "" = by speed mode
+	Ext     string
+}
+
+var audioOutExts = map[string]string{"opus": ".opus", "aac": ".m4a", "copy": ".mka"}
+
+// audioOutBitrates are the per-mode rates for Opus; AAC gets half as much
+// again for the same clarity.
+var audioOutBitrates = map[string]int{
+	"max": 24, "proxy": 24, "screen": 24,
+	"turbo": 32, "ultra_fast": 32, "super_fast": 32,
+	"fast":     48,
+	"balanced": 64,
+	"quality":  96,
+	"archive":  128, "lossless": 128,
+}
+
+// audioOutMono lists the modes that downmix to one channel.
+var audioOutMono = map[string]bool{"max": true, "proxy": true, "screen": true}
+
+func parseAudioOut(codec, ab, outExt string) (*audioOutSpec, error) {
+	if outExt != "" {
+		return nil, errors.New("outExt does not apply to output=audio (the audio codec picks .opus, .m4a or .mka)")
+	}
+	codec = strings.ToLower(codec)
+	if codec == "" {
+		codec = "opus"
+	}
+	ext, ok := audioOutExts[codec]
+	if !ok {
+		return nil, fmt.Errorf("invalid audio %q for output=audio (opus|aac|copy)", codec)
+	}
+	return &audioOutSpec{Codec: codec, Bitrate: ab, Ext: ext}, nil
+}
+
+// checkAudioOut rejects options that only make sense with a picture.
+func (o *compressOpts) checkAudioOut() error {
+	if o.AudioOut == nil {
+		return nil
+	}
+	var bad []string
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{o.AutoCrop, "autocrop"},
+		{o.Reframe != nil, "reframe"},
+		{o.Compare != nil, "compare"},
+		{o.Race != nil, "race"},
+		{o.SegmentSec > 0, "segment_sec"},
+		{o.ValidateFor != "", "validate_for"},
+		{o.Projection != "", "projection"},
+		{o.Watermark != nil, "watermark"},
+		{o.PixFmt != "", "pix_fmt"},
+	} {
+		if c.set {
+			bad = append(bad, c.name)
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("output=audio cannot be combined with %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// audioOutArgs is the ffmpeg command line for output=audio.
+func audioOutArgs(inPath, outPath string, o compressOpts) []string {
+	a := o.AudioOut
+	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
+	if o.TrimStart > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(o.TrimStart, 'f', 3, 64))
+	}
+	args = append(args, "-i", inPath)
+	if o.TrimEnd > 0 {
+		args = append(args, "-t", strconv.FormatFloat(o.TrimEnd-o.TrimStart, 'f', 3, 64))
+	}
+	args = append(args, "-map", "0:a:0", "-vn", "-sn", "-dn")
+
+	kbps := audioOutBitrates[o.SpeedMode]
+	if kbps == 0 {
+		kbps = audioOutBitrates["balanced"]
+	}
+	switch a.Codec {
+	case "copy":
+		args = append(args, "-c:a", "copy")
+	case "aac":
+		args = append(args, "-c:a", "aac", "-b:a", cmp.Or(a.Bitrate, strconv.Itoa(kbps*3/2)+"k"))
+	default:
+		args = append(args, "-c:a", "libopus", "-b:a", cmp.Or(a.Bitrate, strconv.Itoa(kbps)+"k"))
+		if kbps < 64 {
+			args = append(args, "-application", "voip") // tuned for speech
+		}
+	}
+	if a.Codec != "copy" && audioOutMono[o.SpeedMode] {
+		args = append(args, "-ac", "1")
+	}
+
+	if o.StripMetadata {
+		args = append(args, "-map_metadata", "-1", "-fflags", "+bitexact")
+	} else {
+		args = append(args, "-map_metadata", "0")
+	}
+	if o.Chapters == "drop" {
+		args = append(args, "-map_chapters", "-1")
+	}
+	if a.Ext == ".m4a" {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return append(args, outPath)
+}
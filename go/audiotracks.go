@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Every audio track (keep_all_audio=1)
+// ======================
+
+// ffmpeg's default stream selection keeps one audio track (the one with the
+// most channels), so multilingual releases lose their dubs and commentary.
+// keep_all_audio=1 maps every audio track and encodes each with the audio
+// settings. Language tags and dispositions carry over, so players still list
+// the tracks by language and start on the default one; strip_metadata keeps
+// the language tags. Dolby bitrates and the 5.1 fold-down follow each
+// track's own channel count.
+
+func parseKeepAllAudio(s string, o compressOpts) (bool, error) {
+	switch s {
+	case "", "0":
+		return false, nil
+	case "1":
+	default:
+		return false, fmt.Errorf("invalid keep_all_audio %q (0|1)", s)
+	}
+	if o.AudioOut != nil {
+		return false, errors.New("keep_all_audio cannot be combined with output=audio (one track per file)")
+	}
+	return true, nil
+}
+
+// audioStreams returns the audio tracks in output order.
+func (p *probeResult) audioStreams() []*probeStream {
+	var out []*probeStream
+	for i := range p.Streams {
+		if p.Streams[i].CodecType == "audio" {
+			out = append(out, &p.Streams[i])
+		}
+	}
+	return out
+}
+
+// audioMap is the -map for the audio of an explicitly mapped encode.
+func audioMap(o compressOpts) string {
+	if o.KeepAllAudio {
+		return "0:a?"
+	}
+	return "0:a:0?"
+}
+
+// audioTrackChannels returns the channel count of each encoded audio track
+// (one entry, possibly 0, unless every track is kept).
+func audioTrackChannels(o compressOpts) []int {
+	if !o.KeepAllAudio || o.Source == nil {
+		return []int{sourceChannels(o.Source)}
+	}
+	var chs []int
+	for _, s := range o.Source.audioStreams() {
+		chs = append(chs, s.Channels)
+	}
+	if len(chs) == 0 {
+		chs = append(chs, 0)
+	}
+	return chs
+}
+
+// audioTrackSpec is the stream specifier for per-track options: ":a" for
+// the single-track case, ":a:N" otherwise.
+func audioTrackSpec(o compressOpts, i int) string {
+	if !o.KeepAllAudio {
+		return ":a"
+	}
+	return ":a:" + strconv.Itoa(i)
+}
+
+// audioLanguageArgs puts the language tags back on every track after
+// strip_metadata has cleared the stream tags.
+func audioLanguageArgs(o compressOpts) []string {
+	if !o.KeepAllAudio || !o.StripMetadata || o.Source == nil {
+		return nil
+	}
+	var args []string
+	for i, s := range o.Source.audioStreams() {
+		if lang := s.Tags["language"]; lang != "" && lang != "und" {
+			args = append(args, "-metadata:s:a:"+strconv.Itoa(i), "language="+lang)
+		}
+	}
+	return args
+}
+
+// ======================
+// Per-track audio bitrates (audio.N.ab)
+// ======================
+
+// audio.N.ab sets the bitrate of the N-th audio track (0-based, in source
+// order) and wins over ab and the speed mode's bitrate, e.g.
+// audio.0.ab=192k&audio.1.ab=96k for a 5.1 main track and a stereo
+// commentary. Tracks above 0 need keep_all_audio=1.
+
+const audioTracksMax = 16
+
+// audioKbpsRange is each audio encoder's bitrate range in kb/s.
+var audioKbpsRange = map[string][2]int{
+	"aac":  {16, 512},
+	"opus": {6, 510},
+	"ac3":  {32, 640},
+	"eac3": {32, 6144},
+}
+
+// parseAudioKbps checks a bitrate ("192k" or bits per second) for the audio
+// codec and returns it as "<n>k".
+func parseAudioKbps(param, codec, ab string) (string, error) {
+	if ab == "" {
+		return "", nil
+	}
+	s := strings.ToLower(strings.TrimSpace(ab))
+	kbps, err := strconv.Atoi(strings.TrimSuffix(s, "k"))
+	if err == nil && !strings.HasSuffix(s, "k") {
+		kbps /= 1000
+	}
+	r := audioKbpsRange[codec]
+	if err != nil || kbps < r[0] || kbps > r[1] {
+		return "", fmt.Errorf("invalid %s %q for audio=%s (%dk-%dk)", param, ab, codec, r[0], r[1])
+	}
+	return strconv.Itoa(kbps) + "k", nil
+}
+
+func parseTrackBitrates(get func(key, def string) string, o compressOpts) (map[int]string, error) {
+	var out map[int]string
+	codec := strings.ToLower(o.Audio)
+	for i := range audioTracksMax {
+		param := "audio." + strconv.Itoa(i) + ".ab"
+		v := get(param, "")
+		if v == "" {
+			continue
+		}
+		switch {
+		case o.AudioOut != nil:
+			return nil, fmt.Errorf("%s does not apply to output=audio (use ab)", param)
+		case codec == "copy":
+			return nil, fmt.Errorf("%s needs an audio re-encode (audio=copy)", param)
+		case i > 0 && !o.KeepAllAudio:
+			return nil, fmt.Errorf("%s needs keep_all_audio=1 (only the first track is kept)", param)
+		}
+		kbps, err := parseAudioKbps(param, codec, v)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			out = map[int]string{}
+		}
+		out[i] = kbps
+	}
+	return out, nil
+}
+
+// settleTrackBitrates drops overrides for tracks the source does not have
+// and returns notes for the warnings.
+func (o *compressOpts) settleTrackBitrates() []string {
+	if len(o.TrackAB) == 0 || o.Source == nil {
+		return nil
+	}
+	n := len(o.Source.audioStreams())
+	var notes []string
+	for _, i := range slices.Sorted(maps.Keys(o.TrackAB)) {
+		if i >= n {
+			notes = append(notes, fmt.Sprintf("audio.%d.ab ignored: the source has %d audio tracks", i, n))
+			delete(o.TrackAB, i)
+		}
+	}
+	return notes
+}
+
+// trackBitrateArgs sets the audio.N.ab bitrates.
+func trackBitrateArgs(o compressOpts) []string {
+	if strings.EqualFold(o.Audio, "copy") {
+		return nil
+	}
+	var args []string
+	for _, i := range slices.Sorted(maps.Keys(o.TrackAB)) {
+		args = append(args, "-b:a:"+strconv.Itoa(i), o.TrackAB[i])
+	}
+	return args
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseAudioKbps(t *testing.T) {
+	tests := []struct {
+		codec, ab string
+		want      string
+		wantErr   bool
+	}{
+		{codec: "aac", ab: "", want: ""},
+		{codec: "aac", ab: "192k", want: "192k"},
+		{codec: "aac", ab: "192000", want: "192k"},
+		{codec: "aac", ab: " 96K ", want: "96k"},
+		{codec: "aac", ab: "16k", want: "16k"},
+		{codec: "aac", ab: "15k", wantErr: true},
+		{codec: "aac", ab: "600k", wantErr: true},
+		{codec: "opus", ab: "6k", want: "6k"},
+		{codec: "opus", ab: "5k", wantErr: true},
+		{codec: "ac3", ab: "640k", want: "640k"},
+		{codec: "ac3", ab: "1536k", wantErr: true},
+		{codec: "eac3", ab: "1536k", want: "1536k"},
+		{codec: "aac", ab: "abc", wantErr: true},
+		{codec: "aac", ab: "1.5m", wantErr: true},
+		{codec: "copy", ab: "128k", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseAudioKbps("ab", tt.codec, tt.ab)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAudioKbps(%q, %q) error = %v, want error %v", tt.codec, tt.ab, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAudioKbps(%q, %q) = %q, want %q", tt.codec, tt.ab, got, tt.want)
+		}
+	}
+}
+
+func TestParseDolbyBitrateUsesAudioRange(t *testing.T) {
+	for codec, r := range audioKbpsRange {
+		if !isDolby(codec) {
+			continue
+		}
+		if _, err := parseDolbyBitrate(codec, strconv.Itoa(r[1])+"k"); err != nil {
+			t.Errorf("parseDolbyBitrate(%q, max) error = %v", codec, err)
+		}
+		if _, err := parseDolbyBitrate(codec, strconv.Itoa(r[1]+1)+"k"); err == nil {
+			t.Errorf("parseDolbyBitrate(%q, max+1) accepted", codec)
+		}
+	}
+}
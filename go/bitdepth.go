@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ======================
+// Bit depth (bit_depth=8|10)
+// ======================
+
+// highBitDepthRe matches >8-bit pixel formats (yuv420p10le, p010le, gray12le...).
+var highBitDepthRe = regexp.MustCompile(`p1[0-6](le|be)$|^p01[026]|^gray1[0-6]`)
+
+// tenBitEncoders can encode 10-bit output (main10 / AV1 main).
+var tenBitEncoders = map[string]bool{"libx265": true, "libsvtav1": true}
+
+func parseBitDepth(s, codec string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || (n != 8 && n != 10) {
+		return 0, fmt.Errorf("invalid bit_depth %q (8|10)", s)
+	}
+	if n == 10 && codec != "h265" && codec != "av1" {
+		return 0, fmt.Errorf("bit_depth=10 needs codec=h265 or codec=av1 (got %s)", codec)
+	}
+	return n, nil
+}
+
+// highBitDepth reports whether the first video stream is more than 8 bits.
+func (p *probeResult) highBitDepth() bool {
+	if p == nil {
+		return false
+	}
+	vs := p.firstStream("video")
+	return vs != nil && highBitDepthRe.MatchString(vs.PixFmt)
+}
+
+// tenBit decides the output depth for vcodec: bit_depth=10, or (unset) a
+// 10-bit source in quality mode so gradients don't band.
+func tenBit(o compressOpts, vcodec string) bool {
+	if !tenBitEncoders[vcodec] {
+		return false
+	}
+	switch o.BitDepth {
+	case 10:
+		return true
+	case 8:
+		return false
+	}
+	return o.SpeedMode == "quality" && o.Source.highBitDepth()
+}
+
+// tenBitArgs selects the 10-bit pixel format and profile for vcodec.
+func tenBitArgs(vcodec string) []string {
+	args := []string{"-pix_fmt", "yuv420p10le"}
+	if vcodec == "libx265" {
+		args = append(args, "-profile:v", "main10")
+	}
+	return args
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ======================
+// UI branding (BRANDING_DIR)
+// ======================
+
+// BRANDING_DIR white-labels the upload and result pages:
+//
+//	site.json              title, logo, colors, footer, links
+//	static/                served at /brand/ (logo, favicon, CSS)
+//	templates/upload.html  replaces the upload page
+//	templates/result.html  replaces the result page
+//
+// Everything is optional and loaded once at startup. Template overrides get
+// the same data as the built-in pages plus .Site.
+
+type siteLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+type siteColors struct {
+	Primary      string `json:"primary,omitempty"`       // buttons
+	PrimaryHover string `json:"primary_hover,omitempty"` // button hover
+	Accent       string `json:"accent,omitempty"`        // links
+	Background   string `json:"background,omitempty"`
+	Text         string `json:"text,omitempty"`
+}
+
+type siteConfig struct {
+	Title  string     `json:"title,omitempty"`
+	Logo   string     `json:"logo,omitempty"` // URL, or a file in static/ (served at /brand/)
+	Colors siteColors `json:"colors"`
+	Footer string     `json:"footer,omitempty"`
+	Links  []siteLink `json:"links,omitempty"`
+}
+
+var cssColorRe = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|rgba?\([\d\s.,%]+\))$`)
+
+// site is the active branding (defaults reproduce the stock look).
+var site = defaultSite()
+
+func defaultSite() siteConfig {
+	return siteConfig{
+		Title: "Video Compress",
+		Colors: siteColors{Primary: "#111827", PrimaryHover: "#0f172a", Accent: "#667eea",
+			Background: "#ffffff", Text: "#111827"},
+	}
+}
+
+func brandingDir() string {
+	return os.Getenv("BRANDING_DIR")
+}
+
+// loadBranding reads site.json and template overrides from BRANDING_DIR.
+func loadBranding() error {
+	dir := brandingDir()
+	if dir == "" {
+		return nil
+	}
+	s := defaultSite()
+	data, err := os.ReadFile(filepath.Join(dir, "site.json"))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("branding site.json: %w", err)
+		}
+	}
+	for _, c := range []string{s.Colors.Primary, s.Colors.PrimaryHover, s.Colors.Accent, s.Colors.Background, s.Colors.Text} {
+		if c != "" && !cssColorRe.MatchString(c) {
+			return fmt.Errorf("branding: invalid color %q", c)
+		}
+	}
+	if s.Logo != "" && !isHTTPURL(s.Logo) {
+		s.Logo = "/brand/" + filepath.Base(s.Logo)
+	}
+	site = s
+
+	for name, tpl := range map[string]**template.Template{"upload.html": &uploadTpl, "result.html": &resultTpl} {
+		path := filepath.Join(dir, "templates", name)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		t, err := template.ParseFiles(path)
+		if err != nil {
+			return fmt.Errorf("branding %s: %w", name, err)
+		}
+		*tpl = t
+		logger.Printf("🎨 [MAIN] Template override: %s", path)
+	}
+	logger.Printf("🎨 [MAIN] Branding loaded from %s (title %q)", dir, site.Title)
+	return nil
+}
+
+// CSS turns the colors into CSS variables for the built-in pages.
+func (s siteConfig) CSS() template.CSS {
+	c := s.Colors
+	return template.CSS(fmt.Sprintf(":root{--primary:%s;--primary-hover:%s;--accent:%s;--bg:%s;--text:%s}",
+		c.Primary, c.PrimaryHover, c.Accent, c.Background, c.Text))
+}
+
+// brandHandler serves BRANDING_DIR/static at /brand/.
+func brandHandler() http.Handler {
+	dir := brandingDir()
+	if dir == "" {
+		return http.NotFoundHandler()
+	}
+	return http.StripPrefix("/brand/", http.FileServer(http.Dir(filepath.Join(dir, "static"))))
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Job completion callbacks (callback_url=)
+// ======================
+
+// A job started with callback_url (async=1 or detach_on_disconnect=1) POSTs
+// a JSON payload there when it finishes or fails, so a backend does not have
+// to poll /jobs/{id}. The payload is signed with the manifest key (Ed25519,
+// see manifest.go): X-Callback-Signature is the base64 signature over
+// "<X-Callback-Timestamp>.<body>", verifiable with GET /manifests/key.
+// Like url= sources, internal addresses are refused unless
+// FETCH_ALLOW_PRIVATE=1. Network errors, 5xx, 408 and 429 are retried with
+// exponential backoff; other answers end the delivery. The outcome shows up
+// as "callback" in the job view. gRPC jobs link to PUBLIC_URL in the payload.
+
+const (
+	callbackAttempts = 6
+	callbackBackoff  = time.Second
+	callbackMaxWait  = time.Minute
+	callbackTimeout  = 10 * time.Second
+)
+
+// callbackPayload is the body POSTed to callback_url.
+func callbackPayload(j job) map[string]any {
+	v := map[string]any{
+		"event":       "job." + j.State,
+		"job_id":      j.ID,
+		"status":      j.State,
+		"created_at":  j.Created.UTC().Format(time.RFC3339),
+		"finished_at": j.Finished.UTC().Format(time.RFC3339),
+	}
+	if !j.Started.IsZero() {
+		v["duration_ms"] = j.Finished.Sub(j.Started).Milliseconds()
+	}
+	if j.Error != "" {
+		v["error"] = j.Error
+	}
+	if e := j.Result; e != nil && j.ResultID != "" {
+		v["result_id"] = j.ResultID
+		v["input_bytes"] = e.InputBytes
+		v["output_bytes"] = e.OutputBytes
+		v["encode_duration_ms"] = e.ElapsedMs
+		v["download_url"] = j.BaseURL + "/dl/" + j.ResultID
+		v["meta_url"] = j.BaseURL + "/meta/" + j.ResultID
+		if e.OutputURL != "" {
+			v["output_url"] = e.OutputURL
+		}
+	}
+	j.Client.addTo(v)
+	return v
+}
+
+// signCallback returns the signature headers for body.
+func signCallback(body []byte, now time.Time) (http.Header, error) {
+	key, err := manifestSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	msg := append([]byte(ts+"."), body...)
+	h := http.Header{}
+	h.Set("X-Callback-Timestamp", ts)
+	h.Set("X-Callback-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(key, msg)))
+	h.Set("X-Callback-Key-Id", manifestKeyID(key.Public().(ed25519.PublicKey)))
+	return h, nil
+}
+
+// callbackRetryable reports whether an HTTP status is worth another attempt.
+func callbackRetryable(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// sendCallback delivers the finished job's payload in the background.
+func sendCallback(j *job) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		snap, _ := j.snapshot()
+		body, err := json.Marshal(callbackPayload(snap))
+		if err != nil {
+			return
+		}
+		wait := callbackBackoff
+		for attempt := 1; ; attempt++ {
+			err := postCallback(snap.Callback, j.ID, body, attempt)
+			j.update(func(j *job) {
+				j.CallbackAttempts = attempt
+				j.CallbackError = ""
+				if err != nil {
+					j.CallbackError = err.Error()
+				}
+			})
+			var pe *callbackError
+			retry := err != nil && attempt < callbackAttempts
+			if errors.As(err, &pe) && !callbackRetryable(pe.status) {
+				retry = false
+			}
+			if !retry {
+				state := "delivered"
+				if err != nil {
+					state = "failed"
+					logger.Printf("❌ [%s] Callback to %s failed after %d attempts: %v", j.ID, snap.Callback, attempt, err)
+				} else {
+					logger.Printf("📮 [%s] Callback delivered to %s", j.ID, snap.Callback)
+				}
+				j.update(func(j *job) { j.CallbackState = state })
+				return
+			}
+			// jitter keeps many failing jobs from retrying in step
+			d := wait/2 + rand.N(wait/2+1)
+			logger.Printf("⚠️ [%s] Callback attempt %d failed (%v), retrying in %v", j.ID, attempt, err, d.Round(time.Millisecond))
+			time.Sleep(d)
+			wait = min(wait*2, callbackMaxWait)
+		}
+	}()
+}
+
+type callbackError struct {
+	status int
+}
+
+func (e *callbackError) Error() string {
+	return fmt.Sprintf("callback answered %d %s", e.status, http.StatusText(e.status))
+}
+
+func postCallback(url, jobID string, body []byte, attempt int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callbackTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	sig, err := signCallback(body, time.Now())
+	if err != nil {
+		return err
+	}
+	for k, v := range sig {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "videocompress-http")
+	req.Header.Set("X-Callback-Id", jobID)
+	req.Header.Set("X-Callback-Attempt", strconv.Itoa(attempt))
+	// the same address guard as url= sources: callback_url is caller-chosen
+	resp, err := fetchClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &callbackError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// setCallback arms the job's callback; base prefixes the payload URLs.
+func (j *job) setCallback(url, base string) {
+	if url == "" {
+		return
+	}
+	j.update(func(j *job) {
+		j.Callback, j.BaseURL, j.CallbackState = url, base, "pending"
+	})
+}
+
+// publicBaseURL is PUBLIC_URL, the address clients reach the HTTP API at,
+// for callers that did not come over HTTP (gRPC). Without it the HTTP port on
+// the host the caller dialled is assumed.
+func publicBaseURL(host string) string {
+	if u := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/"); u != "" {
+		return u
+	}
+	scheme := "http"
+	if os.Getenv("TLS_CERT_FILE") != "" {
+		scheme = "https"
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, envOr("PORT", "8080"))
+}
+
+// requestBaseURL is the scheme and host the client used to reach us.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if p := r.Header.Get("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	return scheme + "://" + r.Host
+}
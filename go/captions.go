@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Closed captions (captions=auto|keep|drop|extract)
+// ======================
+
+// Broadcast and camera sources often carry CEA-608/708 captions inside the
+// video bitstream (A/53 SEI user data) rather than as a subtitle track, and
+// ffprobe reports them as "closed_captions" on the video stream. x264, x265
+// and VideoToolbox pass them from decoder to encoder, and codec=copy keeps
+// them as they are; VP9, AV1, FFV1 and ProRes have nowhere to put them, and
+// changing the frame rate drops the caption bytes of the dropped frames.
+//
+//	auto     keep them where the encode can, warn where it cannot (default)
+//	keep     like auto, but refuse a codec that cannot carry them
+//	drop     strip them from the output
+//	extract  keep them and also store captions.srt / captions.scc artifacts
+//
+// Extraction decodes the source once more through the lavfi movie source,
+// whose +subcc output turns the caption side data into an EIA-608 track.
+
+// captionCodecs can carry A/53 captions through an encode.
+var captionCodecs = map[string]bool{"h264": true, "h265": true, "copy": true}
+
+// hasClosedCaptions reports whether ffprobe saw captions in the video stream.
+func (p *probeResult) hasClosedCaptions() bool {
+	if p == nil {
+		return false
+	}
+	vs := p.firstStream("video")
+	return vs != nil && vs.ClosedCaptions > 0
+}
+
+func parseCaptions(s string, o compressOpts) (string, error) {
+	if s == "" {
+		s = "auto"
+	}
+	codec := strings.ToLower(o.Codec)
+	switch s {
+	case "auto", "extract":
+	case "keep":
+		if !captionCodecs[codec] {
+			return "", fmt.Errorf("captions=keep needs codec=h264, h265 or copy (%s cannot carry CEA-608/708)", codec)
+		}
+	case "drop":
+		if codec == "copy" {
+			return "", errors.New("captions=drop needs a video re-encode (codec=copy keeps the bitstream as it is)")
+		}
+	default:
+		return "", fmt.Errorf("invalid captions %q (auto|keep|drop|extract)", s)
+	}
+	return s, nil
+}
+
+// captionsRetimed reports whether the encode changes the frame rate, which
+// leaves gaps in the caption data.
+func captionsRetimed(o compressOpts) bool {
+	if strings.ToLower(o.Codec) == "copy" {
+		return false
+	}
+	switch o.SpeedMode {
+	case "turbo", "max", "proxy", "screen":
+		return true
+	}
+	return o.FPS > 0
+}
+
+// settleCaptions rechecks the caption choice against the codec finally
+// chosen and returns notes for the warnings. Sources without captions need
+// nothing.
+func (o *compressOpts) settleCaptions() []string {
+	if !o.Source.hasClosedCaptions() || o.AudioOut != nil || o.Captions == "drop" {
+		return nil
+	}
+	codec := strings.ToLower(o.Codec)
+	hint := ""
+	if o.Captions != "extract" {
+		hint = " (captions=extract saves them as SRT/SCC)"
+	}
+	switch {
+	case !captionCodecs[codec]:
+		return []string{fmt.Sprintf("closed captions dropped: %s cannot carry CEA-608/708%s", codec, hint)}
+	case captionsRetimed(*o):
+		return []string{"closed captions may be incomplete: the frame rate changes" + hint}
+	}
+	return nil
+}
+
+// captionArgs switches the encoder's A/53 caption passthrough on or off for
+// sources that have captions.
+func captionArgs(o compressOpts, vcodec string) []string {
+	if !o.Source.hasClosedCaptions() {
+		return nil
+	}
+	switch vcodec {
+	case "libx264", "libx265", "h264_videotoolbox", "hevc_videotoolbox":
+		if o.Captions == "drop" {
+			return []string{"-a53cc", "0"}
+		}
+		return []string{"-a53cc", "1"}
+	}
+	return nil
+}
+
+// filterPath quotes a file name for use as a filter option inside a
+// filtergraph (two levels of ffmpeg escaping).
+func filterPath(p string) string {
+	quoted := "'" + strings.ReplaceAll(p, "'", `'\''`) + "'"
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`, "[", `\[`, "]", `\]`, ",", `\,`, ";", `\;`).Replace(quoted)
+}
+
+// extractCaptions writes the source's captions (within the trim range) next
+// to outPath as captions.srt and, where this ffmpeg has the muxer,
+// captions.scc, and registers them as artifacts.
+func extractCaptions(ctx context.Context, inPath, outPath string, o compressOpts, artifacts map[string]string) error {
+	srtPath := withExt(outPath, "_captions.srt")
+	sccPath := withExt(outPath, "_captions.scc")
+	args := []string{"-f", "lavfi", "-i", "movie=filename=" + filterPath(inPath) + "[out0+subcc]"}
+	if o.TrimStart > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(o.TrimStart, 'f', 3, 64))
+	}
+	if o.TrimEnd > 0 {
+		args = append(args, "-t", strconv.FormatFloat(o.TrimEnd-o.TrimStart, 'f', 3, 64))
+	}
+	srt := []string{"-map", "0:s:0", "-c:s", "srt", srtPath}
+	scc := []string{"-map", "0:s:0", "-c:s", "copy", "-f", "scc", sccPath}
+	if err := runFF(ctx, append(append(args, srt...), scc...)...); err != nil {
+		// older builds have no SCC muxer: SRT alone is still worth having
+		os.Remove(sccPath)
+		if err := runFF(ctx, append(args, srt...)...); err != nil {
+			os.Remove(srtPath)
+			return err
+		}
+	} else {
+		artifacts["captions.scc"] = sccPath
+	}
+	if st, err := os.Stat(srtPath); err != nil || st.Size() == 0 {
+		os.Remove(srtPath)
+		os.Remove(sccPath)
+		delete(artifacts, "captions.scc")
+		return errors.New("no caption text found")
+	}
+	artifacts["captions.srt"] = srtPath
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Chapters export
+// ======================
+
+type chapterJSON struct {
+	Title string  `json:"title,omitempty"`
+	Start float64 `json:"start_sec"`
+	End   float64 `json:"end_sec"`
+}
+
+// exportChapters writes chapters.json and chapters.ffmeta next to outPath and
+// registers them as artifacts.
+func exportChapters(chs []probeChapter, outPath string, artifacts map[string]string) error {
+	list := make([]chapterJSON, 0, len(chs))
+	var meta strings.Builder
+	meta.WriteString(";FFMETADATA1\n")
+	for _, c := range chs {
+		tb := c.TimeBase
+		if tb == "" {
+			tb = "1/1000"
+		}
+		start, _ := strconv.ParseFloat(c.StartTime, 64)
+		end, _ := strconv.ParseFloat(c.EndTime, 64)
+		list = append(list, chapterJSON{
+			Title: c.Tags["title"],
+			Start: start,
+			End:   end,
+		})
+		meta.WriteString("\n[CHAPTER]\nTIMEBASE=" + tb + "\n")
+		meta.WriteString("START=" + strconv.FormatInt(c.Start, 10) + "\nEND=" + strconv.FormatInt(c.End, 10) + "\n")
+		if t := c.Tags["title"]; t != "" {
+			meta.WriteString("title=" + escapeFFMeta(t) + "\n")
+		}
+	}
+
+	jsonPath := withExt(outPath, "_chapters.json")
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(jsonPath, b, 0o644); err != nil {
+		return err
+	}
+	metaPath := withExt(outPath, "_chapters.ffmeta")
+	if err := os.WriteFile(metaPath, []byte(meta.String()), 0o644); err != nil {
+		return err
+	}
+	artifacts["chapters.json"] = jsonPath
+	artifacts["chapters.ffmeta"] = metaPath
+	return nil
+}
+
+// escapeFFMeta escapes the characters ffmetadata treats specially.
+func escapeFFMeta(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+	return r.Replace(s)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Segmented encodes with checkpoints (segment_sec=)
+// ======================
+
+// segment_sec=N encodes the video of the input as consecutive N-second
+// pieces and joins them with the concat demuxer, encoding the audio once over
+// the whole range while joining (AAC encoded piece by piece would carry
+// encoder priming into every joint). Finished pieces are kept under
+// OUTPUT_DIR/.checkpoints/<key>, where key fingerprints the input and the
+// encode settings, so when a crashed or restarted server is given the same
+// job again (a client retry) it resumes after the last finished segment
+// instead of re-encoding from the start. Async and detached jobs do not need
+// the retry: their checkpoint also records the job and keeps the input, and
+// a restarted server resumes them under the same job ID (resumeCheckpoints).
+// The directory is removed once the output is joined; purge_checkpoints
+// clears abandoned ones.
+
+const (
+	minSegmentSec    = 30
+	fingerprintChunk = 4 << 20
+)
+
+// checkpointInfo is written next to the segments for inspection, purging
+// and resuming.
+type checkpointInfo struct {
+	Source   string         `json:"source"`
+	Segments int            `json:"segments"`
+	Done     int            `json:"done"` // finished segments
+	Created  time.Time      `json:"created"`
+	Job      *checkpointJob `json:"job,omitempty"`
+}
+
+// checkpointJob is the job a checkpoint belongs to, with what it takes to
+// run it again: its request (see job.setRequest) and the input, kept in the
+// checkpoint directory.
+type checkpointJob struct {
+	ID         string       `json:"id"`
+	Params     url.Values   `json:"params"`
+	Header     http.Header  `json:"header,omitempty"`
+	Grant      *uploadGrant `json:"grant,omitempty"`
+	SourceName string       `json:"source_name,omitempty"`
+	Owner      string       `json:"owner"`
+	Client     clientMeta   `json:"client"`
+	Callback   string       `json:"callback_url,omitempty"`
+	BaseURL    string       `json:"base_url,omitempty"`
+	Created    time.Time    `json:"created"`
+	Input      string       `json:"input"`
+}
+
+func checkpointRoot() string {
+	return filepath.Join(outputDir(), ".checkpoints")
+}
+
+func readCheckpoint(dir string) (checkpointInfo, error) {
+	var info checkpointInfo
+	data, err := os.ReadFile(filepath.Join(dir, "checkpoint.json"))
+	if err != nil {
+		return info, err
+	}
+	return info, json.Unmarshal(data, &info)
+}
+
+func writeCheckpoint(dir string, info checkpointInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, "checkpoint.json.tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, "checkpoint.json"))
+}
+
+// recordJob adds the job of ctx to a checkpoint that has none, keeping its
+// input next to the segments. Jobs without recorded options (gRPC) and plain
+// requests are not recorded; they resume when submitted again.
+func recordJob(ctx context.Context, dir, inPath string, info *checkpointInfo) error {
+	j := jobOf(ctx)
+	if info.Job != nil || j == nil || j.params == nil {
+		return nil
+	}
+	input := "input" + filepath.Ext(inPath)
+	if _, err := os.Stat(filepath.Join(dir, input)); err != nil {
+		if err := linkOrCopy(inPath, filepath.Join(dir, input)); err != nil {
+			return err
+		}
+	}
+	snap, _ := j.snapshot()
+	info.Job = &checkpointJob{
+		ID:         snap.ID,
+		Params:     snap.params,
+		Header:     snap.header,
+		Grant:      snap.grant,
+		SourceName: snap.sourceName,
+		Owner:      snap.owner,
+		Client:     snap.Client,
+		Callback:   snap.Callback,
+		BaseURL:    snap.BaseURL,
+		Created:    snap.Created,
+		Input:      input,
+	}
+	return nil
+}
+
+// dropJob forgets the job of ctx in its checkpoint once it has failed, so a
+// restart does not run it again; the segments stay for a retry.
+func dropJob(ctx context.Context, dir string, info *checkpointInfo) {
+	if j := jobOf(ctx); info.Job == nil || j == nil || info.Job.ID != j.ID {
+		return
+	}
+	os.Remove(filepath.Join(dir, info.Job.Input))
+	info.Job = nil
+	writeCheckpoint(dir, *info)
+}
+
+func parseSegmentSec(s, codec string, race bool) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < minSegmentSec {
+		return 0, fmt.Errorf("invalid segment_sec %q (at least %d)", s, minSegmentSec)
+	}
+	if codec == "copy" {
+		return 0, errors.New("segment_sec needs a video re-encode (codec=copy)")
+	}
+	if race {
+		return 0, errors.New("segment_sec cannot be combined with race")
+	}
+	return v, nil
+}
+
+// inputFingerprint hashes the size plus the first, middle and last 4 MiB,
+// which is enough to tell uploads apart without reading hours of video.
+func inputFingerprint(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", st.Size())
+	for _, off := range []int64{0, st.Size()/2 - fingerprintChunk/2, st.Size() - fingerprintChunk} {
+		if _, err := io.Copy(h, io.NewSectionReader(f, max(off, 0), fingerprintChunk)); err != nil {
+			return nil, err
+		}
+	}
+	return h.Sum(nil), nil
+}
+
+// checkpointKey covers the input and everything that shapes the encode.
+func checkpointKey(inPath string, o compressOpts) (string, error) {
+	fp, err := inputFingerprint(inPath)
+	if err != nil {
+		return "", err
+	}
+	o.normalize()
+	o.VideoOnly = true // what the segments hold
+	h := sha256.New()
+	h.Write(fp)
+	io.WriteString(h, strings.Join(buildFFmpegArgs("in", "out"+o.OutExt, o), "\x00"))
+	fmt.Fprintf(h, "\x00%g", o.SegmentSec)
+	return hex.EncodeToString(h.Sum(nil))[:24], nil
+}
+
+// segmentedEncode encodes the video of [TrimStart, TrimEnd or duration) in
+// SegmentSec pieces, skipping the ones a previous attempt finished, and joins
+// them into outPath together with the audio of the range. It returns how many
+// segments were reused.
+func segmentedEncode(ctx context.Context, requestID, inPath, outPath string, o compressOpts) (int, error) {
+	if o.Source == nil {
+		return 0, errors.New("segment_sec needs a probed source")
+	}
+	dur := o.Source.durationSec()
+	if dur <= 0 {
+		return 0, errors.New("segment_sec needs a probed duration")
+	}
+	start, end := o.TrimStart, dur
+	if o.TrimEnd > 0 {
+		end = o.TrimEnd
+	}
+	key, err := checkpointKey(inPath, o)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Join(checkpointRoot(), key)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	n := int(math.Ceil((end - start) / o.SegmentSec))
+	info, err := readCheckpoint(dir)
+	if err != nil {
+		info = checkpointInfo{Source: o.SourceName, Created: time.Now()}
+	}
+	info.Segments = n
+	if err := recordJob(ctx, dir, inPath, &info); err != nil {
+		return 0, err
+	}
+	if err := writeCheckpoint(dir, info); err != nil {
+		return 0, err
+	}
+
+	resumed, err := encodeSegments(ctx, requestID, inPath, dir, o, start, end, &info)
+	if err == nil {
+		err = joinSegments(ctx, inPath, outPath, dir, o, start, end)
+	}
+	if err != nil {
+		dropJob(ctx, dir, &info)
+		return resumed, err
+	}
+	os.RemoveAll(dir)
+	return resumed, nil
+}
+
+// encodeSegments encodes the missing video pieces into dir and lists them
+// all in concat.txt.
+func encodeSegments(ctx context.Context, requestID, inPath, dir string, o compressOpts, start, end float64, info *checkpointInfo) (int, error) {
+	var list strings.Builder
+	resumed := 0
+	for i := 0; i < info.Segments; i++ {
+		seg := filepath.Join(dir, fmt.Sprintf("seg%05d%s", i, o.OutExt))
+		fmt.Fprintf(&list, "file '%s'\n", strings.ReplaceAll(seg, "'", `'\''`))
+		if _, err := os.Stat(seg); err == nil {
+			resumed++
+			continue
+		}
+		so := o
+		so.TrimStart = start + float64(i)*o.SegmentSec
+		so.TrimEnd = math.Min(so.TrimStart+o.SegmentSec, end)
+		so.Chapters = "drop"                                 // re-attached from the source when joining
+		so.VideoOnly = true                                  // the audio is encoded once when joining
+		part := withExt(seg, "."+requestID+".part"+o.OutExt) // per request: identical jobs may overlap
+		sctx := withProgressSpan(ctx, so.TrimStart-start, end-start)
+		if err := runFFmpeg(sctx, inPath, part, so, io.Discard); err != nil {
+			os.Remove(part)
+			return resumed, fmt.Errorf("segment %d/%d: %w", i+1, info.Segments, err)
+		}
+		// only complete segments carry the final name
+		if err := os.Rename(part, seg); err != nil {
+			return resumed, err
+		}
+		if info.Done < i+1 {
+			info.Done = i + 1
+			writeCheckpoint(dir, *info)
+		}
+		logger.Printf("🧩 [%s] Segment %d/%d done (%.0fs-%.0fs)", requestID, i+1, info.Segments, so.TrimStart, so.TrimEnd)
+	}
+	if resumed > 0 {
+		logger.Printf("♻️ [%s] Resumed from checkpoint: %d/%d segments reused", requestID, resumed, info.Segments)
+	}
+	return resumed, os.WriteFile(filepath.Join(dir, "concat.txt"), []byte(list.String()), 0o644)
+}
+
+// joinSegments copies the video pieces into outPath and encodes the audio of
+// [start, end) from the input alongside.
+func joinSegments(ctx context.Context, inPath, outPath, dir string, o compressOpts, start, end float64) error {
+	o.normalize()
+	args := []string{"-f", "concat", "-safe", "0", "-i", filepath.Join(dir, "concat.txt")}
+	if start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(start, 'f', 3, 64))
+	}
+	args = append(args, "-t", strconv.FormatFloat(end-start, 'f', 3, 64), "-i", inPath,
+		// audioMap names the input as 0; here it is the second one
+		"-map", "0", "-map", "1"+strings.TrimPrefix(audioMap(o), "0"))
+	if o.Chapters == "keep" && start == 0 && o.Source != nil && len(o.Source.Chapters) > 0 {
+		args = append(args, "-map_chapters", "1")
+	} else {
+		args = append(args, "-map_chapters", "-1")
+	}
+	args = append(args, "-c", "copy")
+	args = append(args, audioCodecArgs(o)...)
+	args = append(args, audioLanguageArgs(o)...)
+	if o.StripMetadata {
+		args = append(args, "-map_metadata:s:a", "-1")
+	}
+	if outputContainers[strings.ToLower(o.OutExt)].MovFlags {
+		args = append(args, "-movflags", "+faststart")
+	}
+	if err := runFF(ctx, append(args, outPath)...); err != nil {
+		return fmt.Errorf("joining segments: %w", err)
+	}
+	return nil
+}
+
+// resumeCheckpoints runs the jobs a previous run of the server left in
+// their checkpoints again, under the same job IDs; their encodes pick up
+// after the last finished segment.
+func resumeCheckpoints() {
+	entries, err := os.ReadDir(checkpointRoot())
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		dir := filepath.Join(checkpointRoot(), e.Name())
+		info, err := readCheckpoint(dir)
+		if err != nil || info.Job == nil {
+			continue
+		}
+		rec := info.Job
+		// the resumed encode records itself again; until then a crash does
+		// not bring the job back a second time
+		info.Job = nil
+		if err := writeCheckpoint(dir, info); err != nil {
+			logger.Printf("⚠️ [MAIN] Checkpoint %s: %v", e.Name(), err)
+			continue
+		}
+		requestID := randID(8)
+		if err := resumeJob(requestID, dir, rec); err != nil {
+			logger.Printf("⚠️ [%s] Job %s not resumed from checkpoint %s: %v", requestID, rec.ID, e.Name(), err)
+			os.Remove(filepath.Join(dir, rec.Input))
+			continue
+		}
+		logger.Printf("♻️ [%s] Job %s resumed from checkpoint %s (%d/%d segments done)", requestID, rec.ID, e.Name(), info.Done, info.Segments)
+	}
+}
+
+// resumeJob replays the request of a checkpointed job as a background job.
+func resumeJob(requestID, dir string, rec *checkpointJob) error {
+	r := &http.Request{Header: rec.Header}
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	if rec.Grant != nil {
+		r = r.WithContext(context.WithValue(context.Background(), grantCtxKey{}, rec.Grant))
+	}
+	opts, err := parseRequestOpts(r, rec.Params.Get)
+	if err != nil {
+		return err
+	}
+	opts.SourceName = rec.SourceName
+	if opts.KeepInput != nil {
+		opts.KeepInput.Owner = rec.Owner
+	}
+	name := filepath.Base(rec.SourceName)
+	if name == "" || name == "." || name == "/" {
+		name = rec.Input
+	}
+	inPath := filepath.Join(os.TempDir(), requestID+"_"+name)
+	if err := linkOrCopy(filepath.Join(dir, rec.Input), inPath); err != nil {
+		return err
+	}
+	j := restoreJob(rec.ID, rec.Client, rec.Created)
+	j.params, j.sourceName, j.owner, j.header, j.grant = rec.Params, rec.SourceName, rec.Owner, rec.Header, rec.Grant
+	j.setCallback(rec.Callback, rec.BaseURL)
+	runBackground(j, requestID, inPath, opts, &slotLease{key: rec.Owner, ticket: queue.enter()})
+	return nil
+}
+
+// purgeCheckpoints removes checkpoint directories older than max_age
+// (segments of jobs that were never retried).
+func purgeCheckpoints(t *taskConfig) (string, error) {
+	entries, err := os.ReadDir(checkpointRoot())
+	if errors.Is(err, os.ErrNotExist) {
+		return "no checkpoints", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	cutoff := time.Now().Add(-t.maxAge)
+	n := 0
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !e.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(checkpointRoot(), e.Name())); err == nil {
+			n++
+		}
+	}
+	return fmt.Sprintf("purged %d checkpoints", n), nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Clip extraction (POST /clip)
+// ======================
+
+// POST /clip cuts [start, end) out of an upload ("file") or a stored result
+// ("id", optionally "artifact") as a standalone MP4, far cheaper than a full
+// compression when all that is needed is a cut. By default the streams are
+// copied: the clip starts on the last keyframe at or before start, so it
+// may begin slightly early, and the actual start is returned in
+// X-Clip-Start. precise=1 re-encodes instead for a frame-accurate cut; the
+// same re-encode is used as a fallback when the source's streams cannot be
+// copied into MP4.
+
+const clipKeyframeWindow = 30.0 // seconds searched back for a keyframe
+
+type clipRequest struct {
+	Start   float64
+	End     float64 // 0 = to the end
+	Precise bool
+}
+
+// parseTimecode reads seconds ("75.5") or [HH:]MM:SS[.mmm] ("1:15.5").
+func parseTimecode(s string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	var t float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) || (i > 0 && v >= 60) {
+			return 0, fmt.Errorf("invalid time %q (seconds or HH:MM:SS)", s)
+		}
+		t = t*60 + v
+	}
+	return t, nil
+}
+
+func parseClipRequest(get func(string) string) (clipRequest, error) {
+	var cr clipRequest
+	var err error
+	if get("start") == "" {
+		return cr, errors.New("start required")
+	}
+	if cr.Start, err = parseTimecode(get("start")); err != nil {
+		return cr, fmt.Errorf("start: %w", err)
+	}
+	if s := get("end"); s != "" {
+		if cr.End, err = parseTimecode(s); err != nil {
+			return cr, fmt.Errorf("end: %w", err)
+		}
+		if cr.End <= cr.Start {
+			return cr, errors.New("end must be after start")
+		}
+	}
+	switch get("precise") {
+	case "", "0":
+	case "1":
+		cr.Precise = true
+	default:
+		return cr, fmt.Errorf("invalid precise %q (0|1)", get("precise"))
+	}
+	return cr, nil
+}
+
+// keyframeBefore returns the time of the last video keyframe at or before t,
+// or t itself when none is found nearby.
+func keyframeBefore(ctx context.Context, path string, t float64) float64 {
+	if t == 0 {
+		return 0
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	cmd := exec.CommandContext(ctx, ffprobeBin(), "-v", "error", "-select_streams", "v:0", "-skip_frame", "nokey",
+		"-read_intervals", f(math.Max(0, t-clipKeyframeWindow))+"%"+f(t+0.001),
+		"-show_entries", "frame=pts_time", "-print_format", "json", path)
+	out, err := cmd.Output()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		return t
+	}
+	var res struct {
+		Frames []struct {
+			PTSTime string `json:"pts_time"`
+		} `json:"frames"`
+	}
+	if json.Unmarshal(out, &res) != nil {
+		return t
+	}
+	best := -1.0
+	for _, fr := range res.Frames {
+		if v, err := strconv.ParseFloat(fr.PTSTime, 64); err == nil && v <= t+0.0005 && v > best {
+			best = v
+		}
+	}
+	if best < 0 {
+		return t
+	}
+	return best
+}
+
+// cutClip writes [start, end) of inPath to outPath, copying streams or, when
+// encode is set, re-encoding them to H.264/AAC.
+func cutClip(ctx context.Context, inPath, outPath string, start, end float64, encode bool) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	args := []string{"-ss", f(start), "-i", inPath, "-t", f(end - start), "-map", "0:V:0?", "-map", "0:a?"}
+	if encode {
+		args = append(args, "-c:v", "libx264", "-crf", "18", "-preset", "veryfast", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-b:a", "192k")
+	} else {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	}
+	args = append(args, "-map_metadata", "0", "-movflags", "+faststart", outPath)
+	if err := runFF(ctx, args...); err != nil {
+		os.Remove(outPath)
+		return err
+	}
+	if st, err := os.Stat(outPath); err != nil || st.Size() == 0 {
+		os.Remove(outPath)
+		return errors.New("no clip written")
+	}
+	return nil
+}
+
+func clipHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("✂️ [%s] New clip request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target, _, name, cleanup, ok := requestMedia(w, r, requestID)
+	if !ok {
+		return
+	}
+	defer cleanup()
+	cr, err := parseClipRequest(r.FormValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := probeFile(r.Context(), target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	dur := src.durationSec()
+	if dur > 0 {
+		if cr.Start >= dur {
+			http.Error(w, fmt.Sprintf("start %.3fs is past the end (%.3fs)", cr.Start, dur), http.StatusBadRequest)
+			return
+		}
+		if cr.End == 0 || cr.End > dur {
+			cr.End = dur
+		}
+	} else if cr.End == 0 {
+		http.Error(w, "source duration unknown; give end", http.StatusUnprocessableEntity)
+		return
+	}
+
+	start, mode := cr.Start, "encode"
+	if !cr.Precise {
+		start, mode = keyframeBefore(r.Context(), target, cr.Start), "copy"
+	}
+	out, err := os.CreateTemp("", "clip_"+requestID+"_*.mp4")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	out.Close()
+	outPath := out.Name()
+	defer os.Remove(outPath)
+
+	err = cutClip(r.Context(), target, outPath, start, cr.End, mode == "encode")
+	if err != nil && mode == "copy" {
+		logger.Printf("⚠️ [%s] Stream copy failed (%v), re-encoding the clip", requestID, err)
+		start, mode = cr.Start, "encode"
+		err = cutClip(r.Context(), target, outPath, start, cr.End, true)
+	}
+	if err != nil {
+		logger.Printf("❌ [%s] Clip failed: %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if stem == "" {
+		stem = "clip"
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	w.Header().Set("Content-Disposition", `attachment; filename="`+sanitizeFilename(fmt.Sprintf("%s_%.0f-%.0f.mp4", stem, cr.Start, cr.End))+`"`)
+	w.Header().Set("X-Clip-Start", f(start))
+	w.Header().Set("X-Clip-End", f(cr.End))
+	w.Header().Set("X-Clip-Mode", mode)
+	w.Header().Set("Content-Type", "video/mp4")
+	logger.Printf("✅ [%s] Clip %ss-%ss (%s)", requestID, f(start), f(cr.End), mode)
+	http.ServeFile(w, r, outPath)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ======================
+// Side-by-side comparison artifact (compare=1)
+// ======================
+
+// compare=1 stores a short clip with the original on the left and the
+// compressed output on the right as the "compare.mp4" artifact, so a reviewer
+// can judge the chosen mode without downloading both files. The window is
+// compare_sec seconds (default 6) starting at compare_at seconds into the
+// output (default: centred). Both sides are scaled to the same height, at
+// most 720 lines, and encoded near-transparently so the clip itself adds
+// little loss of its own.
+
+const (
+	compareDefaultSec = 6.0
+	compareMaxSec     = 30.0
+	compareMaxHeight  = 720
+)
+
+type compareSpec struct {
+	At  float64 // output time; -1 = centred
+	Sec float64
+}
+
+func parseCompare(on, at, sec string) (*compareSpec, error) {
+	if on != "1" {
+		if at != "" || sec != "" {
+			return nil, errors.New("compare_at and compare_sec need compare=1")
+		}
+		return nil, nil
+	}
+	c := &compareSpec{At: -1, Sec: compareDefaultSec}
+	if at != "" {
+		v, err := strconv.ParseFloat(at, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid compare_at %q (seconds)", at)
+		}
+		c.At = v
+	}
+	if sec != "" {
+		v, err := strconv.ParseFloat(sec, 64)
+		if err != nil || v < 1 || v > compareMaxSec {
+			return nil, fmt.Errorf("invalid compare_sec %q (1-%g)", sec, compareMaxSec)
+		}
+		c.Sec = v
+	}
+	return c, nil
+}
+
+// makeComparison renders the side-by-side clip of inPath and outPath and adds
+// it to artifacts. Output time t is source time o.TrimStart + t.
+func makeComparison(ctx context.Context, requestID, inPath, outPath string, o compressOpts, artifacts map[string]string) error {
+	if o.Source == nil {
+		return errors.New("source not probed")
+	}
+	vs := o.Source.firstStream("video")
+	if vs == nil || vs.Height == 0 {
+		return errors.New("no video stream")
+	}
+	out, err := probeFile(ctx, outPath)
+	if err != nil {
+		return err
+	}
+	dur := out.durationSec()
+	if dur <= 0 {
+		return errors.New("output duration unknown")
+	}
+	length := math.Min(o.Compare.Sec, dur)
+	at := o.Compare.At
+	if at < 0 {
+		at = (dur - length) / 2
+	}
+	if at+length > dur {
+		at = math.Max(0, dur-length)
+	}
+
+	h := vs.Height
+	if r := vs.rotation(); !o.PreserveCapture && (r == 90 || r == 270) {
+		h = vs.Width
+	}
+	h = min(h, compareMaxHeight) &^ 1
+	fps := outputFPS(o)
+	side := fmt.Sprintf("scale=-2:%d,setsar=1,fps=%s", h, strconv.FormatFloat(fps, 'f', 3, 64))
+	graph := fmt.Sprintf("[0:v:0]%s[a];[1:v:0]%s[b];[a][b]hstack=inputs=2,format=yuv420p[v]", side, side)
+
+	path := withExt(outPath, "_compare.mp4")
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	if err := runFF(ctx,
+		"-ss", f(o.TrimStart+at), "-t", f(length), "-i", inPath,
+		"-ss", f(at), "-t", f(length), "-i", outPath,
+		"-filter_complex", graph, "-map", "[v]", "-an",
+		"-c:v", "libx264", "-crf", "16", "-preset", "veryfast", "-movflags", "+faststart", path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errors.New("no comparison clip written")
+	}
+	artifacts["compare.mp4"] = path
+	logger.Printf("🆚 [%s] Comparison clip: %.1fs from %.1fs", requestID, length, at)
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ======================
+// Container / codec compatibility
+// ======================
+
+// compatFallback is the container chosen by compat=container when the
+// requested one cannot hold the streams.
+const compatFallback = ".mkv"
+
+// codecFamily maps request values and ffprobe codec names onto one vocabulary.
+func codecFamily(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case n == "hevc" || n == "h265" || n == "libx265":
+		return "h265"
+	case n == "h264" || n == "libx264" || n == "avc":
+		return "h264"
+	case n == "libsvtav1" || n == "libaom-av1" || n == "librav1e":
+		return "av1"
+	case strings.HasPrefix(n, "prores"):
+		return "prores"
+	case n == "libopus":
+		return "opus"
+	case strings.HasPrefix(n, "pcm_"):
+		return "pcm"
+	case n == "libmp3lame":
+		return "mp3"
+	case n == "libvorbis":
+		return "vorbis"
+	}
+	return n
+}
+
+// compatError is returned when strict compatibility checking rejects a job.
+type compatError struct {
+	Container string
+	Conflicts []string
+}
+
+func (e *compatError) Error() string {
+	return "incompatible options for " + e.Container + ": " + strings.Join(e.Conflicts, "; ")
+}
+
+// effectiveCodecs returns the video/audio codec families that will end up in
+// the output (resolving "copy" through the probed source).
+func effectiveCodecs(o *compressOpts) (video, audio string) {
+	video = codecFamily(o.Codec)
+	if video == "copy" {
+		video = ""
+		if o.Source != nil {
+			if vs := o.Source.firstStream("video"); vs != nil {
+				video = codecFamily(vs.CodecName)
+			}
+		}
+	}
+	audio = codecFamily(o.Audio)
+	if audio == "copy" {
+		audio = ""
+		if o.Source != nil {
+			if as := o.Source.firstStream("audio"); as != nil {
+				audio = codecFamily(as.CodecName)
+			}
+		}
+	}
+	return video, audio
+}
+
+// resolveCompat checks the codec/container combination and, depending on
+// o.Compat, substitutes codecs (codec), switches container (container) or
+// rejects the job (strict). It returns human-readable adjustments.
+func resolveCompat(o *compressOpts) ([]string, error) {
+	ext := strings.ToLower(o.OutExt)
+	cs, known := outputContainers[ext]
+	if !known {
+		return nil, nil
+	}
+	video, audio := effectiveCodecs(o)
+	var conflicts []string
+	videoBad := video != "" && !cs.Video[video]
+	audioBad := audio != "" && !cs.Audio[audio]
+	if videoBad {
+		conflicts = append(conflicts, fmt.Sprintf("video codec %s is not supported in %s (supported: %s)", video, ext, sortedKeys(cs.Video)))
+	}
+	if audioBad {
+		conflicts = append(conflicts, fmt.Sprintf("audio codec %s is not supported in %s (supported: %s)", audio, ext, sortedKeys(cs.Audio)))
+	}
+	if len(conflicts) == 0 {
+		return nil, nil
+	}
+
+	switch o.Compat {
+	case "strict":
+		return nil, &compatError{Container: ext, Conflicts: conflicts}
+	case "container":
+		o.OutExt = compatFallback
+		return []string{fmt.Sprintf("container changed %s → %s (%s)", ext, compatFallback, strings.Join(conflicts, "; "))}, nil
+	default: // codec
+		var notes []string
+		if videoBad {
+			notes = append(notes, fmt.Sprintf("video %s → %s for %s", video, cs.PreferVideo, ext))
+			o.Codec = cs.PreferVideo
+		}
+		if audioBad {
+			notes = append(notes, fmt.Sprintf("audio %s → %s for %s", audio, cs.PreferAudio, ext))
+			o.Audio = cs.PreferAudio
+		}
+		return notes, nil
+	}
+}
+
+func sortedKeys(m map[string]bool) string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ======================
+// Config file (CONFIG_FILE, JSON)
+// ======================
+
+// serverConfig holds settings that do not fit environment variables.
+// Environment variables still configure everything else.
+type serverConfig struct {
+	// Tasks are scheduled maintenance jobs; nil means defaultTasks.
+	Tasks []taskConfig `json:"tasks"`
+	// AISizeRules are the speed=ai size thresholds; nil means defaultSizeRules.
+	AISizeRules []sizeRule `json:"ai_size_rules"`
+	// Limits override the matching environment variables when set.
+	Limits limitsConfig `json:"limits"`
+	// APIKeys are accepted in addition to API_KEYS.
+	APIKeys []string `json:"api_keys"`
+	// Deliver targets (and their notify webhooks) are added to every result.
+	Deliver []deliveryTarget `json:"deliver"`
+}
+
+type limitsConfig struct {
+	ClientMaxJobs   *int    `json:"client_max_jobs"`
+	ClientLimitMode string  `json:"client_limit_mode"`
+	ReadyMaxJobs    *int64  `json:"ready_max_jobs"`
+	ReadyMinFreeMB  *uint64 `json:"ready_min_free_mb"`
+}
+
+func (l limitsConfig) validate() error {
+	if l.ClientMaxJobs != nil && *l.ClientMaxJobs < 0 {
+		return fmt.Errorf("client_max_jobs must be >= 0")
+	}
+	if l.ReadyMaxJobs != nil && *l.ReadyMaxJobs < 0 {
+		return fmt.Errorf("ready_max_jobs must be >= 0")
+	}
+	switch l.ClientLimitMode {
+	case "", "queue", "reject":
+	default:
+		return fmt.Errorf("invalid client_limit_mode %q (queue|reject)", l.ClientLimitMode)
+	}
+	return nil
+}
+
+var (
+	cfgMu sync.RWMutex
+	cfg   serverConfig
+)
+
+// loadConfig reads CONFIG_FILE (if set) and replaces the active config.
+func loadConfig() error {
+	c := serverConfig{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	if c.Tasks == nil {
+		c.Tasks = defaultTasks()
+	}
+	for i := range c.Tasks {
+		if err := c.Tasks[i].validate(); err != nil {
+			return fmt.Errorf("config: task %q: %w", c.Tasks[i].Name, err)
+		}
+	}
+	if c.AISizeRules == nil {
+		c.AISizeRules = defaultSizeRules()
+	}
+	if err := validateSizeRules(c.AISizeRules); err != nil {
+		return fmt.Errorf("config: ai_size_rules: %w", err)
+	}
+	if err := c.Limits.validate(); err != nil {
+		return fmt.Errorf("config: limits: %w", err)
+	}
+	if len(c.Deliver) > maxDeliveryTargets {
+		return fmt.Errorf("config: at most %d deliver targets", maxDeliveryTargets)
+	}
+	for i, t := range c.Deliver {
+		if err := t.validate(); err != nil {
+			return fmt.Errorf("config: deliver[%d]: %w", i, err)
+		}
+	}
+	cfgMu.Lock()
+	cfg = c
+	cfgMu.Unlock()
+	return nil
+}
+
+func currentConfig() serverConfig {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg
+}
+
+// ---------------------------
+// Reload (SIGHUP, POST /admin/reload)
+// ---------------------------
+
+// Reloading re-reads CONFIG_FILE and PROFILES_FILE. A file that fails to
+// parse or validate leaves its current settings active. Running encodes
+// keep the options they started with; new jobs see the new settings.
+
+var (
+	reloadMu   sync.Mutex
+	reloadedAt time.Time
+)
+
+// reloadConfig reloads the config and profile files.
+func reloadConfig(trigger string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	var errs []error
+	if err := loadConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := loadProfiles(); err != nil {
+		errs = append(errs, fmt.Errorf("profiles: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		logger.Printf("⚠️ [MAIN] Config reload (%s) failed, keeping the previous settings where invalid: %v", trigger, err)
+		return err
+	}
+	reloadedAt = time.Now()
+	slots.wake()
+	c := currentConfig()
+	logger.Printf("🔄 [MAIN] Config reloaded (%s): %d tasks, %d api keys, %d deliver targets, %d profiles",
+		trigger, len(c.Tasks), len(c.APIKeys), len(c.Deliver), len(profileNames()))
+	return nil
+}
+
+// reloadOnSignal reloads the configuration on every SIGHUP.
+func reloadOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			reloadConfig("SIGHUP")
+		}
+	}()
+}
+
+// adminReloadHandler serves POST /admin/reload.
+func adminReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !adminAuthorized(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := reloadConfig("admin"); err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"reloaded": false, "error": err.Error()})
+		return
+	}
+	reloadMu.Lock()
+	at := reloadedAt
+	reloadMu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{"reloaded": true, "reloaded_at": at.UTC().Format(time.RFC3339)})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Output containers
+// ======================
+
+type outputContainer struct {
+	MimeType string
+	// MovFlags marks the mp4 muxer family (faststart, use_metadata_tags).
+	MovFlags bool
+	// Video/Audio list the codec families common players handle in this
+	// container (see codecFamily).
+	Video map[string]bool
+	Audio map[string]bool
+	// PreferVideo/PreferAudio are substituted by compat=codec.
+	PreferVideo string
+	PreferAudio string
+}
+
+func codecSet(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+var outputContainers = map[string]outputContainer{
+	".mp4": {MimeType: "video/mp4", MovFlags: true,
+		Video: codecSet("h264", "h265", "av1", "mpeg4"), Audio: codecSet("aac", "mp3", "ac3", "eac3"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+	".m4v": {MimeType: "video/x-m4v", MovFlags: true,
+		Video: codecSet("h264", "h265"), Audio: codecSet("aac", "ac3", "eac3"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+	".mov": {MimeType: "video/quicktime", MovFlags: true,
+		Video: codecSet("h264", "h265", "prores", "mpeg4", "mjpeg"), Audio: codecSet("aac", "pcm", "ac3", "mp3"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+	".mkv": {MimeType: "video/x-matroska",
+		Video:       codecSet("h264", "h265", "vp8", "vp9", "av1", "mpeg4", "prores", "ffv1", "mjpeg"),
+		Audio:       codecSet("aac", "opus", "mp3", "ac3", "eac3", "vorbis", "flac", "pcm"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+	".webm": {MimeType: "video/webm",
+		Video: codecSet("vp8", "vp9", "av1"), Audio: codecSet("opus", "vorbis"),
+		PreferVideo: "vp9", PreferAudio: "opus"},
+	".ts": {MimeType: "video/mp2t",
+		Video: codecSet("h264", "h265", "mpeg2video"), Audio: codecSet("aac", "mp3", "ac3", "eac3", "opus"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+	".avi": {MimeType: "video/x-msvideo",
+		Video: codecSet("h264", "mpeg4", "mjpeg"), Audio: codecSet("mp3", "ac3", "pcm", "aac"),
+		PreferVideo: "h264", PreferAudio: "aac"},
+}
+
+// normalizeOutExt lowercases and dots an outExt value and rejects containers
+// the server does not know how to mux.
+func normalizeOutExt(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if _, ok := outputContainers[ext]; !ok {
+		return "", fmt.Errorf("unsupported outExt %q (supported: %s)", ext, supportedExts())
+	}
+	return ext, nil
+}
+
+func supportedExts() string {
+	names := make(map[string]bool, len(outputContainers))
+	for ext := range outputContainers {
+		names[ext] = true
+	}
+	return sortedKeys(names)
+}
+
+// vp9RateArgs maps the x264-style CRF/preset of the speed profiles onto
+// libvpx-vp9 constant-quality mode.
+func vp9RateArgs(crf int, preset string) []string {
+	q := crf + 8 // x264 23 ≈ vp9 31
+	if q > 63 {
+		q = 63
+	}
+	deadline, cpuUsed := "good", "2"
+	switch preset {
+	case "ultrafast", "superfast":
+		deadline, cpuUsed = "realtime", "8"
+	case "veryfast", "faster":
+		cpuUsed = "5"
+	case "fast", "medium":
+		cpuUsed = "3"
+	}
+	return []string{"-crf", strconv.Itoa(q), "-b:v", "0",
+		"-deadline", deadline, "-cpu-used", cpuUsed, "-row-mt", "1"}
+}
+
+// av1RateArgs maps CRF/preset onto SVT-AV1 (crf 0-63, presets 0-13).
+func av1RateArgs(crf int, preset string) []string {
+	q := crf + 9 // x264 23 ≈ svt-av1 32
+	if q > 63 {
+		q = 63
+	}
+	svtPreset := map[string]string{
+		"ultrafast": "12", "superfast": "11", "veryfast": "10", "faster": "9",
+		"fast": "8", "medium": "7", "slow": "6", "slower": "5", "veryslow": "4", "placebo": "2",
+	}[preset]
+	if svtPreset == "" {
+		svtPreset = "8"
+	}
+	return []string{"-crf", strconv.Itoa(q), "-preset", svtPreset}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Cover art
+// ======================
+
+// coverContainers are the output extensions that support an attached_pic stream.
+var coverContainers = map[string]bool{".mp4": true, ".mov": true, ".m4v": true}
+
+// applyCover re-attaches the source cover art (or the uploaded poster) to an
+// encoded output with a cheap stream-copy remux.
+func applyCover(ctx context.Context, requestID, inPath, outPath string, o compressOpts) error {
+	if !coverContainers[strings.ToLower(filepath.Ext(outPath))] {
+		return nil
+	}
+	var coverIn, coverMap string
+	copyCover := true
+	switch {
+	case o.PosterPath != "":
+		coverIn, coverMap = o.PosterPath, "1:v:0"
+		switch strings.ToLower(filepath.Ext(o.PosterPath)) {
+		case ".jpg", ".jpeg", ".png":
+		default:
+			copyCover = false // e.g. webp: mp4 covers must be jpeg or png
+		}
+	case o.Cover == "keep" && o.Source != nil && o.Source.attachedPic() != nil:
+		coverIn, coverMap = inPath, "1:"+strconv.Itoa(o.Source.attachedPic().Index)
+	default:
+		return nil
+	}
+
+	tmp := withExt(outPath, "_cover"+filepath.Ext(outPath))
+	args := []string{"-y", "-hide_banner", "-loglevel", "error",
+		"-i", outPath, "-i", coverIn,
+		"-map", "0", "-map", coverMap, "-c", "copy"}
+	if !copyCover {
+		args = append(args, "-c:v:1", "mjpeg")
+	}
+	args = append(args, "-disposition:v:1", "attached_pic", "-movflags", "+faststart")
+	if sphericalOut(o) {
+		args = append(args, "-strict", "unofficial")
+	}
+	args = append(args, tmp)
+
+	logger.Printf("🖼️ [%s] Embedding cover art: ffmpeg %s", requestID, strings.Join(args, " "))
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("%v: %s", err, lastLine(stderr.String()))
+	}
+	return os.Rename(tmp, outPath)
+}
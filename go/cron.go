@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Cron expressions (minute hour day-of-month month day-of-week)
+// ======================
+
+// cronSchedule is a parsed 5-field expression or an "@every <duration>".
+type cronSchedule struct {
+	fields [5]map[int]bool // nil = any
+	every  time.Duration
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+var cronBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+// parseCron accepts *, n, a-b, lists and /step, plus @hourly/@daily/@weekly/
+// @monthly and "@every 10m".
+func parseCron(expr string) (cronSchedule, error) {
+	var s cronSchedule
+	expr = strings.TrimSpace(expr)
+	if d, ok := strings.CutPrefix(expr, "@every "); ok {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every < time.Minute {
+			return s, fmt.Errorf("invalid @every duration %q (min 1m)", d)
+		}
+		s.every = every
+		return s, nil
+	}
+	if m, ok := cronMacros[expr]; ok {
+		expr = m
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return s, fmt.Errorf("cron %q: want 5 fields", expr)
+	}
+	for i, p := range parts {
+		set, err := parseCronField(p, cronBounds[i][0], cronBounds[i][1])
+		if err != nil {
+			return s, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		s.fields[i] = set
+	}
+	return s, nil
+}
+
+func parseCronField(f string, lo, hi int) (map[int]bool, error) {
+	if f == "*" {
+		return nil, nil
+	}
+	set := map[int]bool{}
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("bad step %q", part)
+			}
+			step = n
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return nil, fmt.Errorf("bad value %q", part)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return nil, fmt.Errorf("bad range %q", part)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether t (truncated to the minute) is a firing time.
+// For @every schedules, last is the previous run.
+func (s cronSchedule) matches(t, last time.Time) bool {
+	if s.every > 0 {
+		return last.IsZero() || t.Sub(last) >= s.every
+	}
+	vals := [5]int{t.Minute(), t.Hour(), t.Day(), int(t.Month()), int(t.Weekday())}
+	for i, set := range s.fields {
+		if set != nil && !set[vals[i]] {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the first firing time after t (within a year).
+func (s cronSchedule) next(t, last time.Time) time.Time {
+	if s.every > 0 {
+		if last.IsZero() {
+			return t.Truncate(time.Minute).Add(time.Minute)
+		}
+		return last.Add(s.every)
+	}
+	c := t.Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < 366*24*60; i++ {
+		if s.matches(c, last) {
+			return c
+		}
+		c = c.Add(time.Minute)
+	}
+	return time.Time{}
+}
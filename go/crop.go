@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// ======================
+// Black-bar detection (autocrop=1)
+// ======================
+
+const (
+	cropSamples      = 5
+	cropFramesEach   = 12
+	cropMinSavedFrac = 0.02 // ignore "bars" thinner than 2% of the frame
+)
+
+var cropDetectRe = regexp.MustCompile(`crop=(\d+):(\d+):(\d+):(\d+)`)
+
+// detectCrop runs cropdetect on frames sampled across the source and
+// returns a crop=w:h:x:y argument covering every sample (so bright scenes
+// never lose picture), or "" when there are no bars worth removing. With
+// preserve_capture the encode skips autorotation, so detection does too.
+func detectCrop(ctx context.Context, inPath string, src *probeResult, preserveCapture bool) (string, error) {
+	if src == nil {
+		return "", errors.New("source not probed")
+	}
+	vs := src.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return "", errors.New("no video stream")
+	}
+	noAutorotate := preserveCapture && vs.rotation() != 0
+	dur := src.durationSec()
+	x0, y0, x1, y1 := -1, -1, 0, 0
+	for i := 1; i <= cropSamples; i++ {
+		args := []string{"-hide_banner", "-nostats"}
+		if noAutorotate {
+			args = append(args, "-noautorotate")
+		}
+		if dur > 0 {
+			args = append(args, "-ss", strconv.FormatFloat(dur*float64(i)/(cropSamples+1), 'f', 2, 64))
+		}
+		args = append(args, "-i", inPath, "-map", "0:V:0", "-frames:v", strconv.Itoa(cropFramesEach),
+			"-vf", "cropdetect=limit=24:round=2:reset=0", "-f", "null", "-")
+		cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+		out, err := cmd.CombinedOutput()
+		recordUsage(ctx, cmd.ProcessState)
+		if err != nil {
+			return "", fmt.Errorf("cropdetect: %w", err)
+		}
+		m := cropDetectRe.FindAllSubmatch(out, -1)
+		if len(m) == 0 {
+			continue
+		}
+		last := m[len(m)-1] // reset=0: the last line covers all frames of the sample
+		w, _ := strconv.Atoi(string(last[1]))
+		h, _ := strconv.Atoi(string(last[2]))
+		x, _ := strconv.Atoi(string(last[3]))
+		y, _ := strconv.Atoi(string(last[4]))
+		if w <= 0 || h <= 0 {
+			continue // an all-black sample says nothing about the bars
+		}
+		if x0 < 0 || x < x0 {
+			x0 = x
+		}
+		if y0 < 0 || y < y0 {
+			y0 = y
+		}
+		x1, y1 = max(x1, x+w), max(y1, y+h)
+	}
+	if x0 < 0 {
+		return "", nil
+	}
+	w, h := (x1-x0)&^1, (y1-y0)&^1
+	frameW, frameH := vs.Width, vs.Height
+	if r := vs.rotation(); !noAutorotate && (r == 90 || r == 270) {
+		frameW, frameH = frameH, frameW // detected on the rotated picture
+	}
+	saved := 1 - float64(w*h)/float64(frameW*frameH)
+	if saved < cropMinSavedFrac {
+		return "", nil
+	}
+	return fmt.Sprintf("%d:%d:%d:%d", w, h, x0, y0), nil
+}
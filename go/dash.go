@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ======================
+// DASH packaging (format=dash)
+// ======================
+
+// A DASH package is one manifest.mpd with init-<n>.m4s and
+// chunk-<n>-00001.m4s segments per representation (.webm for VP9/Opus,
+// which the dash muxer keeps in WebM). All renditions go into one video
+// adaptation set, so players switch between them, and the audio of the
+// main encode into a second one.
+
+// packageDASH writes outPath (and the lower renditions) as a DASH package
+// in dir.
+func packageDASH(ctx context.Context, outPath, dir string, rends []renditionInfo, o compressOpts) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	srcs := []string{outPath}
+	if len(rends) > 0 {
+		srcs = srcs[:0]
+		for _, r := range rends {
+			srcs = append(srcs, r.path)
+		}
+	}
+	var args []string
+	for _, s := range srcs {
+		args = append(args, "-i", s)
+	}
+	for i := range srcs {
+		args = append(args, "-map", strconv.Itoa(i)+":v:0")
+	}
+	sets := "id=0,streams=v"
+	if o.Source == nil || o.Source.firstStream("audio") != nil {
+		args = append(args, "-map", "0:a:0?")
+		sets += " id=1,streams=a"
+	}
+	args = append(args, "-c", "copy", "-f", "dash",
+		"-seg_duration", strconv.FormatFloat(o.Package.SegmentSec, 'f', -1, 64),
+		"-use_template", "1", "-use_timeline", "1", "-adaptation_sets", sets,
+		"-init_seg_name", "init-$RepresentationID$.$ext$",
+		"-media_seg_name", "chunk-$RepresentationID$-$Number%05d$.$ext$",
+		filepath.Join(dir, "manifest.mpd"))
+	if err := runFF(ctx, args...); err != nil {
+		return fmt.Errorf("packaging DASH: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// ======================
+// Dead-air trimming (trim_dead=silence|black|both)
+// ======================
+
+// One extra decode pass with silencedetect/blackdetect finds leading and
+// trailing dead air; the encode then seeks past it. Only the ends are
+// trimmed, never pauses in the middle. "both" trims only where the picture
+// is black AND the audio silent, the safe choice for lecture captures.
+
+const (
+	deadAirMinSec = 0.5  // shorter gaps are ignored by the detectors
+	deadAirEdge   = 0.05 // an interval this close to the start/end touches it
+	minKeptSec    = 1.0
+)
+
+var (
+	blackRe        = regexp.MustCompile(`black_start:\s*([\d.]+)\s+black_end:\s*([\d.]+)`)
+	silenceStartRe = regexp.MustCompile(`silence_start:\s*(-?[\d.]+)`)
+	silenceEndRe   = regexp.MustCompile(`silence_end:\s*([\d.]+)`)
+)
+
+type interval struct{ from, to float64 }
+
+// edges returns how far the dead air reaches from the start and from where
+// it runs to the end (dur if it doesn't).
+func edges(iv []interval, dur float64) (lead, tail float64) {
+	tail = dur
+	for _, i := range iv {
+		if i.from <= deadAirEdge {
+			lead = max(lead, i.to)
+		}
+		if i.to >= dur-deadAirEdge {
+			tail = min(tail, i.from)
+		}
+	}
+	return lead, tail
+}
+
+func parseFloats(re *regexp.Regexp, out []byte) []float64 {
+	var vs []float64
+	for _, m := range re.FindAllSubmatch(out, -1) {
+		v, _ := strconv.ParseFloat(string(m[1]), 64)
+		vs = append(vs, max(v, 0))
+	}
+	return vs
+}
+
+// detectDeadAir returns the [start, end) range to keep.
+func detectDeadAir(ctx context.Context, inPath string, src *probeResult, mode string) (start, end float64, err error) {
+	if src == nil || src.durationSec() <= 0 {
+		return 0, 0, errors.New("source duration unknown")
+	}
+	dur := src.durationSec()
+	hasVideo, hasAudio := src.firstStream("video") != nil, src.firstStream("audio") != nil
+	wantBlack := (mode == "black" || mode == "both") && hasVideo
+	wantSilence := (mode == "silence" || mode == "both") && hasAudio
+	if !wantBlack && !wantSilence {
+		return 0, 0, fmt.Errorf("nothing to detect for trim_dead=%s in this file", mode)
+	}
+
+	args := []string{"-hide_banner", "-nostats", "-i", inPath}
+	if wantBlack {
+		args = append(args, "-map", "0:V:0", "-vf", fmt.Sprintf("blackdetect=d=%g:pix_th=0.10", deadAirMinSec))
+	}
+	if wantSilence {
+		args = append(args, "-map", "0:a:0", "-af", fmt.Sprintf("silencedetect=n=-50dB:d=%g", deadAirMinSec))
+	}
+	args = append(args, "-f", "null", "-")
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+	out, err := cmd.CombinedOutput()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dead-air detection: %w", err)
+	}
+
+	var black, silence []interval
+	for _, m := range blackRe.FindAllSubmatch(out, -1) {
+		a, _ := strconv.ParseFloat(string(m[1]), 64)
+		b, _ := strconv.ParseFloat(string(m[2]), 64)
+		black = append(black, interval{a, b})
+	}
+	starts, ends := parseFloats(silenceStartRe, out), parseFloats(silenceEndRe, out)
+	for i, a := range starts {
+		b := dur // trailing silence may have no silence_end
+		if i < len(ends) {
+			b = ends[i]
+		}
+		silence = append(silence, interval{a, b})
+	}
+
+	bLead, bTail := edges(black, dur)
+	sLead, sTail := edges(silence, dur)
+	switch {
+	case wantBlack && wantSilence: // both: black and silent
+		start, end = min(bLead, sLead), max(bTail, sTail)
+	case wantBlack:
+		start, end = bLead, bTail
+	default:
+		start, end = sLead, sTail
+	}
+	if end-start < minKeptSec {
+		return 0, 0, errors.New("the whole file looks like dead air; not trimming")
+	}
+	return start, end, nil
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Mode decision (speed=ai) extension point
+// ======================
+
+// A modeDecider picks the speed mode for speed=ai. The built-in "probe"
+// decider weighs how much picture there is to encode (resolution, frame rate,
+// duration) and how compressed the source already is; "size" looks at the
+// file size only. Operators can plug in their own
+// logic without rebuilding by setting MODE_DECIDER_CMD to a program that
+// reads a decisionInput as JSON on stdin and prints the mode (or
+// {"mode": "..."}) on stdout. MODE_DECIDER selects the decider by name; a
+// failing decider falls back to "size". Deciders pick a speed: lossless and
+// archive pick their own codecs before the decision, so they are explicit
+// choices only (decidableMode).
+//
+// After the decision, planAI may lower the resolution of long high-resolution
+// sources, or skip the video re-encode when the source bitrate is already
+// below what the chosen mode would produce.
+
+const decisionTimeout = 10 * time.Second
+
+// decisionInput is what a decider gets to look at.
+type decisionInput struct {
+	SizeMB      int64           `json:"size_mb"`
+	InputBytes  int64           `json:"input_bytes"`
+	DurationSec float64         `json:"duration_sec,omitempty"`
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	FPS         float64         `json:"fps,omitempty"`
+	VideoCodec  string          `json:"video_codec,omitempty"`
+	BitRate     string          `json:"bit_rate,omitempty"`
+	SourceName  string          `json:"source_name,omitempty"`
+	Resolution  string          `json:"resolution"`
+	Codec       string          `json:"codec"`
+	HW          string          `json:"hw"`
+	JobTag      string          `json:"job_tag,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+type modeDecider interface {
+	Decide(ctx context.Context, in decisionInput) (string, error)
+}
+
+// modeDeciderFunc adapts a function to modeDecider.
+type modeDeciderFunc func(ctx context.Context, in decisionInput) (string, error)
+
+func (f modeDeciderFunc) Decide(ctx context.Context, in decisionInput) (string, error) {
+	return f(ctx, in)
+}
+
+var modeDeciders = map[string]modeDecider{
+	"probe":    modeDeciderFunc(decideByProbe),
+	"size":     modeDeciderFunc(decideBySize),
+	"command":  modeDeciderFunc(decideByCommand),
+	"adaptive": modeDeciderFunc(decideAdaptive),
+}
+
+// decidableMode reports whether a decider may answer mode.
+func decidableMode(mode string) bool {
+	switch mode {
+	case "ai", "lossless", "archive":
+		return false
+	}
+	return slices.Contains(speedModes, mode)
+}
+
+// activeModeDecider is MODE_DECIDER, or "command" when only
+// MODE_DECIDER_CMD is set, "adaptive" when only AI_TARGET_SECONDS is set,
+// "size" when the config file tunes ai_size_rules, else "probe".
+func activeModeDecider() string {
+	if name := os.Getenv("MODE_DECIDER"); name != "" {
+		return name
+	}
+	if os.Getenv("MODE_DECIDER_CMD") != "" {
+		return "command"
+	}
+	if adaptiveTarget() > 0 {
+		return "adaptive"
+	}
+	if !slices.Equal(currentConfig().AISizeRules, defaultSizeRules()) {
+		return "size"
+	}
+	return "probe"
+}
+
+func newDecisionInput(sizeMB, inputBytes int64, o compressOpts) decisionInput {
+	in := decisionInput{
+		SizeMB:     sizeMB,
+		InputBytes: inputBytes,
+		SourceName: o.SourceName,
+		Resolution: o.Resolution,
+		Codec:      o.Codec,
+		HW:         o.HW,
+		JobTag:     o.Client.Tag,
+		Metadata:   o.Client.Metadata,
+	}
+	if o.Source != nil {
+		in.DurationSec = o.Source.durationSec()
+		in.BitRate = o.Source.Format.BitRate
+		if vs := o.Source.firstStream("video"); vs != nil {
+			in.Width, in.Height, in.VideoCodec = vs.Width, vs.Height, vs.CodecName
+			in.FPS = vs.frameRate()
+		}
+	}
+	return in
+}
+
+// sizeRule maps uploads of at least MinMB to Mode; the rule with the
+// largest MinMB not above the size wins.
+type sizeRule struct {
+	MinMB int64  `json:"min_mb"`
+	Mode  string `json:"mode"`
+}
+
+// defaultSizeRules are the historical thresholds: balanced below 50 MB,
+// fast up to 200 MB, balanced again up to 250 MB, ultra_fast above.
+func defaultSizeRules() []sizeRule {
+	return []sizeRule{
+		{MinMB: 0, Mode: "balanced"},
+		{MinMB: 50, Mode: "fast"},
+		{MinMB: 200, Mode: "balanced"},
+		{MinMB: 251, Mode: "ultra_fast"},
+	}
+}
+
+// validateSizeRules requires ascending thresholds starting at 0 and
+// decidable speed modes.
+func validateSizeRules(rules []sizeRule) error {
+	if len(rules) == 0 || rules[0].MinMB != 0 {
+		return errors.New("the first rule must have min_mb 0")
+	}
+	for i, r := range rules {
+		if !decidableMode(r.Mode) {
+			return fmt.Errorf("rule %d: invalid mode %q", i, r.Mode)
+		}
+		if i > 0 && r.MinMB <= rules[i-1].MinMB {
+			return fmt.Errorf("rule %d: min_mb %d must be above %d", i, r.MinMB, rules[i-1].MinMB)
+		}
+	}
+	return nil
+}
+
+// decideBySize is the built-in rule: the ai_size_rules of the config file.
+func decideBySize(_ context.Context, in decisionInput) (string, error) {
+	rules := currentConfig().AISizeRules
+	if len(rules) == 0 {
+		rules = defaultSizeRules()
+	}
+	mode := rules[0].Mode
+	for _, r := range rules {
+		if in.SizeMB >= r.MinMB {
+			mode = r.Mode
+		}
+	}
+	return mode, nil
+}
+
+// probeWorkRules map the picture to encode, in megapixel-frames (width ×
+// height × fps × seconds / 1e6), to a mode: the first rule the work stays
+// under wins, and anything bigger is ultra_fast. 1080p30 makes about 3,700
+// megapixel-frames a minute.
+var probeWorkRules = []struct {
+	MaxWork float64
+	Mode    string
+}{
+	{20000, "balanced"},    // ~5 min of 1080p30, ~1.2 min of 4K30
+	{80000, "fast"},        // ~20 min of 1080p30
+	{300000, "super_fast"}, // ~80 min of 1080p30
+}
+
+// probeLowBPP is the source bits per pixel below which it has already been
+// squeezed hard; such sources get one slower, higher-quality mode so the
+// second generation does not fall apart.
+const probeLowBPP = 0.04
+
+// decideByProbe picks the mode from the probed source, or falls back to the
+// size rule when the source was not probed.
+func decideByProbe(ctx context.Context, in decisionInput) (string, error) {
+	fps := in.FPS
+	if fps <= 0 || fps > 240 {
+		fps = 25
+	}
+	if in.DurationSec <= 0 || in.Width <= 0 || in.Height <= 0 {
+		return decideBySize(ctx, in)
+	}
+	pixels := float64(in.Width*in.Height) * fps
+	work := pixels * in.DurationSec / 1e6
+	mode := "ultra_fast"
+	for _, r := range probeWorkRules {
+		if work < r.MaxWork {
+			mode = r.Mode
+			break
+		}
+	}
+	if br, _ := strconv.ParseFloat(in.BitRate, 64); br > 0 && br/pixels < probeLowBPP {
+		if i := slices.Index(adaptiveLadder, mode); i > 1 {
+			mode = adaptiveLadder[i-1]
+		}
+	}
+	return mode, nil
+}
+
+// decideByCommand runs MODE_DECIDER_CMD (split on spaces) with the input
+// as JSON on stdin.
+func decideByCommand(ctx context.Context, in decisionInput) (string, error) {
+	argv := strings.Fields(os.Getenv("MODE_DECIDER_CMD"))
+	if len(argv) == 0 {
+		return "", errors.New("MODE_DECIDER_CMD not set")
+	}
+	ctx, cancel := context.WithTimeout(ctx, decisionTimeout)
+	defer cancel()
+	body, _ := json.Marshal(in)
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v %s", argv[0], err, lastLine(stderr.String()))
+	}
+	mode := strings.TrimSpace(string(out))
+	if strings.HasPrefix(mode, "{") {
+		var v struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.Unmarshal(out, &v); err != nil {
+			return "", fmt.Errorf("%s: bad JSON output: %v", argv[0], err)
+		}
+		mode = v.Mode
+	}
+	return mode, nil
+}
+
+// decideMode runs the active decider and falls back to the size rule when it
+// fails or answers with something that is not a decidable mode. It returns
+// the mode and the name of the decider that produced it.
+func decideMode(ctx context.Context, requestID string, in decisionInput) (string, string) {
+	name := activeModeDecider()
+	d, ok := modeDeciders[name]
+	if !ok {
+		logger.Printf("⚠️ [%s] Unknown MODE_DECIDER %q; using size", requestID, name)
+		name, d = "size", modeDeciders["size"]
+	}
+	mode, err := d.Decide(ctx, in)
+	if err == nil && !decidableMode(mode) {
+		err = fmt.Errorf("invalid mode %q", mode)
+	}
+	if err != nil && name != "size" {
+		logger.Printf("⚠️ [%s] Mode decider %s failed (%v); using size", requestID, name, err)
+		mode, _ = decideBySize(ctx, in)
+		name = "size"
+	}
+	return mode, name
+}
+
+// ======================
+// AI encode plan (speed=ai)
+// ======================
+
+const (
+	aiDownscaleMinSec = 600  // only long sources are worth losing resolution for
+	aiDownscaleEdge   = 1080 // short edge the downscale lands on
+)
+
+// aiTargetBPP is roughly the H.264 bits per pixel each mode's CRF produces
+// on typical footage; HEVC, VP9 and AV1 need about 60% of it.
+var aiTargetBPP = map[string]float64{
+	"quality":    0.10,
+	"balanced":   0.07,
+	"fast":       0.05,
+	"super_fast": 0.045,
+	"ultra_fast": 0.04,
+}
+
+// probeCodecNames maps codec= values to ffprobe codec names.
+var probeCodecNames = map[string]string{"h264": "h264", "h265": "hevc", "vp9": "vp9", "av1": "av1"}
+
+// reshapesVideo reports whether anything besides the codec settings changes
+// the picture or the video stream layout, so a stream copy would not do.
+func (o *compressOpts) reshapesVideo() bool {
+	switch o.SpeedMode {
+	case "turbo", "max", "proxy", "screen", "lossless", "archive":
+		return true // fixed scale, frame rate or codec
+	}
+	return o.Scale != "" || o.FPS > 0 || o.AutoCrop || o.Crop != "" || o.Rotate != 0 || o.Flip != "" || o.Reframe != nil ||
+		o.Watermark != nil || o.MaxLandscape != (resCap{}) || o.MaxPortrait != (resCap{}) ||
+		o.TrimDead != "" || o.TrimStart > 0 || o.TrimEnd > 0 || o.GOPFrames > 0 || o.GOPSec > 0 ||
+		o.Race != nil || o.SegmentSec > 0 || o.SplitMaxSec > 0 || o.Package != nil || len(o.Renditions) > 0 || o.ValidateFor != "" ||
+		o.BitDepth != 0 || o.PixFmt != "" || o.Interlace != "" || o.Alpha == "keep" || o.Projection != "" ||
+		(o.Source != nil && o.Source.isGIF())
+}
+
+// sourceVideoBitRate is the video stream's bitrate, or the container's minus
+// the audio when the stream does not say.
+func sourceVideoBitRate(p *probeResult) float64 {
+	vs := p.firstStream("video")
+	if vs == nil {
+		return 0
+	}
+	if br, _ := strconv.ParseFloat(vs.BitRate, 64); br > 0 {
+		return br
+	}
+	br := float64(p.bitRate())
+	for _, s := range p.Streams {
+		if s.CodecType == "audio" {
+			a, _ := strconv.ParseFloat(s.BitRate, 64)
+			br -= a
+		}
+	}
+	return br
+}
+
+// planAI refines a speed=ai decision with what the probe says: long sources
+// above 1080p are scaled down in the fast modes, and video that is already
+// below the mode's target bitrate in the requested codec is stream-copied.
+// It returns a note per change.
+func (o *compressOpts) planAI() []string {
+	if o.Source == nil {
+		return nil
+	}
+	vs := o.Source.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return nil
+	}
+	w, h := vs.Width, vs.Height
+	if r := vs.rotation(); !o.PreserveCapture && (r == 90 || r == 270) {
+		w, h = h, w
+	}
+	var notes []string
+
+	dur := o.Source.durationSec()
+	switch o.SpeedMode {
+	case "fast", "super_fast", "ultra_fast":
+		if o.Resolution == "original" && o.Scale == "" && min(w, h) > aiDownscaleEdge && dur >= aiDownscaleMinSec {
+			o.Resolution, o.Scale = "1080p", fmt.Sprintf("-2:%d", aiDownscaleEdge)
+			if h > w {
+				o.Scale = fmt.Sprintf("%d:-2", aiDownscaleEdge)
+			}
+			notes = append(notes, fmt.Sprintf("ai: %dx%d source over %.0f minutes, scaled to 1080p", w, h, dur/60))
+			return notes
+		}
+	}
+
+	bpp, ok := aiTargetBPP[o.SpeedMode]
+	codec := strings.ToLower(o.Codec)
+	if !ok || probeCodecNames[codec] == "" || probeCodecNames[codec] != vs.CodecName || o.reshapesVideo() {
+		return notes
+	}
+	if codec != "h264" {
+		bpp *= 0.6
+	}
+	fps := vs.frameRate()
+	if fps <= 0 || fps > 240 {
+		return notes
+	}
+	target := bpp * float64(w*h) * fps
+	if src := sourceVideoBitRate(o.Source); src > 0 && src <= target {
+		o.Codec = "copy"
+		notes = append(notes, fmt.Sprintf("ai: source video is %.0f kb/s, under the ~%.0f kb/s %s would produce; copied without re-encoding",
+			src/1000, target/1000, o.SpeedMode))
+	}
+	return notes
+}
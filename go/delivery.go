@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Output delivery (deliver=[...] → local dir, S3, HTTP PUT/POST)
+// ======================
+
+// S3 targets are limited to the output buckets (see s3output.go), and HTTP
+// targets and notify webhooks get the same internal-address guard as url=
+// sources (see fetch.go).
+
+const maxDeliveryTargets = 10
+
+// deliveryTarget is one destination of a finished result. Notify is an
+// optional webhook told about this target's outcome.
+type deliveryTarget struct {
+	Type   string `json:"type"`             // local|s3|http
+	Path   string `json:"path,omitempty"`   // local: directory under DELIVERY_LOCAL_ROOT
+	Bucket string `json:"bucket,omitempty"` // s3
+	Key    string `json:"key,omitempty"`    // s3: object key (default: download name)
+	URL    string `json:"url,omitempty"`    // http: destination
+	Method string `json:"method,omitempty"` // http: PUT (default) or POST
+	Notify string `json:"notify,omitempty"`
+}
+
+// delivery tracks one target of a result.
+type delivery struct {
+	Target   deliveryTarget `json:"target"`
+	State    string         `json:"state"` // pending|running|done|failed
+	Location string         `json:"location,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Finished string         `json:"finished_at,omitempty"`
+}
+
+// deliveryMu guards the Deliveries of every result entry.
+var deliveryMu sync.Mutex
+
+func parseDeliveryTargets(s string) ([]deliveryTarget, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var ts []deliveryTarget
+	if err := json.Unmarshal([]byte(s), &ts); err != nil {
+		return nil, errors.New("deliver must be a JSON array of targets")
+	}
+	if len(ts) > maxDeliveryTargets {
+		return nil, fmt.Errorf("at most %d delivery targets", maxDeliveryTargets)
+	}
+	for i, t := range ts {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("deliver[%d]: %w", i, err)
+		}
+	}
+	return ts, nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func (t deliveryTarget) validate() error {
+	switch t.Type {
+	case "local":
+		if os.Getenv("DELIVERY_LOCAL_ROOT") == "" {
+			return errors.New("local delivery is disabled (DELIVERY_LOCAL_ROOT not set)")
+		}
+		if _, err := localDeliveryDir(t.Path); err != nil {
+			return err
+		}
+	case "s3":
+		if t.Bucket == "" {
+			return errors.New("s3 target needs a bucket")
+		}
+		if !outputBucketAllowed(t.Bucket) {
+			return &fetchError{http.StatusForbidden, fmt.Sprintf("bucket %q is not allowed for delivery (OUTPUT_S3_BUCKETS)", t.Bucket)}
+		}
+	case "http":
+		if !isHTTPURL(t.URL) {
+			return errors.New("http target needs an http(s) url")
+		}
+		if m := strings.ToUpper(t.Method); m != "" && m != "PUT" && m != "POST" {
+			return errors.New("http method must be PUT or POST")
+		}
+	default:
+		return fmt.Errorf("unknown target type %q (local|s3|http)", t.Type)
+	}
+	if t.Notify != "" && !isHTTPURL(t.Notify) {
+		return errors.New("notify must be an http(s) url")
+	}
+	return nil
+}
+
+// localDeliveryDir resolves a target directory, refusing paths that escape
+// DELIVERY_LOCAL_ROOT.
+func localDeliveryDir(p string) (string, error) {
+	root := filepath.Clean(os.Getenv("DELIVERY_LOCAL_ROOT"))
+	dir := filepath.Join(root, filepath.Clean("/"+p))
+	if rel, err := filepath.Rel(root, dir); err != nil || strings.HasPrefix(rel, "..") {
+		return "", errors.New("local path escapes DELIVERY_LOCAL_ROOT")
+	}
+	return dir, nil
+}
+
+func newDeliveries(ts []deliveryTarget) []*delivery {
+	out := make([]*delivery, 0, len(ts))
+	for _, t := range ts {
+		out = append(out, &delivery{Target: t, State: "pending"})
+	}
+	return out
+}
+
+// deliveriesView copies the delivery states for /meta.
+func deliveriesView(e *resultEntry) []delivery {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	out := make([]delivery, 0, len(e.Deliveries))
+	for _, d := range e.Deliveries {
+		out = append(out, *d)
+	}
+	return out
+}
+
+// runDeliveries pushes the result to all targets in parallel.
+func runDeliveries(requestID, resultID string, e *resultEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, d := range e.Deliveries {
+		wg.Add(1)
+		go func(d *delivery) {
+			defer wg.Done()
+			deliveryMu.Lock()
+			d.State = "running"
+			deliveryMu.Unlock()
+
+			loc, err := deliver(ctx, e, d.Target)
+
+			deliveryMu.Lock()
+			d.Finished = time.Now().UTC().Format(time.RFC3339)
+			if err != nil {
+				d.State = "failed"
+				d.Error = err.Error()
+				logger.Printf("❌ [%s] Delivery to %s failed: %v", requestID, d.Target.Type, err)
+			} else {
+				d.State = "done"
+				d.Location = loc
+				logger.Printf("📦 [%s] Delivered to %s: %s", requestID, d.Target.Type, loc)
+			}
+			snap := *d
+			deliveryMu.Unlock()
+
+			if d.Target.Notify != "" {
+				notifyDelivery(ctx, requestID, resultID, e, snap)
+			}
+		}(d)
+	}
+	wg.Wait()
+	persistResult(resultID, e)
+}
+
+func deliver(ctx context.Context, e *resultEntry, t deliveryTarget) (string, error) {
+	switch t.Type {
+	case "local":
+		dir, err := localDeliveryDir(t.Path)
+		if err != nil {
+			return "", err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		dst := filepath.Join(dir, e.downloadName())
+		return dst, copyFile(e.FilePath, dst)
+	case "s3":
+		c, err := s3FromEnv()
+		if err != nil {
+			return "", err
+		}
+		key := t.Key
+		if key == "" {
+			key = e.downloadName()
+		}
+		return s3PutFile(ctx, c, t.Bucket, key, e.FilePath, contentTypeFor(e.FilePath))
+	case "http":
+		return t.URL, httpUpload(ctx, e, t)
+	}
+	return "", fmt.Errorf("unknown target type %q", t.Type)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func httpUpload(ctx context.Context, e *resultEntry, t deliveryTarget) error {
+	f, err := os.Open(e.FilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	method := strings.ToUpper(t.Method)
+	if method == "" {
+		method = http.MethodPut
+	}
+	req, err := http.NewRequestWithContext(ctx, method, t.URL, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = e.OutputBytes
+	req.Header.Set("Content-Type", contentTypeFor(e.FilePath))
+	req.Header.Set("Content-Disposition", "attachment; filename=\""+e.downloadName()+"\"")
+	if e.Client.Tag != "" {
+		req.Header.Set("X-Job-Tag", e.Client.Tag)
+	}
+	resp, err := fetchClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", method, t.URL, resp.Status)
+	}
+	return nil
+}
+
+// notifyDelivery POSTs the outcome of one target (best effort).
+func notifyDelivery(ctx context.Context, requestID, resultID string, e *resultEntry, d delivery) {
+	payload := map[string]any{
+		"result_id": resultID,
+		"delivery":  d,
+	}
+	e.Client.addTo(payload)
+	body, _ := json.Marshal(payload)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Target.Notify, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := fetchClient().Do(req)
+	if err != nil {
+		logger.Printf("⚠️ [%s] Delivery notify failed: %v", requestID, err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ======================
+// Device compatibility profiles (validate_for=)
+// ======================
+
+// A device profile lists what a family of players decodes reliably. Before
+// the encode the request is steered towards it (container, codecs, 8-bit
+// 4:2:0, hvc1 tagging); afterwards the output is probed and checked, and a
+// mismatch (e.g. a copied High 10 stream, or 4K exceeding the level) is
+// re-encoded once with the profile's safe settings. compat=strict rejects
+// instead of adjusting.
+
+// deviceCodec constrains one video codec family.
+type deviceCodec struct {
+	Profiles []string // ffprobe profile names (nil = any)
+	MaxLevel int      // ffprobe level_idc (h264 42 = 4.2, hevc 123 = 4.1); 0 = any
+	PixFmts  []string // nil = any
+	Tag      string   // codec tag required in mp4/mov (hvc1 for Apple HEVC)
+}
+
+type deviceProfile struct {
+	Containers []string // the first is used when the requested one is not allowed
+	Video      map[string]deviceCodec
+	Audio      map[string]bool
+	MaxBox     resCap // landscape bounding box applied by the re-encode
+}
+
+var (
+	h264Profiles = []string{"Constrained Baseline", "Baseline", "Main", "High"}
+	yuv420       = []string{"yuv420p", "yuvj420p"}
+)
+
+var deviceProfiles = map[string]deviceProfile{
+	// QuickTime Player, iOS/macOS Photos and AVFoundation
+	"quicktime": {
+		Containers: []string{".mp4", ".mov", ".m4v"},
+		Video: map[string]deviceCodec{
+			"h264":   {Profiles: h264Profiles, PixFmts: yuv420},
+			"h265":   {Profiles: []string{"Main", "Main 10"}, PixFmts: []string{"yuv420p", "yuv420p10le"}, Tag: "hvc1"},
+			"prores": {},
+		},
+		Audio: codecSet("aac", "mp3", "ac3", "eac3", "pcm"),
+	},
+	// Android MediaCodec baseline (guaranteed decoders, 1080p)
+	"android": {
+		Containers: []string{".mp4", ".webm", ".mkv"},
+		Video: map[string]deviceCodec{
+			"h264": {Profiles: h264Profiles, MaxLevel: 42, PixFmts: yuv420},
+			"h265": {Profiles: []string{"Main"}, MaxLevel: 123, PixFmts: []string{"yuv420p"}},
+			"vp9":  {Profiles: []string{"Profile 0"}, PixFmts: []string{"yuv420p"}},
+		},
+		Audio:  codecSet("aac", "opus", "mp3", "vorbis", "flac"),
+		MaxBox: resCap{W: 1920, H: 1080},
+	},
+	// <video> in every current desktop and mobile browser
+	"web": {
+		Containers: []string{".mp4", ".webm"},
+		Video: map[string]deviceCodec{
+			"h264": {Profiles: h264Profiles, PixFmts: yuv420},
+			"vp9":  {Profiles: []string{"Profile 0"}, PixFmts: []string{"yuv420p"}},
+			"av1":  {Profiles: []string{"Main"}, PixFmts: []string{"yuv420p"}},
+		},
+		Audio: codecSet("aac", "opus", "mp3", "vorbis"),
+	},
+}
+
+func parseValidateFor(s, speed string) error {
+	if s == "" {
+		return nil
+	}
+	if _, ok := deviceProfiles[s]; !ok {
+		return fmt.Errorf("invalid validate_for %q (quicktime|android|web)", s)
+	}
+	if speed == "lossless" {
+		return fmt.Errorf("validate_for=%s cannot be combined with speed=lossless", s)
+	}
+	return nil
+}
+
+// levelString renders an ffprobe level_idc the way specs write it.
+func levelString(family string, level int) string {
+	if family == "h265" {
+		return fmt.Sprintf("%.1f", float64(level)/30)
+	}
+	return fmt.Sprintf("%d.%d", level/10, level%10)
+}
+
+func (dp deviceProfile) allows(video string) bool {
+	_, ok := dp.Video[video]
+	return ok
+}
+
+// videoProblems checks a video stream against the profile; the codec tag
+// is only checked for mp4/mov outputs (ext "" skips it).
+func (dp deviceProfile) videoProblems(vs *probeStream, ext string) []string {
+	family := codecFamily(vs.CodecName)
+	dc, ok := dp.Video[family]
+	if !ok {
+		return []string{"video codec " + family + " is not supported"}
+	}
+	var problems []string
+	if dc.Profiles != nil && vs.Profile != "" && !slices.Contains(dc.Profiles, vs.Profile) {
+		problems = append(problems, fmt.Sprintf("%s profile %s is not supported (%s)", family, vs.Profile, strings.Join(dc.Profiles, ", ")))
+	}
+	if dc.MaxLevel > 0 && vs.Level > dc.MaxLevel {
+		problems = append(problems, fmt.Sprintf("%s level %s exceeds %s", family, levelString(family, vs.Level), levelString(family, dc.MaxLevel)))
+	}
+	if dc.PixFmts != nil && vs.PixFmt != "" && !slices.Contains(dc.PixFmts, vs.PixFmt) {
+		problems = append(problems, fmt.Sprintf("pixel format %s is not supported (%s)", vs.PixFmt, strings.Join(dc.PixFmts, ", ")))
+	}
+	if dc.Tag != "" && outputContainers[ext].MovFlags && vs.CodecTag != dc.Tag {
+		problems = append(problems, fmt.Sprintf("%s must be tagged %s (got %s)", family, dc.Tag, vs.CodecTag))
+	}
+	return problems
+}
+
+// problems checks a probed output against the profile.
+func (dp deviceProfile) problems(p *probeResult, ext string) []string {
+	var problems []string
+	if !slices.Contains(dp.Containers, ext) {
+		problems = append(problems, "container "+ext+" is not supported")
+	}
+	if vs := p.firstStream("video"); vs != nil {
+		problems = append(problems, dp.videoProblems(vs, ext)...)
+	}
+	if as := p.firstStream("audio"); as != nil && !dp.Audio[codecFamily(as.CodecName)] {
+		problems = append(problems, "audio codec "+codecFamily(as.CodecName)+" is not supported")
+	}
+	return problems
+}
+
+// safeVideo/safeAudio pick the container's preferred codec when the
+// profile allows it, else the profile's first choice.
+func (dp deviceProfile) safeVideo(ext string) string {
+	if pv := outputContainers[ext].PreferVideo; dp.allows(pv) && outputContainers[ext].Video[pv] {
+		return pv
+	}
+	if outputContainers[ext].Video["h264"] && dp.allows("h264") {
+		return "h264"
+	}
+	return "vp9"
+}
+
+func (dp deviceProfile) safeAudio(ext string) string {
+	if pa := outputContainers[ext].PreferAudio; dp.Audio[pa] {
+		return pa
+	}
+	return "aac"
+}
+
+// applyDevice steers the options towards o.ValidateFor before the encode.
+// It runs after resolveCompat, so the container already holds the codecs.
+func (o *compressOpts) applyDevice() ([]string, error) {
+	dp, ok := deviceProfiles[o.ValidateFor]
+	if !ok {
+		return nil, nil
+	}
+	var notes, conflicts []string
+	adjust := func(problem, note string, apply func()) {
+		if o.Compat == "strict" {
+			conflicts = append(conflicts, problem)
+			return
+		}
+		apply()
+		notes = append(notes, note)
+	}
+
+	ext := strings.ToLower(o.OutExt)
+	if !slices.Contains(dp.Containers, ext) {
+		adjust("container "+ext+" is not supported", fmt.Sprintf("container %s → %s for %s", ext, dp.Containers[0], o.ValidateFor),
+			func() { o.OutExt = dp.Containers[0] })
+		ext = strings.ToLower(o.OutExt)
+	}
+
+	video, audio := effectiveCodecs(o)
+	var videoBad []string
+	switch {
+	case video != "" && !dp.allows(video):
+		videoBad = []string{"video codec " + video + " is not supported"}
+	case o.Codec == "copy" && o.Source != nil:
+		if vs := o.Source.firstStream("video"); vs != nil {
+			videoBad = dp.videoProblems(vs, "") // the tag is set by deviceArgs
+		}
+	}
+	if len(videoBad) > 0 {
+		safe := dp.safeVideo(ext)
+		adjust(strings.Join(videoBad, "; "), fmt.Sprintf("video %s → %s for %s (%s)", o.Codec, safe, o.ValidateFor, strings.Join(videoBad, "; ")),
+			func() { o.Codec, o.BitDepth = safe, 0 })
+	}
+	if audio != "" && !dp.Audio[audio] {
+		safe := dp.safeAudio(ext)
+		adjust("audio codec "+audio+" is not supported", fmt.Sprintf("audio %s → %s for %s", audio, safe, o.ValidateFor),
+			func() { o.Audio = safe })
+	}
+
+	// 8-bit 4:2:0 unless the profile takes 10-bit for this codec
+	if dc, ok := dp.Video[codecFamily(o.Codec)]; ok && dc.PixFmts != nil && o.Codec != "copy" {
+		tenOK := slices.ContainsFunc(dc.PixFmts, highBitDepthRe.MatchString)
+		switch {
+		case o.BitDepth == 10 && !tenOK:
+			adjust("10-bit "+codecFamily(o.Codec)+" is not supported", fmt.Sprintf("bit_depth 10 → 8 for %s", o.ValidateFor),
+				func() { o.BitDepth = 8 })
+		case o.BitDepth == 0 && !tenOK:
+			o.BitDepth = 8
+		}
+		if o.PixFmt != "" && !slices.Contains(dc.PixFmts, o.PixFmt) {
+			adjust("pixel format "+o.PixFmt+" is not supported", fmt.Sprintf("pix_fmt %s → %s for %s", o.PixFmt, dc.PixFmts[0], o.ValidateFor),
+				func() { o.PixFmt = dc.PixFmts[0] })
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, &compatError{Container: "validate_for=" + o.ValidateFor, Conflicts: conflicts}
+	}
+	return notes, nil
+}
+
+// deviceArgs adds the codec tag the profile needs (also for stream copy).
+func deviceArgs(o compressOpts, vcodec string) []string {
+	dp, ok := deviceProfiles[o.ValidateFor]
+	if !ok || !outputContainers[strings.ToLower(o.OutExt)].MovFlags {
+		return nil
+	}
+	family := codecFamily(vcodec)
+	if vcodec == "copy" && o.Source != nil {
+		if vs := o.Source.firstStream("video"); vs != nil {
+			family = codecFamily(vs.CodecName)
+		}
+	}
+	if family == "hevc_videotoolbox" {
+		family = "h265"
+	}
+	if dc := dp.Video[family]; dc.Tag != "" {
+		return []string{"-tag:v", dc.Tag}
+	}
+	return nil
+}
+
+// enforceDevice probes the encoded output and, if it breaks the profile,
+// re-encodes it once with safe settings. compat=strict returns the problems
+// as a compatError instead.
+func enforceDevice(ctx context.Context, requestID, inPath, outPath string, o *compressOpts) ([]string, error) {
+	dp, ok := deviceProfiles[o.ValidateFor]
+	if !ok {
+		return nil, nil
+	}
+	ext := strings.ToLower(o.OutExt)
+	out, err := probeFile(ctx, outPath)
+	if err != nil {
+		return nil, fmt.Errorf("validate_for: cannot probe output: %w", err)
+	}
+	problems := dp.problems(out, ext)
+	if len(problems) == 0 {
+		logger.Printf("📱 [%s] Output is compatible with %s", requestID, o.ValidateFor)
+		return nil, nil
+	}
+	if o.Compat == "strict" {
+		return nil, &compatError{Container: "validate_for=" + o.ValidateFor, Conflicts: problems}
+	}
+
+	logger.Printf("📱 [%s] Not %s-compatible (%s); re-encoding with safe settings", requestID, o.ValidateFor, strings.Join(problems, "; "))
+	safe := *o
+	safe.Codec, safe.Audio, safe.BitDepth = dp.safeVideo(ext), dp.safeAudio(ext), 8
+	safe.HW, safe.HWDecode = "none", "none"
+	if dp.MaxBox != (resCap{}) {
+		safe.MaxLandscape, safe.MaxPortrait = dp.MaxBox, resCap{W: dp.MaxBox.H, H: dp.MaxBox.W}
+	}
+	if err := runFFmpeg(ctx, inPath, outPath, safe, io.Discard); err != nil {
+		return nil, fmt.Errorf("validate_for=%s re-encode failed: %w", o.ValidateFor, err)
+	}
+	if out, err = probeFile(ctx, outPath); err != nil {
+		return nil, fmt.Errorf("validate_for: cannot probe output: %w", err)
+	}
+	if still := dp.problems(out, ext); len(still) > 0 {
+		return nil, &compatError{Container: "validate_for=" + o.ValidateFor, Conflicts: still}
+	}
+	*o = safe
+	return []string{fmt.Sprintf("re-encoded as %s/%s for %s (%s)", safe.Codec, safe.Audio, o.ValidateFor, strings.Join(problems, "; "))}, nil
+}
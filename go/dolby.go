@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Dolby Digital audio (audio=ac3|eac3)
+// ======================
+
+// TVs, AV receivers and soundbars decode Dolby Digital (AC-3) and Dolby
+// Digital Plus (E-AC-3) everywhere, while Opus is rare and multichannel AAC
+// is patchy. audio=ac3|eac3 encodes the first audio track with ffmpeg's
+// native encoders, keeping its channel layout up to 5.1 (7.1 is folded down
+// to 5.1, the encoders' limit) regardless of speed mode: the point is
+// surround on the living-room system. ab sets the bitrate; otherwise it
+// follows the channel count (dolbyKbps).
+
+// dolbyKbps are the default bitrates for mono, stereo and surround.
+var dolbyKbps = map[string][3]int{
+	"ac3":  {96, 192, 448},
+	"eac3": {64, 128, 384},
+}
+
+const dolbyMaxChannels = 6
+
+func isDolby(audio string) bool {
+	a := strings.ToLower(audio)
+	return a == "ac3" || a == "eac3"
+}
+
+// parseDolbyBitrate checks ab ("448k" or bits per second) for audio=ac3|eac3
+// against the encoder's range in audioKbpsRange.
+func parseDolbyBitrate(codec, ab string) (string, error) {
+	return parseAudioKbps("ab", codec, ab)
+}
+
+// sourceChannels is the channel count of the source's first audio track
+// (0 if unknown).
+func sourceChannels(p *probeResult) int {
+	if p == nil {
+		return 0
+	}
+	if as := p.firstStream("audio"); as != nil {
+		return as.Channels
+	}
+	return 0
+}
+
+// settleDolby returns a note when the source has more channels than the
+// Dolby encoders take, or "".
+func (o *compressOpts) settleDolby() string {
+	if !isDolby(o.Audio) {
+		return ""
+	}
+	if ch := slices.Max(audioTrackChannels(*o)); ch > dolbyMaxChannels {
+		return fmt.Sprintf("audio downmixed from %d channels to 5.1 for %s", ch, strings.ToLower(o.Audio))
+	}
+	return ""
+}
+
+// dolbyArgs encodes the audio as AC-3/E-AC-3, each track at the bitrate for
+// its channel count.
+func dolbyArgs(o compressOpts) []string {
+	codec := strings.ToLower(o.Audio)
+	args := []string{"-c:a", codec}
+	for i, ch := range audioTrackChannels(o) {
+		spec := audioTrackSpec(o, i)
+		kbps := o.DolbyAB
+		if kbps == "" {
+			rates := dolbyKbps[codec]
+			switch {
+			case ch == 1:
+				kbps = strconv.Itoa(rates[0]) + "k"
+			case ch > 2:
+				kbps = strconv.Itoa(rates[2]) + "k"
+			default:
+				kbps = strconv.Itoa(rates[1]) + "k"
+			}
+		}
+		args = append(args, "-b"+spec, kbps)
+		if ch > dolbyMaxChannels {
+			args = append(args, "-ac"+spec, strconv.Itoa(dolbyMaxChannels))
+		}
+	}
+	return args
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ======================
+// Job events (GET /events/{id}, Server-Sent Events)
+// ======================
+
+// /events/{id} streams a job as it changes: a "state" event with the job
+// view on connect and on every state transition, "progress" events with
+// percent, fps, bitrate and ETA while ffmpeg runs, and a final "state" event
+// before the stream ends. Comment lines keep idle proxies from closing the
+// connection.
+
+const sseKeepAlive = 15 * time.Second
+
+func writeEvent(w io.Writer, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// progressEvent is the data of a "progress" event.
+func progressEvent(j job) map[string]any {
+	v := map[string]any{"id": j.ID, "percent": j.Progress}
+	if p := j.Encode; p != nil {
+		v["frame"] = p.Frame
+		v["fps"] = p.FPS
+		v["bitrate"] = p.Bitrate
+		v["out_time_sec"] = p.OutTimeSec
+		v["speed"] = p.Speed
+		if p.ETASec > 0 {
+			v["eta_sec"] = p.ETASec
+		}
+	}
+	return v
+}
+
+// eventsHandler serves GET /events/{id}.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	j, ok := getJob(strings.Trim(strings.TrimPrefix(r.URL.Path, "/events"), "/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx: do not buffer the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	var last job
+	first := true
+	for {
+		snap, changed := j.snapshot()
+		var err error
+		if first || snap.State != last.State {
+			err = writeEvent(w, "state", snap.view())
+		}
+		if err == nil && snap.Encode != nil && (last.Encode == nil || *snap.Encode != *last.Encode) {
+			err = writeEvent(w, "progress", progressEvent(snap))
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil || snap.terminal() {
+			return
+		}
+		first, last = false, snap
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -20,13 +20,13 @@ func compressVideoWithHeader(filePath, speed string) error {
 
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
-	
+
 	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
 		return err
 	}
 	io.Copy(part, file)
-	
+
 	writer.WriteField("speed", speed)
 	writer.Close()
 
@@ -34,7 +34,7 @@ func compressVideoWithHeader(filePath, speed string) error {
 	if err != nil {
 		return err
 	}
-	
+
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	// This is the key header to get file bytes instead of UI
 	req.Header.Set("Accept", "application/octet-stream")
@@ -84,13 +84,13 @@ func compressVideoWithParam(filePath, speed string) error {
 
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
-	
+
 	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
 	if err != nil {
 		return err
 	}
 	io.Copy(part, file)
-	
+
 	writer.WriteField("speed", speed)
 	writer.WriteField("api", "1") // This parameter also triggers API mode
 	writer.Close()
@@ -99,7 +99,7 @@ func compressVideoWithParam(filePath, speed string) error {
 	if err != nil {
 		return err
 	}
-	
+
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	client := &http.Client{}
@@ -132,7 +132,7 @@ func compressVideoWithParam(filePath, speed string) error {
 
 // PrintCurlExample shows cURL commands for API usage
 func printCurlExample() {
-	fmt.Println(`
+	fmt.Print(`
 # cURL example to get compressed file bytes:
 curl -X POST \
   -H "Accept: application/octet-stream" \
@@ -169,7 +169,7 @@ func main() {
 	}
 
 	filePath := os.Args[1]
-	
+
 	fmt.Println("=== Example 1: Using Accept header ===")
 	err := compressVideoWithHeader(filePath, "ai")
 	if err != nil {
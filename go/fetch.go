@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ======================
+// Remote input (url=)
+// ======================
+
+// /compress accepts "url" instead of a "file" part when the source already
+// lives on object storage or a CDN. The server downloads it into its temp
+// directory under the same size limit as an upload, within FETCH_TIMEOUT
+// (default 15m), and only keeps it when the response looks like media: the
+// Content-Type must be video/*, audio/* or a generic binary type, and the
+// first bytes must not be a known non-media signature (see sniff.go).
+//
+// Addresses on loopback, private and link-local networks are refused so a
+// client cannot make the server fetch from its own infrastructure; set
+// FETCH_ALLOW_PRIVATE=1 when sources sit on an internal MinIO or similar.
+//
+// input=s3://bucket/key reads the source from S3-compatible storage with the
+// server's own credentials (see s3.go), so large files never pass through the
+// load balancer. Only buckets listed in INPUT_S3_BUCKETS (comma-separated)
+// may be named; unset, input=s3 is refused, so clients cannot read the
+// results bucket or anything else the credentials happen to reach.
+
+const fetchMaxRedirects = 5
+
+// fetchTypes are the non-media Content-Types that still pass: object stores
+// often serve uploads untyped.
+var fetchTypes = map[string]bool{
+	"":                         true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+	"application/mp4":          true,
+	"application/mxf":          true,
+	"application/ogg":          true,
+}
+
+// fetchError carries the status compressHandler answers with.
+type fetchError struct {
+	status int
+	msg    string
+}
+
+func (e *fetchError) Error() string { return e.msg }
+
+func fetchAllowPrivate() bool { return os.Getenv("FETCH_ALLOW_PRIVATE") == "1" }
+
+// fetchDial refuses internal addresses after DNS resolution, so a hostname
+// that resolves to 127.0.0.1 is caught as well as a literal IP.
+func fetchDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("unexpected address %q", address)
+	}
+	if internalIP(ip) {
+		return fmt.Errorf("refusing to fetch from internal address %s", ip)
+	}
+	return nil
+}
+
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkPublicHost applies the fetchDial rule to a URL that ffmpeg opens
+// itself: every address host resolves to must be public.
+func checkPublicHost(ctx context.Context, host string) error {
+	if fetchAllowPrivate() {
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, a := range ips {
+		if internalIP(a.IP) {
+			return fmt.Errorf("refusing to connect to internal address %s", a.IP)
+		}
+	}
+	return nil
+}
+
+func fetchClient() *http.Client {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	if !fetchAllowPrivate() {
+		d.Control = fetchDial
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = d.DialContext
+	tr.Proxy = nil // a proxy would hide the address being dialled
+	return &http.Client{
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			if !isHTTPURL(req.URL.String()) {
+				return errors.New("redirect to a non-HTTP URL")
+			}
+			return nil
+		},
+	}
+}
+
+// fetchName picks the source file name: Content-Disposition, then the last
+// URL path segment.
+func fetchName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if n := sanitizeFilename(filepath.Base(params["filename"])); n != "" {
+			return n
+		}
+	}
+	if n := sanitizeFilename(path.Base(resp.Request.URL.Path)); n != "" && n != "/" {
+		return n
+	}
+	return "remote.mp4"
+}
+
+// fetchSource downloads rawURL into the temp directory and returns its path
+// and file name. Failures are *fetchError.
+func fetchSource(ctx context.Context, requestID, rawURL string, limit int64) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !isHTTPURL(rawURL) || u.Host == "" {
+		return "", "", &fetchError{http.StatusBadRequest, fmt.Sprintf("invalid url %q (http or https)", rawURL)}
+	}
+	ctx, cancel := context.WithTimeout(ctx, envDuration("FETCH_TIMEOUT", 15*time.Minute))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", &fetchError{http.StatusBadRequest, err.Error()}
+	}
+	req.Header.Set("User-Agent", "videocompress-http")
+	logger.Printf("🌍 [%s] Fetching source from %s", requestID, u.Redacted())
+	resp, err := fetchClient().Do(req)
+	if err != nil {
+		return "", "", &fetchError{http.StatusBadGateway, "fetching url: " + err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", &fetchError{http.StatusBadGateway, fmt.Sprintf("fetching url: remote answered %s", resp.Status)}
+	}
+	return saveFetched(requestID, resp, fetchName(resp), limit)
+}
+
+// inputBucketAllowed reports whether clients may read from bucket; only
+// buckets listed in INPUT_S3_BUCKETS are.
+func inputBucketAllowed(bucket string) bool {
+	return bucketListed(os.Getenv("INPUT_S3_BUCKETS"), bucket)
+}
+
+// fetchS3Source downloads s3://bucket/key like fetchSource.
+func fetchS3Source(ctx context.Context, requestID, raw string, limit int64) (string, string, error) {
+	loc, err := parseS3URL(raw)
+	if err != nil || loc.Key == "" || strings.HasSuffix(loc.Key, "/") {
+		return "", "", &fetchError{http.StatusBadRequest, fmt.Sprintf("invalid input %q (s3://bucket/key)", raw)}
+	}
+	if !inputBucketAllowed(loc.Bucket) {
+		return "", "", &fetchError{http.StatusForbidden, fmt.Sprintf("bucket %q is not allowed for input (INPUT_S3_BUCKETS)", loc.Bucket)}
+	}
+	c, err := s3FromEnv()
+	if err != nil {
+		return "", "", &fetchError{http.StatusServiceUnavailable, "input=s3: " + err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, envDuration("FETCH_TIMEOUT", 15*time.Minute))
+	defer cancel()
+	logger.Printf("🌍 [%s] Fetching source from %s", requestID, raw)
+	resp, err := s3Get(ctx, c, loc.Bucket, loc.Key, nil)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", &fetchError{http.StatusNotFound, "no such object: " + raw}
+	}
+	if err != nil {
+		return "", "", &fetchError{http.StatusBadGateway, "fetching source: " + err.Error()}
+	}
+	defer resp.Body.Close()
+	name := sanitizeFilename(path.Base(loc.Key))
+	if name == "" {
+		name = "remote.mp4"
+	}
+	return saveFetched(requestID, resp, name, limit)
+}
+
+// saveFetched checks a remote source's size and type and writes its body
+// to the temp directory as name.
+func saveFetched(requestID string, resp *http.Response, name string, limit int64) (string, string, error) {
+	if limit > 0 && resp.ContentLength > limit {
+		return "", "", &fetchError{http.StatusRequestEntityTooLarge, fmt.Sprintf("remote file is %s, over the %s limit", humanBytes(resp.ContentLength), humanBytes(limit))}
+	}
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !fetchTypes[ct] && !strings.HasPrefix(ct, "video/") && !strings.HasPrefix(ct, "audio/") {
+		return "", "", &fetchError{http.StatusUnsupportedMediaType, fmt.Sprintf("remote file is not a video or audio file (Content-Type %s)", ct)}
+	}
+
+	inPath := filepath.Join(os.TempDir(), requestID+"_"+name)
+	f, err := os.Create(inPath)
+	if err != nil {
+		return "", "", &fetchError{http.StatusInternalServerError, "save error: " + err.Error()}
+	}
+	body := io.Reader(resp.Body)
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit+1)
+	}
+	head := make([]byte, 16)
+	n, _ := io.ReadFull(body, head)
+	head = head[:n]
+	for _, sig := range notMediaSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			f.Close()
+			os.Remove(inPath)
+			return "", "", &fetchError{http.StatusUnsupportedMediaType, fmt.Sprintf("remote file is not a video or audio file (looks like: %s)", sig.kind)}
+		}
+	}
+	written, err := io.Copy(f, io.MultiReader(bytes.NewReader(head), body))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	switch {
+	case err != nil:
+		os.Remove(inPath)
+		return "", "", &fetchError{http.StatusBadGateway, "fetching source: " + err.Error()}
+	case limit > 0 && written > limit:
+		os.Remove(inPath)
+		return "", "", &fetchError{http.StatusRequestEntityTooLarge, fmt.Sprintf("remote file is over the %s limit", humanBytes(limit))}
+	case written == 0:
+		os.Remove(inPath)
+		return "", "", &fetchError{http.StatusBadGateway, "fetching source: remote file is empty"}
+	}
+	logger.Printf("✅ [%s] Fetched %s (%s)", requestID, name, humanBytes(written))
+	return inPath, name, nil
+}
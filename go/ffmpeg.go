@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// FFmpeg binaries (FFMPEG_PATH, FFPROBE_PATH)
+// ======================
+
+// FFMPEG_PATH and FFPROBE_PATH pin the binaries; by default they are looked
+// up on PATH, and with only FFMPEG_PATH set, ffprobe is taken from the same
+// directory when it is there. At startup the server records both versions
+// and the encoders ffmpeg was built with, and refuses to start when
+// FFMPEG_MIN_VERSION or FFMPEG_REQUIRED_ENCODERS (default libx264,aac) are
+// not met. A missing ffmpeg only stops startup when FFMPEG_PATH names it.
+//
+// FFMPEG_BUILDS=name=/path/ffmpeg,... adds more builds for hosts where one
+// build cannot do everything (say NVENC in one, SVT-AV1 in another). Each
+// command is routed by the encoders (-c:v, -c:a) and -hwaccel it uses: the
+// default build when it has them all, else the first extra build that does.
+
+// knownEncoders are the encoders /health reports on.
+var knownEncoders = []string{
+	"libx264", "libx265", "libvpx-vp9", "libsvtav1", "prores_ks", "ffv1",
+	"h264_videotoolbox", "hevc_videotoolbox", "aac", "libopus", "libmp3lame", "ac3", "eac3",
+}
+
+func ffmpegBin() string {
+	return envOr("FFMPEG_PATH", "ffmpeg")
+}
+
+func ffprobeBin() string {
+	if p := os.Getenv("FFPROBE_PATH"); p != "" {
+		return p
+	}
+	if p := os.Getenv("FFMPEG_PATH"); p != "" {
+		sibling := filepath.Join(filepath.Dir(p), "ffprobe"+filepath.Ext(p))
+		if _, err := os.Stat(sibling); err == nil {
+			return sibling
+		}
+	}
+	return "ffprobe"
+}
+
+// ffmpegBuild is one detected ffmpeg binary.
+type ffmpegBuild struct {
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	Version  string   `json:"version"`
+	Encoders []string `json:"encoders"` // the knownEncoders present
+
+	encoders map[string]bool
+	hwaccels map[string]bool
+}
+
+// supports reports whether the build has every encoder and hwaccel in need.
+func (b *ffmpegBuild) supports(need []string) bool {
+	for _, n := range need {
+		if !b.encoders[n] && !b.hwaccels[n] {
+			return false
+		}
+	}
+	return true
+}
+
+type ffmpegInfo struct {
+	Path         string   `json:"path"`
+	Version      string   `json:"version"`
+	ProbePath    string   `json:"ffprobe_path"`
+	ProbeVersion string   `json:"ffprobe_version"`
+	Encoders     []string `json:"encoders"`                   // the knownEncoders present in any build
+	Missing      []string `json:"missing_encoders,omitempty"` // knownEncoders absent from all builds
+	// Builds are the FFMPEG_BUILDS; the default build is used first.
+	Builds []ffmpegBuild `json:"builds,omitempty"`
+
+	def ffmpegBuild
+}
+
+var (
+	ffmpegMu       sync.Mutex
+	ffmpegDetected ffmpegInfo
+)
+
+// hasHWAccel reports whether any build offers the hwaccel.
+func (info ffmpegInfo) hasHWAccel(name string) bool {
+	if info.def.hwaccels[name] {
+		return true
+	}
+	for i := range info.Builds {
+		if info.Builds[i].hwaccels[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func currentFFmpeg() ffmpegInfo {
+	ffmpegMu.Lock()
+	defer ffmpegMu.Unlock()
+	return ffmpegDetected
+}
+
+var versionRe = regexp.MustCompile(`version\s+n?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// toolVersion runs bin -version and returns the version word of the first
+// line ("6.1.1", "n7.0", "N-113950-g...").
+func toolVersion(ctx context.Context, bin string) (string, error) {
+	out, err := exec.CommandContext(ctx, bin, "-hide_banner", "-version").Output()
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	f := strings.Fields(line)
+	if len(f) < 3 || f[1] != "version" {
+		return "", fmt.Errorf("unexpected version output %q", line)
+	}
+	return f[2], nil
+}
+
+// versionAtLeast compares "major.minor[.patch]" versions. Git snapshot
+// builds ("N-...") carry no release number and always pass.
+func versionAtLeast(have, want string) bool {
+	h := versionRe.FindStringSubmatch("version " + have)
+	w := versionRe.FindStringSubmatch("version " + want)
+	if h == nil || w == nil {
+		return true
+	}
+	for i := 1; i <= 3; i++ {
+		a, _ := strconv.Atoi(h[i])
+		b, _ := strconv.Atoi(w[i])
+		if a != b {
+			return a > b
+		}
+	}
+	return true
+}
+
+func listEncoders(ctx context.Context, bin string) (map[string]bool, error) {
+	out, err := exec.CommandContext(ctx, bin, "-hide_banner", "-encoders").Output()
+	if err != nil {
+		return nil, err
+	}
+	encs := map[string]bool{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		// " V....D libx264   libx264 H.264 / AVC ..."
+		f := strings.Fields(sc.Text())
+		if len(f) >= 2 && len(f[0]) == 6 && f[1] != "=" {
+			encs[f[1]] = true
+		}
+	}
+	return encs, nil
+}
+
+func listHWAccels(ctx context.Context, bin string) (map[string]bool, error) {
+	out, err := exec.CommandContext(ctx, bin, "-hide_banner", "-hwaccels").Output()
+	if err != nil {
+		return nil, err
+	}
+	hw := map[string]bool{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		// "Hardware acceleration methods:" then one name per line
+		if f := strings.Fields(sc.Text()); len(f) == 1 {
+			hw[f[0]] = true
+		}
+	}
+	return hw, nil
+}
+
+// detectBuild runs bin to learn its version, encoders and hwaccels.
+func detectBuild(ctx context.Context, name, bin string) (ffmpegBuild, error) {
+	b := ffmpegBuild{Name: name, Path: bin}
+	p, err := exec.LookPath(bin)
+	if err != nil {
+		return b, err
+	}
+	b.Path = p
+	if b.Version, err = toolVersion(ctx, p); err != nil {
+		return b, err
+	}
+	if b.encoders, err = listEncoders(ctx, p); err != nil {
+		return b, fmt.Errorf("-encoders: %w", err)
+	}
+	if b.hwaccels, err = listHWAccels(ctx, p); err != nil {
+		return b, fmt.Errorf("-hwaccels: %w", err)
+	}
+	for _, e := range knownEncoders {
+		if b.encoders[e] {
+			b.Encoders = append(b.Encoders, e)
+		}
+	}
+	return b, nil
+}
+
+// parseBuilds reads FFMPEG_BUILDS (name=path,...).
+func parseBuilds(s string) ([][2]string, error) {
+	var out [][2]string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(item, "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("FFMPEG_BUILDS: %q is not name=path", item)
+		}
+		out = append(out, [2]string{name, path})
+	}
+	return out, nil
+}
+
+// commandNeeds lists the encoders and hwaccels an ffmpeg command line uses.
+func commandNeeds(args []string) []string {
+	var need []string
+	for i := 0; i+1 < len(args); i++ {
+		k, v := args[i], args[i+1]
+		switch {
+		case k == "-hwaccel" && v != "auto" && v != "none":
+		case k == "-c" || k == "-vcodec" || k == "-acodec" || strings.HasPrefix(k, "-c:") || strings.HasPrefix(k, "-codec"):
+			if v == "copy" {
+				continue
+			}
+		default:
+			continue
+		}
+		need = append(need, v)
+		i++
+	}
+	return need
+}
+
+// ffmpegFor picks the ffmpeg binary for a command line.
+func ffmpegFor(args []string) string {
+	info := currentFFmpeg()
+	if len(info.Builds) == 0 {
+		return ffmpegBin()
+	}
+	need := commandNeeds(args)
+	if info.def.supports(need) {
+		return ffmpegBin()
+	}
+	for i := range info.Builds {
+		if info.Builds[i].supports(need) {
+			return info.Builds[i].Path
+		}
+	}
+	return ffmpegBin()
+}
+
+// checkFFmpeg detects the ffmpeg/ffprobe binaries at startup.
+func checkFFmpeg() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	info := ffmpegInfo{Path: ffmpegBin(), ProbePath: ffprobeBin()}
+	if p, err := exec.LookPath(info.Path); err == nil {
+		info.Path = p
+	} else {
+		if os.Getenv("FFMPEG_PATH") != "" {
+			return fmt.Errorf("FFMPEG_PATH: %w", err)
+		}
+		logger.Printf("⚠️ [MAIN] ffmpeg not found in PATH; encodes will fail until it is installed")
+		return nil
+	}
+	def, err := detectBuild(ctx, "default", info.Path)
+	if err != nil {
+		return fmt.Errorf("ffmpeg %s: %w", info.Path, err)
+	}
+	info.def, info.Version = def, def.Version
+	if min := os.Getenv("FFMPEG_MIN_VERSION"); min != "" && !versionAtLeast(info.Version, min) {
+		return fmt.Errorf("ffmpeg %s is version %s, FFMPEG_MIN_VERSION is %s", info.Path, info.Version, min)
+	}
+	if p, err := exec.LookPath(info.ProbePath); err == nil {
+		info.ProbePath = p
+		if info.ProbeVersion, err = toolVersion(ctx, p); err != nil {
+			return fmt.Errorf("ffprobe %s: %w", p, err)
+		}
+	} else if os.Getenv("FFPROBE_PATH") != "" {
+		return fmt.Errorf("FFPROBE_PATH: %w", err)
+	} else {
+		logger.Printf("⚠️ [MAIN] ffprobe not found; probing (AI decisions, verification) will fail")
+	}
+
+	builds, err := parseBuilds(os.Getenv("FFMPEG_BUILDS"))
+	if err != nil {
+		return err
+	}
+	for _, nb := range builds {
+		b, err := detectBuild(ctx, nb[0], nb[1])
+		if err != nil {
+			return fmt.Errorf("FFMPEG_BUILDS %s (%s): %w", nb[0], nb[1], err)
+		}
+		info.Builds = append(info.Builds, b)
+		logger.Printf("🔧 [MAIN] ffmpeg build %s: %s (%s), encoders %s", b.Name, b.Version, b.Path, strings.Join(b.Encoders, ", "))
+	}
+
+	// an encoder counts as present when any build has it
+	has := func(e string) bool {
+		if def.encoders[e] {
+			return true
+		}
+		for i := range info.Builds {
+			if info.Builds[i].encoders[e] {
+				return true
+			}
+		}
+		return false
+	}
+	var missing []string
+	for _, e := range strings.Split(envOr("FFMPEG_REQUIRED_ENCODERS", "libx264,aac"), ",") {
+		if e = strings.TrimSpace(e); e != "" && !has(e) {
+			missing = append(missing, e)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("ffmpeg lacks required encoders: %s", strings.Join(missing, ", "))
+	}
+	for _, e := range knownEncoders {
+		if has(e) {
+			info.Encoders = append(info.Encoders, e)
+		} else {
+			info.Missing = append(info.Missing, e)
+		}
+	}
+	ffmpegMu.Lock()
+	ffmpegDetected = info
+	ffmpegMu.Unlock()
+	logger.Printf("🔧 [MAIN] ffmpeg %s (%s), ffprobe %s", info.Version, info.Path, info.ProbeVersion)
+	if len(info.Missing) > 0 {
+		logger.Printf("ℹ️ [MAIN] Encoders not available: %s", strings.Join(info.Missing, ", "))
+	}
+	return nil
+}
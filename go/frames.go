@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Frame extraction (POST /frames)
+// ======================
+
+// POST /frames pulls still images out of an upload ("file") or a stored
+// result ("id", optionally "artifact") and returns them as a ZIP, for
+// galleries and ML datasets. Frames come either at a list of timestamps
+// ("times=1.5,10,62") or every "interval" seconds between "start" and "end".
+// The ZIP also holds frames.json mapping each image to its time.
+
+const (
+	framesMax         = 500
+	framesMinInterval = 0.04 // one frame per 40 ms (25 fps)
+)
+
+type frameRequest struct {
+	Times    []float64
+	Interval float64
+	Start    float64
+	End      float64 // 0 = to the end
+	Format   string  // jpg|png
+	Width    int     // 0 = source width
+}
+
+type frameEntry struct {
+	Name    string  `json:"name"`
+	TimeSec float64 `json:"time_sec"`
+}
+
+func parseFrameRequest(get func(string) string) (frameRequest, error) {
+	fr := frameRequest{Format: "jpg"}
+	seconds := func(name string) (float64, error) {
+		v, err := strconv.ParseFloat(get(name), 64)
+		if err != nil || v < 0 || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("invalid %s %q (seconds)", name, get(name))
+		}
+		return v, nil
+	}
+	times, interval := get("times"), get("interval")
+	switch {
+	case times != "" && interval != "":
+		return fr, errors.New("give times or interval, not both")
+	case times != "":
+		for _, s := range strings.Split(times, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || v < 0 || math.IsInf(v, 0) {
+				return fr, fmt.Errorf("invalid timestamp %q (seconds)", s)
+			}
+			fr.Times = append(fr.Times, v)
+		}
+		if len(fr.Times) > framesMax {
+			return fr, fmt.Errorf("at most %d timestamps", framesMax)
+		}
+	case interval != "":
+		v, err := seconds("interval")
+		if err != nil || v < framesMinInterval {
+			return fr, fmt.Errorf("invalid interval %q (at least %g seconds)", interval, framesMinInterval)
+		}
+		fr.Interval = v
+		if get("start") != "" {
+			if fr.Start, err = seconds("start"); err != nil {
+				return fr, err
+			}
+		}
+		if get("end") != "" {
+			if fr.End, err = seconds("end"); err != nil {
+				return fr, err
+			}
+			if fr.End <= fr.Start {
+				return fr, errors.New("end must be after start")
+			}
+		}
+	default:
+		return fr, errors.New("times or interval required")
+	}
+	switch f := strings.ToLower(get("format")); f {
+	case "":
+	case "jpg", "jpeg":
+		fr.Format = "jpg"
+	case "png":
+		fr.Format = "png"
+	default:
+		return fr, fmt.Errorf("invalid format %q (jpg|png)", f)
+	}
+	if s := get("width"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 16 || n > 7680 {
+			return fr, fmt.Errorf("invalid width %q (16-7680)", s)
+		}
+		fr.Width = n
+	}
+	return fr, nil
+}
+
+// outArgs are the filter and quality arguments for one image.
+func (fr frameRequest) outArgs(filter string) []string {
+	vf := "scale=iw*sar:ih" // square pixels
+	if fr.Width > 0 {
+		vf = fmt.Sprintf("scale=%d:-2", fr.Width)
+	}
+	if filter != "" {
+		vf = filter + "," + vf
+	}
+	args := []string{"-vf", vf}
+	if fr.Format == "jpg" {
+		args = append(args, "-q:v", "2")
+	}
+	return args
+}
+
+func framesHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🖼️ [%s] New frame extraction from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target, _, name, cleanup, ok := requestMedia(w, r, requestID)
+	if !ok {
+		return
+	}
+	defer cleanup()
+	fr, err := parseFrameRequest(r.FormValue)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := probeFile(r.Context(), target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if src.firstStream("video") == nil {
+		http.Error(w, "no video stream", http.StatusUnprocessableEntity)
+		return
+	}
+	dur := src.durationSec()
+
+	dir, err := os.MkdirTemp("", "frames_"+requestID+"_")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if stem == "" {
+		stem = "frame"
+	}
+	var frames []frameEntry
+	var paths []string
+	add := func(path string, t float64) {
+		frames = append(frames, frameEntry{
+			Name:    fmt.Sprintf("%s_%04d_%.3fs.%s", stem, len(frames)+1, t, fr.Format),
+			TimeSec: math.Round(t*1000) / 1000,
+		})
+		paths = append(paths, path)
+	}
+
+	if fr.Times != nil {
+		for i, t := range fr.Times {
+			if dur > 0 && t >= dur {
+				continue // past the end
+			}
+			path := filepath.Join(dir, fmt.Sprintf("t%04d.%s", i, fr.Format))
+			args := append([]string{"-ss", strconv.FormatFloat(t, 'f', 3, 64), "-i", target, "-frames:v", "1"}, fr.outArgs("")...)
+			if err := runFF(r.Context(), append(args, path)...); err != nil {
+				logger.Printf("❌ [%s] Frame at %.3fs: %v", requestID, t, err)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if _, err := os.Stat(path); err == nil {
+				add(path, t)
+			}
+		}
+	} else {
+		if dur > 0 && fr.Start >= dur {
+			http.Error(w, fmt.Sprintf("start %.3fs is past the end of the video (%.3fs)", fr.Start, dur), http.StatusBadRequest)
+			return
+		}
+		end := fr.End
+		if end == 0 || (dur > 0 && end > dur) {
+			end = dur
+		}
+		if end > 0 && int((end-fr.Start)/fr.Interval)+1 > framesMax {
+			http.Error(w, fmt.Sprintf("interval gives more than %d frames; raise it or narrow start/end", framesMax), http.StatusBadRequest)
+			return
+		}
+		args := []string{"-ss", strconv.FormatFloat(fr.Start, 'f', 3, 64)}
+		if end > 0 {
+			args = append(args, "-t", strconv.FormatFloat(end-fr.Start, 'f', 3, 64))
+		}
+		sel := fmt.Sprintf("select='isnan(prev_selected_t)+gte(t-prev_selected_t,%s)'", strconv.FormatFloat(fr.Interval, 'f', -1, 64))
+		args = append(append(args, "-i", target), fr.outArgs(sel)...)
+		args = append(args, "-vsync", "vfr", "-frames:v", strconv.Itoa(framesMax), filepath.Join(dir, "i%05d."+fr.Format))
+		if err := runFF(r.Context(), args...); err != nil {
+			logger.Printf("❌ [%s] Frame extraction: %v", requestID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		got, _ := filepath.Glob(filepath.Join(dir, "i*."+fr.Format))
+		sort.Strings(got)
+		for i, p := range got {
+			add(p, fr.Start+float64(i)*fr.Interval)
+		}
+	}
+	if len(frames) == 0 {
+		http.Error(w, "no frames at those times", http.StatusUnprocessableEntity)
+		return
+	}
+
+	index, _ := json.MarshalIndent(frames, "", "  ")
+	indexPath := filepath.Join(dir, "frames.json")
+	if err := os.WriteFile(indexPath, index, 0o644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	names := make([]string, 0, len(frames)+1)
+	for _, f := range frames {
+		names = append(names, f.Name)
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+sanitizeFilename(stem+"_frames.zip")+`"`)
+	w.Header().Set("X-Frame-Count", strconv.Itoa(len(frames)))
+	if err := writeZip(w, append(names, "frames.json"), append(paths, indexPath)); err != nil {
+		logger.Printf("❌ [%s] Sending frames failed: %v", requestID, err)
+		return
+	}
+	logger.Printf("✅ [%s] Sent %d frames", requestID, len(frames))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Keyframe placement (gop=)
+// ======================
+
+// Only turbo/max trade seekability for speed (fixed 300-frame GOP, no
+// scene-cut detection). Every other mode gets scene-cut keyframes with a
+// GOP of gopDefaultSec (screen and archive a long one, proxy a short one),
+// and gop= overrides both. Archive leaves the minimum keyframe distance to
+// the encoder.
+
+const (
+	gopDefaultSec = 5.0
+	gopScreenSec  = 10.0
+	gopProxySec   = 2.0 // short, so review players and NLEs scrub smoothly
+	gopSpeedFixed = 300 // turbo/max
+	x264SceneCut  = 40  // x264's default threshold, set explicitly
+)
+
+// parseGOP accepts a frame count ("120") or seconds ("2s", "0.5s").
+func parseGOP(s string) (frames int, sec float64, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	if v, ok := strings.CutSuffix(s, "s"); ok {
+		sec, err = strconv.ParseFloat(v, 64)
+		if err != nil || sec <= 0 || sec > 60 {
+			return 0, 0, fmt.Errorf("invalid gop %q (frames or 0.1s-60s)", s)
+		}
+		return 0, sec, nil
+	}
+	frames, err = strconv.Atoi(s)
+	if err != nil || frames < 1 || frames > 3600 {
+		return 0, 0, fmt.Errorf("invalid gop %q (1-3600 frames or seconds like 2s)", s)
+	}
+	return frames, 0, nil
+}
+
+// outputFPS is the frame rate the encoder sees (forced, else source, else 25).
+func outputFPS(o compressOpts) float64 {
+	if o.FPS > 0 {
+		return float64(o.FPS)
+	}
+	if o.Source != nil {
+		if r := o.Source.firstStream("video").frameRate(); r > 0 && r <= 240 {
+			return r
+		}
+	}
+	return 25
+}
+
+// gopArgs returns the keyframe flags for vcodec in the current mode.
+func gopArgs(o compressOpts, vcodec string) []string {
+	switch vcodec {
+	case "copy", "ffv1", "prores_ks":
+		return nil // stream copy / intra-only codecs
+	}
+	fps := outputFPS(o)
+	speed := o.SpeedMode == "turbo" || o.SpeedMode == "max"
+
+	gop := int(math.Round(fps * gopDefaultSec))
+	switch {
+	case o.GOPFrames > 0:
+		gop = o.GOPFrames
+	case o.GOPSec > 0:
+		gop = max(1, int(math.Round(fps*o.GOPSec)))
+	case speed:
+		gop = gopSpeedFixed
+	case o.SpeedMode == "screen":
+		gop = int(math.Round(fps * gopScreenSec))
+	case o.SpeedMode == "archive":
+		gop = int(math.Round(fps * gopArchiveSec))
+	case o.SpeedMode == "proxy":
+		gop = int(math.Round(fps * gopProxySec))
+	}
+	args := []string{"-g", strconv.Itoa(gop)}
+	if o.SplitMaxSec > 0 {
+		// segment_max_sec: a keyframe at every cut so parts are exactly that long
+		args = append(args, "-force_key_frames", "expr:gte(t,n_forced*"+strconv.FormatFloat(o.SplitMaxSec, 'f', -1, 64)+")")
+	} else if o.Package != nil {
+		// format=hls|dash: segments start on a keyframe every segment duration
+		args = append(args, "-force_key_frames", "expr:gte(t,n_forced*"+strconv.FormatFloat(o.Package.SegmentSec, 'f', -1, 64)+")")
+	}
+
+	switch vcodec {
+	case "h264_videotoolbox", "hevc_videotoolbox":
+		return args // no min-keyint / scene-cut controls
+	case "libx264", "libx265":
+		if speed {
+			return append(args, "-keyint_min", strconv.Itoa(gop)) // no scene cuts
+		}
+		if o.SpeedMode == "archive" {
+			return args
+		}
+		// scene cuts may add keyframes, but no closer than a second apart
+		args = append(args, "-keyint_min", strconv.Itoa(min(gop, max(1, int(math.Round(fps))))))
+		if vcodec == "libx264" {
+			args = append(args, "-sc_threshold", strconv.Itoa(x264SceneCut))
+		}
+	}
+	return args
+}
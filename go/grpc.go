@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "videocompress-http/videocompresspb"
+)
+
+// ======================
+// gRPC API (served alongside HTTP when GRPC_PORT is set)
+// ======================
+
+const grpcChunkSize = 256 << 10 // 256 KB
+
+type grpcServer struct {
+	pb.UnimplementedVideoCompressServer
+}
+
+// startGRPC serves the API on port. The returned func drains it: running
+// calls finish, unless ctx ends first.
+func startGRPC(port string) (func(context.Context) error, error) {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, err
+	}
+	s := grpc.NewServer(grpc.MaxRecvMsgSize(4<<20),
+		grpc.UnaryInterceptor(grpcUnaryAuth), grpc.StreamInterceptor(grpcStreamAuth))
+	pb.RegisterVideoCompressServer(s, &grpcServer{})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			logger.Printf("💥 [GRPC] Server error: %v", err)
+		}
+	}()
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			return ctx.Err()
+		}
+	}, nil
+}
+
+// receiveUpload writes chunk payloads to a temp file until the client closes
+// its send side. next returns the chunk of each message or an error.
+func receiveUpload(name string, next func() (*pb.Chunk, error)) (string, error) {
+	base := filepath.Base(name)
+	if base == "" || base == "." || base == "/" {
+		base = "upload.mp4"
+	}
+	inPath := filepath.Join(os.TempDir(), "grpc_"+randID(6)+"_"+base)
+	f, err := os.Create(inPath)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "save error: %v", err)
+	}
+	defer f.Close()
+	var n int64
+	for {
+		c, err := next()
+		if errors.Is(err, io.EOF) {
+			return inPath, nil
+		}
+		if err != nil {
+			os.Remove(inPath)
+			return "", err
+		}
+		if c == nil {
+			continue
+		}
+		if n += int64(len(c.Data)); n > maxUploadSize {
+			os.Remove(inPath)
+			return "", status.Errorf(codes.ResourceExhausted, "upload too large (limit %s)", humanBytes(maxUploadSize))
+		}
+		if _, err := f.Write(c.Data); err != nil {
+			os.Remove(inPath)
+			return "", status.Errorf(codes.Internal, "save error: %v", err)
+		}
+	}
+}
+
+// optsFromProto maps typed options onto the form parser; params carries any
+// other form field (e.g. strip_metadata) verbatim. The job tag may also come
+// as x-job-tag metadata, like the X-Job-Tag header over HTTP.
+func optsFromProto(ctx context.Context, p *pb.CompressOptions) (compressOpts, error) {
+	typed := map[string]string{
+		"codec":      p.GetCodec(),
+		"audio":      p.GetAudio(),
+		"ab":         p.GetAb(),
+		"hw":         p.GetHw(),
+		"outExt":     p.GetOutExt(),
+		"speed":      p.GetSpeed(),
+		"resolution": p.GetResolution(),
+	}
+	if p.GetFps() > 0 {
+		typed["fps"] = strconv.Itoa(int(p.GetFps()))
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-job-tag"); len(v) > 0 {
+			typed["job_tag"] = v[0]
+		}
+	}
+	o, err := parseOptsFrom(func(k string) string {
+		if v := typed[k]; v != "" {
+			return v
+		}
+		return p.GetParams()[k]
+	})
+	if o.KeepInput != nil {
+		o.KeepInput.Owner = grpcClientKey(ctx) // retained inputs are the caller's
+	}
+	return o, err
+}
+
+// grpcAPIKey is the x-api-key metadata value, or "".
+func grpcAPIKey(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-api-key"); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// grpcAuthorize applies the HTTP API key rule to a call: with API_KEYS set,
+// x-api-key metadata must hold one of them. Upload tokens are HTTP-only.
+func grpcAuthorize(ctx context.Context) error {
+	if len(apiKeys()) == 0 || validAPIKey(grpcAPIKey(ctx)) {
+		return nil
+	}
+	return status.Error(codes.Unauthenticated, "API key required")
+}
+
+func grpcUnaryAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := grpcAuthorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcStreamAuth(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := grpcAuthorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// grpcAuthority is the host the client dialled (:authority), or "".
+func grpcAuthority(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(":authority"); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// grpcClientKey mirrors clientKey: a valid x-api-key, else the peer IP.
+func grpcClientKey(ctx context.Context) string {
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	return apiClientKey(grpcAPIKey(ctx), addr)
+}
+
+func jobToProto(j job) *pb.Job {
+	out := &pb.Job{
+		Id:       j.ID,
+		State:    j.State,
+		ResultId: j.ResultID,
+		Error:    j.Error,
+		JobTag:   j.Client.Tag,
+	}
+	if len(j.Client.Metadata) > 0 {
+		out.MetadataJson = string(j.Client.Metadata)
+	}
+	if e := j.Result; e != nil {
+		out.Mode = e.ModeFinal
+		out.ModeDecider = e.ModeDecider
+		out.InputBytes = e.InputBytes
+		out.OutputBytes = e.OutputBytes
+		out.EncodeDurationMs = e.ElapsedMs
+		out.ThroughputMbS = e.Throughput
+	}
+	return out
+}
+
+func sendFile(path string, send func(*pb.Chunk) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return status.Errorf(codes.NotFound, "result file: %v", err)
+	}
+	defer f.Close()
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if err := send(&pb.Chunk{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return status.Errorf(codes.Internal, "read result: %v", err)
+		}
+	}
+}
+
+func (s *grpcServer) Compress(stream pb.VideoCompress_CompressServer) error {
+	requestID := randID(8)
+	logger.Printf("📥 [%s] New gRPC compression stream", requestID)
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	po := first.GetOptions()
+	if po == nil {
+		return status.Error(codes.InvalidArgument, "first message must carry options")
+	}
+	opts, err := optsFromProto(stream.Context(), po)
+	if err != nil {
+		if optsErrorStatus(err) == http.StatusForbidden {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	opts.SourceName = po.GetFilename()
+
+	inPath, err := receiveUpload(po.GetFilename(), func() (*pb.Chunk, error) {
+		m, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		return m.GetChunk(), nil
+	})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(inPath)
+	logger.Printf("📄 [%s] gRPC upload saved: %s", requestID, inPath)
+
+	key := grpcClientKey(stream.Context())
+	ticket := queue.enter()
+	defer queue.leave(ticket)
+	if err := slots.acquire(stream.Context(), key); err != nil {
+		if errors.Is(err, errClientBusy) {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return err
+	}
+	defer slots.release(key)
+	queue.run(ticket)
+
+	j := newJob(opts.Client)
+	j.owner = key
+	j.setCallback(opts.CallbackURL, publicBaseURL(grpcAuthority(stream.Context())))
+	j.start()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e, err := compressFile(j.track(stream.Context()), requestID, inPath, opts)
+		id := ""
+		if err == nil {
+			id = storeResult(requestID, e)
+		}
+		j.finish(id, e, err)
+	}()
+
+	// Stream job updates until the encode reaches a terminal state.
+	for {
+		snap, changed := j.snapshot()
+		if err := stream.Send(&pb.CompressResponse{Payload: &pb.CompressResponse_Job{Job: jobToProto(snap)}}); err != nil {
+			<-done
+			return err
+		}
+		if snap.terminal() {
+			if snap.State == jobFailed {
+				code := codes.Internal
+				if snap.Reject {
+					code = codes.FailedPrecondition
+				}
+				return status.Error(code, snap.Error)
+			}
+			return sendFile(snap.Result.FilePath, func(c *pb.Chunk) error {
+				return stream.Send(&pb.CompressResponse{Payload: &pb.CompressResponse_Chunk{Chunk: c}})
+			})
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			<-done
+			return stream.Context().Err()
+		}
+	}
+}
+
+func (s *grpcServer) Probe(stream pb.VideoCompress_ProbeServer) error {
+	name := ""
+	inPath, err := receiveUpload("probe_input", func() (*pb.Chunk, error) {
+		m, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if n := m.GetFilename(); n != "" {
+			name = n
+		}
+		return m.GetChunk(), nil
+	})
+	if err != nil {
+		return err
+	}
+	defer os.Remove(inPath)
+	logger.Printf("🔎 [GRPC] Probing %s", name)
+
+	p, err := probeFile(stream.Context(), inPath)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp := &pb.ProbeResponse{
+		FormatName:  p.Format.FormatName,
+		DurationSec: p.durationSec(),
+		BitRate:     p.bitRate(),
+		SizeBytes:   p.sizeBytes(),
+	}
+	for _, st := range p.Streams {
+		br, _ := strconv.ParseInt(st.BitRate, 10, 64)
+		resp.Streams = append(resp.Streams, &pb.ProbeStream{
+			Index:        int32(st.Index),
+			CodecType:    st.CodecType,
+			CodecName:    st.CodecName,
+			Width:        int32(st.Width),
+			Height:       int32(st.Height),
+			PixFmt:       st.PixFmt,
+			AvgFrameRate: st.AvgFrameRate,
+			BitRate:      br,
+			Tags:         st.Tags,
+		})
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, req *pb.GetJobRequest) (*pb.Job, error) {
+	j, ok := getJob(req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "job not found")
+	}
+	snap, _ := j.snapshot()
+	return jobToProto(snap), nil
+}
+
+func (s *grpcServer) Download(req *pb.DownloadRequest, stream pb.VideoCompress_DownloadServer) error {
+	e, ok := getResult(req.GetResultId())
+	if !ok {
+		return status.Error(codes.NotFound, "result not found")
+	}
+	return sendFile(e.FilePath, stream.Send)
+}
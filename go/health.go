@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ======================
+// Liveness / readiness (/healthz, /readyz)
+// ======================
+
+// /healthz answers 200 as long as the process serves HTTP. /readyz answers
+// 503 when this instance should not get new jobs: ffmpeg missing, the
+// output or temp disk nearly full, a shutdown in progress, or too many jobs
+// in flight. On SIGTERM the server drains: /readyz turns 503, new requests
+// keep being served for DRAIN_DELAY so load balancers notice, then the
+// server stops accepting connections and waits up to SHUTDOWN_TIMEOUT for
+// running encodes.
+
+var draining atomic.Bool
+
+var errDiskFreeUnsupported = errors.New("disk free space not supported on this platform")
+
+// readyMaxJobs is READY_MAX_JOBS, the in-flight job count at which the
+// instance reports not ready (0 = no limit).
+func readyMaxJobs() int64 {
+	if n := currentConfig().Limits.ReadyMaxJobs; n != nil {
+		return *n
+	}
+	n, err := strconv.ParseInt(envOr("READY_MAX_JOBS", "100"), 10, 64)
+	if err != nil || n < 0 {
+		return 100
+	}
+	return n
+}
+
+// readyMinFreeBytes is READY_MIN_FREE_MB in bytes.
+func readyMinFreeBytes() uint64 {
+	if n := currentConfig().Limits.ReadyMinFreeMB; n != nil {
+		return *n << 20
+	}
+	n, err := strconv.ParseUint(envOr("READY_MIN_FREE_MB", "1024"), 10, 64)
+	if err != nil {
+		n = 1024
+	}
+	return n << 20
+}
+
+func envDuration(k string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(k))
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// readiness runs every check; the map holds "ok" or the failure reason.
+func readiness() (bool, map[string]string) {
+	checks := map[string]string{"ffmpeg": "ok", "disk": "ok", "draining": "ok", "jobs": "ok"}
+	if !isFFmpegAvailable() {
+		checks["ffmpeg"] = ffmpegBin() + " not found"
+	}
+	min := readyMinFreeBytes()
+	for _, dir := range []string{outputDir(), os.TempDir()} {
+		free, err := diskFree(dir)
+		switch {
+		case errors.Is(err, errDiskFreeUnsupported):
+		case err != nil:
+			checks["disk"] = fmt.Sprintf("%s: %v", dir, err)
+		case free < min:
+			checks["disk"] = fmt.Sprintf("%s: %s free, need %s", dir, humanBytes(int64(free)), humanBytes(int64(min)))
+		}
+	}
+	if draining.Load() {
+		checks["draining"] = "shutting down"
+	}
+	if max, n := readyMaxJobs(), int64(queue.depth()); max > 0 && n >= max {
+		checks["jobs"] = fmt.Sprintf("%d jobs in flight (limit %d)", n, max)
+	}
+	for _, v := range checks {
+		if v != "ok" {
+			return false, checks
+		}
+	}
+	return true, checks
+}
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"status": "alive"})
+}
+
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ok, checks := readiness()
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+		logger.Printf("🚧 [READY] Not ready: %v", checks)
+	}
+	writeJSON(w, status, map[string]any{"ready": ok, "jobs_in_flight": queue.depth(), "checks": checks})
+}
+
+// drainOnSignal shuts s (and the extra listeners, e.g. HTTP/3 and gRPC) down
+// gracefully on SIGTERM or interrupt. The returned channel is closed once
+// running requests have finished (or the timeout hit); main waits on it
+// after ListenAndServe returns.
+func drainOnSignal(s *http.Server, extra ...func(context.Context) error) <-chan struct{} {
+	done := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		defer close(done)
+		got := <-sig
+		draining.Store(true)
+		delay := envDuration("DRAIN_DELAY", 5*time.Second)
+		logger.Printf("🛑 [MAIN] %v received; draining (%d jobs in flight), closing listeners in %v", got, queue.depth(), delay)
+		time.Sleep(delay)
+		ctx, cancel := context.WithTimeout(context.Background(), envDuration("SHUTDOWN_TIMEOUT", 10*time.Minute))
+		defer cancel()
+		var listeners sync.WaitGroup
+		for _, stop := range extra {
+			if stop != nil {
+				listeners.Add(1)
+				go func() {
+					defer listeners.Done()
+					if err := stop(ctx); err != nil {
+						logger.Printf("⚠️ [MAIN] Listener did not drain cleanly: %v", err)
+					}
+				}()
+			}
+		}
+		err := s.Shutdown(ctx)
+		listeners.Wait() // e.g. gRPC streams still encoding
+		if err != nil {
+			logger.Printf("⚠️ [MAIN] Shutdown did not finish cleanly: %v", err)
+			s.Close()
+			return
+		}
+		if n := queue.depth(); n > 0 {
+			logger.Printf("⏳ [MAIN] Waiting for %d background jobs", n)
+		}
+		if err := waitBackground(ctx); err != nil {
+			logger.Printf("⚠️ [MAIN] Background jobs still running at shutdown: %v", err)
+			return
+		}
+		logger.Printf("👋 [MAIN] Drained, all requests finished")
+	}()
+	return done
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ======================
+// Encode history + adaptive speed=ai decider
+// ======================
+
+// Every finished encode is recorded with its mode, sizes and wall-clock
+// time. With OUTPUT_DIR the history is appended to
+// OUTPUT_DIR/.history-<hostname>.jsonl (per host, since speed depends on the
+// machine) and reloaded at startup; otherwise it lives in memory.
+//
+// The "adaptive" decider starts from the size rules and then looks at how
+// long recent encodes of similar size (0.5x-2x, same codec and hardware)
+// took in that mode. While the estimate for this file exceeds
+// AI_TARGET_SECONDS it steps to the next faster mode that history says
+// fits, or that has no history yet.
+
+const (
+	historyMax        = 2000 // records kept in memory
+	historyMinSamples = 3    // samples needed before a mode's estimate is trusted
+)
+
+// adaptiveLadder is the order the adaptive decider speeds up through. Modes
+// that also change the resolution (turbo, max) are left out.
+var adaptiveLadder = []string{"quality", "balanced", "fast", "super_fast", "ultra_fast"}
+
+type historyRecord struct {
+	Time        time.Time `json:"time"`
+	Mode        string    `json:"mode"`
+	Codec       string    `json:"codec"`
+	HW          string    `json:"hw"`
+	InputBytes  int64     `json:"input_bytes"`
+	OutputBytes int64     `json:"output_bytes"`
+	ElapsedMs   int64     `json:"elapsed_ms"`
+}
+
+func (h historyRecord) throughput() float64 {
+	if h.ElapsedMs <= 0 {
+		return 0
+	}
+	return float64(h.InputBytes) / (1 << 20) / (float64(h.ElapsedMs) / 1000)
+}
+
+var (
+	historyMu    sync.Mutex
+	history      []historyRecord
+	historyLines int // records in the history file
+)
+
+func historyPath() string {
+	if !persistentOutputs() {
+		return ""
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "local"
+	}
+	return filepath.Join(outputDir(), ".history-"+sanitizeFilename(host)+".jsonl")
+}
+
+// loadHistory reads the persisted history of this host.
+func loadHistory() error {
+	p := historyPath()
+	if p == "" {
+		return nil
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var recs []historyRecord
+	lines := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines++
+		var h historyRecord
+		if json.Unmarshal(sc.Bytes(), &h) == nil && h.Mode != "" {
+			recs = append(recs, h)
+		}
+	}
+	if len(recs) > historyMax {
+		recs = recs[len(recs)-historyMax:]
+	}
+	historyMu.Lock()
+	history, historyLines = recs, lines
+	historyMu.Unlock()
+	logger.Printf("📚 [MAIN] Encode history: %d records from %s", len(recs), p)
+	return nil
+}
+
+// recordHistory adds a finished encode. The file is rewritten with just
+// the in-memory window once it holds twice as many records.
+func recordHistory(h historyRecord) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = append(history, h)
+	if len(history) > historyMax {
+		history = slices.Clone(history[len(history)-historyMax:])
+	}
+	p := historyPath()
+	if p == "" {
+		return
+	}
+	var err error
+	if historyLines >= 2*historyMax {
+		err = writeHistory(p, history)
+		historyLines = len(history)
+	} else {
+		err = appendHistory(p, h)
+		historyLines++
+	}
+	if err != nil {
+		logger.Printf("⚠️ [HISTORY] Could not persist encode history: %v", err)
+	}
+}
+
+func appendHistory(p string, h historyRecord) error {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, _ := json.Marshal(h)
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func writeHistory(p string, recs []historyRecord) error {
+	tmp := p + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, h := range recs {
+		line, _ := json.Marshal(h)
+		w.Write(append(line, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+// similarThroughputs returns the throughputs (input MB/s) of encodes in
+// mode with a comparable input, newest first, at most 20.
+func similarThroughputs(mode string, in decisionInput) []float64 {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	var out []float64
+	for i := len(history) - 1; i >= 0 && len(out) < 20; i-- {
+		h := history[i]
+		if h.Mode != mode || h.Codec != in.Codec || h.HW != in.HW {
+			continue
+		}
+		if h.InputBytes*2 < in.InputBytes || h.InputBytes > in.InputBytes*2 {
+			continue
+		}
+		if t := h.throughput(); t > 0 {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func median(v []float64) float64 {
+	s := slices.Clone(v)
+	sort.Float64s(s)
+	return s[len(s)/2]
+}
+
+// estimateSeconds predicts the encode time of in for mode; ok is false
+// without enough history.
+func estimateSeconds(mode string, in decisionInput) (float64, bool) {
+	t := similarThroughputs(mode, in)
+	if len(t) < historyMinSamples {
+		return 0, false
+	}
+	return float64(in.InputBytes) / (1 << 20) / median(t), true
+}
+
+// adaptiveTarget is AI_TARGET_SECONDS, the wall-clock budget per encode.
+func adaptiveTarget() float64 {
+	v, err := strconv.ParseFloat(os.Getenv("AI_TARGET_SECONDS"), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return v
+}
+
+// decideAdaptive is the size rule, sped up until history says the encode
+// fits the target.
+func decideAdaptive(ctx context.Context, in decisionInput) (string, error) {
+	mode, _ := decideBySize(ctx, in)
+	target := adaptiveTarget()
+	if target == 0 {
+		return mode, nil
+	}
+	i := slices.Index(adaptiveLadder, mode)
+	if i < 0 {
+		return mode, nil
+	}
+	for ; i < len(adaptiveLadder); i++ {
+		mode = adaptiveLadder[i]
+		est, ok := estimateSeconds(mode, in)
+		if !ok || est <= target {
+			return mode, nil
+		}
+	}
+	return mode, nil // even the fastest mode is over budget
+}
+
+type historySummary struct {
+	Mode       string  `json:"mode"`
+	Samples    int     `json:"samples"`
+	MedianMBps float64 `json:"median_mb_s"`
+	MedianPct  float64 `json:"median_output_pct"` // output size as % of input
+}
+
+// summarizeHistory groups the history by mode for /health.
+func summarizeHistory() []historySummary {
+	historyMu.Lock()
+	byMode := map[string][]historyRecord{}
+	for _, h := range history {
+		byMode[h.Mode] = append(byMode[h.Mode], h)
+	}
+	historyMu.Unlock()
+	out := make([]historySummary, 0, len(byMode))
+	for mode, recs := range byMode {
+		var tp, pct []float64
+		for _, h := range recs {
+			tp = append(tp, h.throughput())
+			if h.InputBytes > 0 {
+				pct = append(pct, float64(h.OutputBytes)/float64(h.InputBytes)*100)
+			}
+		}
+		s := historySummary{Mode: mode, Samples: len(recs), MedianMBps: math.Round(median(tp)*100) / 100}
+		if len(pct) > 0 {
+			s.MedianPct = math.Round(median(pct)*10) / 10
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Mode < out[j].Mode })
+	return out
+}
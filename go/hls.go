@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// HLS packaging (format=hls)
+// ======================
+
+// An HLS package is a VOD playlist (index.m3u8) and its segments. Segments
+// are MPEG-TS (seg_00000.ts) for H.264 and fragmented MP4 (init.mp4 +
+// seg_00000.m4s) for H.265 and AV1, which Apple players only accept as
+// fMP4; hls_segment=ts|fmp4 picks explicitly. With renditions each one gets
+// <name>/index.m3u8 under a master.m3u8.
+
+// hlsSegmentCodecs are the video codecs each segment type can carry.
+var hlsSegmentCodecs = map[string]map[string]bool{
+	"ts":   {"h264": true, "copy": true},
+	"fmp4": {"h264": true, "h265": true, "av1": true, "copy": true},
+}
+
+// settlePackage picks the HLS segment type for the codec finally chosen and
+// returns a note for the warnings, or "".
+func (o *compressOpts) settlePackage() string {
+	if o.Package == nil || o.Package.Format != "hls" {
+		return ""
+	}
+	codec := strings.ToLower(o.Codec)
+	switch {
+	case o.Package.Segment == "":
+		o.Package.Segment = "ts"
+		if codec == "h265" || codec == "av1" {
+			o.Package.Segment = "fmp4"
+		}
+	case !hlsSegmentCodecs[o.Package.Segment][codec]:
+		o.Package.Segment = "fmp4"
+		return fmt.Sprintf("hls_segment=fmp4: ts segments cannot carry %s", codec)
+	}
+	return ""
+}
+
+// segmentHLS cuts src into dir/index.m3u8 and its segments.
+func segmentHLS(ctx context.Context, src, dir string, o compressOpts) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	segExt := ".ts"
+	args := []string{"-i", src, "-map", "0", "-c", "copy", "-f", "hls",
+		"-hls_time", strconv.FormatFloat(o.Package.SegmentSec, 'f', -1, 64),
+		"-hls_playlist_type", "vod", "-hls_flags", "independent_segments"}
+	if o.Package.Segment == "fmp4" {
+		segExt = ".m4s"
+		args = append(args, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", "init.mp4")
+	}
+	args = append(args, "-hls_segment_filename", filepath.Join(dir, "seg_%05d"+segExt), filepath.Join(dir, "index.m3u8"))
+	if err := runFF(ctx, args...); err != nil {
+		return fmt.Errorf("packaging HLS: %w", err)
+	}
+	return nil
+}
+
+// packageHLS segments outPath into dir, or each rendition into its own
+// directory under a master.m3u8.
+func packageHLS(ctx context.Context, outPath, dir string, rends []renditionInfo, o compressOpts) error {
+	if len(rends) == 0 {
+		return segmentHLS(ctx, outPath, dir, o)
+	}
+	for _, r := range rends {
+		if err := segmentHLS(ctx, r.path, filepath.Join(dir, r.Name), o); err != nil {
+			return err
+		}
+	}
+	return writeMasterPlaylist(dir, rends, o)
+}
+
+// writeMasterPlaylist lists the renditions' playlists, highest first, with
+// the peak and average segment bitrates players pick a variant by.
+func writeMasterPlaylist(dir string, rends []renditionInfo, o compressOpts) error {
+	var b strings.Builder
+	version := 3
+	if o.Package.Segment == "fmp4" {
+		version = 7
+	}
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:%d\n#EXT-X-INDEPENDENT-SEGMENTS\n", version)
+	for _, r := range rends {
+		peak, avg, err := hlsBandwidth(filepath.Join(dir, r.Name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%dx%d\n%s/index.m3u8\n",
+			peak, avg, r.Width, r.Height, r.Name)
+	}
+	return os.WriteFile(filepath.Join(dir, "master.m3u8"), []byte(b.String()), 0o644)
+}
+
+// hlsBandwidth reads a variant playlist and returns the peak and average
+// segment bitrates in bits per second.
+func hlsBandwidth(dir string) (int64, int64, error) {
+	pl, err := os.ReadFile(filepath.Join(dir, "index.m3u8"))
+	if err != nil {
+		return 0, 0, err
+	}
+	var peak, bits int64
+	var total, dur float64
+	for _, line := range strings.Split(string(pl), "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+			v, _, _ = strings.Cut(v, ",")
+			dur, _ = strconv.ParseFloat(v, 64)
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") || dur <= 0 {
+			continue
+		}
+		st, err := os.Stat(filepath.Join(dir, line))
+		if err != nil {
+			return 0, 0, err
+		}
+		peak = max(peak, int64(float64(st.Size()*8)/dur))
+		bits += st.Size() * 8
+		total += dur
+		dur = 0
+	}
+	if total <= 0 {
+		return 0, 0, errors.New("packaging HLS: empty variant playlist")
+	}
+	return peak, int64(float64(bits) / total), nil
+}
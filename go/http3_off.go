@@ -0,0 +1,18 @@
+//go:build !http3
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type http3Server struct {
+	advertise func(http.Handler) http.Handler
+	shutdown  func(context.Context) error
+}
+
+func startHTTP3(string, http.Handler, string, string) (*http3Server, error) {
+	return nil, errors.New("this binary was built without HTTP/3 support (rebuild with -tags http3)")
+}
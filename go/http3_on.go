@@ -0,0 +1,48 @@
+//go:build http3
+
+// Building with HTTP/3 pulls in the QUIC implementation (quic-go, required
+// in go.mod):
+//
+//	go build -tags http3
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+type http3Server struct {
+	advertise func(http.Handler) http.Handler
+	shutdown  func(context.Context) error
+}
+
+func startHTTP3(addr string, h http.Handler, cert, key string) (*http3Server, error) {
+	s := &http3.Server{Addr: addr, Handler: h}
+	errc := make(chan error, 1)
+	go func() { errc <- s.ListenAndServeTLS(cert, key) }()
+	select {
+	case err := <-errc: // failed to bind
+		return nil, err
+	case <-time.After(200 * time.Millisecond):
+	}
+	go func() {
+		if err := <-errc; err != nil && err != http.ErrServerClosed {
+			logger.Printf("💥 [MAIN] HTTP/3 server error: %v", err)
+		}
+	}()
+	return &http3Server{
+		advertise: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.ProtoMajor < 3 {
+					s.SetQUICHeaders(w.Header()) // Alt-Svc: h3=":port"
+				}
+				next.ServeHTTP(w, r)
+			})
+		},
+		shutdown: s.Shutdown,
+	}, nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// UI localization
+// ======================
+
+// The upload and result pages look their strings up by key. English and
+// Spanish are built in; LOCALES_DIR (default BRANDING_DIR/locales) may hold
+// <lang>.json files with flat key → text maps that add languages or
+// override built-in strings. Missing keys fall back to English.
+//
+// The language comes from ?lang= (remembered in a cookie), then the cookie,
+// then Accept-Language, then DEFAULT_LANG.
+
+const langCookie = "lang"
+
+var uiStrings = map[string]map[string]string{
+	"en": {
+		"lang.name":            "English",
+		"lang.label":           "Language",
+		"nav.api_docs":         "📖 View API Documentation",
+		"upload.file":          "Video file",
+		"upload.mode":          "Mode",
+		"upload.mode.ai":       "AI (auto from the video)",
+		"upload.mode.turbo":    "TURBO (very fast, 720p long-edge)",
+		"upload.mode.max":      "MAX (very fast, 480p long-edge)",
+		"upload.mode.proxy":    "Proxy (tiny 240p/15 fps preview)",
+		"upload.mode.ultra":    "Ultra Fast",
+		"upload.mode.super":    "Super Fast",
+		"upload.mode.fast":     "Fast",
+		"upload.mode.balanced": "Balanced",
+		"upload.mode.quality":  "Quality",
+		"upload.mode.screen":   "Screen recording",
+		"upload.mode.lossless": "Lossless (archival, large)",
+		"upload.mode.archive":  "Archive (slow H.265, long-term storage)",
+		"upload.mode.hint":     "AI weighs resolution, length and bitrate.",
+		"upload.content":       "Content",
+		"upload.content.any":   "Generic",
+		"upload.content.film":  "Film / camera",
+		"upload.content.anim":  "Animation",
+		"upload.content.cast":  "Screencast",
+		"upload.content.sport": "Sports / fast motion",
+		"upload.resolution":    "Resolution",
+		"upload.original":      "Original",
+		"upload.advanced":      "Advanced",
+		"upload.codec":         "Video codec",
+		"upload.codec.copy":    "Copy video stream",
+		"upload.bit_depth":     "Bit depth",
+		"upload.auto":          "Auto",
+		"upload.autocrop":      "Black bars",
+		"upload.keep":          "Keep",
+		"upload.autocrop.on":   "Detect and crop",
+		"upload.trim_dead":     "Dead air",
+		"upload.trim.both":     "Trim black + silent ends",
+		"upload.trim.silence":  "Trim silent ends",
+		"upload.trim.black":    "Trim black ends",
+		"upload.max_landscape": "Max landscape",
+		"upload.max_portrait":  "Max portrait",
+		"upload.no_cap":        "No cap",
+		"upload.alpha":         "Transparency",
+		"upload.alpha.auto":    "Keep if the format allows",
+		"upload.alpha.keep":    "Keep (switch to VP9/WebM or ProRes)",
+		"upload.alpha.drop":    "Flatten",
+		"upload.grain":         "AV1 film grain",
+		"upload.grain.auto":    "Auto (film content only)",
+		"upload.grain.off":     "Off",
+		"upload.grain.light":   "Light",
+		"upload.grain.medium":  "Medium",
+		"upload.grain.heavy":   "Heavy",
+		"upload.hw":            "Hardware",
+		"upload.hw.none":       "CPU only",
+		"upload.hwdecode":      "Hardware decode",
+		"upload.hwdecode.hw":   "Follow hardware",
+		"upload.hwdecode.cpu":  "CPU decode",
+		"upload.audio":         "Audio codec",
+		"upload.audio.copy":    "Copy audio",
+		"upload.ext":           "Output extension",
+		"upload.device":        "Must play on",
+		"upload.device.any":    "Anything",
+		"upload.device.web":    "Web browsers",
+		"upload.submit":        "Compress",
+		"upload.curl":          "cURL (API, returns file bytes + timing headers)",
+		"progress.upload":      "Uploading",
+		"progress.encode":      "Compressing",
+		"progress.done":        "✅ Done.",
+		"progress.download":    "Download",
+		"progress.failed":      "❌ Failed",
+		"result.page_title":    "Compression result",
+		"result.title":         "✅ Compression complete",
+		"result.mode":          "Mode",
+		"result.decided_by":    "decided by",
+		"result.time":          "Time taken",
+		"result.throughput":    "Throughput",
+		"result.input":         "Input size",
+		"result.output":        "Output size",
+		"result.bytes":         "bytes",
+		"result.resolution":    "Resolution",
+		"result.codec":         "Video codec",
+		"result.audio":         "Audio codec",
+		"result.hw":            "Hardware",
+		"result.download":      "⬇️ Download compressed file",
+		"result.api":           "API example",
+		"result.poster":        "Poster frame",
+		"result.poster_pick":   "🖼️ Use this frame as poster",
+		"result.poster_failed": "❌ Could not set the poster",
+	},
+	"es": {
+		"lang.name":            "Español",
+		"lang.label":           "Idioma",
+		"nav.api_docs":         "📖 Ver la documentación de la API",
+		"upload.file":          "Archivo de vídeo",
+		"upload.mode":          "Modo",
+		"upload.mode.ai":       "IA (automático según el vídeo)",
+		"upload.mode.turbo":    "TURBO (muy rápido, 720p lado largo)",
+		"upload.mode.max":      "MAX (muy rápido, 480p lado largo)",
+		"upload.mode.proxy":    "Proxy (vista previa mínima 240p/15 fps)",
+		"upload.mode.ultra":    "Ultrarrápido",
+		"upload.mode.super":    "Superrápido",
+		"upload.mode.fast":     "Rápido",
+		"upload.mode.balanced": "Equilibrado",
+		"upload.mode.quality":  "Calidad",
+		"upload.mode.screen":   "Grabación de pantalla",
+		"upload.mode.lossless": "Sin pérdida (archivo, grande)",
+		"upload.mode.archive":  "Archivo (H.265 lento, almacenamiento a largo plazo)",
+		"upload.mode.hint":     "La IA pondera resolución, duración y bitrate.",
+		"upload.content":       "Contenido",
+		"upload.content.any":   "Genérico",
+		"upload.content.film":  "Película / cámara",
+		"upload.content.anim":  "Animación",
+		"upload.content.cast":  "Screencast",
+		"upload.content.sport": "Deportes / movimiento rápido",
+		"upload.resolution":    "Resolución",
+		"upload.original":      "Original",
+		"upload.advanced":      "Avanzado",
+		"upload.codec":         "Códec de vídeo",
+		"upload.codec.copy":    "Copiar la pista de vídeo",
+		"upload.bit_depth":     "Profundidad de bits",
+		"upload.auto":          "Automático",
+		"upload.autocrop":      "Bandas negras",
+		"upload.keep":          "Mantener",
+		"upload.autocrop.on":   "Detectar y recortar",
+		"upload.trim_dead":     "Tiempo muerto",
+		"upload.trim.both":     "Recortar extremos negros y en silencio",
+		"upload.trim.silence":  "Recortar extremos en silencio",
+		"upload.trim.black":    "Recortar extremos negros",
+		"upload.max_landscape": "Máximo horizontal",
+		"upload.max_portrait":  "Máximo vertical",
+		"upload.no_cap":        "Sin límite",
+		"upload.alpha":         "Transparencia",
+		"upload.alpha.auto":    "Mantener si el formato lo permite",
+		"upload.alpha.keep":    "Mantener (cambia a VP9/WebM o ProRes)",
+		"upload.alpha.drop":    "Aplanar",
+		"upload.grain":         "Grano de película AV1",
+		"upload.grain.auto":    "Automático (solo contenido de cine)",
+		"upload.grain.off":     "Desactivado",
+		"upload.grain.light":   "Ligero",
+		"upload.grain.medium":  "Medio",
+		"upload.grain.heavy":   "Fuerte",
+		"upload.hw":            "Hardware",
+		"upload.hw.none":       "Solo CPU",
+		"upload.hwdecode":      "Decodificación por hardware",
+		"upload.hwdecode.hw":   "Igual que el hardware",
+		"upload.hwdecode.cpu":  "Decodificar en CPU",
+		"upload.audio":         "Códec de audio",
+		"upload.audio.copy":    "Copiar el audio",
+		"upload.ext":           "Extensión de salida",
+		"upload.device":        "Debe reproducirse en",
+		"upload.device.any":    "Cualquier cosa",
+		"upload.device.web":    "Navegadores web",
+		"upload.submit":        "Comprimir",
+		"upload.curl":          "cURL (API, devuelve el archivo y cabeceras de tiempos)",
+		"progress.upload":      "Subiendo",
+		"progress.encode":      "Comprimiendo",
+		"progress.done":        "✅ Listo.",
+		"progress.download":    "Descargar",
+		"progress.failed":      "❌ Error",
+		"result.page_title":    "Resultado de la compresión",
+		"result.title":         "✅ Compresión terminada",
+		"result.mode":          "Modo",
+		"result.decided_by":    "decidido por",
+		"result.time":          "Tiempo",
+		"result.throughput":    "Rendimiento",
+		"result.input":         "Tamaño de entrada",
+		"result.output":        "Tamaño de salida",
+		"result.bytes":         "bytes",
+		"result.resolution":    "Resolución",
+		"result.codec":         "Códec de vídeo",
+		"result.audio":         "Códec de audio",
+		"result.hw":            "Hardware",
+		"result.download":      "⬇️ Descargar el archivo comprimido",
+		"result.api":           "Ejemplo de API",
+		"result.poster":        "Fotograma de portada",
+		"result.poster_pick":   "🖼️ Usar este fotograma como portada",
+		"result.poster_failed": "❌ No se pudo guardar la portada",
+	},
+}
+
+// uiText is the string table for one request, exposed to templates as .L
+// ({{.L.T "upload.file"}}, {{.L.Lang}}).
+type uiText struct {
+	Lang string
+}
+
+// T returns the text for key, falling back to English and then the key.
+func (t uiText) T(key string) string {
+	if s, ok := uiStrings[t.Lang][key]; ok {
+		return s
+	}
+	if s, ok := uiStrings["en"][key]; ok {
+		return s
+	}
+	return key
+}
+
+type uiLang struct {
+	Code string
+	Name string
+}
+
+// uiLangs lists the available languages for the selector.
+func uiLangs() []uiLang {
+	var out []uiLang
+	for code := range uiStrings {
+		out = append(out, uiLang{Code: code, Name: uiText{Lang: code}.T("lang.name")})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+func localesDir() string {
+	if dir := os.Getenv("LOCALES_DIR"); dir != "" {
+		return dir
+	}
+	if dir := brandingDir(); dir != "" {
+		return filepath.Join(dir, "locales")
+	}
+	return ""
+}
+
+// loadLocales merges LOCALES_DIR/*.json into the built-in tables.
+func loadLocales() error {
+	dir := localesDir()
+	if dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var m map[string]string
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("locale %s: %w", filepath.Base(f), err)
+		}
+		code := strings.ToLower(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if uiStrings[code] == nil {
+			uiStrings[code] = map[string]string{}
+		}
+		for k, v := range m {
+			uiStrings[code][k] = v
+		}
+		logger.Printf("🌍 [MAIN] Locale %s loaded (%d strings)", code, len(m))
+	}
+	return nil
+}
+
+// matchLang maps a tag like "es-MX" to a loaded language ("es-mx", then "es").
+func matchLang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return ""
+	}
+	if _, ok := uiStrings[tag]; ok {
+		return tag
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if _, ok := uiStrings[base]; ok {
+			return base
+		}
+	}
+	return ""
+}
+
+// acceptLanguage picks the best loaded language from an Accept-Language
+// header, honouring q-values.
+func acceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if code := matchLang(tag); code != "" && q > bestQ {
+			best, bestQ = code, q
+		}
+	}
+	return best
+}
+
+// requestLang resolves the UI language for r.
+func requestLang(r *http.Request) string {
+	if code := matchLang(r.FormValue("lang")); code != "" {
+		return code
+	}
+	if c, err := r.Cookie(langCookie); err == nil {
+		if code := matchLang(c.Value); code != "" {
+			return code
+		}
+	}
+	if code := acceptLanguage(r.Header.Get("Accept-Language")); code != "" {
+		return code
+	}
+	if code := matchLang(os.Getenv("DEFAULT_LANG")); code != "" {
+		return code
+	}
+	return "en"
+}
+
+// rememberLang stores an explicit ?lang= choice in a cookie.
+func rememberLang(w http.ResponseWriter, r *http.Request, code string) {
+	if r.URL.Query().Get("lang") == "" {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: langCookie, Value: code, Path: "/",
+		MaxAge: int((365 * 24 * time.Hour).Seconds()), SameSite: http.SameSiteLaxMode})
+}
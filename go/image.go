@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Image compression (POST /compress-image)
+// ======================
+
+// imageFormats maps the accepted format names to their output extension.
+var imageFormats = map[string]string{
+	"jpeg": ".jpg",
+	"jpg":  ".jpg",
+	"png":  ".png",
+	"webp": ".webp",
+	"avif": ".avif",
+}
+
+// imageEncoderArgs returns the encoder flags for a target format at a
+// 1-100 quality (higher is better).
+func imageEncoderArgs(format string, quality int) []string {
+	switch format {
+	case "png":
+		// lossless: quality only trades CPU for size
+		return []string{"-c:v", "png", "-compression_level", "9", "-pred", "mixed"}
+	case "webp":
+		return []string{"-c:v", "libwebp", "-quality", strconv.Itoa(quality), "-compression_level", "4"}
+	case "avif":
+		crf := 63 - quality*63/100
+		return []string{"-c:v", "libaom-av1", "-still-picture", "1", "-crf", strconv.Itoa(crf),
+			"-cpu-used", "6", "-pix_fmt", "yuv420p", "-f", "avif"}
+	default: // jpeg: -q:v 2 (best) .. 31 (worst)
+		q := 2 + (100-quality)*29/99
+		return []string{"-c:v", "mjpeg", "-q:v", strconv.Itoa(q), "-pix_fmt", "yuvj420p"}
+	}
+}
+
+func compressImageHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🖼️ [%s] New image compression request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseUploadForm(w, r, requestID) {
+		return
+	}
+	inPath, inputBytes, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file field required", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(inPath)
+
+	client, err := clientMetaFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nameTmpl, err := formOutputName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := strings.ToLower(r.FormValue("format"))
+	if format == "" {
+		// keep the input format when it is one we can write
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(inPath)), ".")
+		if _, ok := imageFormats[format]; !ok {
+			format = "jpeg"
+		}
+	}
+	ext, ok := imageFormats[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid format %q (jpeg|png|webp|avif)", format), http.StatusBadRequest)
+		return
+	}
+	quality := 80
+	if v := r.FormValue("quality"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "quality must be 1-100", http.StatusBadRequest)
+			return
+		}
+		quality = n
+	}
+	maxDim := func(key string) (int, error) {
+		v := r.FormValue(key)
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 16384 {
+			return 0, fmt.Errorf("%s must be 1-16384", key)
+		}
+		return n, nil
+	}
+	maxW, err := maxDim("max_width")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	maxH, err := maxDim("max_height")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outPath := outputPath(requestID, withExt(filepath.Base(inPath), "_compressed"+ext))
+	resolution := "original"
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", inPath}
+	if maxW > 0 || maxH > 0 {
+		resolution = fmt.Sprintf("max %dx%d", maxW, maxH)
+		if maxW == 0 {
+			maxW = 16384
+		}
+		if maxH == 0 {
+			maxH = 16384
+		}
+		// downscale only, keeping aspect ratio
+		args = append(args, "-vf", fmt.Sprintf(
+			`scale=w='min(iw\,%d)':h='min(ih\,%d)':force_original_aspect_ratio=decrease`, maxW, maxH))
+	}
+	args = append(args, "-map_metadata", "-1", "-frames:v", "1", "-update", "1")
+	args = append(args, imageEncoderArgs(format, quality)...)
+	args = append(args, outPath)
+
+	logger.Printf("⚙️ [%s] FFmpeg command: ffmpeg %s", requestID, strings.Join(args, " "))
+	start := time.Now()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(r.Context(), ffmpegFor(args), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(outPath)
+		logger.Printf("❌ [%s] Image compression failed: %v", requestID, err)
+		http.Error(w, "compression failed: "+lastLine(stderr.String()), 500)
+		return
+	}
+	st, err := os.Stat(outPath)
+	if err != nil || st.Size() == 0 {
+		http.Error(w, errOutputInvalid.Error(), 500)
+		return
+	}
+	elapsed := time.Since(start)
+
+	entry := &resultEntry{
+		FilePath:    outPath,
+		ModeFinal:   "image",
+		ModeDecider: "manual",
+		InputBytes:  inputBytes,
+		OutputBytes: st.Size(),
+		Resolution:  resolution,
+		Codec:       format,
+		Audio:       "none",
+		HW:          "none",
+		ElapsedMs:   elapsed.Milliseconds(),
+		Client:      client,
+		Name:        renderOutputName(outputNameTemplate(nameTmpl), r.MultipartForm.File["file"][0].Filename, "image", resolution, format, ext),
+	}
+	if sec := elapsed.Seconds(); sec > 0 {
+		entry.Throughput = (float64(inputBytes) / (1024 * 1024)) / sec
+	}
+	logger.Printf("✅ [%s] Image compressed: %s → %s", requestID, humanBytes(inputBytes), humanBytes(st.Size()))
+	serveResultFile(w, r, requestID, entry)
+}
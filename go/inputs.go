@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Retained inputs (keep_input=1, /inputs)
+// ======================
+
+// Uploads are deleted as soon as their encode finishes. keep_input=1 keeps
+// the upload for keep_input_ttl (default KEEP_INPUT_TTL, 24h; at most
+// KEEP_INPUT_MAX_TTL, 7 days) under an input ID, returned as X-Input-Id and
+// "input_id" in /meta, so it can be probed (POST /probe input_id=...), used
+// for thumbnails and frames, and re-run without uploading it again. POST
+// /compress with input_id= in place of the file encodes it again with new
+// options ("same source, three outputs"); input_id may also be a finished
+// job whose original was kept (KEEP_ORIGINALS=1).
+// Retained inputs count against a per-client quota (KEEP_INPUT_QUOTA, e.g.
+// 20GB, unset = unlimited; the client is the API key or IP as for
+// CLIENT_MAX_JOBS, and inputs kept with an upload token belong to the key
+// that issued it). An upload that would exceed it is not kept and the
+// result carries a warning; the encode itself is unaffected.
+//
+//	GET    /inputs       the caller's retained inputs and quota usage
+//	GET    /inputs/{id}  one input
+//	DELETE /inputs/{id}  release it early
+//
+// Inputs are only visible to the client that kept them. With OUTPUT_DIR
+// they are stored there and survive restarts; the purge_inputs task (every
+// 5 minutes by default) deletes expired ones.
+
+const (
+	keepInputDefaultTTL = 24 * time.Hour
+	keepInputMaxTTL     = 7 * 24 * time.Hour
+)
+
+// keepInputSpec is a keep_input=1 request.
+type keepInputSpec struct {
+	TTL   time.Duration
+	Owner string // clientKey of the request
+}
+
+// keptInput is a retained upload.
+type keptInput struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Bytes   int64     `json:"size_bytes"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	Owner   string    `json:"owner"`
+	Path    string    `json:"path"`
+}
+
+var (
+	inputsMu sync.Mutex
+	inputs   = map[string]*keptInput{}
+)
+
+func parseKeepInput(on, ttl string) (*keepInputSpec, error) {
+	switch strings.ToLower(on) {
+	case "", "0", "false", "no":
+		if ttl != "" {
+			return nil, errors.New("keep_input_ttl needs keep_input=1")
+		}
+		return nil, nil
+	case "1", "true", "yes":
+	default:
+		return nil, fmt.Errorf("invalid keep_input %q (0|1)", on)
+	}
+	k := &keepInputSpec{TTL: envDuration("KEEP_INPUT_TTL", keepInputDefaultTTL)}
+	maxTTL := envDuration("KEEP_INPUT_MAX_TTL", keepInputMaxTTL)
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 || d > maxTTL {
+			return nil, fmt.Errorf("invalid keep_input_ttl %q (up to %v)", ttl, maxTTL)
+		}
+		k.TTL = d
+	}
+	k.TTL = min(k.TTL, maxTTL)
+	return k, nil
+}
+
+// keepInputQuota is the per-client byte quota (KEEP_INPUT_QUOTA, 0 = none).
+func keepInputQuota() int64 {
+	s := os.Getenv("KEEP_INPUT_QUOTA")
+	if s == "" {
+		return 0
+	}
+	n, err := parseByteSize(s)
+	if err != nil {
+		logger.Printf("⚠️ [INPUTS] Ignoring KEEP_INPUT_QUOTA: %v", err)
+		return 0
+	}
+	return n
+}
+
+func inputIndexDir() string {
+	return filepath.Join(outputDir(), ".inputs")
+}
+
+// keepInput links the upload at inPath into the output dir and registers it
+// for k.TTL. It returns the input, or an error (quota) to report as a
+// warning.
+func keepInput(requestID, inPath, name string, k *keepInputSpec) (*keptInput, error) {
+	st, err := os.Stat(inPath)
+	if err != nil {
+		return nil, err
+	}
+	purgeExpiredInputs()
+	if name == "" {
+		name = filepath.Base(inPath)
+	}
+	now := time.Now()
+	in := &keptInput{
+		ID:      randID(12),
+		Name:    filepath.Base(name),
+		Bytes:   st.Size(),
+		Created: now,
+		Expires: now.Add(k.TTL),
+		Owner:   k.Owner,
+	}
+	in.Path = outputPath(requestID, "input"+strings.ToLower(filepath.Ext(name)))
+	// check the quota and reserve the bytes in one go, so concurrent uploads
+	// of the same owner cannot both fit under it
+	inputsMu.Lock()
+	if quota := keepInputQuota(); quota > 0 {
+		if used := inputUsage(k.Owner); used+in.Bytes > quota {
+			inputsMu.Unlock()
+			return nil, fmt.Errorf("quota exceeded (%s kept of %s, this upload is %s)",
+				humanBytes(used), humanBytes(quota), humanBytes(in.Bytes))
+		}
+	}
+	inputs[in.ID] = in
+	inputsMu.Unlock()
+	if err := linkOrCopy(inPath, in.Path); err != nil {
+		inputsMu.Lock()
+		delete(inputs, in.ID)
+		inputsMu.Unlock()
+		return nil, err
+	}
+	persistInput(in)
+	logger.Printf("🗄️ [%s] Input kept as %s until %s", requestID, in.ID, in.Expires.Format(time.RFC3339))
+	return in, nil
+}
+
+// inputUsage sums the bytes owner has kept. The caller holds inputsMu.
+func inputUsage(owner string) int64 {
+	var n int64
+	for _, in := range inputs {
+		if in.Owner == owner {
+			n += in.Bytes
+		}
+	}
+	return n
+}
+
+// getInput returns a retained input of owner that has not expired.
+func getInput(id, owner string) (*keptInput, bool) {
+	inputsMu.Lock()
+	in, ok := inputs[id]
+	inputsMu.Unlock()
+	if !ok || in.Owner != owner || time.Now().After(in.Expires) {
+		return nil, false
+	}
+	if _, err := os.Stat(in.Path); err != nil {
+		return nil, false
+	}
+	return in, true
+}
+
+// keptInputPath is the file of a retained input by ID regardless of owner,
+// for re-runs of a job that kept it ("" once it expired).
+func keptInputPath(id string) string {
+	inputsMu.Lock()
+	in, ok := inputs[id]
+	inputsMu.Unlock()
+	if !ok || time.Now().After(in.Expires) {
+		return ""
+	}
+	return in.Path
+}
+
+// referencedInput resolves input_id on /compress: a retained input of the
+// caller or the kept original of a finished job the caller submitted. It links the file to a
+// private temp path, as an upload would be, and returns it with the source
+// name. Failures are *fetchError.
+func referencedInput(requestID, id, owner string) (string, string, error) {
+	var src, name string
+	var snap job
+	if j, ok := getJob(id); ok {
+		snap, _ = j.snapshot()
+	}
+	if in, ok := getInput(id, owner); ok {
+		src, name = in.Path, in.Name
+	} else if snap.ID != "" && snap.owner == owner {
+		if snap.State != jobDone || snap.Result == nil {
+			return "", "", &fetchError{http.StatusConflict, "job " + id + " is " + snap.State + "; only a finished job's input can be reused"}
+		}
+		src, name = originalPath(snap.Result), snap.sourceName
+		if _, err := os.Stat(src); src == "" || err != nil {
+			return "", "", &fetchError{http.StatusGone, "the original input of job " + id + " is no longer kept (KEEP_ORIGINALS=1 or keep_input=1 keep it)"}
+		}
+	} else {
+		return "", "", &fetchError{http.StatusNotFound, fmt.Sprintf("unknown input_id %q", id)}
+	}
+	name = filepath.Base(name)
+	if name == "" || name == "." || name == "/" {
+		name = filepath.Base(src)
+	}
+	path := filepath.Join(os.TempDir(), requestID+"_"+name)
+	if err := linkOrCopy(src, path); err != nil {
+		return "", "", &fetchError{http.StatusInternalServerError, "save error: " + err.Error()}
+	}
+	logger.Printf("♻️ [%s] Reusing input %s (%s)", requestID, id, name)
+	return path, name, nil
+}
+
+func releaseInput(in *keptInput) {
+	inputsMu.Lock()
+	delete(inputs, in.ID)
+	inputsMu.Unlock()
+	os.Remove(in.Path)
+	if persistentOutputs() {
+		os.Remove(filepath.Join(inputIndexDir(), in.ID+".json"))
+	}
+}
+
+// purgeExpiredInputs releases the inputs past their TTL.
+func purgeExpiredInputs() (int, int64) {
+	now := time.Now()
+	var expired []*keptInput
+	inputsMu.Lock()
+	for _, in := range inputs {
+		if now.After(in.Expires) {
+			expired = append(expired, in)
+		}
+	}
+	inputsMu.Unlock()
+	var freed int64
+	for _, in := range expired {
+		releaseInput(in)
+		freed += in.Bytes
+	}
+	return len(expired), freed
+}
+
+// purgeInputs is the purge_inputs maintenance task.
+func purgeInputs(t *taskConfig) (string, error) {
+	n, freed := purgeExpiredInputs()
+	return fmt.Sprintf("released %d expired inputs (%s)", n, humanBytes(freed)), nil
+}
+
+// persistInput writes the input's sidecar JSON (no-op without OUTPUT_DIR).
+func persistInput(in *keptInput) {
+	if !persistentOutputs() {
+		return
+	}
+	data, err := json.Marshal(in)
+	if err == nil {
+		if err = os.MkdirAll(inputIndexDir(), 0o755); err == nil {
+			err = os.WriteFile(filepath.Join(inputIndexDir(), in.ID+".json"), data, 0o644)
+		}
+	}
+	if err != nil {
+		logger.Printf("⚠️ [INPUTS] Could not persist %s: %v", in.ID, err)
+	}
+}
+
+// loadPersistedInputs restores the retained inputs after a restart.
+func loadPersistedInputs() int {
+	paths, _ := filepath.Glob(filepath.Join(inputIndexDir(), "*.json"))
+	n := 0
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var in keptInput
+		if json.Unmarshal(data, &in) != nil {
+			continue
+		}
+		if _, err := os.Stat(in.Path); err != nil {
+			os.Remove(p) // the file is gone; drop the stale index entry
+			continue
+		}
+		inputsMu.Lock()
+		inputs[in.ID] = &in
+		inputsMu.Unlock()
+		n++
+	}
+	return n
+}
+
+func (in *keptInput) view() map[string]any {
+	return map[string]any{
+		"id":         in.ID,
+		"name":       in.Name,
+		"size_bytes": in.Bytes,
+		"created":    in.Created.UTC(),
+		"expires":    in.Expires.UTC(),
+		"url":        "/inputs/" + in.ID,
+	}
+}
+
+// inputsHandler serves GET /inputs, GET /inputs/{id} and DELETE /inputs/{id}.
+func inputsHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(6)
+	r, ok := authorizeUpload(w, r)
+	if !ok {
+		return
+	}
+	owner := clientKey(r)
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/inputs"), "/")
+	if id == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		purgeExpiredInputs()
+		inputsMu.Lock()
+		list := []map[string]any{}
+		var used int64
+		for _, in := range inputs {
+			if in.Owner == owner {
+				list = append(list, in.view())
+				used += in.Bytes
+			}
+		}
+		inputsMu.Unlock()
+		sort.Slice(list, func(i, j int) bool {
+			return list[i]["created"].(time.Time).Before(list[j]["created"].(time.Time))
+		})
+		writeJSON(w, http.StatusOK, map[string]any{
+			"inputs":      list,
+			"used_bytes":  used,
+			"quota_bytes": keepInputQuota(),
+		})
+		return
+	}
+	in, ok := getInput(id, owner)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, in.view())
+	case http.MethodDelete:
+		releaseInput(in)
+		logger.Printf("🗑️ [%s] Input %s released by %s", requestID, id, r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseKeepInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		on, ttl string
+		env     map[string]string
+		want    time.Duration // 0: nothing kept
+		wantErr bool
+	}{
+		{name: "off"},
+		{name: "explicitly off", on: "no"},
+		{name: "default ttl", on: "1", want: 24 * time.Hour},
+		{name: "ttl", on: "true", ttl: "2h", want: 2 * time.Hour},
+		{name: "server default", on: "1", env: map[string]string{"KEEP_INPUT_TTL": "6h"}, want: 6 * time.Hour},
+		{name: "default capped by max", on: "1", env: map[string]string{"KEEP_INPUT_MAX_TTL": "1h"}, want: time.Hour},
+		{name: "ttl at max", on: "1", ttl: "168h", want: 168 * time.Hour},
+		{name: "ttl over max", on: "1", ttl: "169h", wantErr: true},
+		{name: "ttl over lowered max", on: "1", ttl: "2h", env: map[string]string{"KEEP_INPUT_MAX_TTL": "1h"}, wantErr: true},
+		{name: "negative ttl", on: "1", ttl: "-1h", wantErr: true},
+		{name: "ttl without unit", on: "1", ttl: "3600", wantErr: true},
+		{name: "ttl while off", ttl: "2h", wantErr: true},
+		{name: "bad switch", on: "2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KEEP_INPUT_TTL", "")
+			t.Setenv("KEEP_INPUT_MAX_TTL", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := parseKeepInput(tt.on, tt.ttl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseKeepInput(%q, %q) error = %v, want error %v", tt.on, tt.ttl, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.want == 0 {
+				if got != nil {
+					t.Errorf("parseKeepInput(%q, %q) = %+v, want nil", tt.on, tt.ttl, got)
+				}
+				return
+			}
+			if got == nil || got.TTL != tt.want {
+				t.Errorf("parseKeepInput(%q, %q) = %+v, want TTL %v", tt.on, tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ======================
+// Interlaced output (interlace=keep|tff|bff)
+// ======================
+
+// Output is progressive by default. Broadcast delivery still often asks for
+// interlaced video, so interlace= encodes field-coded H.264 (or interlaced
+// ProRes/FFV1) with the field order flagged in the stream and container:
+//
+//	keep  interlaced sources keep their field order; progressive ones stay progressive
+//	tff   top field first (1080i50 in Europe, most HD broadcast)
+//	bff   bottom field first (DV, NTSC SD)
+//
+// An interlaced source in the other order is shifted by a line (fieldorder).
+// A progressive source above 30 fps is woven into fields from consecutive
+// frames, halving the frame rate (50p → 25i); slower ones are flagged as
+// interlaced as they are (PsF). Scaling, frame-rate changes and reframing
+// would tear the fields apart, so they cannot be combined.
+
+// interlaceCodecs can code interlaced pictures.
+var interlaceCodecs = map[string]bool{"h264": true, "prores": true, "ffv1": true}
+
+func parseInterlace(s string, o compressOpts) (string, error) {
+	switch s {
+	case "", "progressive":
+		return "", nil
+	case "keep", "tff", "bff":
+	default:
+		return "", fmt.Errorf("invalid interlace %q (keep|tff|bff)", s)
+	}
+	codec := strings.ToLower(o.Codec)
+	switch {
+	case codec == "copy":
+		return "", errors.New("interlace needs a video re-encode (codec=copy keeps the source's fields as they are)")
+	case !interlaceCodecs[codec]:
+		return "", fmt.Errorf("interlace needs codec=h264, prores or ffv1 (got %s)", codec)
+	case strings.EqualFold(o.HW, "videotoolbox"):
+		return "", errors.New("interlace is not supported by hw=videotoolbox")
+	}
+	var bad []string
+	switch o.SpeedMode {
+	case "turbo", "max", "proxy", "screen", "archive":
+		bad = append(bad, "speed="+o.SpeedMode)
+	}
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{o.Resolution != "" && o.Resolution != "original", "resolution"},
+		{o.FPS > 0, "fps"},
+		{o.MaxLandscape != (resCap{}) || o.MaxPortrait != (resCap{}), "max_landscape/max_portrait"},
+		{o.Reframe != nil, "reframe"},
+		{o.Race != nil, "race"},
+	} {
+		if c.set {
+			bad = append(bad, c.name)
+		}
+	}
+	if len(bad) > 0 {
+		return "", fmt.Errorf("interlace cannot be combined with %s (it would break the fields)", strings.Join(bad, ", "))
+	}
+	return s, nil
+}
+
+// sourceFieldOrder is "tff", "bff" or "" (progressive or unknown).
+func sourceFieldOrder(p *probeResult) string {
+	if p == nil {
+		return ""
+	}
+	vs := p.firstStream("video")
+	if vs == nil {
+		return ""
+	}
+	switch vs.FieldOrder {
+	case "tt", "tb":
+		return "tff"
+	case "bb", "bt":
+		return "bff"
+	}
+	return ""
+}
+
+// settleInterlace resolves interlace=keep against the source and rechecks the
+// codec finally chosen. It returns notes for the warnings.
+func (o *compressOpts) settleInterlace() []string {
+	if o.Interlace == "" {
+		return nil
+	}
+	src := sourceFieldOrder(o.Source)
+	if o.Interlace == "keep" {
+		if src == "" {
+			o.Interlace = ""
+			return []string{"interlace=keep: the source is progressive, so the output is too"}
+		}
+		o.Interlace = src
+	}
+	if codec := strings.ToLower(o.Codec); !interlaceCodecs[codec] {
+		note := fmt.Sprintf("interlace ignored: %s cannot code interlaced video", codec)
+		o.Interlace = ""
+		return []string{note}
+	}
+	if src == "" && o.Source != nil {
+		if vs := o.Source.firstStream("video"); vs != nil && vs.frameRate() > 30 {
+			return []string{fmt.Sprintf("progressive %.3g fps source woven into %s fields at %.3g fps", vs.frameRate(), o.Interlace, vs.frameRate()/2)}
+		}
+	}
+	return nil
+}
+
+// interlaceFilter brings the frames into o.Interlace field order.
+func interlaceFilter(o compressOpts) string {
+	src := sourceFieldOrder(o.Source)
+	switch {
+	case src == o.Interlace:
+		return "setfield=" + o.Interlace
+	case src != "":
+		return "fieldorder=" + o.Interlace
+	}
+	if o.Source != nil {
+		if vs := o.Source.firstStream("video"); vs != nil && vs.frameRate() > 30 {
+			return "interlace=scan=" + o.Interlace + ":lowpass=complex"
+		}
+	}
+	return "setfield=" + o.Interlace
+}
+
+// interlaceArgs switches vcodec to field coding and flags the field order.
+func interlaceArgs(o compressOpts, vcodec string) []string {
+	if o.Interlace == "" {
+		return nil
+	}
+	order := "tt"
+	if o.Interlace == "bff" {
+		order = "bb"
+	}
+	var args []string
+	switch vcodec {
+	case "libx264":
+		args = []string{"-flags", "+ildct+ilme"}
+	case "prores_ks":
+		args = []string{"-flags", "+ildct"}
+	}
+	return append(args, "-field_order", order)
+}
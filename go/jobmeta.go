@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// ======================
+// Client-supplied job metadata (metadata field, X-Job-Tag header)
+// ======================
+
+const (
+	maxClientMetadata = 8 << 10 // 8 KB of JSON
+	maxJobTag         = 128
+)
+
+// clientMeta is opaque caller data stored with a job/result and echoed
+// back (/meta, X-Job-Tag, job views) so callers can correlate results.
+type clientMeta struct {
+	Tag      string          `json:"job_tag,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// parseClientMeta validates a metadata JSON object and a job tag.
+func parseClientMeta(metadata, tag string) (clientMeta, error) {
+	var c clientMeta
+	if metadata = strings.TrimSpace(metadata); metadata != "" {
+		if len(metadata) > maxClientMetadata {
+			return c, errors.New("metadata exceeds 8 KB")
+		}
+		var obj map[string]any
+		if err := json.Unmarshal([]byte(metadata), &obj); err != nil {
+			return c, errors.New("metadata must be a JSON object")
+		}
+		c.Metadata = json.RawMessage(metadata)
+	}
+	tag = strings.TrimSpace(tag)
+	if len(tag) > maxJobTag {
+		return c, errors.New("job tag exceeds 128 characters")
+	}
+	if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return c, errors.New("job tag contains non-printable characters")
+	}
+	c.Tag = tag
+	return c, nil
+}
+
+// clientMetaFromRequest reads the metadata form field and the X-Job-Tag
+// header (falling back to a job_tag field).
+func clientMetaFromRequest(r *http.Request) (clientMeta, error) {
+	tag := r.Header.Get("X-Job-Tag")
+	if tag == "" {
+		tag = r.FormValue("job_tag")
+	}
+	return parseClientMeta(r.FormValue("metadata"), tag)
+}
+
+// addTo copies the non-empty fields into a JSON view.
+func (c clientMeta) addTo(v map[string]any) {
+	if c.Tag != "" {
+		v["job_tag"] = c.Tag
+	}
+	if len(c.Metadata) > 0 {
+		v["metadata"] = c.Metadata
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Job registry
+// ======================
+
+const (
+	jobWaitDefault = 60 * time.Second
+	jobWaitMax     = 5 * time.Minute
+)
+
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+type job struct {
+	ID       string
+	State    string
+	ResultID string
+	Result   *resultEntry
+	Error    string
+	Progress float64 // percent
+	Encode   *encodeProgress
+	Reject   bool // failed because the request itself was rejected (e.g. compat=strict, max_output_bytes)
+	Client   clientMeta
+	Created  time.Time
+	Started  time.Time
+	Finished time.Time
+
+	// Callback is the callback_url told when the job ends; BaseURL makes the
+	// URLs in its payload absolute.
+	Callback         string
+	BaseURL          string
+	CallbackState    string // pending|delivered|failed
+	CallbackAttempts int
+	CallbackError    string
+
+	// params and sourceName are the request's options and upload name,
+	// replayed by /jobs/{id}/rerun. header (Accept, X-Job-Tag) and grant
+	// (the upload token) complete them when a checkpointed job is resumed
+	// after a restart.
+	params     url.Values
+	sourceName string
+	header     http.Header
+	grant      *uploadGrant
+	// owner is the clientKey of the submitter; only it may reuse the job's
+	// kept original as input_id.
+	owner string
+
+	// changed is closed and replaced on every update so watchers can block
+	// until something happens.
+	changed chan struct{}
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*job{}
+)
+
+func newJob(c clientMeta) *job {
+	return restoreJob(randID(8), c, time.Now())
+}
+
+// restoreJob registers a queued job under a known ID (a job resumed from its
+// checkpoint after a restart).
+func restoreJob(id string, c clientMeta, created time.Time) *job {
+	j := &job{
+		ID:      id,
+		Client:  c,
+		State:   jobQueued,
+		Created: created,
+		changed: make(chan struct{}),
+	}
+	jobsMu.Lock()
+	jobs[j.ID] = j
+	jobsMu.Unlock()
+	return j
+}
+
+// setRequest records what re-runs and resumed checkpoints replay: the
+// options, the upload name and who submitted them.
+func (j *job) setRequest(r *http.Request, params url.Values, sourceName string) {
+	j.params, j.sourceName, j.owner = params, sourceName, clientKey(r)
+	j.header = http.Header{}
+	for _, k := range []string{"Accept", "X-Job-Tag"} {
+		if v := r.Header.Get(k); v != "" {
+			j.header.Set(k, v)
+		}
+	}
+	j.grant = grantOf(r)
+}
+
+func getJob(id string) (*job, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	j, ok := jobs[id]
+	return j, ok
+}
+
+// update applies fn under the registry lock and wakes up watchers.
+func (j *job) update(fn func(j *job)) {
+	jobsMu.Lock()
+	fn(j)
+	close(j.changed)
+	j.changed = make(chan struct{})
+	jobsMu.Unlock()
+}
+
+func (j *job) start() {
+	j.update(func(j *job) {
+		j.State = jobRunning
+		j.Started = time.Now()
+	})
+}
+
+type jobCtxKey struct{}
+
+// jobOf is the job an encode runs for (nil within a plain request).
+func jobOf(ctx context.Context) *job {
+	j, _ := ctx.Value(jobCtxKey{}).(*job)
+	return j
+}
+
+// track reports the progress of the encodes run under the returned context
+// to the job, and lets them find it with jobOf.
+func (j *job) track(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, jobCtxKey{}, j)
+	return withProgress(ctx, func(p encodeProgress) {
+		j.update(func(j *job) {
+			j.Encode = &p
+			if p.Percent > 0 {
+				j.Progress = p.Percent
+			}
+		})
+	})
+}
+
+func (j *job) finish(resultID string, e *resultEntry, err error) {
+	j.update(func(j *job) {
+		j.Finished = time.Now()
+		if err != nil {
+			var ce *compatError
+			var se *sizeLimitError
+			j.State = jobFailed
+			j.Error = err.Error()
+			j.Reject = errors.As(err, &ce) || errors.As(err, &se)
+			return
+		}
+		j.State = jobDone
+		j.Progress = 100
+		j.ResultID = resultID
+		j.Result = e
+	})
+	if j.Callback != "" {
+		sendCallback(j)
+	}
+}
+
+// snapshot returns a copy of the job plus a channel closed on the next update.
+func (j *job) snapshot() (job, <-chan struct{}) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return *j, j.changed
+}
+
+func (j *job) terminal() bool {
+	return j.State == jobDone || j.State == jobFailed
+}
+
+// view is the JSON form of a job snapshot.
+func (j job) view() map[string]any {
+	v := map[string]any{
+		"id":         j.ID,
+		"state":      j.State,
+		"progress":   j.Progress,
+		"created_at": j.Created.UTC().Format(time.RFC3339),
+	}
+	if !j.Started.IsZero() {
+		v["started_at"] = j.Started.UTC().Format(time.RFC3339)
+	}
+	if !j.Finished.IsZero() {
+		v["finished_at"] = j.Finished.UTC().Format(time.RFC3339)
+	}
+	if j.Error != "" {
+		v["error"] = j.Error
+	}
+	if j.ResultID != "" {
+		v["result_id"] = j.ResultID
+		v["download_url"] = "/dl/" + j.ResultID
+		v["meta_url"] = "/meta/" + j.ResultID
+		if j.Result != nil && j.Result.OutputURL != "" {
+			v["output_url"] = j.Result.OutputURL
+		}
+	}
+	if j.Callback != "" {
+		cb := map[string]any{"url": j.Callback, "state": j.CallbackState, "attempts": j.CallbackAttempts}
+		if j.CallbackError != "" {
+			cb["error"] = j.CallbackError
+		}
+		v["callback"] = cb
+	}
+	j.Client.addTo(v)
+	return v
+}
+
+// parseWaitTimeout accepts a Go duration ("90s", "2m") or plain seconds.
+func parseWaitTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return jobWaitDefault, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		n, nerr := strconv.Atoi(s)
+		if nerr != nil {
+			return 0, fmt.Errorf("invalid timeout %q (e.g. 60s)", s)
+		}
+		d = time.Duration(n) * time.Second
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", s)
+	}
+	return min(d, jobWaitMax), nil
+}
+
+// ======================
+// Background jobs (/compress async=1)
+// ======================
+
+// background counts the jobs running after their request returned, so a
+// graceful shutdown can wait for them.
+var background sync.WaitGroup
+
+// runBackground encodes an async upload after its request has answered 202.
+// The job owns inPath and the poster; it waits for the request's job slot
+// (see limitClientAsync) and holds it until it finishes.
+func runBackground(j *job, requestID, inPath string, opts compressOpts, slot *slotLease) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		defer slot.free()
+		defer os.Remove(inPath)
+		if opts.PosterPath != "" {
+			defer os.Remove(opts.PosterPath)
+		}
+		// the job stays queued until the client has a free slot
+		if err := slot.take(context.Background()); err != nil {
+			logger.Printf("❌ [%s] Background job %s got no job slot: %v", requestID, j.ID, err)
+			j.finish("", nil, err)
+			return
+		}
+		j.start()
+		logger.Printf("⏳ [%s] Background encode of job %s started", requestID, j.ID)
+		e, err := compressFile(j.track(context.Background()), requestID, inPath, opts)
+		id := ""
+		if err != nil {
+			logger.Printf("❌ [%s] Background job %s failed: %v", requestID, j.ID, err)
+		} else {
+			id = storeResult(requestID, e)
+			logger.Printf("✅ [%s] Background job %s done, result %s", requestID, j.ID, id)
+		}
+		j.finish(id, e, err)
+	}()
+}
+
+// waitBackground blocks until the background jobs finish or ctx ends.
+func waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ======================
+// Job HTTP API
+// ======================
+
+// jobsHandler serves GET /jobs/{id}, the current state of a job, and
+// GET /jobs/{id}/wait?timeout=60s, which blocks until the job is done or
+// failed (or the timeout elapses) and returns its state. POST
+// /jobs/{id}/rerun encodes the job's input again (see rerun.go).
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/"), "/")
+	j, ok := getJob(id)
+	if !ok || (action != "" && action != "wait" && action != "rerun") {
+		http.NotFound(w, r)
+		return
+	}
+	if action == "rerun" {
+		rerunJob(w, r, j)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if action == "" {
+		snap, _ := j.snapshot()
+		writeJSON(w, http.StatusOK, snap.view())
+		return
+	}
+	timeout, err := parseWaitTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		snap, changed := j.snapshot()
+		if snap.terminal() {
+			writeJSON(w, http.StatusOK, snap.view())
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			// still running: same body, clients check "state" and call again
+			writeJSON(w, http.StatusOK, snap.view())
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
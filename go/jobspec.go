@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ======================
+// Declarative job spec (POST /jobspec)
+// ======================
+
+// A job spec describes a whole request in one JSON document instead of flat
+// form fields: named inputs (multipart fields), shared filters (trim,
+// watermark), and up to maxSpecOutputs outputs, each with its own /compress
+// params, optional filter overrides and destinations.
+//
+//	{"inputs": {"video": "file", "logo": "logo"},
+//	 "filters": {"trim": {"start": 5, "end": 65}, "watermark": {"input": "logo"}},
+//	 "outputs": [{"name": "hd", "params": {"resolution": "1080p"}},
+//	             {"name": "preview", "params": {"speed": "turbo"}, "filters": {}}]}
+
+const maxSpecOutputs = 5
+
+type jobSpec struct {
+	Inputs  map[string]string `json:"inputs,omitempty"` // input name → multipart field ("video" is the source)
+	Filters specFilters       `json:"filters"`
+	Outputs []specOutput      `json:"outputs"`
+}
+
+type specFilters struct {
+	Trim      *specTrim      `json:"trim,omitempty"`
+	Watermark *watermarkSpec `json:"watermark,omitempty"`
+}
+
+type specTrim struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+}
+
+type specOutput struct {
+	Name         string           `json:"name,omitempty"`
+	Params       map[string]any   `json:"params,omitempty"`
+	Filters      *specFilters     `json:"filters,omitempty"` // replaces the shared filters
+	Destinations []deliveryTarget `json:"destinations,omitempty"`
+}
+
+// parseJobSpec validates the spec and returns one set of options per output.
+func parseJobSpec(s string) (*jobSpec, []compressOpts, error) {
+	var spec jobSpec
+	if err := json.Unmarshal([]byte(s), &spec); err != nil {
+		return nil, nil, fmt.Errorf("invalid spec: %v", err)
+	}
+	if len(spec.Inputs) == 0 {
+		spec.Inputs = map[string]string{"video": "file"}
+	}
+	if spec.Inputs["video"] == "" {
+		return nil, nil, errors.New(`spec inputs need a "video" entry`)
+	}
+	if len(spec.Outputs) == 0 || len(spec.Outputs) > maxSpecOutputs {
+		return nil, nil, fmt.Errorf("spec needs 1-%d outputs", maxSpecOutputs)
+	}
+
+	names := map[string]bool{}
+	var all []compressOpts
+	for i := range spec.Outputs {
+		out := &spec.Outputs[i]
+		if out.Name == "" {
+			out.Name = fmt.Sprintf("output%d", i+1)
+		}
+		if names[out.Name] {
+			return nil, nil, fmt.Errorf("outputs[%d]: duplicate name %q", i, out.Name)
+		}
+		names[out.Name] = true
+
+		params, err := stringParams(out.Params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		opts, err := parseOptsFrom(func(k string) string { return params[k] })
+		if err != nil {
+			return nil, nil, fmt.Errorf("outputs[%d]: %w", i, err)
+		}
+		for j, t := range out.Destinations {
+			if err := t.validate(); err != nil {
+				return nil, nil, fmt.Errorf("outputs[%d].destinations[%d]: %w", i, j, err)
+			}
+		}
+		opts.Deliver = append(opts.Deliver, out.Destinations...)
+		if len(opts.Deliver) > maxDeliveryTargets {
+			return nil, nil, fmt.Errorf("outputs[%d]: at most %d destinations", i, maxDeliveryTargets)
+		}
+
+		f := spec.Filters
+		if out.Filters != nil {
+			f = *out.Filters
+		}
+		if (f.Trim != nil || f.Watermark != nil) && opts.Codec == "copy" {
+			return nil, nil, fmt.Errorf("outputs[%d]: filters need a video re-encode (codec=copy)", i)
+		}
+		if t := f.Trim; t != nil {
+			if t.Start < 0 || (t.End != 0 && t.End <= t.Start) {
+				return nil, nil, fmt.Errorf("outputs[%d]: trim needs 0 <= start < end", i)
+			}
+			if opts.TrimDead != "" {
+				return nil, nil, fmt.Errorf("outputs[%d]: trim cannot be combined with trim_dead", i)
+			}
+			opts.TrimStart, opts.TrimEnd = t.Start, t.End
+		}
+		if f.Watermark != nil {
+			wm := *f.Watermark
+			if spec.Inputs[wm.Input] == "" || wm.Input == "video" {
+				return nil, nil, fmt.Errorf("outputs[%d]: watermark input %q is not an image input", i, wm.Input)
+			}
+			if err := wm.normalize(); err != nil {
+				return nil, nil, fmt.Errorf("outputs[%d]: %w", i, err)
+			}
+			opts.Watermark = &wm
+		}
+		all = append(all, opts)
+	}
+	return &spec, all, nil
+}
+
+func jobSpecHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("📜 [%s] New job spec from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseUploadForm(w, r, requestID) {
+		return
+	}
+	spec, all, err := parseJobSpec(r.FormValue("spec"))
+	if err != nil {
+		http.Error(w, err.Error(), optsErrorStatus(err))
+		return
+	}
+
+	// Save every named input
+	paths := map[string]string{}
+	defer func() {
+		for _, p := range paths {
+			os.Remove(p)
+		}
+	}()
+	for name, field := range spec.Inputs {
+		p, _, err := saveFormFile(r, field)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("input %q: file field %q required", name, field), http.StatusBadRequest)
+			return
+		}
+		paths[name] = p
+	}
+	sourceName := ""
+	if fh := r.MultipartForm.File[spec.Inputs["video"]]; len(fh) > 0 {
+		sourceName = fh[0].Filename
+	}
+
+	status := http.StatusOK
+	results := make([]map[string]any, 0, len(all))
+	manifest := &resultManifest{ID: requestID}
+	for i, opts := range all {
+		name := spec.Outputs[i].Name
+		opts.SourceName = sourceName
+		if opts.Watermark != nil {
+			opts.Watermark.path = paths[opts.Watermark.Input]
+		}
+		// each output gets its own ID so output paths never collide
+		outID := fmt.Sprintf("%s-%d", requestID, i+1)
+		logger.Printf("📜 [%s] Output %d/%d (%s)", requestID, i+1, len(all), name)
+		res := map[string]any{"name": name}
+		e, err := compressFile(r.Context(), outID, paths["video"], opts)
+		if err != nil {
+			logger.Printf("❌ [%s] Output %s failed: %v", requestID, name, err)
+			res["error"] = err.Error()
+			if status == http.StatusOK {
+				status = http.StatusInternalServerError
+				var ce *compatError
+				var ve *verifyError
+				var se *sizeLimitError
+				if errors.As(err, &ce) || errors.As(err, &ve) || errors.As(err, &se) {
+					status = http.StatusUnprocessableEntity
+				}
+			}
+			results = append(results, res)
+			manifest.Failed = append(manifest.Failed, name)
+			continue
+		}
+		id := storeResult(outID, e)
+		manifest.Files = append(manifest.Files, manifestFiles(name, id, e)...)
+		res["result_id"] = id
+		res["download_url"] = "/dl/" + id
+		res["meta_url"] = "/meta/" + id
+		res["output_bytes"] = e.OutputBytes
+		if len(e.Warnings) > 0 {
+			res["warnings"] = e.Warnings
+		}
+		results = append(results, res)
+	}
+	logger.Printf("✅ [%s] Job spec finished: %d outputs (HTTP %d)", requestID, len(results), status)
+	resp := map[string]any{"outputs": results}
+	if sm, err := signManifest(manifest); err != nil {
+		logger.Printf("⚠️ [%s] Could not sign manifest: %v", requestID, err)
+	} else {
+		resp["manifest"] = sm.Body
+		resp["manifest_signature"] = sm.Signature
+		resp["manifest_url"] = "/manifests/" + manifest.ID
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Per-title ladder analysis (POST /analyze-ladder)
+// ======================
+
+// Sampled segments of the title are encoded at every resolution × CRF
+// candidate, scored against the source and reduced to the quality/bitrate
+// frontier; the recommended ladder is picked from that frontier.
+
+const (
+	ladderMaxSamples = 5
+	ladderMaxRungs   = 6
+	ladderSpacing    = 1.6 // minimum bitrate ratio between neighbouring rungs
+)
+
+var (
+	ladderHeights = []int{2160, 1440, 1080, 720, 540, 360, 240}
+	ladderCRFs    = []int{20, 23, 26, 29, 32}
+)
+
+// ladderPoint is one candidate encode (averaged over the samples).
+type ladderPoint struct {
+	Height int     `json:"height"`
+	Width  int     `json:"width"`
+	CRF    int     `json:"crf"`
+	Kbps   int     `json:"kbps"`
+	Score  float64 `json:"score"`
+}
+
+// ladderAnalysis is the answer of /analyze-ladder, signed as a manifest.
+type ladderAnalysis struct {
+	SHA256     string        `json:"sha256,omitempty"` // of the analysed upload
+	Metric     string        `json:"metric"`
+	Samples    int           `json:"samples"`
+	SampleSec  float64       `json:"sample_sec"`
+	Candidates []ladderPoint `json:"candidates"`
+	Ladder     []ladderPoint `json:"ladder"`
+}
+
+type ladderRequest struct {
+	Heights   []int
+	CRFs      []int
+	Samples   int
+	SampleSec float64
+	Metric    string
+}
+
+func parseIntList(s string, lo, hi int) ([]int, error) {
+	var out []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(f), "p"))
+		if err != nil || n < lo || n > hi {
+			return nil, fmt.Errorf("invalid value %q (%d-%d)", f, lo, hi)
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseLadderRequest(r *http.Request) (ladderRequest, error) {
+	lr := ladderRequest{Heights: ladderHeights, CRFs: ladderCRFs, Samples: 3, SampleSec: 4, Metric: "ssim"}
+	var err error
+	if s := r.FormValue("resolutions"); s != "" {
+		if lr.Heights, err = parseIntList(s, 144, 4320); err != nil {
+			return lr, fmt.Errorf("resolutions: %w", err)
+		}
+	}
+	if s := r.FormValue("crfs"); s != "" {
+		if lr.CRFs, err = parseIntList(s, 0, 51); err != nil {
+			return lr, fmt.Errorf("crfs: %w", err)
+		}
+	}
+	if s := r.FormValue("samples"); s != "" {
+		if lr.Samples, err = strconv.Atoi(s); err != nil || lr.Samples < 1 || lr.Samples > ladderMaxSamples {
+			return lr, fmt.Errorf("samples must be 1-%d", ladderMaxSamples)
+		}
+	}
+	if s := r.FormValue("sample_sec"); s != "" {
+		if lr.SampleSec, err = strconv.ParseFloat(s, 64); err != nil || lr.SampleSec < 1 || lr.SampleSec > 30 {
+			return lr, fmt.Errorf("sample_sec must be 1-30")
+		}
+	}
+	if s := r.FormValue("metric"); s != "" {
+		lr.Metric = s
+	}
+	return lr, validMetric(lr.Metric)
+}
+
+// runFF runs ffmpeg quietly, returning the last log line on failure. Its CPU
+// time counts toward the job's usage.
+func runFF(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), append([]string{"-y", "-hide_banner", "-loglevel", "error"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, lastLine(string(out)))
+	}
+	return nil
+}
+
+// analyzeLadder encodes every candidate and returns all points plus the
+// recommended ladder (highest rung first).
+func analyzeLadder(ctx context.Context, requestID, inPath string, src *probeResult, lr ladderRequest) ([]ladderPoint, []ladderPoint, error) {
+	vs := src.firstStream("video")
+	if vs == nil || vs.Height == 0 {
+		return nil, nil, fmt.Errorf("no video stream")
+	}
+	work, err := os.MkdirTemp("", "ladder_"+requestID+"_")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(work)
+
+	// Reference samples: near-lossless, so every candidate starts from the
+	// same frames.
+	dur := src.durationSec()
+	sampleSec, samples := lr.SampleSec, lr.Samples
+	if dur > 0 && dur <= sampleSec {
+		sampleSec, samples = dur, 1 // short clip: analyze all of it
+	}
+	var refs []string
+	for i := 1; i <= samples; i++ {
+		ref := filepath.Join(work, fmt.Sprintf("ref%d.mkv", i))
+		var args []string
+		if dur > sampleSec {
+			args = append(args, "-ss", strconv.FormatFloat((dur-sampleSec)*float64(i)/float64(samples+1), 'f', 2, 64))
+		}
+		args = append(args, "-i", inPath, "-t", strconv.FormatFloat(sampleSec, 'f', 2, 64), "-map", "0:V:0", "-an",
+			"-c:v", "libx264", "-qp", "0", "-preset", "ultrafast", ref)
+		if err := runFF(ctx, args...); err != nil {
+			return nil, nil, fmt.Errorf("sample %d: %w", i, err)
+		}
+		refs = append(refs, ref)
+	}
+
+	var points []ladderPoint
+	for _, h := range lr.Heights {
+		if h > vs.Height {
+			continue // never upscale
+		}
+		w := int(math.Round(float64(vs.Width)*float64(h)/float64(vs.Height))) &^ 1
+		for _, crf := range lr.CRFs {
+			var bytes int64
+			var score float64
+			for i, ref := range refs {
+				enc := filepath.Join(work, fmt.Sprintf("c%d_%d_%d.mp4", h, crf, i))
+				if err := runFF(ctx, "-i", ref, "-vf", fmt.Sprintf("scale=-2:%d:flags=bicubic", h),
+					"-c:v", "libx264", "-preset", "veryfast", "-crf", strconv.Itoa(crf), "-pix_fmt", "yuv420p", enc); err != nil {
+					return nil, nil, fmt.Errorf("%dp crf %d: %w", h, crf, err)
+				}
+				s, err := measureQuality(ctx, enc, ref, lr.Metric)
+				if err != nil {
+					return nil, nil, err
+				}
+				if st, err := os.Stat(enc); err == nil {
+					bytes += st.Size()
+				}
+				score += s
+				os.Remove(enc)
+			}
+			p := ladderPoint{Height: h, Width: w, CRF: crf,
+				Kbps:  int(float64(bytes) * 8 / 1000 / (sampleSec * float64(len(refs)))),
+				Score: math.Round(score/float64(len(refs))*10000) / 10000}
+			logger.Printf("📐 [%s] %dp CRF %d: %d kbps, %s %.4f", requestID, h, crf, p.Kbps, lr.Metric, p.Score)
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return nil, nil, fmt.Errorf("no candidate resolution at or below the source (%dp)", vs.Height)
+	}
+	return points, recommendLadder(points), nil
+}
+
+// recommendLadder keeps the quality/bitrate frontier (no cheaper point
+// scores higher) and walks it from the top, taking a rung whenever the
+// bitrate has dropped by ladderSpacing.
+func recommendLadder(points []ladderPoint) []ladderPoint {
+	sorted := append([]ladderPoint(nil), points...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Kbps < sorted[j].Kbps })
+	var frontier []ladderPoint
+	best := math.Inf(-1)
+	for _, p := range sorted {
+		if p.Score > best {
+			frontier = append(frontier, p)
+			best = p.Score
+		}
+	}
+	var ladder []ladderPoint
+	for i := len(frontier) - 1; i >= 0 && len(ladder) < ladderMaxRungs; i-- {
+		p := frontier[i]
+		if n := len(ladder); n > 0 {
+			last := ladder[n-1]
+			if float64(p.Kbps)*ladderSpacing > float64(last.Kbps) || p.Height > last.Height {
+				continue
+			}
+		}
+		ladder = append(ladder, p)
+	}
+	return ladder
+}
+
+func analyzeLadderHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("📐 [%s] New ladder analysis from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseMediaUpload(w, r, requestID) {
+		return
+	}
+	lr, err := parseLadderRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inPath, _, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file field required", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(inPath)
+
+	src, err := probeFile(r.Context(), inPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	points, ladder, err := analyzeLadder(r.Context(), requestID, inPath, src, lr)
+	if err != nil {
+		logger.Printf("❌ [%s] Ladder analysis failed: %v", requestID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	logger.Printf("✅ [%s] Recommended %d-rung ladder from %d candidates", requestID, len(ladder), len(points))
+	a := &ladderAnalysis{Metric: lr.Metric, Samples: lr.Samples, SampleSec: lr.SampleSec, Candidates: points, Ladder: ladder}
+	if d, err := digestFile(inPath); err == nil {
+		a.SHA256 = d.SHA256
+	}
+	resp := map[string]any{
+		"metric":     a.Metric,
+		"samples":    a.Samples,
+		"sample_sec": a.SampleSec,
+		"candidates": a.Candidates,
+		"ladder":     a.Ladder,
+	}
+	if sm, err := signManifest(&resultManifest{ID: requestID, Ladder: a}); err != nil {
+		logger.Printf("⚠️ [%s] Could not sign manifest: %v", requestID, err)
+	} else {
+		resp["manifest"] = sm.Body
+		resp["manifest_signature"] = sm.Signature
+		resp["manifest_url"] = "/manifests/" + requestID
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ======================
+// Per-client concurrent job limit
+// ======================
+
+var errClientBusy = errors.New("too many concurrent jobs for this client")
+
+// clientSlots counts running jobs per client (API key or IP).
+type clientSlots struct {
+	mu     sync.Mutex
+	active map[string]int
+	// freed is closed and replaced whenever a slot is released so queued
+	// requests can re-check.
+	freed chan struct{}
+}
+
+var slots = &clientSlots{active: map[string]int{}, freed: make(chan struct{})}
+
+// clientMaxJobs is the per-client limit (CLIENT_MAX_JOBS, 0 = unlimited).
+func clientMaxJobs() int {
+	if n := currentConfig().Limits.ClientMaxJobs; n != nil {
+		return *n
+	}
+	n, err := strconv.Atoi(envOr("CLIENT_MAX_JOBS", "2"))
+	if err != nil || n < 0 {
+		return 2
+	}
+	return n
+}
+
+// clientQueues reports whether excess jobs wait (CLIENT_LIMIT_MODE=queue,
+// the default) or are rejected with 429 (reject).
+func clientQueues() bool {
+	if m := currentConfig().Limits.ClientLimitMode; m != "" {
+		return m != "reject"
+	}
+	return envOr("CLIENT_LIMIT_MODE", "queue") != "reject"
+}
+
+// acquire takes a slot for key, waiting for one to free up in queue mode.
+func (c *clientSlots) acquire(ctx context.Context, key string) error {
+	for {
+		// re-read each time: the limit can change on a config reload
+		max := clientMaxJobs()
+		c.mu.Lock()
+		if max == 0 || c.active[key] < max {
+			c.active[key]++
+			c.mu.Unlock()
+			return nil
+		}
+		if !clientQueues() {
+			c.mu.Unlock()
+			return errClientBusy
+		}
+		ch := c.freed
+		c.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *clientSlots) release(key string) {
+	c.mu.Lock()
+	if c.active[key]--; c.active[key] <= 0 {
+		delete(c.active, key)
+	}
+	close(c.freed)
+	c.freed = make(chan struct{})
+	c.mu.Unlock()
+}
+
+// wake makes queued requests re-check their limit (after a config reload).
+func (c *clientSlots) wake() {
+	c.mu.Lock()
+	close(c.freed)
+	c.freed = make(chan struct{})
+	c.mu.Unlock()
+}
+
+// clientKey identifies the caller: a valid X-API-Key (for upload tokens, the
+// key that issued it, so its uploads share that key's jobs and kept inputs),
+// otherwise the remote IP. Unchecked keys are ignored so a client cannot
+// spread its jobs over invented keys.
+func clientKey(r *http.Request) string {
+	if g := grantOf(r); g != nil {
+		return "key:" + g.Issuer
+	}
+	return apiClientKey(strings.TrimSpace(r.Header.Get("X-API-Key")), r.RemoteAddr)
+}
+
+// apiClientKey is the client key for API key k sent from addr.
+func apiClientKey(k, addr string) string {
+	if validAPIKey(k) {
+		return "key:" + keyFingerprint(k)
+	}
+	return "ip:" + remoteIP(addr)
+}
+
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// limitClient wraps a job-creating handler so each client holds at most
+// CLIENT_MAX_JOBS slots at once. Only POST requests count as jobs.
+func limitClient(next http.HandlerFunc) http.HandlerFunc {
+	return limitClientWith(next, false)
+}
+
+// limitClientAsync is limitClient for handlers that may answer 202 and
+// encode in the background (async=1, re-runs). In queue mode the slot is not
+// taken up front: the handler calls holdSlot before encoding within the
+// request, or hands the lease to its background job with keepSlot, which
+// waits for the slot there so the job shows as queued meanwhile.
+func limitClientAsync(next http.HandlerFunc) http.HandlerFunc {
+	return limitClientWith(next, true)
+}
+
+func limitClientWith(next http.HandlerFunc, deferred bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+		r, ok := authorizeUpload(w, r)
+		if !ok {
+			return
+		}
+		lease := &slotLease{key: clientKey(r), ticket: queue.enter()}
+		// reject mode never waits, so over-limit requests get their 429 now
+		if !deferred || !clientQueues() {
+			if err := lease.take(r.Context()); err != nil {
+				queue.leave(lease.ticket)
+				slotRefused(w, lease.key, err)
+				return
+			}
+		}
+		defer lease.done()
+		next(w, r.WithContext(context.WithValue(r.Context(), slotCtxKey{}, lease)))
+	}
+}
+
+// slotRefused answers a request that did not get a job slot.
+func slotRefused(w http.ResponseWriter, key string, err error) {
+	requestID := randID(6)
+	if errors.Is(err, errClientBusy) {
+		logger.Printf("🚦 [%s] Client %s is at its job limit (%d), rejecting", requestID, key, clientMaxJobs())
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	logger.Printf("🚦 [%s] Client %s gave up waiting for a job slot: %v", requestID, key, err)
+}
+
+// slotLease is the slot and queue ticket limitClient holds for a request.
+type slotLease struct {
+	key    string
+	ticket *queueTicket
+	held   bool // the slot is taken
+	kept   bool // handed to a background job
+}
+
+type slotCtxKey struct{}
+
+func leaseOf(r *http.Request) *slotLease {
+	l, _ := r.Context().Value(slotCtxKey{}).(*slotLease)
+	return l
+}
+
+// take acquires the slot unless it is already held.
+func (l *slotLease) take(ctx context.Context) error {
+	if l == nil || l.held {
+		return nil
+	}
+	if err := slots.acquire(ctx, l.key); err != nil {
+		return err
+	}
+	l.held = true
+	queue.run(l.ticket)
+	return nil
+}
+
+// free releases the slot (if taken) and the queue ticket.
+func (l *slotLease) free() {
+	if l == nil {
+		return
+	}
+	if l.held {
+		slots.release(l.key)
+	}
+	queue.leave(l.ticket)
+}
+
+func (l *slotLease) done() {
+	if !l.kept {
+		l.free()
+	}
+}
+
+// holdSlot takes the job slot of r before it is encoded within the request
+// (see limitClientAsync). On failure it has answered the request.
+func holdSlot(w http.ResponseWriter, r *http.Request) bool {
+	l := leaseOf(r)
+	if err := l.take(r.Context()); err != nil {
+		slotRefused(w, l.key, err)
+		return false
+	}
+	return true
+}
+
+// keepSlot hands the lease of r to a background job that outlives the
+// request. The job takes the slot with take (a no-op when it is already
+// held) and releases everything with free; both accept nil.
+func keepSlot(r *http.Request) *slotLease {
+	l := leaseOf(r)
+	if l != nil {
+		l.kept = true
+	}
+	return l
+}
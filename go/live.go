@@ -0,0 +1,494 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Live ingest (RTMP/SRT or pulled URL → transcode → record/restream/HLS)
+// ======================
+
+const (
+	liveListening = "listening" // ffmpeg waits for the publisher / is transcoding
+	liveEnded     = "ended"
+	liveFailed    = "failed"
+)
+
+type liveSession struct {
+	ID         string
+	Protocol   string // rtmp|srt|pull
+	Source     string // input URL when Protocol is pull
+	Port       int
+	StreamKey  string
+	IngestURL  string
+	State      string
+	Record     bool
+	RecordPath string
+	ResultID   string
+	Restream   []string
+	HLS        bool
+	HLSDir     string
+	HLSTime    int // segment duration (seconds)
+	HLSSize    int // playlist window (segments)
+	Opts       compressOpts
+	Client     clientMeta
+	Started    time.Time
+	Ended      time.Time
+	Error      string
+
+	stopping bool // DELETE was requested; a non-zero exit is expected
+	cmd      *exec.Cmd
+	stderr   *bytes.Buffer
+}
+
+var (
+	livePullSchemes = map[string]bool{"http": true, "https": true, "rtmp": true, "rtmps": true, "rtsp": true, "srt": true}
+	liveHLSName     = regexp.MustCompile(`^(index\.m3u8|seg_\d{5,}\.ts)$`)
+
+	liveMu       sync.Mutex
+	liveSessions = map[string]*liveSession{}
+	livePorts    = map[int]bool{}
+)
+
+func liveMaxSessions() int {
+	n, err := strconv.Atoi(envOr("LIVE_MAX_SESSIONS", "4"))
+	if err != nil || n < 1 {
+		return 4
+	}
+	return n
+}
+
+// allocLivePort picks a free port in LIVE_PORT_MIN..LIVE_PORT_MAX. Caller holds liveMu.
+func allocLivePort() (int, error) {
+	lo, _ := strconv.Atoi(envOr("LIVE_PORT_MIN", "19350"))
+	hi, _ := strconv.Atoi(envOr("LIVE_PORT_MAX", "19399"))
+	for p := lo; p <= hi; p++ {
+		if livePorts[p] {
+			continue
+		}
+		// make sure nothing else on the host holds it
+		l, err := net.Listen("tcp", ":"+strconv.Itoa(p))
+		if err != nil {
+			continue
+		}
+		l.Close()
+		livePorts[p] = true
+		return p, nil
+	}
+	return 0, errors.New("no free live ingest port")
+}
+
+// liveInputURL is the listener URL ffmpeg binds for the publisher.
+func liveInputURL(s *liveSession) string {
+	if s.Protocol == "srt" {
+		return fmt.Sprintf("srt://0.0.0.0:%d?mode=listener&latency=200000&streamid=%s", s.Port, s.StreamKey)
+	}
+	return fmt.Sprintf("rtmp://0.0.0.0:%d/live/%s", s.Port, s.StreamKey)
+}
+
+// buildLiveArgs transcodes the ingest with the speed profile of the session
+// (low-latency x264 + AAC) and fans out to the recording and restream targets.
+func buildLiveArgs(s *liveSession) []string {
+	o := s.Opts
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	if s.Protocol == "rtmp" {
+		args = append(args, "-listen", "1")
+	}
+	if s.Protocol == "pull" {
+		if strings.HasPrefix(s.Source, "http") {
+			// plain files over HTTP would otherwise be read faster than real time
+			args = append(args, "-re")
+		}
+		args = append(args, "-i", s.Source)
+	} else {
+		args = append(args, "-i", liveInputURL(s))
+	}
+
+	fps := o.FPS
+	if fps == 0 {
+		fps = 30
+	}
+	var vf []string
+	switch o.SpeedMode {
+	case "turbo":
+		vf = append(vf, "scale='if(gt(a,1),-2,720)':'if(gt(a,1),720,-2)':flags=fast_bilinear")
+	case "max":
+		vf = append(vf, "scale='if(gt(a,1),-2,480)':'if(gt(a,1),480,-2)':flags=fast_bilinear")
+	default:
+		if o.Scale != "" {
+			// keep aspect: fit the target height, even width
+			h := o.Scale[strings.IndexByte(o.Scale, ':')+1:]
+			vf = append(vf, "scale=-2:"+h+":flags=fast_bilinear")
+		}
+	}
+	if len(vf) > 0 {
+		args = append(args, "-vf", strings.Join(vf, ","))
+	}
+	args = append(args, "-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", o.Preset, "-tune", "zerolatency",
+		"-crf", strconv.Itoa(o.CRF), "-maxrate", "6M", "-bufsize", "12M",
+		"-r", strconv.Itoa(fps), "-g", strconv.Itoa(fps*2), "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", o.AB, "-ar", "48000")
+
+	var outs []string
+	if s.Record {
+		// fragmented MP4 stays playable if the publisher drops mid-stream
+		outs = append(outs, "[f=mp4:movflags=+frag_keyframe+empty_moov+default_base_moof]"+s.RecordPath)
+	}
+	for _, u := range s.Restream {
+		outs = append(outs, "[f=flv:onfail=ignore]"+u)
+	}
+	if s.HLS {
+		// sliding window: old segments are deleted as new ones are written
+		outs = append(outs, fmt.Sprintf("[f=hls:hls_time=%d:hls_list_size=%d:hls_flags=delete_segments+independent_segments:hls_segment_filename=%s]%s",
+			s.HLSTime, s.HLSSize, filepath.Join(s.HLSDir, "seg_%05d.ts"), filepath.Join(s.HLSDir, "index.m3u8")))
+	}
+	return append(args, "-f", "tee", strings.Join(outs, "|"))
+}
+
+func (s *liveSession) view() map[string]any {
+	v := map[string]any{
+		"id":         s.ID,
+		"protocol":   s.Protocol,
+		"ingest_url": s.IngestURL,
+		"stream_key": s.StreamKey,
+		"state":      s.State,
+		"mode":       s.Opts.SpeedMode,
+		"resolution": s.Opts.Resolution,
+		"record":     s.Record,
+		"restream":   len(s.Restream),
+		"started_at": s.Started.UTC().Format(time.RFC3339),
+	}
+	if !s.Ended.IsZero() {
+		v["ended_at"] = s.Ended.UTC().Format(time.RFC3339)
+	}
+	s.Client.addTo(v)
+	if s.Source != "" {
+		v["source"] = s.Source
+	}
+	if s.HLS {
+		v["hls_url"] = "/live/" + s.ID + "/index.m3u8"
+	}
+	if s.ResultID != "" {
+		v["result_id"] = s.ResultID
+		v["download_url"] = "/dl/" + s.ResultID
+	}
+	if s.Error != "" {
+		v["error"] = s.Error
+	}
+	return v
+}
+
+// startLive launches the listener and a goroutine that finalizes the
+// session (recording → result store) when ffmpeg exits.
+func startLive(requestID string, s *liveSession) error {
+	args := buildLiveArgs(s)
+	logger.Printf("📡 [%s] Live FFmpeg command: ffmpeg %s", requestID, strings.Join(args, " "))
+	s.stderr = &bytes.Buffer{}
+	s.cmd = exec.Command(ffmpegFor(args), args...)
+	s.cmd.Stderr = s.stderr
+	if err := s.cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		err := s.cmd.Wait()
+		liveMu.Lock()
+		defer liveMu.Unlock()
+		delete(livePorts, s.Port)
+		if s.HLS {
+			// give players a minute to fetch the final segments
+			time.AfterFunc(time.Minute, func() { os.RemoveAll(s.HLSDir) })
+		}
+		s.Ended = time.Now()
+		s.State = liveEnded
+		if err != nil && !s.stopping {
+			s.State = liveFailed
+			s.Error = lastLine(s.stderr.String())
+		}
+		if s.Record {
+			if st, statErr := os.Stat(s.RecordPath); statErr == nil && st.Size() > 0 {
+				s.ResultID = storeResult(requestID, &resultEntry{
+					FilePath:    s.RecordPath,
+					ModeFinal:   s.Opts.SpeedMode,
+					ModeDecider: "manual",
+					OutputBytes: st.Size(),
+					Resolution:  s.Opts.Resolution,
+					Codec:       "h264",
+					Audio:       "aac",
+					HW:          "none",
+					ElapsedMs:   s.Ended.Sub(s.Started).Milliseconds(),
+					Client:      s.Client,
+				})
+			} else {
+				s.State = liveFailed
+				if s.Error == "" {
+					s.Error = "no stream was received"
+				}
+			}
+		}
+		logger.Printf("📴 [%s] Live session %s %s", requestID, s.ID, s.State)
+	}()
+	return nil
+}
+
+func createLiveSession(w http.ResponseWriter, r *http.Request, requestID string) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	proto := strings.ToLower(r.FormValue("protocol"))
+	if proto == "" {
+		proto = "rtmp"
+	}
+	source := r.FormValue("source")
+	if source != "" {
+		proto = "pull"
+		u, err := url.Parse(source)
+		if err != nil || !livePullSchemes[u.Scheme] || u.Hostname() == "" {
+			http.Error(w, "source must be an http(s), rtmp(s), rtsp or srt URL", http.StatusBadRequest)
+			return
+		}
+		if err := checkPublicHost(r.Context(), u.Hostname()); err != nil {
+			http.Error(w, "source: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if proto != "rtmp" && proto != "srt" && proto != "pull" {
+		http.Error(w, "protocol must be rtmp or srt", http.StatusBadRequest)
+		return
+	}
+	opts, err := parseOpts(r)
+	if err != nil {
+		http.Error(w, err.Error(), optsErrorStatus(err))
+		return
+	}
+	if opts.SpeedMode == "ai" {
+		opts.SpeedMode = "fast" // no file size to decide on for a live feed
+	}
+	opts.applySpeedMode()
+
+	s := &liveSession{
+		ID:        randID(8),
+		Protocol:  proto,
+		Source:    source,
+		HLS:       r.FormValue("hls") == "1" || r.FormValue("hls") == "true",
+		HLSTime:   4,
+		HLSSize:   6,
+		StreamKey: randID(10),
+		State:     liveListening,
+		Record:    r.FormValue("record") != "0",
+		Opts:      opts,
+		Client:    opts.Client,
+		Started:   time.Now(),
+	}
+	for _, u := range r.Form["restream"] {
+		t, err := url.Parse(u)
+		if err != nil || (t.Scheme != "rtmp" && t.Scheme != "rtmps") || t.Hostname() == "" {
+			http.Error(w, "restream targets must be rtmp:// or rtmps:// URLs", http.StatusBadRequest)
+			return
+		}
+		if err := checkPublicHost(r.Context(), t.Hostname()); err != nil {
+			http.Error(w, "restream: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		s.Restream = append(s.Restream, u)
+	}
+	if v := r.FormValue("hls_time"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 30 {
+			http.Error(w, "hls_time must be 1-30 seconds", http.StatusBadRequest)
+			return
+		}
+		s.HLSTime = n
+	}
+	if v := r.FormValue("hls_list_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 2 || n > 60 {
+			http.Error(w, "hls_list_size must be 2-60", http.StatusBadRequest)
+			return
+		}
+		s.HLSSize = n
+	}
+	if !s.Record && len(s.Restream) == 0 && !s.HLS {
+		http.Error(w, "nothing to do: enable record or hls, or add a restream target", http.StatusBadRequest)
+		return
+	}
+	s.RecordPath = outputPath(requestID, "live_"+s.ID+".mp4")
+	if s.HLS {
+		dir, err := os.MkdirTemp("", "live_"+s.ID+"_hls_")
+		if err != nil {
+			http.Error(w, "hls dir: "+err.Error(), 500)
+			return
+		}
+		s.HLSDir = dir
+	}
+	started := false
+	defer func() {
+		if !started {
+			os.RemoveAll(s.HLSDir)
+		}
+	}()
+
+	liveMu.Lock()
+	active := 0
+	for _, x := range liveSessions {
+		if x.State == liveListening {
+			active++
+		}
+	}
+	if active >= liveMaxSessions() {
+		liveMu.Unlock()
+		http.Error(w, "too many live sessions", http.StatusServiceUnavailable)
+		return
+	}
+	if proto != "pull" {
+		port, err := allocLivePort()
+		if err != nil {
+			liveMu.Unlock()
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		s.Port = port
+		host := envOr("LIVE_PUBLIC_HOST", "")
+		if host == "" {
+			host, _, _ = net.SplitHostPort(r.Host)
+			if host == "" {
+				host = r.Host
+			}
+		}
+		if proto == "srt" {
+			s.IngestURL = fmt.Sprintf("srt://%s:%d?streamid=%s", host, port, s.StreamKey)
+		} else {
+			s.IngestURL = fmt.Sprintf("rtmp://%s:%d/live/%s", host, port, s.StreamKey)
+		}
+	}
+	liveSessions[s.ID] = s
+	if err := startLive(requestID, s); err != nil {
+		delete(liveSessions, s.ID)
+		delete(livePorts, s.Port)
+		liveMu.Unlock()
+		http.Error(w, "live start failed: "+err.Error(), 500)
+		return
+	}
+	started = true
+	view := s.view()
+	liveMu.Unlock()
+
+	if proto == "pull" {
+		logger.Printf("📡 [%s] Live session %s pulling %s", requestID, s.ID, s.Source)
+	} else {
+		logger.Printf("📡 [%s] Live session %s listening on %s", requestID, s.ID, s.IngestURL)
+	}
+	writeJSON(w, http.StatusCreated, view)
+}
+
+func stopLiveSession(s *liveSession) {
+	liveMu.Lock()
+	s.stopping = true
+	liveMu.Unlock()
+	if s.cmd != nil && s.cmd.Process != nil {
+		// SIGINT lets ffmpeg finalize the recording
+		_ = s.cmd.Process.Signal(os.Interrupt)
+	}
+}
+
+// serveLiveHLS serves the playlist and segments of a session's HLS window.
+func serveLiveHLS(w http.ResponseWriter, r *http.Request, s *liveSession, name string) {
+	liveMu.Lock()
+	dir, hls := s.HLSDir, s.HLS
+	liveMu.Unlock()
+	if !hls || !liveHLSName.MatchString(name) {
+		http.NotFound(w, r)
+		return
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		// playlist is written once the first segment completes
+		http.NotFound(w, r)
+		return
+	}
+	if strings.HasSuffix(name, ".m3u8") {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeFile(w, r, path)
+}
+
+// liveHandler serves POST/GET /live, GET/DELETE /live/{id} and
+// GET /live/{id}/index.m3u8 (+ segments).
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(6)
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/live"), "/")
+
+	if rest == "" {
+		switch r.Method {
+		case http.MethodPost:
+			createLiveSession(w, r, requestID)
+		case http.MethodGet:
+			liveMu.Lock()
+			list := make([]map[string]any, 0, len(liveSessions))
+			for _, s := range liveSessions {
+				list = append(list, s.view())
+			}
+			liveMu.Unlock()
+			sort.Slice(list, func(i, j int) bool {
+				return list[i]["started_at"].(string) > list[j]["started_at"].(string)
+			})
+			writeJSON(w, http.StatusOK, map[string]any{"sessions": list})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, file, _ := strings.Cut(rest, "/")
+	// HLS files stay open to players; the session itself needs the key
+	if file == "" && !authorizeRead(w, r) {
+		return
+	}
+	liveMu.Lock()
+	s, ok := liveSessions[id]
+	liveMu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if file != "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		serveLiveHLS(w, r, s, file)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		liveMu.Lock()
+		view := s.view()
+		liveMu.Unlock()
+		writeJSON(w, http.StatusOK, view)
+	case http.MethodDelete:
+		logger.Printf("🛑 [%s] Stopping live session %s", requestID, id)
+		stopLiveSession(s)
+		writeJSON(w, http.StatusAccepted, map[string]any{"id": id, "stopping": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
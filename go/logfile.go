@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ======================
+// Log file (LOG_FILE) with rotation
+// ======================
+
+// rotatingFile is an append-only log file that can be swapped out by the
+// rotate_logs task while the logger keeps writing.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+var logFile *rotatingFile
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{path: path, f: f}, nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Write(p)
+}
+
+// rotate renames the current file to <path>.<timestamp>, reopens a fresh one
+// and deletes all but the newest keep rotated files.
+func (r *rotatingFile) rotate(keep int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rotated := r.path + "." + time.Now().Format("20060102-150405")
+	if err := os.Rename(r.path, rotated); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", err
+	}
+	r.f.Close()
+	r.f = f
+
+	old, _ := filepath.Glob(r.path + ".*")
+	sort.Strings(old) // timestamps sort chronologically
+	for len(old) > keep {
+		os.Remove(old[0])
+		old = old[1:]
+	}
+	return rotated, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ======================
+// Lossless mode (speed=lossless)
+// ======================
+
+// Lossless re-encodes normalize the codec/container without touching a
+// single pixel: FFV1 in MKV, VP9 lossless in WebM, otherwise x264 -qp 0
+// (or x265 lossless=1 for codec=h265). Audio is copied. The price is size,
+// which the warnings spell out.
+
+const losslessSizeWarning = "lossless output is typically several times larger than the source"
+
+// applyLossless picks the lossless encoder for the container and drops
+// every option that would lose information. It returns the adjustments.
+func (o *compressOpts) applyLossless() []string {
+	notes := []string{losslessSizeWarning}
+	if o.Resolution != "original" || o.Scale != "" {
+		notes = append(notes, fmt.Sprintf("resolution %s ignored in lossless mode", o.Resolution))
+		o.Resolution, o.Scale = "original", ""
+	}
+	if o.MaxLandscape != (resCap{}) || o.MaxPortrait != (resCap{}) {
+		notes = append(notes, "max_landscape/max_portrait ignored in lossless mode")
+		o.MaxLandscape, o.MaxPortrait = resCap{}, resCap{}
+	}
+	if o.FPS > 0 {
+		notes = append(notes, "fps ignored in lossless mode")
+		o.FPS = 0
+	}
+	if strings.ToLower(o.HW) != "none" {
+		notes = append(notes, "hardware encoders cannot encode losslessly; using CPU")
+		o.HW = "none"
+	}
+	if o.BitDepth == 8 {
+		o.BitDepth = 0 // keep the source pixel format
+	}
+	if o.Codec != "copy" {
+		switch strings.ToLower(o.OutExt) {
+		case ".mkv":
+			o.Codec = "ffv1"
+		case ".webm":
+			o.Codec = "vp9"
+		default:
+			if o.Codec != "h265" {
+				o.Codec = "h264"
+			}
+		}
+	}
+	o.Audio = "copy"
+	return notes
+}
+
+// losslessArgs replaces the rate control of vcodec with its lossless mode.
+func losslessArgs(vcodec string) []string {
+	switch vcodec {
+	case "libx264":
+		return []string{"-qp", "0", "-preset", "veryfast"}
+	case "libx265":
+		return []string{"-preset", "veryfast", "-x265-params", "lossless=1"}
+	case "libvpx-vp9":
+		return []string{"-lossless", "1", "-row-mt", "1"}
+	case "ffv1":
+		// archival settings: intra-only, per-slice CRCs
+		return []string{"-level", "3", "-g", "1", "-slices", "16", "-slicecrc", "1"}
+	}
+	return nil
+}
+
+// losslessSizeNote reports how much bigger the result came out.
+func losslessSizeNote(inputBytes, outputBytes int64) string {
+	if inputBytes <= 0 || outputBytes <= inputBytes {
+		return ""
+	}
+	return fmt.Sprintf("lossless output is %.1f× the input size (%s → %s)",
+		float64(outputBytes)/float64(inputBytes), humanBytes(inputBytes), humanBytes(outputBytes))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ======================
+// EBU R128 loudness measurement (POST /measure-loudness)
+// ======================
+
+// loudnessReport is the BS.1770 measurement of one audio stream.
+type loudnessReport struct {
+	Stream          int             `json:"stream"`
+	IntegratedLUFS  float64         `json:"integrated_lufs"`
+	TruePeakDBTP    float64         `json:"true_peak_dbtp"`
+	LoudnessRangeLU float64         `json:"loudness_range_lu"`
+	ThresholdLUFS   float64         `json:"threshold_lufs"`
+	DurationSec     float64         `json:"duration_sec,omitempty"`
+	Compliance      map[string]bool `json:"compliance"`
+}
+
+// loudnessSpec is a delivery target: integrated loudness ± tolerance and a
+// true-peak ceiling.
+type loudnessSpec struct {
+	Target, Tolerance, MaxPeak float64
+}
+
+var loudnessSpecs = map[string]loudnessSpec{
+	"ebu_r128":  {Target: -23, Tolerance: 0.5, MaxPeak: -1}, // EBU R128 (file delivery)
+	"atsc_a85":  {Target: -24, Tolerance: 2, MaxPeak: -2},   // ATSC A/85
+	"streaming": {Target: -14, Tolerance: 1, MaxPeak: -1},   // common platform target
+}
+
+// measureLoudness runs loudnorm's analysis pass (same integrator as
+// ebur128) and parses its JSON summary.
+func measureLoudness(ctx context.Context, inPath string, stream int) (*loudnessReport, error) {
+	args := []string{"-hide_banner", "-nostats", "-i", inPath,
+		"-map", "0:a:" + strconv.Itoa(stream), "-af", "loudnorm=print_format=json", "-f", "null", "-"}
+	out, err := exec.CommandContext(ctx, ffmpegFor(args), args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("loudness analysis failed: %s", lastLine(string(out)))
+	}
+	i := bytes.LastIndexByte(out, '{')
+	j := bytes.LastIndexByte(out, '}')
+	if i < 0 || j < i {
+		return nil, errors.New("no loudness summary in ffmpeg output")
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(out[i:j+1], &raw); err != nil {
+		return nil, fmt.Errorf("loudness summary: %w", err)
+	}
+	num := func(k string) float64 {
+		v, err := strconv.ParseFloat(raw[k], 64)
+		if err != nil || math.IsInf(v, 0) {
+			return -70 // silence reports -inf; -70 LUFS is the BS.1770 absolute gate
+		}
+		return math.Round(v*10) / 10
+	}
+	rep := &loudnessReport{
+		Stream:          stream,
+		IntegratedLUFS:  num("input_i"),
+		TruePeakDBTP:    num("input_tp"),
+		LoudnessRangeLU: max(num("input_lra"), 0),
+		ThresholdLUFS:   num("input_thresh"),
+		Compliance:      map[string]bool{},
+	}
+	for name, s := range loudnessSpecs {
+		rep.Compliance[name] = math.Abs(rep.IntegratedLUFS-s.Target) <= s.Tolerance && rep.TruePeakDBTP <= s.MaxPeak
+	}
+	return rep, nil
+}
+
+func measureLoudnessHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🔊 [%s] New loudness measurement from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseMediaUpload(w, r, requestID) {
+		return
+	}
+	stream := 0
+	if s := r.FormValue("stream"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid stream", http.StatusBadRequest)
+			return
+		}
+		stream = n
+	}
+	inPath, _, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file field required", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(inPath)
+
+	src, err := probeFile(r.Context(), inPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	n := 0
+	for _, s := range src.Streams {
+		if s.CodecType == "audio" {
+			n++
+		}
+	}
+	if stream >= n {
+		http.Error(w, fmt.Sprintf("no audio stream %d (file has %d)", stream, n), http.StatusUnprocessableEntity)
+		return
+	}
+
+	rep, err := measureLoudness(r.Context(), inPath, stream)
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	rep.DurationSec = src.durationSec()
+	logger.Printf("📏 [%s] Loudness: %.1f LUFS, %.1f dBTP, LRA %.1f LU", requestID, rep.IntegratedLUFS, rep.TruePeakDBTP, rep.LoudnessRangeLU)
+	writeJSON(w, http.StatusOK, rep)
+}
@@ -15,6 +15,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -32,6 +34,15 @@ var (
 func init() {
 	// Create a custom logger with timestamp and process info
 	logger = log.New(os.Stdout, "", log.LstdFlags|log.Lmicroseconds)
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		// also write to a file the rotate_logs task can rotate
+		if f, err := openRotatingFile(path); err == nil {
+			logFile = f
+			logger.SetOutput(io.MultiWriter(os.Stdout, f))
+		} else {
+			logger.Printf("⚠️ Could not open LOG_FILE: %v", err)
+		}
+	}
 	
 	// Log startup information
 	logger.Printf("🚀 VideoCompress API starting up...")
@@ -49,7 +60,7 @@ func getCurrentDir() string {
 }
 
 func isFFmpegAvailable() bool {
-	_, err := exec.LookPath("ffmpeg")
+	_, err := exec.LookPath(ffmpegBin())
 	return err == nil
 }
 
@@ -83,6 +94,12 @@ func randID(n int) string {
 	return hex.EncodeToString(b)
 }
 
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func humanBytes(n int64) string {
 	const k = 1024.0
 	f := float64(n)
@@ -103,17 +120,84 @@ func humanBytes(n int64) string {
 // ======================
 
 type compressOpts struct {
-	Codec      string // h264|h265|copy
+	Codec      string // h264|h265|vp9|av1|ffv1|prores|copy
 	CRF        int    // CPU encoders quality
 	Preset     string // ultrafast..placebo (CPU encoders)
 	Scale      string // e.g. 1280:-2 or 1920:1080 (fixed WxH). Leave empty to auto.
 	FPS        int    // force output fps if >0
 	Audio      string // aac|opus|copy
 	AB         string // audio bitrate (e.g. 128k)
+	DolbyAB    string // ab for audio=ac3|eac3 ("" = by channel count)
 	HW         string // videotoolbox|none
-	OutExt     string // .mp4 (recommended)
-	SpeedMode  string // ultra_fast|super_fast|fast|balanced|quality|ai|max|turbo
+	OutExt     string // .mp4 (recommended)|.m4v|.mov|.mkv|.webm|.ts|.avi
+	SpeedMode  string // ultra_fast|super_fast|fast|balanced|quality|ai|max|turbo|proxy|screen|lossless|archive
 	Resolution string // 360p|480p|720p|1080p|1440p|2160p|original
+
+	StripMetadata    bool   // drop global/stream tags (GPS, device, creation time)
+	PreserveMetadata bool   // explicitly copy global/stream tags
+	Chapters         string // keep|drop|export
+	Captions         string // auto|keep|drop|extract (embedded CEA-608/708)
+	KeepAllAudio     bool   // every audio track with its language, not just the first
+	TrackAB          map[int]string // audio.N.ab: per-track bitrate overrides
+	PreserveCapture  bool   // copy creation_time and rotation/display matrix
+	Cover            string // keep|drop (embedded cover art)
+	PosterPath       string // uploaded image to embed as cover (overrides source cover)
+	Compat           string // codec|container|strict (how to resolve codec/container conflicts)
+	Content          string // animation|film|screencast|sports (encoder tuning hint, optional)
+	OutputName       string // download name template (output_name), e.g. {basename}_{mode}
+	SourceName       string // original upload filename (for {basename})
+	HWDecode         string // none|auto|videotoolbox|cuda|vaapi|qsv (decode only; "" = follow hw)
+	BitDepth         int    // 8|10 (0 = auto: keep 10-bit sources in quality mode)
+	PixFmt           string // pix_fmt: explicit pixel format (e.g. yuv422p10le)
+	Interlace        string // interlace=keep|tff|bff ("" = progressive)
+	FilmGrain        int    // AV1 film-grain synthesis level 0-50 (-1 = auto)
+	FilmGrainDenoise string // 0|1 ("" = encoder default)
+	Alpha            string // auto|keep|drop (resolved to keep|drop before encoding)
+	Projection       string // equirectangular: tag the output as 360° (injected)
+	Stereo           string // mono|top-bottom|left-right (with Projection)
+	MaxLandscape     resCap // bounding box for landscape/square sources (max_landscape)
+	MaxPortrait      resCap // bounding box for portrait sources (max_portrait)
+	AutoCrop         bool   // detect and crop black bars (autocrop=1)
+	Crop             string // w:h:x:y found by detectCrop or given as crop=
+	Rotate           int    // rotate=90|180|270: clockwise turn after the crop
+	Flip             string // flip=h|v: mirror after the turn
+	Reframe          *reframeSpec // reframe=9:16: crop to another aspect ratio
+	Compare          *compareSpec // compare=1: side-by-side clip artifact
+	Thumbnails       *thumbSpec   // thumbnails=N: stills of the output as artifacts
+	Preview          *previewSpec // preview=gif|webp: animated preview artifact
+	Storyboard       *storyboardSpec // storyboard=1: sprite sheets + WebVTT track
+	KeepInput        *keepInputSpec  // keep_input=1: retain the upload under an input ID
+	TrimDead         string // silence|black|both: cut leading/trailing dead air
+	TrimStart        float64
+	TrimEnd          float64 // 0 = to the end
+	GOPFrames        int     // gop=N: keyframe interval in frames
+	GOPSec           float64 // gop=Ns: keyframe interval in seconds
+	Race             *raceSpec // race=[...]: concurrent candidate encodes
+	SkipVerify       bool      // verify=0: skip the post-encode verification pass
+	ValidateFor      string    // validate_for=quicktime|android|web
+	MaxOutputBytes   int64     // max_output_bytes (0 = no limit)
+	OnOversize       string    // reencode|fail
+	SegmentSec       float64   // segment_sec: checkpointed segment encode
+	VideoOnly        bool      // internal: a segment_sec piece; the audio is encoded when joining
+	SplitMaxBytes    int64     // segment_max_size: split the output into a ZIP of parts
+	SplitMaxSec      float64   // segment_max_sec: longest part when splitting
+	Package          *packageSpec // format=hls|dash: playlist/manifest + segments instead of one file
+	Renditions       []rendition // renditions=1080p,720p: ladder, the first is the one being encoded
+	Watermark        *watermarkSpec // job spec only
+	CallbackURL      string         // callback_url: POSTed when the job ends
+	OutputS3         *s3Location    // output=s3://bucket/key: upload the result there
+	AudioOut         *audioOutSpec  // output=audio: drop the picture
+
+	// Client is the caller's metadata/tag, echoed back with the result.
+	Client clientMeta
+	// Deliver lists extra destinations for the result (deliver=[...]).
+	Deliver []deliveryTarget
+	// Token is the ID of the upload token that submitted the encode ("" with
+	// an API key).
+	Token string
+
+	// Source is the ffprobe report of the input (nil if ffprobe is unavailable).
+	Source *probeResult
 }
 
 func (o *compressOpts) normalize() {
@@ -129,6 +213,13 @@ func (o *compressOpts) normalize() {
 	if o.HW == "" {
 		o.HW = "none" // VPS-safe default
 	}
+	if o.HWDecode == "" {
+		// Historical behaviour: hw=videotoolbox also decodes on the GPU
+		o.HWDecode = "none"
+		if strings.ToLower(o.HW) == "videotoolbox" {
+			o.HWDecode = "videotoolbox"
+		}
+	}
 	if o.OutExt == "" {
 		o.OutExt = ".mp4"
 	}
@@ -138,23 +229,29 @@ func (o *compressOpts) normalize() {
 	if o.Resolution == "" {
 		o.Resolution = "original"
 	}
+	if o.Chapters == "" {
+		o.Chapters = "keep"
+	}
+	if o.Captions == "" {
+		o.Captions = "auto"
+	}
+	if o.Cover == "" {
+		o.Cover = "keep"
+	}
+	if o.Compat == "" {
+		o.Compat = "codec"
+	}
 	o.applyResolution()
 }
 
-func chooseSpeedBySize(sizeMB int64) string {
-	switch {
-	case sizeMB >= 700:
-		return "ultra_fast"
-	case sizeMB >= 200:
-		return "super_fast"
-	case sizeMB >= 50:
-		return "fast"
-	case sizeMB >= 10:
-		return "balanced"
-	default:
-		return "balanced"
-	}
-}
+// speedModes lists every accepted speed value.
+var speedModes = []string{"ai", "turbo", "max", "proxy", "ultra_fast", "super_fast", "fast", "balanced", "quality", "screen", "lossless", "archive"}
+
+// proxy mode bitrate cap (review copies and editing proxies)
+const (
+	proxyMaxRate = "400k"
+	proxyBufSize = "800k"
+)
 
 // Apply speed profile → CRF/Preset/AB
 func (o *compressOpts) applySpeedMode() {
@@ -169,6 +266,12 @@ func (o *compressOpts) applySpeedMode() {
 		o.CRF = 36
 		o.Preset = "ultrafast"
 		o.AB = "64k"
+	case "proxy":
+		// Review/editing proxies: 240p, 15 fps and a bitrate cap handled in
+		// buildFFmpegArgs
+		o.CRF = 30
+		o.Preset = "veryfast"
+		o.AB = "64k"
 	case "ultra_fast":
 		o.CRF = 32
 		o.Preset = "ultrafast"
@@ -185,6 +288,23 @@ func (o *compressOpts) applySpeedMode() {
 		o.CRF = 23
 		o.Preset = "fast"
 		o.AB = "128k"
+	case "screen":
+		// Screencasts: mostly static, sharp-edged frames; bits go to the
+		// few changing regions, so a high CRF still reads well.
+		o.CRF = 32
+		o.Preset = "faster"
+		o.AB = "64k"
+	case "lossless":
+		// rate control comes from losslessArgs; AB only matters if the
+		// container forced an audio re-encode
+		o.CRF = 0
+		o.Preset = "veryfast"
+		o.AB = "320k"
+	case "archive":
+		// storage over speed: see applyArchive
+		o.CRF = 20
+		o.Preset = "slow"
+		o.AB = "192k"
 	default: // balanced
 		if o.CRF == 0 {
 			o.CRF = 26
@@ -193,9 +313,24 @@ func (o *compressOpts) applySpeedMode() {
 			o.Preset = "veryfast"
 		}
 	}
+	// imported mode overrides (see /admin/profiles)
+	if ov, ok := lookupModeOverride(o.SpeedMode); ok {
+		if ov.CRF > 0 {
+			o.CRF = ov.CRF
+		}
+		if ov.Preset != "" {
+			o.Preset = ov.Preset
+		}
+		if ov.AB != "" {
+			o.AB = ov.AB
+		}
+	}
 }
 
 func (o *compressOpts) applyResolution() {
+	if len(o.Renditions) > 0 {
+		return // sized per rendition by settleRenditions
+	}
 	switch o.Resolution {
 	case "360p":
 		o.Scale = "640:360"
@@ -216,27 +351,91 @@ func (o *compressOpts) applyResolution() {
 	}
 }
 
-// Extra safety for very small inputs
-func (o *compressOpts) tinyInputSafety(fileSize int64) {
+// Extra safety for very small inputs. A codec asked for with an explicit
+// bit_depth, and the audio codec audio.N.ab was checked against, are kept;
+// other codec changes are returned as warnings.
+func (o *compressOpts) tinyInputSafety(fileSize int64) []string {
 	sizeMB := fileSize / (1024 * 1024)
-	if sizeMB < 10 {
+	if sizeMB >= 10 || o.SpeedMode == "lossless" || o.SpeedMode == "archive" || o.Alpha == "keep" || o.PixFmt != "" {
+		return nil
+	}
+	codec, audio := o.Codec, o.Audio
+	if o.BitDepth == 0 {
 		o.Codec = "h264"
-		o.Audio = "aac"
-		o.Scale = ""
-		o.CRF = 22
-		o.Preset = "veryfast"
-		o.HW = "none"
 	}
+	if !isDolby(o.Audio) && len(o.TrackAB) == 0 {
+		o.Audio = "aac" // an explicit Dolby track is what the player needs
+	}
+	if c := outputContainers[strings.ToLower(o.OutExt)]; !c.Video[o.Codec] && c.PreferVideo != "" {
+		// e.g. .webm: stay within the container's codecs
+		o.Codec = c.PreferVideo
+		if len(o.TrackAB) == 0 {
+			o.Audio = c.PreferAudio
+		}
+	}
+	o.Scale = ""
+	o.CRF = 22
+	o.Preset = "veryfast"
+	o.HW = "none"
+	o.HWDecode = "none"
+	var notes []string
+	if !strings.EqualFold(codec, o.Codec) && !strings.EqualFold(codec, "h264") {
+		notes = append(notes, fmt.Sprintf("small input (<10 MB): codec=%s replaced by %s", codec, o.Codec))
+	}
+	if !strings.EqualFold(audio, o.Audio) && !strings.EqualFold(audio, "aac") {
+		notes = append(notes, fmt.Sprintf("small input (<10 MB): audio=%s replaced by %s", audio, o.Audio))
+	}
+	return notes
 }
 
 // ffmpeg args (orientation‑aware for turbo/max)
 func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
-	// Base flags; try HW decode on mac when enabled
+	if o.AudioOut != nil {
+		return audioOutArgs(inPath, outPath, o)
+	}
+	// Base flags; HW decode is independent of the encoder. Frames only stay
+	// on the GPU when the matching HW encoder consumes them; otherwise they
+	// are downloaded so CPU filters/x264 can use them.
 	args := []string{"-y", "-hide_banner", "-loglevel", "error"}
-	if strings.ToLower(o.HW) == "videotoolbox" {
-		args = append(args, "-hwaccel", "videotoolbox", "-hwaccel_output_format", "videotoolbox")
+	if dec := strings.ToLower(o.HWDecode); dec != "" && dec != "none" && strings.ToLower(o.Codec) != "copy" {
+		args = append(args, "-hwaccel", dec)
+		if dec == "videotoolbox" && strings.ToLower(o.HW) == "videotoolbox" {
+			args = append(args, "-hwaccel_output_format", "videotoolbox")
+		}
+	}
+	// Keep the original display matrix instead of rotating pixels, so
+	// photo-library apps see the same orientation metadata as the source.
+	// (crop/rotate/flip work on the upright picture, so they turn the pixels)
+	rotation := 0
+	if o.PreserveCapture && o.Source != nil && !o.transformed() {
+		if vs := o.Source.firstStream("video"); vs != nil {
+			rotation = vs.rotation()
+		}
+		if rotation != 0 && strings.ToLower(o.Codec) != "copy" {
+			args = append(args, "-noautorotate")
+		}
+	}
+	bsfIn, bsfOut := copyBitstreamArgs(o)
+	args = append(args, bsfIn...)
+	args = append(args, alphaInputArgs(o)...)
+	if o.TrimStart > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(o.TrimStart, 'f', 3, 64))
 	}
 	args = append(args, "-i", inPath)
+	if o.Watermark != nil {
+		args = append(args, "-i", o.Watermark.path)
+	}
+	if o.TrimEnd > 0 {
+		args = append(args, "-t", strconv.FormatFloat(o.TrimEnd-o.TrimStart, 'f', 3, 64))
+	}
+
+	// Cover art is re-attached after the encode (see applyCover); keep it out
+	// of the main mapping so it is neither re-encoded nor picked as "the" video.
+	// keep_all_audio maps every audio track instead of ffmpeg's single pick.
+	// (the watermark graph maps its own output)
+	if (o.KeepAllAudio || o.Source != nil && o.Source.attachedPic() != nil) && o.Watermark == nil {
+		args = append(args, "-map", "0:V:0?", "-map", audioMap(o))
+	}
 
 	// ---------------------------
 	// ORIENTATION-SAFE SCALING
@@ -245,29 +444,76 @@ func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
 	// a = iw/ih (aspect). Use -2 to keep even dimensions.
 	//   turbo longEdge=720:  landscape -> h=720 (w auto), portrait -> w=720 (h auto)
 	//   max   longEdge=480:  landscape -> h=480 (w auto), portrait -> w=480 (h auto)
-	vf := ""
+	//   proxy longEdge=240:  landscape -> h=240 (w auto), portrait -> w=240 (h auto), 15 fps
+	var vf []string
 	if strings.ToLower(o.Codec) != "copy" {
+		// crop bars first so scaling sees the real picture aspect
+		if o.Crop != "" {
+			vf = append(vf, "crop="+o.Crop)
+		}
+		if o.Reframe != nil {
+			vf = append(vf, o.Reframe.filter(o.TrimStart))
+			if o.Scale != "" {
+				o.Scale = o.Reframe.scale(o.Scale) // resolution presets are landscape
+			}
+		}
+		vf = append(vf, o.transformFilters()...)
+		o.Scale = o.turnScale(o.Scale)
 		switch o.SpeedMode {
 		case "turbo":
 			if o.FPS == 0 {
 				o.FPS = 24
 			}
-			vf = "scale='if(gt(a,1),-2,720)':'if(gt(a,1),720,-2)':flags=fast_bilinear,setsar=1"
+			vf = append(vf, "scale='if(gt(a,1),-2,720)':'if(gt(a,1),720,-2)':flags=fast_bilinear,setsar=1")
 		case "max":
 			if o.FPS == 0 {
 				o.FPS = 24
 			}
-			vf = "scale='if(gt(a,1),-2,480)':'if(gt(a,1),480,-2)':flags=fast_bilinear,setsar=1"
+			vf = append(vf, "scale='if(gt(a,1),-2,480)':'if(gt(a,1),480,-2)':flags=fast_bilinear,setsar=1")
+		case "proxy":
+			if o.FPS == 0 {
+				o.FPS = 15
+			}
+			vf = append(vf, "scale='if(gt(a,1),-2,240)':'if(gt(a,1),240,-2)':flags=bilinear,setsar=1")
+		case "screen":
+			if o.FPS == 0 {
+				o.FPS = 15
+			}
+			// lanczos keeps text crisp when downscaling
+			if o.Scale != "" {
+				vf = append(vf, "scale="+o.Scale+":flags=lanczos,setsar=1")
+			}
 		default:
 			// Respect explicit fixed WxH if provided (e.g. from Resolution),
 			// otherwise don't add a scale filter.
 			if o.Scale != "" {
-				vf = "scale=" + o.Scale + ":flags=fast_bilinear,setsar=1"
+				vf = append(vf, "scale="+o.Scale+":flags=fast_bilinear,setsar=1")
 			}
 		}
+		// per-orientation caps apply on top of any mode/resolution scaling
+		if f := capFilter(o.MaxLandscape, o.MaxPortrait); f != "" {
+			vf = append(vf, f)
+		}
+	}
+
+	// Animated GIF: cap the (often 100 fps nominal) rate and pad to even
+	// dimensions, which 4:2:0 encoders require.
+	isGIF := o.Source != nil && o.Source.isGIF()
+	if isGIF && strings.ToLower(o.Codec) != "copy" {
+		if o.FPS == 0 && o.Source.firstStream("video").frameRate() > 30 {
+			o.FPS = 30
+		}
+		vf = append(vf, "pad=ceil(iw/2)*2:ceil(ih/2)*2")
+	}
+
+	if o.Interlace != "" && strings.ToLower(o.Codec) != "copy" {
+		vf = append(vf, interlaceFilter(o))
 	}
-	if vf != "" {
-		args = append(args, "-vf", vf)
+
+	if o.Watermark != nil && strings.ToLower(o.Codec) != "copy" {
+		args = append(args, "-filter_complex", watermarkGraph(vf, o.Watermark), "-map", "[vout]", "-map", audioMap(o))
+	} else if len(vf) > 0 {
+		args = append(args, "-vf", strings.Join(vf, ","))
 	}
 
 	// fps (only if re-encoding video)
@@ -288,6 +534,14 @@ func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
 		} else {
 			vcodec = "libx265"
 		}
+	case "vp9":
+		vcodec = "libvpx-vp9"
+	case "av1":
+		vcodec = "libsvtav1"
+	case "ffv1":
+		vcodec = "ffv1"
+	case "prores":
+		vcodec = "prores_ks"
 	default: // h264
 		if strings.ToLower(o.HW) == "videotoolbox" {
 			vcodec = "h264_videotoolbox"
@@ -296,14 +550,31 @@ func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
 		}
 	}
 
+	lossless := o.SpeedMode == "lossless" || vcodec == "ffv1"
 	if vcodec == "copy" {
 		args = append(args, "-c:v", "copy")
+		if o.TrimStart > 0 {
+			// the copy starts on the keyframe settleTrim found; keep it at 0
+			args = append(args, "-avoid_negative_ts", "make_zero")
+		}
 	} else {
 		args = append(args, "-c:v", vcodec)
 
-		switch vcodec {
+		rc := vcodec
+		if lossless {
+			rc = "lossless"
+		}
+		switch rc {
+		case "lossless":
+			args = append(args, losslessArgs(vcodec)...)
 		case "libx264", "libx265":
 			args = append(args, "-crf", strconv.Itoa(o.CRF), "-preset", o.Preset)
+		case "libvpx-vp9":
+			args = append(args, vp9RateArgs(o.CRF, o.Preset)...)
+		case "libsvtav1":
+			args = append(args, av1RateArgs(o.CRF, o.Preset)...)
+		case "prores_ks":
+			args = append(args, proresArgs(o.CRF, o.Alpha == "keep", o.PixFmt)...)
 		case "h264_videotoolbox", "hevc_videotoolbox":
 			// map CRF→bitrate for hardware encoders
 			bitrate := "3M"
@@ -325,12 +596,48 @@ func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
 			if o.SpeedMode == "turbo" {
 				bitrate = "2500k"
 			}
+			if o.SpeedMode == "proxy" {
+				bitrate = proxyMaxRate
+			}
 			args = append(args, "-b:v", bitrate)
 		}
 
-		// browser/player compatibility
-		if strings.ToLower(o.OutExt) == ".mp4" {
+		// browser/player compatibility (GIFs decode to RGB/palette formats)
+		switch {
+		case vcodec == "prores_ks":
+			// pixel format chosen by proresArgs
+		case o.PixFmt != "":
+			args = append(args, "-pix_fmt", o.PixFmt)
+		case vcodec == "ffv1":
+			// FFV1 keeps any source pixel format, alpha included
+		case o.Alpha == "keep":
+			args = append(args, "-pix_fmt", alphaPixFmt(vcodec))
+		case tenBit(o, vcodec):
+			args = append(args, tenBitArgs(vcodec)...)
+		case o.BitDepth == 8, isGIF:
 			args = append(args, "-pix_fmt", "yuv420p")
+		case lossless:
+			// keep the source pixel format (4:2:2/4:4:4 and high bit depth)
+		default:
+			switch strings.ToLower(o.OutExt) {
+			case ".mp4", ".m4v", ".webm":
+				args = append(args, "-pix_fmt", "yuv420p")
+			}
+		}
+	}
+
+	args = append(args, deviceArgs(o, vcodec)...)
+	if vcodec != "copy" {
+		args = append(args, interlaceArgs(o, vcodec)...)
+		args = append(args, captionArgs(o, vcodec)...)
+		args = append(args, renditionArgs(o, vcodec)...)
+	}
+
+	// Proxy: cap the bitrate so busy footage stays small too
+	if o.SpeedMode == "proxy" {
+		switch vcodec {
+		case "libx264", "libx265", "libvpx-vp9":
+			args = append(args, "-maxrate", proxyMaxRate, "-bufsize", proxyBufSize)
 		}
 	}
 
@@ -339,41 +646,129 @@ func buildFFmpegArgs(inPath, outPath string, o compressOpts) []string {
 		switch vcodec {
 		case "libx264":
 			args = append(args, "-tune", "fastdecode,zerolatency")
-			args = append(args, "-g", "300", "-keyint_min", "300")
 			args = append(args, "-x264-params",
 				"no-scenecut=1:ref=1:bframes=0:me=dia:subme=0:trellis=0:aq-mode=0:fast_pskip=1:sync-lookahead=0:rc-lookahead=0")
 		case "libx265":
 			args = append(args, "-tune", "fastdecode")
-			args = append(args, "-g", "300", "-keyint_min", "300")
 		case "h264_videotoolbox", "hevc_videotoolbox":
 			args = append(args, "-realtime", "true")
-			args = append(args, "-g", "300")
 		}
 	}
 
+	// Keyframes: fixed GOP for turbo/max, scene cuts + configurable GOP
+	// for everything else (long GOP for screen)
+	if !lossless {
+		args = append(args, gopArgs(o, vcodec)...)
+	}
+
+	// Screencasts: screencast tuning unless another content hint was given
+	content := o.Content
+	if o.SpeedMode == "screen" && content == "" {
+		content = "screencast"
+	}
+	// Content hint: tune/deblock/AQ (turbo/max keep their own low-latency tuning)
+	if o.SpeedMode != "max" && o.SpeedMode != "turbo" && !lossless {
+		args = append(args, contentArgs(vcodec, content)...)
+	}
+	if vcodec == "libsvtav1" {
+		args = append(args, av1GrainArgs(o, content)...)
+	}
+
 	// ---------------------------
 	// AUDIO
 	// ---------------------------
+	if o.VideoOnly {
+		args = append(args, "-an")
+	} else {
+		args = append(args, audioCodecArgs(o)...)
+	}
+
+	// container-aware bitstream filters for stream copy (TS/ADTS/Annex-B)
+	args = append(args, bsfOut...)
+
+	// ---------------------------
+	// METADATA
+	// ---------------------------
+	movflags := "+faststart"
+	switch {
+	case o.StripMetadata:
+		args = append(args, "-map_metadata", "-1",
+			"-map_metadata:s:v", "-1", "-map_metadata:s:a", "-1", "-fflags", "+bitexact")
+	case o.PreserveMetadata:
+		args = append(args, "-map_metadata", "0",
+			"-map_metadata:s:v", "0:s:v", "-map_metadata:s:a", "0:s:a")
+		// keep custom keys (e.g. com.apple.quicktime.*) in mp4/mov udta
+		movflags += "+use_metadata_tags"
+	}
+	if !o.VideoOnly {
+		args = append(args, audioLanguageArgs(o)...)
+	}
+
+	if o.PreserveCapture && o.Source != nil {
+		if ct := o.Source.Format.Tags["creation_time"]; ct != "" {
+			args = append(args, "-metadata", "creation_time="+ct)
+		}
+		if cd := o.Source.Format.Tags["com.apple.quicktime.creationdate"]; cd != "" {
+			args = append(args, "-metadata", "com.apple.quicktime.creationdate="+cd)
+			if !strings.Contains(movflags, "use_metadata_tags") {
+				movflags += "+use_metadata_tags"
+			}
+		}
+		if rotation != 0 {
+			// legacy tag for older ffmpeg; newer builds carry the display
+			// matrix through automatically with -noautorotate
+			args = append(args, "-metadata:s:v:0", "rotate="+strconv.Itoa(rotation))
+		}
+	}
+
+	// Chapters: map explicitly so they survive re-encode (and strip_metadata)
+	if o.Chapters == "drop" {
+		args = append(args, "-map_chapters", "-1")
+	} else if o.Source != nil && len(o.Source.Chapters) > 0 {
+		args = append(args, "-map_chapters", "0")
+	}
+
+	// faststart only where the muxer understands it (mp4/mov family)
+	if outputContainers[strings.ToLower(o.OutExt)].MovFlags {
+		args = append(args, "-movflags", movflags)
+		if sphericalOut(o) {
+			// sv3d/st3d boxes are "unofficial" to the mov muxer
+			args = append(args, "-strict", "unofficial")
+		}
+	}
+	args = append(args, "-threads", "0", outPath)
+	return args
+}
+
+// audioCodecArgs are the audio encoder settings of o.
+func audioCodecArgs(o compressOpts) []string {
+	var args []string
 	switch strings.ToLower(o.Audio) {
 	case "copy":
 		args = append(args, "-c:a", "copy")
+	case "ac3", "eac3":
+		args = append(args, dolbyArgs(o)...)
 	case "opus":
 		args = append(args, "-c:a", "libopus", "-b:a", o.AB)
+		if o.SpeedMode == "screen" {
+			args = append(args, "-ac", "1", "-application", "voip")
+		}
 	default:
 		args = append(args, "-c:a", "aac", "-b:a", o.AB)
 		// turbo: stereo 96k; max: mono 64k
 		if o.SpeedMode == "turbo" {
 			args = append(args, "-ac", "2")
 			args = append(args, "-b:a", "96k")
-		} else if o.SpeedMode == "max" {
+		} else if o.SpeedMode == "proxy" {
+			args = append(args, "-ac", "2")
+		} else if o.SpeedMode == "max" || o.SpeedMode == "screen" {
+			// mono voice
 			args = append(args, "-ac", "1")
 			args = append(args, "-b:a", "64k")
 		}
 	}
-
-	// faststart + threads
-	args = append(args, "-movflags", "+faststart", "-threads", "0", outPath)
-	return args
+	// audio.N.ab last, so it wins over the mode's bitrate
+	return append(args, trackBitrateArgs(o)...)
 }
 
 // run ffmpeg synchronously; if HW fails, retry CPU
@@ -384,14 +779,16 @@ func runFFmpeg(ctx context.Context, inPath, outPath string, o compressOpts, logW
 	o.normalize()
 	args := buildFFmpegArgs(inPath, outPath, o)
 	
-	logger.Printf("⚙️ [%s] FFmpeg command: ffmpeg %s", requestID, strings.Join(args, " "))
+	bin := ffmpegFor(args)
+	logger.Printf("⚙️ [%s] FFmpeg command: %s %s", requestID, bin, strings.Join(args, " "))
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	cmd.Stdout = logWriter
+	cmd := exec.CommandContext(ctx, bin, withProgressArgs(ctx, args)...)
+	cmd.Stdout = progressOut(ctx, &o, logWriter)
 	cmd.Stderr = logWriter
 	
-	logger.Printf("▶️ [%s] Executing FFmpeg with hardware: %s", requestID, o.HW)
+	logger.Printf("▶️ [%s] Executing FFmpeg with hardware: %s (decode: %s)", requestID, o.HW, o.HWDecode)
 	err := cmd.Run()
+	recordUsage(ctx, cmd.ProcessState)
 	if err == nil {
 		logger.Printf("✅ [%s] FFmpeg compression completed successfully", requestID)
 		return nil
@@ -399,17 +796,19 @@ func runFFmpeg(ctx context.Context, inPath, outPath string, o compressOpts, logW
 
 	logger.Printf("⚠️ [%s] FFmpeg failed: %v", requestID, err)
 	
-	if strings.Contains(strings.ToLower(o.HW), "videotoolbox") {
-		logger.Printf("🔄 [%s] VideoToolbox failed; falling back to CPU", requestID)
-		fmt.Fprintln(logWriter, "VideoToolbox failed; falling back to CPU.")
+	if strings.Contains(strings.ToLower(o.HW), "videotoolbox") || o.HWDecode != "none" {
+		logger.Printf("🔄 [%s] Hardware path failed (hw=%s, hwdecode=%s); falling back to CPU", requestID, o.HW, o.HWDecode)
+		fmt.Fprintln(logWriter, "Hardware path failed; falling back to CPU.")
 		o.HW = "none"
+		o.HWDecode = "none"
 		args = buildFFmpegArgs(inPath, outPath, o)
-		cmd = exec.CommandContext(ctx, "ffmpeg", args...)
-		cmd.Stdout = logWriter
+		cmd = exec.CommandContext(ctx, ffmpegFor(args), withProgressArgs(ctx, args)...)
+		cmd.Stdout = progressOut(ctx, &o, logWriter)
 		cmd.Stderr = logWriter
 		
 		logger.Printf("🔄 [%s] Retrying FFmpeg with CPU only", requestID)
 		err = cmd.Run()
+		recordUsage(ctx, cmd.ProcessState)
 		if err == nil {
 			logger.Printf("✅ [%s] FFmpeg CPU fallback completed successfully", requestID)
 		} else {
@@ -438,6 +837,46 @@ type resultEntry struct {
 	HW          string
 	ElapsedMs   int64
 	Throughput  float64 // MB/s
+
+	// Artifacts are extra files produced alongside the output, served at
+	// /dl/{id}/{name}.
+	Artifacts map[string]string
+	// Warnings are adjustments the server made to the request (shown in /meta).
+	Warnings []string
+	// Client is the caller's metadata/tag for correlating results.
+	Client clientMeta
+	// Name is the download (Content-Disposition) filename.
+	Name string
+	// Deliveries track the copies pushed to deliver targets (guarded by deliveryMu).
+	Deliveries []*delivery
+	// Created is when the result was stored (used by retention tasks).
+	Created time.Time
+	// Race lists the candidates of a race=[...] encode, winner marked.
+	Race []raceResult `json:",omitempty"`
+	// Pipeline lists the steps of a /pipeline job and their outcome.
+	Pipeline []pipelineStep `json:",omitempty"`
+	// Shared is the object-storage copy for other replicas (RESULT_STORE=s3).
+	Shared *sharedCopy `json:",omitempty"`
+	// Usage is the CPU, memory and I/O of the job's ffmpeg processes.
+	Usage *resourceUsage `json:",omitempty"`
+	// Digests are the SHA-256 and length of the output ("") and artifacts,
+	// for download ETags.
+	Digests map[string]fileDigest `json:",omitempty"`
+	// OutputURL is the object the output was uploaded to (output=s3://...).
+	OutputURL string `json:",omitempty"`
+	// Renditions describe the encodes of renditions=..., highest first.
+	Renditions []renditionInfo `json:",omitempty"`
+	// Thumbnails are the thumbnails=N stills (also in Artifacts).
+	Thumbnails []thumbnailInfo `json:",omitempty"`
+	// Storyboard is the storyboard=1 sprite sheet layout (files in Artifacts).
+	Storyboard *storyboardInfo `json:",omitempty"`
+	// InputID is the retained upload (keep_input=1), see /inputs.
+	InputID string `json:",omitempty"`
+	// Token is the ID of the upload token that created the result, the only
+	// token that may read it.
+	Token string `json:",omitempty"`
+
+	stored string // result ID once stored
 }
 
 var (
@@ -445,6 +884,106 @@ var (
 	store   = map[string]*resultEntry{}
 )
 
+var errOutputInvalid = errors.New("output seems empty or invalid")
+
+func artifactNames(e *resultEntry) []string {
+	names := make([]string, 0, len(e.Artifacts))
+	for name := range e.Artifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contentTypeFor(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if c, ok := outputContainers[ext]; ok {
+		return c.MimeType
+	}
+	switch ext {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".gif":
+		return "image/gif"
+	case ".json":
+		return "application/json"
+	case ".zip":
+		return "application/zip"
+	case ".m4a":
+		return "audio/mp4"
+	case ".opus":
+		return "audio/ogg"
+	case ".mka":
+		return "audio/x-matroska"
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".mpd":
+		return "application/dash+xml"
+	case ".m4s":
+		return "video/iso.segment"
+	case ".txt", ".ffmeta":
+		return "text/plain; charset=utf-8"
+	case ".vtt":
+		return "text/vtt; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// storeResult registers a finished encode and returns its download ID.
+func storeResult(requestID string, e *resultEntry) string {
+	if e.stored != "" {
+		return e.stored // already stored (detach_on_disconnect)
+	}
+	id := randID(12)
+	e.stored = id
+	logger.Printf("💾 [%s] Storing result entry with ID: %s", requestID, id)
+	e.Created = time.Now()
+	e.digestFiles(requestID)
+	storeMu.Lock()
+	store[id] = e
+	storeMu.Unlock()
+	persistResult(id, e)
+	if len(e.Renditions) > 0 {
+		signResultManifest(requestID, id, e)
+	}
+	logger.Printf("✅ [%s] Result stored successfully", requestID)
+	if resultStoreS3() {
+		go shareResult(requestID, id, e)
+	}
+	if len(e.Deliveries) > 0 {
+		logger.Printf("📦 [%s] Delivering result to %d targets", requestID, len(e.Deliveries))
+		go runDeliveries(requestID, id, e)
+	}
+	return id
+}
+
+// getResult looks id up in memory, then in the shared store (another
+// replica's result).
+func getResult(id string) (*resultEntry, bool) {
+	storeMu.Lock()
+	e, ok := store[id]
+	storeMu.Unlock()
+	if ok {
+		return e, true
+	}
+	if e, ok = loadSharedResult(id); !ok {
+		return nil, false
+	}
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	if cur, ok := store[id]; ok {
+		return cur, true
+	}
+	store[id] = e
+	return e, true
+}
+
 // ======================
 //
 // HTTP layer
@@ -453,15 +992,17 @@ var (
 
 var uploadTpl = template.Must(template.New("u").Parse(`
 <!doctype html>
+<html lang="{{.L.Lang}}">
 <meta charset="utf-8">
-<title>Video Compress</title>
+<title>{{.Site.Title}}</title>
 <style>
-body{font-family:ui-sans-serif,system-ui;margin:40px;max-width:900px;line-height:1.6}
+{{.Site.CSS}}
+body{font-family:ui-sans-serif,system-ui;margin:40px;max-width:900px;line-height:1.6;background:var(--bg);color:var(--text)}
 .form-group{margin:12px 0}
 label{display:block;margin-bottom:6px;font-weight:600}
 input,select{width:100%;padding:8px;border:1px solid #d1d5db;border-radius:6px}
-button{background:#111827;color:#fff;border:0;padding:12px 20px;border-radius:8px;cursor:pointer}
-button:hover{background:#0f172a}
+button{background:var(--primary);color:#fff;border:0;padding:12px 20px;border-radius:8px;cursor:pointer}
+button:hover{background:var(--primary-hover)}
 details{margin:12px 0}
 pre{background:#f3f4f6;padding:12px;border-radius:6px;overflow:auto}
 .grid{display:grid;grid-template-columns:repeat(auto-fit,minmax(160px,1fr));gap:8px}
@@ -469,39 +1010,68 @@ pre{background:#f3f4f6;padding:12px;border-radius:6px;overflow:auto}
 small{color:#6b7280}
 .kv{display:grid;grid-template-columns:200px 1fr;gap:8px 16px}
 kbd{background:#f3f4f6;border:1px solid #e5e7eb;border-radius:4px;padding:2px 6px}
+.logo{max-height:48px;vertical-align:middle;margin-right:12px}
+footer{margin-top:32px;color:#6b7280;font-size:14px}
+footer a{color:var(--accent);margin-right:12px}
+.lang{display:flex;gap:8px;align-items:center;justify-content:flex-end}
+.lang label{margin:0}
+.lang select{width:auto}
+#progress progress{width:100%;height:16px}
 </style>
 
-<h1>Video Compress</h1>
+<h1>{{if .Site.Logo}}<img class="logo" src="{{.Site.Logo}}" alt="">{{end}}{{.Site.Title}}</h1>
 <p style="text-align: center; margin-bottom: 20px;">
-  <a href="/api-docs" style="color: #667eea; text-decoration: none; font-weight: 500;">📖 View API Documentation</a>
+  <a href="/api-docs" style="color: var(--accent); text-decoration: none; font-weight: 500;">{{.L.T "nav.api_docs"}}</a>
 </p>
+{{if gt (len .Langs) 1}}<form method="get" action="/" class="lang">
+  <label for="lang">{{.L.T "lang.label"}}</label>
+  <select id="lang" name="lang" onchange="this.form.submit()">
+    {{range .Langs}}<option value="{{.Code}}"{{if eq .Code $.L.Lang}} selected{{end}}>{{.Name}}</option>{{end}}
+  </select>
+  <noscript><button type="submit">OK</button></noscript>
+</form>{{end}}
 
 <form method="post" action="/compress" enctype="multipart/form-data">
   <input type="hidden" name="ui" value="1">
+  <input type="hidden" name="lang" value="{{.L.Lang}}">
   <div class="form-group">
-    <label>Video file</label>
-    <input type="file" name="file" accept="video/*" required>
+    <label>{{.L.T "upload.file"}}</label>
+    <input type="file" name="file" accept="video/*,image/gif" required>
   </div>
 
   <div class="grid">
     <div class="card">
-      <label>Mode</label>
+      <label>{{.L.T "upload.mode"}}</label>
       <select name="speed">
-        <option value="ai" selected>AI (auto by size)</option>
-        <option value="turbo">TURBO (very fast, 720p long-edge)</option>
-        <option value="max">MAX (very fast, 480p long-edge)</option>
-        <option value="ultra_fast">Ultra Fast</option>
-        <option value="super_fast">Super Fast</option>
-        <option value="fast">Fast</option>
-        <option value="balanced">Balanced</option>
-        <option value="quality">Quality</option>
+        <option value="ai" selected>{{.L.T "upload.mode.ai"}}</option>
+        <option value="turbo">{{.L.T "upload.mode.turbo"}}</option>
+        <option value="max">{{.L.T "upload.mode.max"}}</option>
+        <option value="proxy">{{.L.T "upload.mode.proxy"}}</option>
+        <option value="ultra_fast">{{.L.T "upload.mode.ultra"}}</option>
+        <option value="super_fast">{{.L.T "upload.mode.super"}}</option>
+        <option value="fast">{{.L.T "upload.mode.fast"}}</option>
+        <option value="balanced">{{.L.T "upload.mode.balanced"}}</option>
+        <option value="quality">{{.L.T "upload.mode.quality"}}</option>
+        <option value="screen">{{.L.T "upload.mode.screen"}}</option>
+        <option value="lossless">{{.L.T "upload.mode.lossless"}}</option>
+        <option value="archive">{{.L.T "upload.mode.archive"}}</option>
+      </select>
+      <small>{{.L.T "upload.mode.hint"}}</small>
+    </div>
+    <div class="card">
+      <label>{{.L.T "upload.content"}}</label>
+      <select name="content">
+        <option value="" selected>{{.L.T "upload.content.any"}}</option>
+        <option value="film">{{.L.T "upload.content.film"}}</option>
+        <option value="animation">{{.L.T "upload.content.anim"}}</option>
+        <option value="screencast">{{.L.T "upload.content.cast"}}</option>
+        <option value="sports">{{.L.T "upload.content.sport"}}</option>
       </select>
-      <small>AI picks by file size only.</small>
     </div>
     <div class="card">
-      <label>Resolution</label>
+      <label>{{.L.T "upload.resolution"}}</label>
       <select name="resolution">
-        <option value="original" selected>Original</option>
+        <option value="original" selected>{{.L.T "upload.original"}}</option>
         <option value="360p">360p</option>
         <option value="480p">480p</option>
         <option value="720p">720p</option>
@@ -513,45 +1083,208 @@ kbd{background:#f3f4f6;border:1px solid #e5e7eb;border-radius:4px;padding:2px 6p
   </div>
 
   <details>
-    <summary>Advanced</summary>
+    <summary>{{.L.T "upload.advanced"}}</summary>
     <div class="grid">
       <div class="card">
-        <label>Video codec</label>
+        <label>{{.L.T "upload.codec"}}</label>
         <select name="codec">
           <option value="h264" selected>H.264</option>
           <option value="h265">H.265/HEVC</option>
-          <option value="copy">Copy video stream</option>
+          <option value="vp9">VP9</option>
+          <option value="av1">AV1 (SVT-AV1)</option>
+          <option value="prores">ProRes (MOV)</option>
+          <option value="copy">{{.L.T "upload.codec.copy"}}</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.bit_depth"}}</label>
+        <select name="bit_depth">
+          <option value="" selected>{{.L.T "upload.auto"}}</option>
+          <option value="8">8-bit</option>
+          <option value="10">10-bit (H.265/AV1)</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.autocrop"}}</label>
+        <select name="autocrop">
+          <option value="" selected>{{.L.T "upload.keep"}}</option>
+          <option value="1">{{.L.T "upload.autocrop.on"}}</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.trim_dead"}}</label>
+        <select name="trim_dead">
+          <option value="" selected>{{.L.T "upload.keep"}}</option>
+          <option value="both">{{.L.T "upload.trim.both"}}</option>
+          <option value="silence">{{.L.T "upload.trim.silence"}}</option>
+          <option value="black">{{.L.T "upload.trim.black"}}</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.max_landscape"}}</label>
+        <select name="max_landscape">
+          <option value="" selected>{{.L.T "upload.no_cap"}}</option>
+          <option value="2160p">3840×2160</option>
+          <option value="1080p">1920×1080</option>
+          <option value="720p">1280×720</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.max_portrait"}}</label>
+        <select name="max_portrait">
+          <option value="" selected>{{.L.T "upload.no_cap"}}</option>
+          <option value="1080p">1080×1920</option>
+          <option value="720p">720×1280</option>
+          <option value="480p">480×854</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.alpha"}}</label>
+        <select name="alpha">
+          <option value="auto" selected>{{.L.T "upload.alpha.auto"}}</option>
+          <option value="keep">{{.L.T "upload.alpha.keep"}}</option>
+          <option value="drop">{{.L.T "upload.alpha.drop"}}</option>
         </select>
       </div>
       <div class="card">
-        <label>Hardware</label>
+        <label>{{.L.T "upload.grain"}}</label>
+        <select name="film_grain">
+          <option value="" selected>{{.L.T "upload.grain.auto"}}</option>
+          <option value="0">{{.L.T "upload.grain.off"}}</option>
+          <option value="4">{{.L.T "upload.grain.light"}}</option>
+          <option value="8">{{.L.T "upload.grain.medium"}}</option>
+          <option value="16">{{.L.T "upload.grain.heavy"}}</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.hw"}}</label>
         <select name="hw">
-          <option value="none" selected>CPU only</option>
+          <option value="none" selected>{{.L.T "upload.hw.none"}}</option>
           <option value="videotoolbox">macOS VideoToolbox</option>
         </select>
       </div>
       <div class="card">
-        <label>Audio codec</label>
+        <label>{{.L.T "upload.hwdecode"}}</label>
+        <select name="hwdecode">
+          <option value="" selected>{{.L.T "upload.hwdecode.hw"}}</option>
+          <option value="none">{{.L.T "upload.hwdecode.cpu"}}</option>
+          <option value="auto">{{.L.T "upload.auto"}}</option>
+          <option value="videotoolbox">VideoToolbox</option>
+          <option value="cuda">NVIDIA CUDA/NVDEC</option>
+          <option value="vaapi">VA-API</option>
+          <option value="qsv">Intel Quick Sync</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.audio"}}</label>
         <select name="audio">
           <option value="aac" selected>AAC</option>
           <option value="opus">Opus</option>
-          <option value="copy">Copy audio</option>
+          <option value="ac3">Dolby Digital (AC-3)</option>
+          <option value="eac3">Dolby Digital Plus (E-AC-3)</option>
+          <option value="copy">{{.L.T "upload.audio.copy"}}</option>
         </select>
       </div>
       <div class="card">
-        <label>Output extension</label>
+        <label>{{.L.T "upload.ext"}}</label>
         <select name="outExt">
           <option value=".mp4" selected>.mp4</option>
           <option value=".mov">.mov</option>
+          <option value=".m4v">.m4v</option>
+          <option value=".mkv">.mkv</option>
+          <option value=".webm">.webm (VP9/Opus)</option>
+          <option value=".ts">.ts</option>
+        </select>
+      </div>
+      <div class="card">
+        <label>{{.L.T "upload.device"}}</label>
+        <select name="validate_for">
+          <option value="" selected>{{.L.T "upload.device.any"}}</option>
+          <option value="quicktime">QuickTime / iOS</option>
+          <option value="android">Android</option>
+          <option value="web">{{.L.T "upload.device.web"}}</option>
         </select>
       </div>
     </div>
   </details>
 
-  <button type="submit">Compress</button>
+  <button type="submit">{{.L.T "upload.submit"}}</button>
 </form>
 
-<h3>cURL (API, returns file bytes + timing headers)</h3>
+<div id="progress" hidden>
+  <progress max="100" value="0"></progress>
+  <p class="status"></p>
+</div>
+<script>
+// With JavaScript the upload runs as an async job and the page follows its
+// progress over /events/{id}; without it the form posts as usual.
+(function () {
+  var form = document.querySelector('form[action="/compress"]');
+  if (!form || !window.EventSource || !window.FormData) return;
+  var T = {
+    uploading: {{.L.T "progress.upload"}},
+    encoding: {{.L.T "progress.encode"}},
+    done: {{.L.T "progress.done"}},
+    download: {{.L.T "progress.download"}},
+    failed: {{.L.T "progress.failed"}}
+  };
+  var box = document.getElementById('progress');
+  var bar = box.querySelector('progress');
+  var status = box.querySelector('.status');
+  form.addEventListener('submit', function (ev) {
+    ev.preventDefault();
+    var data = new FormData(form);
+    data.set('async', '1');
+    box.hidden = false;
+    form.querySelector('button[type=submit]').disabled = true;
+    var fail = function (msg) {
+      status.textContent = T.failed + ': ' + msg;
+      form.querySelector('button[type=submit]').disabled = false;
+    };
+    var xhr = new XMLHttpRequest();
+    xhr.open('POST', '/compress');
+    xhr.upload.onprogress = function (e) {
+      if (!e.lengthComputable) return;
+      bar.value = e.loaded / e.total * 100;
+      status.textContent = T.uploading + ' ' + Math.floor(bar.value) + '%';
+    };
+    xhr.onerror = function () { fail(xhr.statusText || 'network error'); };
+    xhr.onload = function () {
+      if (xhr.status !== 202) return fail(xhr.responseText);
+      var job = JSON.parse(xhr.responseText);
+      bar.value = 0;
+      status.textContent = T.encoding;
+      var es = new EventSource('/events/' + job.id);
+      es.addEventListener('progress', function (e) {
+        var p = JSON.parse(e.data);
+        bar.value = p.percent || 0;
+        var parts = [T.encoding + ' ' + (p.percent || 0) + '%'];
+        if (p.fps) parts.push(p.fps + ' fps');
+        if (p.eta_sec) parts.push('ETA ' + p.eta_sec + 's');
+        status.textContent = parts.join(' · ');
+      });
+      es.addEventListener('state', function (e) {
+        var s = JSON.parse(e.data);
+        if (s.state === 'done') {
+          es.close();
+          bar.value = 100;
+          var a = document.createElement('a');
+          a.href = s.download_url;
+          a.textContent = T.download;
+          status.textContent = T.done + ' ';
+          status.appendChild(a);
+        } else if (s.state === 'failed') {
+          es.close();
+          fail(s.error);
+        }
+      });
+    };
+    xhr.send(data);
+  });
+})();
+</script>
+
+<h3>{{.L.T "upload.curl"}}</h3>
 <pre>
 curl -f -S -o out.mp4 \
   -H "Accept: application/octet-stream" \
@@ -561,38 +1294,78 @@ curl -f -S -o out.mp4 \
 
 # headers include: X-Encode-Duration-Ms and X-Throughput-MBps
 </pre>
+{{if or .Site.Footer .Site.Links}}<footer>
+  {{range .Site.Links}}<a href="{{.URL}}">{{.Label}}</a>{{end}}
+  {{if .Site.Footer}}<p>{{.Site.Footer}}</p>{{end}}
+</footer>{{end}}
 `))
 
 var resultTpl = template.Must(template.New("r").Parse(`
 <!doctype html>
+<html lang="{{.L.Lang}}">
 <meta charset="utf-8">
-<title>Compression result</title>
+<title>{{.L.T "result.page_title"}} · {{.Site.Title}}</title>
 <style>
-body{font-family:ui-sans-serif,system-ui;margin:40px;max-width:900px;line-height:1.6}
+{{.Site.CSS}}
+body{font-family:ui-sans-serif,system-ui;margin:40px;max-width:900px;line-height:1.6;background:var(--bg);color:var(--text)}
 h1{margin-top:0}
 .kv{display:grid;grid-template-columns:240px 1fr;gap:8px 16px}
 code{background:#f3f4f6;border-radius:4px;padding:2px 6px}
-a.btn{display:inline-block;margin-top:16px;background:#111827;color:#fff;text-decoration:none;padding:12px 16px;border-radius:8px}
-a.btn:hover{background:#0f172a}
+a.btn,button.btn{display:inline-block;margin-top:16px;background:var(--primary);color:#fff;text-decoration:none;padding:12px 16px;border-radius:8px}
+a.btn:hover,button.btn:hover{background:var(--primary-hover)}
+button.btn{border:0;font:inherit;cursor:pointer}
 pre{background:#f3f4f6;padding:12px;border-radius:6px;overflow:auto}
+.logo{max-height:48px;display:block;margin-bottom:12px}
+.poster video,.poster img{max-width:100%;border-radius:6px;display:block}
+.poster input{width:100%}
+footer{margin-top:32px;color:#6b7280;font-size:14px}
+footer a{color:var(--accent);margin-right:12px}
 </style>
 
-<h1>✅ Compression complete</h1>
+{{if .Site.Logo}}<a href="/"><img class="logo" src="{{.Site.Logo}}" alt="{{.Site.Title}}"></a>{{end}}
+<h1>{{.L.T "result.title"}}</h1>
 <div class="kv">
-  <div>Mode</div><div><code>{{.ModeFinal}}</code> <small>(decided by: {{.ModeDecider}})</small></div>
-  <div>Time taken</div><div>{{printf "%.2f" .Seconds}} s</div>
-  <div>Throughput</div><div>{{printf "%.2f" .Throughput}} MB/s</div>
-  <div>Input size</div><div>{{.InputHuman}} ({{.InputBytes}} bytes)</div>
-  <div>Output size</div><div>{{.OutputHuman}} ({{.OutputBytes}} bytes)</div>
-  <div>Resolution</div><div>{{.Resolution}}</div>
-  <div>Video codec</div><div>{{.Codec}}</div>
-  <div>Audio codec</div><div>{{.Audio}}</div>
-  <div>Hardware</div><div>{{.HW}}</div>
+  <div>{{.L.T "result.mode"}}</div><div><code>{{.ModeFinal}}</code> <small>({{.L.T "result.decided_by"}}: {{.ModeDecider}})</small></div>
+  <div>{{.L.T "result.time"}}</div><div>{{printf "%.2f" .Seconds}} s</div>
+  <div>{{.L.T "result.throughput"}}</div><div>{{printf "%.2f" .Throughput}} MB/s</div>
+  <div>{{.L.T "result.input"}}</div><div>{{.InputHuman}} ({{.InputBytes}} {{.L.T "result.bytes"}})</div>
+  <div>{{.L.T "result.output"}}</div><div>{{.OutputHuman}} ({{.OutputBytes}} {{.L.T "result.bytes"}})</div>
+  <div>{{.L.T "result.resolution"}}</div><div>{{.Resolution}}</div>
+  <div>{{.L.T "result.codec"}}</div><div>{{.Codec}}</div>
+  <div>{{.L.T "result.audio"}}</div><div>{{.Audio}}</div>
+  <div>{{.L.T "result.hw"}}</div><div>{{.HW}}</div>
 </div>
 
-<a class="btn" href="/dl/{{.ID}}?name={{.SuggestName}}">⬇️ Download compressed file</a>
+<a class="btn" href="/dl/{{.ID}}?name={{.SuggestName}}">{{.L.T "result.download"}}</a>
 
-<h3>API example</h3>
+{{if .Poster}}<h3>{{.L.T "result.poster"}}</h3>
+<div class="poster">
+  <video id="scrub" src="/dl/{{.ID}}" preload="metadata" muted playsinline></video>
+  <input type="range" id="scrubTime" min="0" max="0" step="0.04" value="0">
+  <code id="scrubLabel">0.00 s</code><br>
+  <button class="btn" id="posterBtn" type="button">{{.L.T "result.poster_pick"}}</button>
+  <p id="posterOut"></p>
+</div>
+<script>
+(function(){
+  const v=document.getElementById('scrub'), t=document.getElementById('scrubTime'),
+        label=document.getElementById('scrubLabel'), out=document.getElementById('posterOut');
+  v.addEventListener('loadedmetadata',()=>{ t.max=v.duration.toFixed(2); });
+  t.addEventListener('input',()=>{ v.currentTime=+t.value; label.textContent=(+t.value).toFixed(2)+' s'; });
+  document.getElementById('posterBtn').addEventListener('click',async()=>{
+    const body=new URLSearchParams({time:t.value});
+    const res=await fetch('/poster/{{.ID}}',{method:'POST',body});
+    if(!res.ok){ out.textContent={{.L.T "result.poster_failed"}}+': '+await res.text(); return; }
+    const p=await res.json();
+    out.innerHTML='';
+    const a=document.createElement('a'); a.href=p.url;
+    const img=document.createElement('img'); img.src=p.url+'?t='+Date.now(); img.alt=p.artifact;
+    a.appendChild(img); out.appendChild(a);
+  });
+})();
+</script>{{end}}
+
+<h3>{{.L.T "result.api"}}</h3>
 <pre>
 curl -f -S -o out.mp4 \
   -H "Accept: application/octet-stream" \
@@ -604,6 +1377,10 @@ curl -f -S -o out.mp4 \
 # Timing headers:
 # X-Encode-Duration-Ms, X-Throughput-MBps
 </pre>
+{{if or .Site.Footer .Site.Links}}<footer>
+  {{range .Site.Links}}<a href="{{.URL}}">{{.Label}}</a>{{end}}
+  {{if .Site.Footer}}<p>{{.Site.Footer}}</p>{{end}}
+</footer>{{end}}
 `))
 
 var apiDocsTpl = template.Must(template.New("api").Parse(`
@@ -1015,7 +1792,7 @@ var apiDocsTpl = template.Must(template.New("api").Parse(`
                 <div class="stat-label">API Endpoints</div>
             </div>
             <div class="stat-card">
-                <div class="stat-number">8</div>
+                <div class="stat-number">10</div>
                 <div class="stat-label">Speed Modes</div>
             </div>
             <div class="stat-card">
@@ -1236,6 +2013,11 @@ with open('video.mp4', 'rb') as f:
                         <div class="mode-details">CRF: 36 | Preset: ultrafast | Audio: 64k mono</div>
                         <div class="mode-description">Maximum compression, 480p long-edge, smallest file size</div>
                     </div>
+                    <div class="mode-card">
+                        <div class="mode-name">🎞️ Proxy</div>
+                        <div class="mode-details">CRF: 30, max 400k | Preset: veryfast | Audio: 64k stereo | 15 fps</div>
+                        <div class="mode-description">Tiny 240p proxy for review and editing workflows, 2 s GOP for smooth scrubbing</div>
+                    </div>
                     <div class="mode-card">
                         <div class="mode-name">⚡ Ultra Fast</div>
                         <div class="mode-details">CRF: 32 | Preset: ultrafast | Audio: 96k</div>
@@ -1261,6 +2043,21 @@ with open('video.mp4', 'rb') as f:
                         <div class="mode-details">CRF: 23 | Preset: fast | Audio: 128k</div>
                         <div class="mode-description">High quality compression for important videos</div>
                     </div>
+                    <div class="mode-card">
+                        <div class="mode-name">🖥️ Screen</div>
+                        <div class="mode-details">CRF: 32 | Preset: faster | Audio: 64k mono | 15 fps</div>
+                        <div class="mode-description">Screencasts and slides: still-image tuning, long GOP, crisp text</div>
+                    </div>
+                    <div class="mode-card">
+                        <div class="mode-name">💎 Lossless</div>
+                        <div class="mode-details">x264 QP 0 | FFV1 in MKV | Audio: copy</div>
+                        <div class="mode-description">Archival codec/container normalization without quality loss; output is usually larger than the source</div>
+                    </div>
+                    <div class="mode-card">
+                        <div class="mode-name">🗄️ Archive</div>
+                        <div class="mode-details">CRF: 20 | Preset: slow | H.265 10-bit (AV1 on request) | Audio: copy</div>
+                        <div class="mode-description">Long-term storage: original resolution/fps, long scene-cut GOP, all metadata and chapters kept</div>
+                    </div>
                 </div>
             </div>
         </div>
@@ -1284,7 +2081,7 @@ with open('video.mp4', 'rb') as f:
     "ok": true,
     "service": "videocompress",
     "version": "3.2.0-orientation",
-    "modes": ["ai", "turbo", "max", "ultra_fast", "super_fast", "fast", "balanced", "quality"],
+    "modes": ["ai", "turbo", "max", "proxy", "ultra_fast", "super_fast", "fast", "balanced", "quality", "screen", "lossless", "archive"],
     "defaults": {
         "codec": "h264",
         "resolution": "original",
@@ -1320,7 +2117,28 @@ with open('video.mp4', 'rb') as f:
                                 <td>File</td>
                                 <td><span class="required">Required</span></td>
                                 <td>-</td>
-                                <td>Video file to compress</td>
+                                <td>Video file to compress (or give url or input)</td>
+                            </tr>
+                            <tr>
+                                <td>url</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>http(s) URL to download the source from instead of uploading it; same size limit as an upload, video/audio Content-Types only</td>
+                            </tr>
+                            <tr>
+                                <td>input</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>s3://bucket/key: read the source from object storage with the server's credentials instead of an upload (INPUT_S3_BUCKETS limits the buckets)</td>
+                            </tr>
+                            <tr>
+                                <td>input_id</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Encode an input kept with keep_input=1 (X-Input-Id), or the kept original of a finished job (its job ID), instead of an upload</td>
                             </tr>
                             <tr>
                                 <td>speed</td>
@@ -1348,7 +2166,7 @@ with open('video.mp4', 'rb') as f:
                                 <td>String</td>
                                 <td><span class="optional">Optional</span></td>
                                 <td>aac</td>
-                                <td>Audio codec</td>
+                                <td>Audio codec: aac, opus, ac3, eac3 (Dolby, up to 5.1, bitrate via ab) or copy</td>
                             </tr>
                             <tr>
                                 <td>hw</td>
@@ -1357,364 +2175,1562 @@ with open('video.mp4', 'rb') as f:
                                 <td>none</td>
                                 <td>Hardware acceleration</td>
                             </tr>
-                        </tbody>
-                    </table>
-                </div>
-
-                <div class="response-headers">
-                    <h4>Response Headers (API Mode):</h4>
-                    <table class="header-table">
-                        <thead>
                             <tr>
-                                <th>Header</th>
-                                <th>Description</th>
-                                <th>Example</th>
+                                <td>hwdecode</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>follows hw</td>
+                                <td>Hardware decoding (none, auto, videotoolbox, cuda, vaapi, qsv); combine with CPU x264 encoding</td>
                             </tr>
-                        </thead>
-                        <tbody>
                             <tr>
-                                <td>X-Mode</td>
-                                <td>Final compression mode used</td>
-                                <td>balanced</td>
+                                <td>strip_metadata</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = remove GPS, device and creation tags</td>
                             </tr>
                             <tr>
-                                <td>X-Encode-Duration-Ms</td>
-                                <td>Encoding time in milliseconds</td>
-                                <td>15000</td>
+                                <td>preserve_metadata</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = explicitly copy global and stream tags</td>
                             </tr>
                             <tr>
-                                <td>X-Throughput-MBps</td>
-                                <td>Processing speed in MB/s</td>
-                                <td>25.5</td>
+                                <td>chapters</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>keep</td>
+                                <td>keep = carry chapters into the output, drop = remove them, export = keep and also store chapters.json / chapters.ffmeta artifacts</td>
                             </tr>
                             <tr>
-                                <td>X-Input-Bytes</td>
-                                <td>Original file size</td>
-                                <td>52428800</td>
+                                <td>captions</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>auto</td>
+                                <td>Embedded CEA-608/708 captions: auto/keep carry them through H.264/H.265 encodes, drop = remove them, extract = keep and also store captions.srt / captions.scc artifacts</td>
                             </tr>
                             <tr>
-                                <td>X-Output-Bytes</td>
-                                <td>Compressed file size</td>
-                                <td>15728640</td>
+                                <td>keep_all_audio</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = encode every audio track (dubs, commentary) with its language tag instead of only the first</td>
                             </tr>
-                        </tbody>
-                    </table>
-                </div>
-
-                <div class="example">
-                    <div class="example-header">Example Request:</div>
-curl -X POST \
--H "Accept: application/octet-stream" \
--F "file=@video.mp4" \
--F "speed=balanced" \
--F "resolution=720p" \
--F "codec=h264" \
--F "hw=videotoolbox" \
--o compressed.mp4 \
-http://localhost:8080/compress
-                </div>
-                <button class="try-it" onclick="testEndpoint('POST', '/compress')">Try it</button>
-            </div>
-
-            <div class="endpoint">
-                <div class="endpoint-header">
-                    <span class="method get">GET</span>
-                    <span class="endpoint-path">/dl/{id}</span>
-                </div>
-                <div class="endpoint-description">Download a compressed file from the temporary store (web UI flow only)</div>
-
-                <div class="parameters">
-                    <h4>Parameters:</h4>
-                    <table class="param-table">
-                        <thead>
                             <tr>
-                                <th>Parameter</th>
-                                <th>Type</th>
-                                <th>Required</th>
-                                <th>Description</th>
+                                <td>audio.N.ab</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>ab</td>
+                                <td>Bitrate of audio track N (0-based), e.g. audio.0.ab=192k, audio.1.ab=96k; tracks above 0 need keep_all_audio=1</td>
                             </tr>
-                        </thead>
-                        <tbody>
                             <tr>
-                                <td>id</td>
+                                <td>preserve_capture</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = copy creation_time and original rotation/display metadata (photo-library friendly)</td>
+                            </tr>
+                            <tr>
+                                <td>cover</td>
                                 <td>String</td>
-                                <td><span class="required">Required</span></td>
-                                <td>File ID from compression result</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>keep</td>
+                                <td>keep = re-attach embedded cover art, drop = remove it</td>
                             </tr>
                             <tr>
-                                <td>name</td>
+                                <td>poster</td>
+                                <td>File</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Image to embed as the MP4/MOV cover (jpeg/png copied, other formats converted)</td>
+                            </tr>
+                            <tr>
+                                <td>compat</td>
                                 <td>String</td>
                                 <td><span class="optional">Optional</span></td>
-                                <td>Custom filename for download</td>
+                                <td>codec</td>
+                                <td>How codec/container conflicts (e.g. opus in .mp4, h265 in .avi) are resolved: codec = substitute a compatible codec, container = switch to .mkv, strict = reject with 422</td>
                             </tr>
-                        </tbody>
-                    </table>
-                </div>
-            </div>
-
-            <div class="endpoint">
-                <div class="endpoint-header">
-                    <span class="method get">GET</span>
-                    <span class="endpoint-path">/meta/{id}</span>
-                </div>
-                <div class="endpoint-description">Get metadata for a compressed file (web UI flow only)</div>
-
-                <div class="example">
-                    <div class="example-header">Response:</div>
-{
-    "id": "abc123def456",
-    "mode": "balanced",
-    "mode_decider": "ai",
-    "input_bytes": 52428800,
-    "output_bytes": 15728640,
-    "resolution": "720p",
-    "codec": "h264",
-    "audio": "aac",
-    "hw": "none",
-    "encode_duration_ms": 15000,
-    "throughput_mb_s": 25.5
-}
-                </div>
-            </div>
-        </div>
-
-        <div class="section">
-            <div class="section-header">
-                <h2>🔧 Configuration Options</h2>
-                <p>Available parameters and their values</p>
-            </div>
-            <div class="endpoint">
-                <div class="parameters">
-                    <h4>Resolution Options:</h4>
-                    <table class="param-table">
-                        <thead>
                             <tr>
-                                <th>Value</th>
-                                <th>Output Size</th>
-                                <th>Description</th>
+                                <td>content</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Content hint: animation, film, screencast or sports (tunes deblocking/AQ per encoder)</td>
                             </tr>
-                        </thead>
-                        <tbody>
                             <tr>
-                                <td>original</td>
-                                <td>Original</td>
-                                <td>Keep original resolution</td>
+                                <td>metadata</td>
+                                <td>JSON object</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Caller data (max 8 KB) stored with the job and echoed in /meta and job views</td>
                             </tr>
                             <tr>
-                                <td>360p</td>
-                                <td>640x360</td>
-                                <td>360p resolution</td>
+                                <td>job_tag</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Correlation tag (or X-Job-Tag header), echoed as X-Job-Tag</td>
                             </tr>
                             <tr>
-                                <td>480p</td>
-                                <td>854x480</td>
-                                <td>480p resolution</td>
+                                <td>output_name</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>{basename}_{mode}</td>
+                                <td>Download filename template: {basename}, {mode}, {resolution}, {codec}, {date} (extension added automatically)</td>
                             </tr>
                             <tr>
-                                <td>720p</td>
-                                <td>1280x720</td>
-                                <td>720p resolution</td>
+                                <td>deliver</td>
+                                <td>JSON array</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Extra delivery targets: local dir, S3 bucket or HTTP PUT/POST URL, each with optional notify webhook; status in /meta</td>
                             </tr>
                             <tr>
-                                <td>1080p</td>
-                                <td>1920x1080</td>
-                                <td>1080p resolution</td>
+                                <td>profile</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Named encoding profile (see /admin/profiles); request fields override its values</td>
                             </tr>
                             <tr>
-                                <td>1440p</td>
-                                <td>2560x1440</td>
-                                <td>1440p resolution</td>
+                                <td>bit_depth</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>auto</td>
+                                <td>8 or 10. 10-bit (main10 / AV1 main) requires codec=h265 or av1; unset keeps 10-bit sources 10-bit in quality mode</td>
                             </tr>
                             <tr>
-                                <td>2160p</td>
-                                <td>3840x2160</td>
-                                <td>4K resolution</td>
+                                <td>pix_fmt</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>auto</td>
+                                <td>yuv420p, yuv422p, yuv444p or their 10-bit variants (yuv422p10le, ...); checked against codec, hw and container</td>
                             </tr>
-                        </tbody>
-                    </table>
-                </div>
-
-                <div class="parameters">
-                    <h4>Codec Options:</h4>
-                    <table class="param-table">
-                        <thead>
                             <tr>
-                                <th>Parameter</th>
-                                <th>Values</th>
-                                <th>Description</th>
+                                <td>interlace</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>progressive</td>
+                                <td>keep, tff or bff: field-coded output with the field order flagged (codec h264, prores or ffv1; original resolution and frame rate)</td>
                             </tr>
-                        </thead>
-                        <tbody>
                             <tr>
-                                <td>codec</td>
-                                <td>h264, h265, copy</td>
-                                <td>Video codec</td>
+                                <td>film_grain</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>auto</td>
+                                <td>AV1 only: film-grain synthesis level 0-50 (8 with content=film)</td>
                             </tr>
                             <tr>
-                                <td>audio</td>
-                                <td>aac, opus, copy</td>
-                                <td>Audio codec</td>
+                                <td>film_grain_denoise</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>encoder default</td>
+                                <td>AV1 only: 1 denoises and re-synthesizes grain, 0 keeps source grain</td>
                             </tr>
                             <tr>
-                                <td>hw</td>
-                                <td>none, videotoolbox</td>
-                                <td>Hardware acceleration</td>
+                                <td>alpha</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>auto</td>
+                                <td>auto|keep|drop: keep transparency of ProRes 4444 / VP9 alpha sources (VP9/WebM, ProRes 4444/MOV) or flatten</td>
                             </tr>
-                        </tbody>
-                    </table>
-                </div>
-            </div>
-        </div>
-
-        <div class="section">
-            <div class="section-header">
-                <h2>🚨 Error Handling</h2>
-                <p>Common error responses and troubleshooting</p>
-            </div>
-            <div class="endpoint">
-                <div class="example">
-                    <div class="example-header">400 Bad Request:</div>
-{
-    "error": "file field required"
-}
-                </div>
-
-                <div class="example">
-                    <div class="example-header">404 Not Found:</div>
-{
-    "error": "Not found"
-}
-                </div>
-
-                <div class="example">
-                    <div class="example-header">500 Internal Server Error:</div>
-{
-    "error": "compression failed: FFmpeg error message"
-}
-                </div>
-            </div>
-        </div>
-
-        <div class="footer">
-            <p>🎬 VideoCompress API v3.2.0-orientation | Built with Go & FFmpeg</p>
-            <p>📚 <a href="/" style="color: #667eea;">Web Interface</a> | 📖 <a href="/api-docs" style="color: #667eea;">API Docs</a></p>
-        </div>
-    </div>
-
-    <script>
-        function switchTab(tabName) {
-            // Hide all tab contents
-            const tabContents = document.querySelectorAll('.tab-content');
-            tabContents.forEach(content => content.classList.remove('active'));
-
-            // Remove active class from all tabs
-            const tabs = document.querySelectorAll('.tab');
-            tabs.forEach(tab => tab.classList.remove('active'));
-
-            // Show selected tab content
-            document.getElementById(tabName).classList.add('active');
-
-            // Add active class to clicked tab
-            event.target.classList.add('active');
-        }
-
-        function copyCode(button) {
-            const codeBlock = button.previousElementSibling;
-            const text = codeBlock.textContent;
-
-            navigator.clipboard.writeText(text).then(() => {
-                const originalText = button.textContent;
-                button.textContent = 'Copied!';
-                button.style.background = '#28a745';
-
-                setTimeout(() => {
-                    button.textContent = originalText;
-                    button.style.background = '#6c757d';
-                }, 2000);
-            });
-        }
-
-        function testEndpoint(method, path) {
-            const baseUrl = window.location.origin;
-            const url = baseUrl + path;
-
-            if (method === 'GET') {
-                window.open(url, '_blank');
-            } else {
-                alert('POST requests require a file upload. Please use the web interface or API tools like Postman.');
-            }
-        }
-
-        // Add syntax highlighting
-        document.addEventListener('DOMContentLoaded', function () {
-            const codeBlocks = document.querySelectorAll('.code-block');
-            codeBlocks.forEach(block => {
-                let html = block.innerHTML;
-
-                // Highlight keywords
-                html = html.replace(/\b(curl|POST|GET|Accept|application\/octet-stream|file|speed|ai|balanced|resolution|codec|hw|videotoolbox|package|import|func|var|const|type|struct|interface|map|slice|chan|go|defer|if|else|for|range|switch|case|default|select|break|continue|return|panic|recover|go|select|chan|close|len|cap|new|make|append|copy|delete|complex|real|imag|iota|nil|true|false)\b/g, '<span class="keyword">$1</span>');
-
-                // Highlight strings
-                html = html.replace(/"([^"]*)"/g, '<span class="string">"$1"</span>');
-
-                // Highlight comments
-                html = html.replace(/(\/\/.*)/g, '<span class="comment">$1</span>');
-
-                block.innerHTML = html;
-            });
-        });
-    </script>
-</body>
-</html>
-`))
+                            <tr>
+                                <td>projection</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>equirectangular: tag an mp4/mov output as 360° video (Spherical Video V1); source 360° metadata is kept automatically</td>
+                            </tr>
+                            <tr>
+                                <td>stereo</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>mono</td>
+                                <td>With projection: mono, top-bottom or left-right</td>
+                            </tr>
+                            <tr>
+                                <td>max_landscape</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Cap for landscape/square sources: WxH (1920x1080) or 1080p; never upscales</td>
+                            </tr>
+                            <tr>
+                                <td>max_portrait</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Cap for portrait sources: WxH (720x1280) or 720p (short edge)</td>
+                            </tr>
+                            <tr>
+                                <td>autocrop</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>false</td>
+                                <td>1 = detect letterbox/pillarbox bars (cropdetect on sampled frames) and crop them before scaling</td>
+                            </tr>
+                            <tr>
+                                <td>crop</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>w:h:x:y window of the upright picture, before scaling (not with autocrop)</td>
+                            </tr>
+                            <tr>
+                                <td>rotate / flip</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>rotate=90|180|270 turns clockwise after the crop; flip=h|v mirrors; presets follow the turned orientation</td>
+                            </tr>
+                            <tr>
+                                <td>trim_dead</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>silence, black or both: trim leading/trailing silence or black frames (both = black and silent)</td>
+                            </tr>
+                            <tr>
+                                <td>start / end / duration</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Compress only a clip: seconds or [HH:]MM:SS[.mmm]; end or duration (codec=copy starts on the keyframe before start)</td>
+                            </tr>
+                            <tr>
+                                <td>gop</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>5s</td>
+                                <td>Keyframe interval: frames (120) or seconds (2s); scene-cut keyframes except in turbo/max</td>
+                            </tr>
+                            <tr>
+                                <td>race</td>
+                                <td>JSON</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Run 2-3 candidate encodes at once, e.g. [{"hw":"videotoolbox"},{"hw":"none"}] or [{"crf":24},{"crf":28}] (keys: crf, preset, hw, hwdecode)</td>
+                            </tr>
+                            <tr>
+                                <td>race_goal</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>smallest</td>
+                                <td>smallest, quality or fastest: how the race winner is chosen</td>
+                            </tr>
+                            <tr>
+                                <td>race_min_score</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Race: minimum SSIM (0-1) a candidate must reach</td>
+                            </tr>
+                            <tr>
+                                <td>race_max_bytes</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Race: maximum output size in bytes</td>
+                            </tr>
+                            <tr>
+                                <td>verify</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>1</td>
+                                <td>Verify the output (duration, streams, first/last GOP decode, audio, A/V end skew); failures return 422 with problems. 0 skips</td>
+                            </tr>
+                            <tr>
+                                <td>validate_for</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Device profile to check the output against: quicktime, android or web. Incompatible outputs are re-encoded (or rejected with compat=strict)</td>
+                            </tr>
+                            <tr>
+                                <td>max_output_bytes</td>
+                                <td>Integer</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Output size ceiling in bytes; oversized outputs are re-encoded once more aggressively or fail with code output_too_large (422)</td>
+                            </tr>
+                            <tr>
+                                <td>on_oversize</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>reencode</td>
+                                <td>reencode (one retry at a higher CRF) or fail when max_output_bytes is exceeded</td>
+                            </tr>
+                            <tr>
+                                <td>segment_sec</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Encode in checkpointed N-second segments (min 30); a retried job, or an async job after a restart, resumes after the last finished segment</td>
+                            </tr>
+                            <tr>
+                                <td>async</td>
+                                <td>string</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = answer 202 with a job ID at once and encode in the background; poll /jobs/{id}</td>
+                            </tr>
+                            <tr>
+                                <td>detach_on_disconnect</td>
+                                <td>string</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = keep encoding if the client disconnects; the job ID is sent early as X-Job-Id (103 Early Hints)</td>
+                            </tr>
+                            <tr>
+                                <td>segment_max_size</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Split the output into parts under this size (e.g. 16MB, 50M) and return them as a ZIP</td>
+                            </tr>
+                            <tr>
+                                <td>segment_max_sec</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Split the output into parts of at most N seconds and return them as a ZIP</td>
+                            </tr>
+                            <tr>
+                                <td>format</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>hls or dash = package for streaming: index.m3u8 or manifest.mpd plus segments, returned as a ZIP (or uploaded with output=s3://...)</td>
+                            </tr>
+                            <tr>
+                                <td>hls_time</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>6</td>
+                                <td>With format=hls: segment duration in seconds (1-30)</td>
+                            </tr>
+                            <tr>
+                                <td>hls_segment</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>by codec</td>
+                                <td>With format=hls: ts (H.264) or fmp4 (H.265/AV1 default)</td>
+                            </tr>
+                            <tr>
+                                <td>seg_duration</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>6</td>
+                                <td>With format=dash: segment duration in seconds (1-30)</td>
+                            </tr>
+                            <tr>
+                                <td>renditions</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Encode several sizes in one job, e.g. 1080p,720p,480p: a ZIP of the renditions, or one streaming package with format=hls|dash</td>
+                            </tr>
+                            <tr>
+                                <td>reframe</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>Crop to another aspect ratio, e.g. 9:16 for Stories/Shorts</td>
+                            </tr>
+                            <tr>
+                                <td>reframe_x</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0.5</td>
+                                <td>Window position for reframe: 0 (left) to 1 (right), or auto to follow motion</td>
+                            </tr>
+                            <tr>
+                                <td>compare</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>compare=1 stores a side-by-side clip (original left, compressed right) as the compare.mp4 artifact</td>
+                            </tr>
+                            <tr>
+                                <td>thumbnails</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>1-20 evenly spaced stills of the output, stored as thumb_01.jpg... artifacts (thumbnail_format=jpg|png, thumbnail_width, default 320)</td>
+                            </tr>
+                            <tr>
+                                <td>preview</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>gif or webp = looping hover preview of the output at /dl/{id}/preview (preview_sec default 3, preview_fps default 10, preview_width default 320)</td>
+                            </tr>
+                            <tr>
+                                <td>storyboard</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = sprite sheets (storyboard_01.jpg...) plus a WebVTT thumbnails track at /dl/{id}/storyboard.vtt for player scrubbing (storyboard_interval seconds, default auto; storyboard_width default 160; storyboard_columns default 10)</td>
+                            </tr>
+                            <tr>
+                                <td>keep_input</td>
+                                <td>Boolean</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>0</td>
+                                <td>1 = keep the upload under an input ID (X-Input-Id) for keep_input_ttl (default 24h) to probe it or re-run the job; see GET /inputs</td>
+                            </tr>
+                            <tr>
+                                <td>compare_at</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>centred</td>
+                                <td>Start of the comparison window in output seconds</td>
+                            </tr>
+                            <tr>
+                                <td>compare_sec</td>
+                                <td>Number</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>6</td>
+                                <td>Length of the comparison clip in seconds (1-30)</td>
+                            </tr>
+                            <tr>
+                                <td>output</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>-</td>
+                                <td>audio = drop the picture and return a compact audio file (audio=opus → .opus, default; aac → .m4a; copy → .mka). s3://bucket/key (key ending in / = prefix) uploads the result there; API mode then returns metadata with output_url instead of the bytes. Default OUTPUT_S3_URL; local opts out</td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </div>
+
+                <div class="response-headers">
+                    <h4>Response Headers (API Mode):</h4>
+                    <table class="header-table">
+                        <thead>
+                            <tr>
+                                <th>Header</th>
+                                <th>Description</th>
+                                <th>Example</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            <tr>
+                                <td>X-Mode</td>
+                                <td>Final compression mode used</td>
+                                <td>balanced</td>
+                            </tr>
+                            <tr>
+                                <td>X-Encode-Duration-Ms</td>
+                                <td>Encoding time in milliseconds</td>
+                                <td>15000</td>
+                            </tr>
+                            <tr>
+                                <td>X-Throughput-MBps</td>
+                                <td>Processing speed in MB/s</td>
+                                <td>25.5</td>
+                            </tr>
+                            <tr>
+                                <td>X-Input-Bytes</td>
+                                <td>Original file size</td>
+                                <td>52428800</td>
+                            </tr>
+                            <tr>
+                                <td>X-Output-Bytes</td>
+                                <td>Compressed file size</td>
+                                <td>15728640</td>
+                            </tr>
+                            <tr>
+                                <td>X-Result-Id</td>
+                                <td>Result ID for /dl/{id} and /meta/{id}</td>
+                                <td>a1b2c3d4e5f6</td>
+                            </tr>
+                            <tr>
+                                <td>X-Input-Id</td>
+                                <td>Retained upload of keep_input=1, for input_id= on /probe, /frames, /thumbnail, /clip</td>
+                                <td>5f0c2a9e81d4</td>
+                            </tr>
+                            <tr>
+                                <td>X-Artifacts</td>
+                                <td>Extra files available at /dl/{id}/{name}</td>
+                                <td>chapters.json,chapters.ffmeta</td>
+                            </tr>
+                            <tr>
+                                <td>X-Warnings</td>
+                                <td>Adjustments made to the request (e.g. codec substitutions)</td>
+                                <td>audio opus → aac for .mp4</td>
+                            </tr>
+                            <tr>
+                                <td>X-Job-Tag</td>
+                                <td>Caller-supplied job tag (from X-Job-Tag / job_tag)</td>
+                                <td>order-1234</td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </div>
+
+                <div class="example">
+                    <div class="example-header">Example Request:</div>
+curl -X POST \
+-H "Accept: application/octet-stream" \
+-F "file=@video.mp4" \
+-F "speed=balanced" \
+-F "resolution=720p" \
+-F "codec=h264" \
+-F "hw=videotoolbox" \
+-o compressed.mp4 \
+http://localhost:8080/compress
+                </div>
+                <button class="try-it" onclick="testEndpoint('POST', '/compress')">Try it</button>
+            </div>
+
+            <div class="endpoint">
+                <div class="endpoint-header">
+                    <span class="method get">GET</span>
+                    <span class="endpoint-path">/dl/{id}</span>
+                </div>
+                <div class="endpoint-description">Download a compressed file from the temporary store (web UI flow only)</div>
+
+                <div class="parameters">
+                    <h4>Parameters:</h4>
+                    <table class="param-table">
+                        <thead>
+                            <tr>
+                                <th>Parameter</th>
+                                <th>Type</th>
+                                <th>Required</th>
+                                <th>Description</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            <tr>
+                                <td>id</td>
+                                <td>String</td>
+                                <td><span class="required">Required</span></td>
+                                <td>File ID from compression result</td>
+                            </tr>
+                            <tr>
+                                <td>name</td>
+                                <td>String</td>
+                                <td><span class="optional">Optional</span></td>
+                                <td>Custom filename for download</td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+
+            <div class="endpoint">
+                <div class="endpoint-header">
+                    <span class="method get">GET</span>
+                    <span class="endpoint-path">/meta/{id}</span>
+                </div>
+                <div class="endpoint-description">Get metadata for a compressed file (web UI flow only)</div>
+
+                <div class="example">
+                    <div class="example-header">Response:</div>
+{
+    "id": "abc123def456",
+    "mode": "balanced",
+    "mode_decider": "ai",
+    "input_bytes": 52428800,
+    "output_bytes": 15728640,
+    "resolution": "720p",
+    "codec": "h264",
+    "audio": "aac",
+    "hw": "none",
+    "encode_duration_ms": 15000,
+    "throughput_mb_s": 25.5
+}
+                </div>
+            </div>
+        </div>
+
+        <div class="section">
+            <div class="section-header">
+                <h2>🔧 Configuration Options</h2>
+                <p>Available parameters and their values</p>
+            </div>
+            <div class="endpoint">
+                <div class="parameters">
+                    <h4>Resolution Options:</h4>
+                    <table class="param-table">
+                        <thead>
+                            <tr>
+                                <th>Value</th>
+                                <th>Output Size</th>
+                                <th>Description</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            <tr>
+                                <td>original</td>
+                                <td>Original</td>
+                                <td>Keep original resolution</td>
+                            </tr>
+                            <tr>
+                                <td>360p</td>
+                                <td>640x360</td>
+                                <td>360p resolution</td>
+                            </tr>
+                            <tr>
+                                <td>480p</td>
+                                <td>854x480</td>
+                                <td>480p resolution</td>
+                            </tr>
+                            <tr>
+                                <td>720p</td>
+                                <td>1280x720</td>
+                                <td>720p resolution</td>
+                            </tr>
+                            <tr>
+                                <td>1080p</td>
+                                <td>1920x1080</td>
+                                <td>1080p resolution</td>
+                            </tr>
+                            <tr>
+                                <td>1440p</td>
+                                <td>2560x1440</td>
+                                <td>1440p resolution</td>
+                            </tr>
+                            <tr>
+                                <td>2160p</td>
+                                <td>3840x2160</td>
+                                <td>4K resolution</td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </div>
+
+                <div class="parameters">
+                    <h4>Codec Options:</h4>
+                    <table class="param-table">
+                        <thead>
+                            <tr>
+                                <th>Parameter</th>
+                                <th>Values</th>
+                                <th>Description</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            <tr>
+                                <td>codec</td>
+                                <td>h264, h265, vp9, av1, prores, copy</td>
+                                <td>Video codec</td>
+                            </tr>
+                            <tr>
+                                <td>audio</td>
+                                <td>aac, opus, ac3, eac3, copy</td>
+                                <td>Audio codec</td>
+                            </tr>
+                            <tr>
+                                <td>hw</td>
+                                <td>none, videotoolbox</td>
+                                <td>Hardware acceleration</td>
+                            </tr>
+                            <tr>
+                                <td>hwdecode</td>
+                                <td>none, auto, videotoolbox, cuda, vaapi, qsv</td>
+                                <td>Hardware decoding, independent of the encoder</td>
+                            </tr>
+                        </tbody>
+                    </table>
+                </div>
+            </div>
+        </div>
+
+        <div class="section">
+            <div class="section-header">
+                <h2>🚨 Error Handling</h2>
+                <p>Common error responses and troubleshooting</p>
+            </div>
+            <div class="endpoint">
+                <div class="example">
+                    <div class="example-header">400 Bad Request:</div>
+{
+    "error": "file field required"
+}
+                </div>
+
+                <div class="example">
+                    <div class="example-header">404 Not Found:</div>
+{
+    "error": "Not found"
+}
+                </div>
+
+                <div class="example">
+                    <div class="example-header">500 Internal Server Error:</div>
+{
+    "error": "compression failed: FFmpeg error message"
+}
+                </div>
+            </div>
+        </div>
+
+        <div class="footer">
+            <p>🎬 VideoCompress API v3.2.0-orientation | Built with Go & FFmpeg</p>
+            <p>📚 <a href="/" style="color: #667eea;">Web Interface</a> | 📖 <a href="/api-docs" style="color: #667eea;">API Docs</a></p>
+        </div>
+    </div>
+
+    <script>
+        function switchTab(tabName) {
+            // Hide all tab contents
+            const tabContents = document.querySelectorAll('.tab-content');
+            tabContents.forEach(content => content.classList.remove('active'));
+
+            // Remove active class from all tabs
+            const tabs = document.querySelectorAll('.tab');
+            tabs.forEach(tab => tab.classList.remove('active'));
+
+            // Show selected tab content
+            document.getElementById(tabName).classList.add('active');
+
+            // Add active class to clicked tab
+            event.target.classList.add('active');
+        }
+
+        function copyCode(button) {
+            const codeBlock = button.previousElementSibling;
+            const text = codeBlock.textContent;
+
+            navigator.clipboard.writeText(text).then(() => {
+                const originalText = button.textContent;
+                button.textContent = 'Copied!';
+                button.style.background = '#28a745';
+
+                setTimeout(() => {
+                    button.textContent = originalText;
+                    button.style.background = '#6c757d';
+                }, 2000);
+            });
+        }
+
+        function testEndpoint(method, path) {
+            const baseUrl = window.location.origin;
+            const url = baseUrl + path;
+
+            if (method === 'GET') {
+                window.open(url, '_blank');
+            } else {
+                alert('POST requests require a file upload. Please use the web interface or API tools like Postman.');
+            }
+        }
+
+        // Add syntax highlighting
+        document.addEventListener('DOMContentLoaded', function () {
+            const codeBlocks = document.querySelectorAll('.code-block');
+            codeBlocks.forEach(block => {
+                let html = block.innerHTML;
+
+                // Highlight keywords
+                html = html.replace(/\b(curl|POST|GET|Accept|application\/octet-stream|file|speed|ai|balanced|resolution|codec|hw|videotoolbox|package|import|func|var|const|type|struct|interface|map|slice|chan|go|defer|if|else|for|range|switch|case|default|select|break|continue|return|panic|recover|go|select|chan|close|len|cap|new|make|append|copy|delete|complex|real|imag|iota|nil|true|false)\b/g, '<span class="keyword">$1</span>');
+
+                // Highlight strings
+                html = html.replace(/"([^"]*)"/g, '<span class="string">"$1"</span>');
+
+                // Highlight comments
+                html = html.replace(/(\/\/.*)/g, '<span class="comment">$1</span>');
+
+                block.innerHTML = html;
+            });
+        });
+    </script>
+</body>
+</html>
+`))
+
+func uploadPage(w http.ResponseWriter, r *http.Request) {
+	lang := requestLang(r)
+	rememberLang(w, r, lang)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = uploadTpl.Execute(w, map[string]any{"Site": site, "L": uiText{Lang: lang}, "Langs": uiLangs()})
+}
+
+// Cleanly save a multipart file to disk (kept for completeness)
+func savePartToTemp(part *multipart.Part, suggested string) (string, error) {
+	tmpDir := os.TempDir()
+	name := filepath.Base(suggested)
+	if name == "" || name == "." || name == "/" {
+		name = "upload_" + randID(6)
+	}
+	dst := filepath.Join(tmpDir, name)
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, part)
+	return dst, err
+}
+
+// saveFormFile copies a multipart file field (after ParseMultipartForm) to a
+// uniquely named temp file and returns its path and size.
+func saveFormFile(r *http.Request, field string) (string, int64, error) {
+	file, hdr, err := r.FormFile(field)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	name := filepath.Base(hdr.Filename)
+	if name == "" || name == "." || name == "/" {
+		name = "upload"
+	}
+	dst := filepath.Join(os.TempDir(), randID(6)+"_"+name)
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, file)
+	f.Close()
+	if err != nil {
+		os.Remove(dst)
+		return "", 0, err
+	}
+	return dst, n, nil
+}
+
+// saveFileHeader copies one multipart file part to dst.
+func saveFileHeader(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, src)
+	return err
+}
+
+// Parse options (after ParseMultipartForm)
+func parseOpts(r *http.Request) (compressOpts, error) {
+	return parseRequestOpts(r, r.FormValue)
+}
+
+// parseRequestOpts parses options from form (the request's fields, or a
+// job's recorded ones for a re-run) with the request's headers and upload
+// token applied.
+func parseRequestOpts(r *http.Request, form func(string) string) (compressOpts, error) {
+	negotiated := acceptedContainer(r.Header.Get("Accept"))
+	value := func(k string) string {
+		if k == "job_tag" {
+			if t := r.Header.Get("X-Job-Tag"); t != "" {
+				return t
+			}
+		}
+		// Accept: video/webm etc. stands in for a missing outExt
+		if k == "outExt" && form(k) == "" && negotiated != "" {
+			return negotiated
+		}
+		return form(k)
+	}
+	var o compressOpts
+	var err error
+	if g := grantOf(r); g == nil {
+		o, err = parseOptsFrom(value)
+	} else {
+		var denied []string
+		o, err = parseOptsFrom(g.value(value, &denied))
+		if err == nil && len(denied) > 0 {
+			slices.Sort(denied)
+			err = fmt.Errorf("options not allowed by the upload token: %s", strings.Join(slices.Compact(denied), ", "))
+		}
+		o.Token = g.ID
+	}
+	if o.KeepInput != nil {
+		o.KeepInput.Owner = clientKey(r) // retained inputs are the caller's
+	}
+	return o, err
+}
+
+// parseOptsFrom parses options from any key/value source (form fields, gRPC params).
+// profile=<name> supplies defaults for fields the request leaves empty.
+func parseOptsFrom(value func(string) string) (compressOpts, error) {
+	prof, err := lookupProfile(value("profile"))
+	if err != nil {
+		return compressOpts{}, err
+	}
+	return parseOptsWith(value, prof)
+}
+
+func parseOptsWith(value func(string) string, prof encodingProfile) (compressOpts, error) {
+	o := compressOpts{}
+	get := func(key, def string) string {
+		if v := value(key); v != "" {
+			return v
+		}
+		if v := prof.Params[key]; v != "" {
+			return v
+		}
+		return def
+	}
+	o.Codec = get("codec", "h264")
+	o.Audio = get("audio", "aac")
+	o.AB = get("ab", "")
+	o.HW = get("hw", "none")
+	o.HWDecode = get("hwdecode", "")
+	switch o.HWDecode {
+	case "", "none", "auto", "videotoolbox", "cuda", "vaapi", "qsv":
+	default:
+		return o, fmt.Errorf("invalid hwdecode %q (none|auto|videotoolbox|cuda|vaapi|qsv)", o.HWDecode)
+	}
+	ext, err := normalizeOutExt(get("outExt", ".mp4"))
+	if err != nil {
+		return o, err
+	}
+	o.OutExt = ext
+	if isDolby(o.Audio) {
+		if o.DolbyAB, err = parseDolbyBitrate(strings.ToLower(o.Audio), o.AB); err != nil {
+			return o, err
+		}
+	}
+	if o.BitDepth, err = parseBitDepth(get("bit_depth", ""), o.Codec); err != nil {
+		return o, err
+	}
+	if o.MaxLandscape, err = parseResCap(get("max_landscape", ""), false); err != nil {
+		return o, err
+	}
+	if o.MaxPortrait, err = parseResCap(get("max_portrait", ""), true); err != nil {
+		return o, err
+	}
+	o.AutoCrop = get("autocrop", "") == "1"
+	if o.Reframe, err = parseReframe(get("reframe", ""), get("reframe_x", ""), o.Codec); err != nil {
+		return o, err
+	}
+	if err := parseTransform(get("crop", ""), get("rotate", ""), get("flip", ""), &o); err != nil {
+		return o, err
+	}
+	if o.Compare, err = parseCompare(get("compare", ""), get("compare_at", ""), get("compare_sec", "")); err != nil {
+		return o, err
+	}
+	o.SkipVerify = get("verify", "1") == "0"
+	if o.Race, err = parseRace(get("race", ""), get("race_goal", ""), get("race_min_score", ""), get("race_max_bytes", "")); err != nil {
+		return o, err
+	}
+	if o.SegmentSec, err = parseSegmentSec(get("segment_sec", ""), o.Codec, o.Race != nil); err != nil {
+		return o, err
+	}
+	if o.GOPFrames, o.GOPSec, err = parseGOP(get("gop", "")); err != nil {
+		return o, err
+	}
+	if o.SplitMaxBytes, o.SplitMaxSec, err = parseSplit(get("segment_max_size", ""), get("segment_max_sec", "")); err != nil {
+		return o, err
+	}
+	if o.KeepInput, err = parseKeepInput(get("keep_input", ""), get("keep_input_ttl", "")); err != nil {
+		return o, err
+	}
+	o.TrimDead = get("trim_dead", "")
+	switch o.TrimDead {
+	case "", "silence", "black", "both":
+	default:
+		return o, fmt.Errorf("invalid trim_dead %q (silence|black|both)", o.TrimDead)
+	}
+	if err := parseTrim(get("start", ""), get("end", ""), get("duration", ""), &o); err != nil {
+		return o, err
+	}
+	o.Projection, o.Stereo = get("projection", ""), get("stereo", "")
+	if err := parseSpherical(o.Projection, o.Stereo, o.OutExt); err != nil {
+		return o, err
+	}
+	if o.FilmGrain, o.FilmGrainDenoise, err = parseFilmGrain(get("film_grain", ""), get("film_grain_denoise", ""), o.Codec); err != nil {
+		return o, err
+	}
+	o.SpeedMode = get("speed", "ai")
+	o.Resolution = get("resolution", "original")
+	if fpsStr := get("fps", ""); fpsStr != "" {
+		if n, err := strconv.Atoi(fpsStr); err == nil && n > 0 && n <= 60 {
+			o.FPS = n
+		}
+	}
+	o.StripMetadata = get("strip_metadata", "") == "1"
+	o.PreserveMetadata = get("preserve_metadata", "") == "1"
+	if o.StripMetadata && o.PreserveMetadata {
+		return o, errors.New("strip_metadata and preserve_metadata are mutually exclusive")
+	}
+	o.PreserveCapture = get("preserve_capture", "") == "1"
+	o.Cover = get("cover", "keep")
+	if o.Cover != "keep" && o.Cover != "drop" {
+		return o, fmt.Errorf("invalid cover %q (keep|drop)", o.Cover)
+	}
+	o.Compat = get("compat", "codec")
+	switch o.Compat {
+	case "codec", "container", "strict":
+	default:
+		return o, fmt.Errorf("invalid compat %q (codec|container|strict)", o.Compat)
+	}
+	o.OnOversize = get("on_oversize", "reencode")
+	if o.MaxOutputBytes, err = parseMaxOutput(get("max_output_bytes", ""), o.OnOversize); err != nil {
+		return o, err
+	}
+	o.ValidateFor = get("validate_for", "")
+	if err := parseValidateFor(o.ValidateFor, o.SpeedMode); err != nil {
+		return o, err
+	}
+	o.OutputName = get("output_name", "")
+	if err := validateNameTemplate(o.OutputName); err != nil {
+		return o, err
+	}
+	if o.Deliver, err = parseDeliveryTargets(get("deliver", "")); err != nil {
+		return o, err
+	}
+	output := get("output", "")
+	if output == "audio" {
+		if o.AudioOut, err = parseAudioOut(get("audio", ""), get("ab", ""), get("outExt", "")); err != nil {
+			return o, err
+		}
+		o.OutExt, output = o.AudioOut.Ext, ""
+	}
+	if o.OutputS3, err = parseOutputTarget(output); err != nil {
+		return o, err
+	}
+	o.Alpha = get("alpha", "auto")
+	switch o.Alpha {
+	case "auto", "keep", "drop":
+	default:
+		return o, fmt.Errorf("invalid alpha %q (auto|keep|drop)", o.Alpha)
+	}
+	o.Content = get("content", "")
+	if err := validContent(o.Content); err != nil {
+		return o, err
+	}
+	if o.CallbackURL = get("callback_url", ""); o.CallbackURL != "" && !isHTTPURL(o.CallbackURL) {
+		return o, errors.New("callback_url must be an http(s) URL")
+	}
+	if o.Client, err = parseClientMeta(get("metadata", ""), get("job_tag", "")); err != nil {
+		return o, err
+	}
+	o.Chapters = get("chapters", "keep")
+	switch o.Chapters {
+	case "keep", "drop", "export":
+	default:
+		return o, fmt.Errorf("invalid chapters %q (keep|drop|export)", o.Chapters)
+	}
+	if o.Captions, err = parseCaptions(get("captions", "auto"), o); err != nil {
+		return o, err
+	}
+	if o.KeepAllAudio, err = parseKeepAllAudio(get("keep_all_audio", ""), o); err != nil {
+		return o, err
+	}
+	if o.TrackAB, err = parseTrackBitrates(get, o); err != nil {
+		return o, err
+	}
+	if o.Thumbnails, err = parseThumbSpec(get("thumbnails", ""), "", get("thumbnail_format", ""), get("thumbnail_width", ""), 0); err != nil {
+		return o, err
+	}
+	if o.Thumbnails != nil && o.AudioOut != nil {
+		return o, errors.New("thumbnails need a video output (output=audio)")
+	}
+	if o.Preview, err = parsePreview(get("preview", ""), get("preview_sec", ""), get("preview_fps", ""), get("preview_width", "")); err != nil {
+		return o, err
+	}
+	if o.Preview != nil && o.AudioOut != nil {
+		return o, errors.New("preview needs a video output (output=audio)")
+	}
+	if o.Storyboard, err = parseStoryboard(get("storyboard", ""), get("storyboard_interval", ""), get("storyboard_width", ""), get("storyboard_columns", "")); err != nil {
+		return o, err
+	}
+	if o.Storyboard != nil && o.AudioOut != nil {
+		return o, errors.New("storyboard needs a video output (output=audio)")
+	}
+	if err := o.checkAudioOut(); err != nil {
+		return o, err
+	}
+	if o.PixFmt, err = parsePixFmt(get("pix_fmt", ""), o); err != nil {
+		return o, err
+	}
+	if o.PixFmt != "" && o.Alpha == "auto" {
+		o.Alpha = "drop" // the chosen format has no alpha plane
+	}
+	if o.Interlace, err = parseInterlace(get("interlace", ""), o); err != nil {
+		return o, err
+	}
+	if o.Renditions, err = parseRenditions(get("renditions", ""), o); err != nil {
+		return o, err
+	}
+	if o.Package, err = parsePackage(get("format", ""), get("hls_time", ""), get("hls_segment", ""), get("seg_duration", ""), get("outExt", ""), o); err != nil {
+		return o, err
+	}
+	if o.Package != nil {
+		o.OutExt = ".mp4" // encoded as MP4, then packaged
+	}
+	o.normalize()
+	return o, nil
+}
+
+// compressFile runs the full pipeline (AI decision, safety, profile, FFmpeg,
+// validation) for an input already on disk. Shared by the HTTP and gRPC APIs.
+func compressFile(ctx context.Context, requestID, inPath string, opts compressOpts) (*resultEntry, error) {
+	ctx, usage := withUsage(ctx)
+	// File size
+	logger.Printf("📊 [%s] Calculating file statistics...", requestID)
+	st, _ := os.Stat(inPath)
+	var sizeMB int64
+	var inputBytes int64
+	if st != nil {
+		inputBytes = st.Size()
+		sizeMB = st.Size() / (1024 * 1024)
+		logger.Printf("📈 [%s] File size: %s (%d bytes, %d MB)", requestID, humanBytes(inputBytes), inputBytes, sizeMB)
+	} else {
+		logger.Printf("⚠️ [%s] Could not get file stats", requestID)
+	}
+
+	// Probe the source (optional: the pipeline still works without ffprobe)
+	if src, err := probeFile(ctx, inPath); err == nil {
+		opts.Source = src
+		logger.Printf("🔎 [%s] Source: %s, %.1fs, %d streams, %d chapters",
+			requestID, src.Format.FormatName, src.durationSec(), len(src.Streams), len(src.Chapters))
+	} else {
+		logger.Printf("⚠️ [%s] Could not probe source: %v", requestID, err)
+	}
+
+	if opts.AudioOut != nil && opts.Source != nil && opts.Source.firstStream("audio") == nil {
+		return nil, &compatError{Container: opts.OutExt, Conflicts: []string{"output=audio needs an audio stream; the source has none"}}
+	}
+
+	// Lossless picks its own codecs, so it runs before the compat check
+	var warnings []string
+	if opts.SpeedMode == "lossless" {
+		warnings = append(warnings, opts.applyLossless()...)
+		logger.Printf("💎 [%s] Lossless mode: video %s, audio %s", requestID, opts.Codec, opts.Audio)
+	}
+	if opts.SpeedMode == "archive" {
+		warnings = append(warnings, opts.applyArchive()...)
+		logger.Printf("🗄️ [%s] Archive mode: video %s, audio %s", requestID, opts.Codec, opts.Audio)
+	}
+
+	// Black bars: crop before any scaling
+	if opts.AutoCrop && opts.Codec != "copy" {
+		crop, err := detectCrop(ctx, inPath, opts.Source, opts.PreserveCapture)
+		switch {
+		case err != nil:
+			logger.Printf("⚠️ [%s] Autocrop skipped: %v", requestID, err)
+			warnings = append(warnings, "autocrop skipped: "+err.Error())
+		case crop == "":
+			logger.Printf("✂️ [%s] Autocrop: no black bars found", requestID)
+		default:
+			opts.Crop = crop
+			logger.Printf("✂️ [%s] Autocrop: crop=%s", requestID, crop)
+		}
+	}
+
+	// Dead air: seek past leading and stop before trailing silence/black
+	if opts.TrimDead != "" {
+		start, end, err := detectDeadAir(ctx, inPath, opts.Source, opts.TrimDead)
+		if err != nil {
+			logger.Printf("⚠️ [%s] Dead-air trim skipped: %v", requestID, err)
+			warnings = append(warnings, "trim_dead skipped: "+err.Error())
+		} else if dur := opts.Source.durationSec(); start > 0 || end < dur {
+			opts.TrimStart = start
+			if end < dur {
+				opts.TrimEnd = end
+			}
+			note := fmt.Sprintf("trimmed %.1fs leading and %.1fs trailing dead air (%s)", start, dur-end, opts.TrimDead)
+			logger.Printf("✂️ [%s] %s", requestID, note)
+			warnings = append(warnings, note)
+		}
+	}
+
+	// Reframe: pick where the window sits over time
+	if opts.Reframe != nil && opts.Reframe.Auto {
+		track, err := trackMotion(ctx, inPath, opts.Source, opts.Reframe, opts.PreserveCapture)
+		if err != nil {
+			logger.Printf("⚠️ [%s] Motion tracking skipped, reframing centred: %v", requestID, err)
+			warnings = append(warnings, "reframe_x=auto skipped: "+err.Error())
+		} else {
+			opts.Reframe.Track = track
+			logger.Printf("🎯 [%s] Reframe %d:%d following motion (%d points)", requestID, opts.Reframe.W, opts.Reframe.H, len(track))
+		}
+	} else if opts.Reframe != nil {
+		logger.Printf("🎯 [%s] Reframe %d:%d at x=%.2f", requestID, opts.Reframe.W, opts.Reframe.H, opts.Reframe.X)
+	}
+
+	// Transparent sources: keep alpha (possibly switching codec) or say why not
+	alphaNotes, err := opts.resolveAlpha()
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		return nil, err
+	}
+	for _, n := range alphaNotes {
+		logger.Printf("🫥 [%s] Alpha: %s", requestID, n)
+	}
+	warnings = append(warnings, alphaNotes...)
+
+	// Resolve codec/container conflicts before spending time on the encode
+	notes, err := resolveCompat(&opts)
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		return nil, err
+	}
+	for _, n := range notes {
+		logger.Printf("🔀 [%s] Compatibility: %s", requestID, n)
+	}
+	warnings = append(warnings, notes...)
+
+	// Device profile: container, codecs and pixel format a target player takes
+	deviceNotes, err := opts.applyDevice()
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		return nil, err
+	}
+	for _, n := range deviceNotes {
+		logger.Printf("📱 [%s] %s", requestID, n)
+	}
+	warnings = append(warnings, deviceNotes...)
+
+	if opts.AudioOut != nil && opts.SpeedMode == "ai" {
+		// nothing to weigh without a picture: speech is clear at the balanced rate
+		opts.SpeedMode = "balanced"
+	}
+	if len(opts.Renditions) > 0 && opts.SpeedMode == "ai" {
+		// the ladder fixes the sizes; AI would pick one size for all of them
+		opts.SpeedMode = "balanced"
+	}
+
+	// Decide final mode if AI
+	logger.Printf("🤖 [%s] Processing speed mode decision...", requestID)
+	modeDecider := "manual"
+	if opts.SpeedMode == "ai" {
+		modeDecider = "ai"
+		mode, decider := decideMode(ctx, requestID, newDecisionInput(sizeMB, inputBytes, opts))
+		opts.SpeedMode = mode
+		logger.Printf("🎯 [%s] Final AI mode: %s (decider: %s, %d MB file)", requestID, opts.SpeedMode, decider, sizeMB)
+	} else {
+		logger.Printf("🎛️ [%s] Using manual mode: %s", requestID, opts.SpeedMode)
+	}
+
+	// Small-file safety
+	logger.Printf("🛡️ [%s] Applying small-file safety checks...", requestID)
+	for _, n := range opts.tinyInputSafety(inputBytes) {
+		logger.Printf("⚠️ [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	logger.Printf("✅ [%s] Safety checks applied", requestID)
+
+	// Apply profile params
+	logger.Printf("⚙️ [%s] Applying speed profile parameters...", requestID)
+	opts.applySpeedMode()
+	logger.Printf("✅ [%s] Profile applied: CRF=%d, Preset=%s, AB=%s", requestID, opts.CRF, opts.Preset, opts.AB)
+	if modeDecider == "ai" {
+		for _, n := range opts.planAI() {
+			logger.Printf("🧠 [%s] %s", requestID, n)
+			warnings = append(warnings, n)
+		}
+	}
+	if n := opts.settlePixFmt(); n != "" {
+		logger.Printf("🎨 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	for _, n := range opts.settleInterlace() {
+		logger.Printf("📺 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	for _, n := range opts.settleCaptions() {
+		logger.Printf("💬 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	if n := opts.settlePackage(); n != "" {
+		logger.Printf("📺 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	for _, n := range opts.settleRenditions() {
+		logger.Printf("🪜 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	if n := opts.settleDolby(); n != "" {
+		logger.Printf("🔊 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	if opts.KeepAllAudio && opts.Source != nil {
+		logger.Printf("🔊 [%s] Keeping all %d audio tracks", requestID, len(opts.Source.audioStreams()))
+	}
+	for _, n := range opts.settleTrackBitrates() {
+		logger.Printf("🔊 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	transformNotes, err := opts.settleTransform()
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		return nil, &compatError{Container: opts.OutExt, Conflicts: []string{err.Error()}}
+	}
+	for _, n := range transformNotes {
+		logger.Printf("🔄 [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+	// start/end/duration: once the codec is final (a copy starts on a keyframe)
+	trimNotes, err := opts.settleTrim(ctx, inPath)
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		return nil, &compatError{Container: opts.OutExt, Conflicts: []string{err.Error()}}
+	}
+	for _, n := range trimNotes {
+		logger.Printf("✂️ [%s] %s", requestID, n)
+		warnings = append(warnings, n)
+	}
+
+	outPath := outputPath(requestID, withExt(filepath.Base(inPath), "_compressed"+opts.OutExt))
+	logger.Printf("🎬 [%s] Output path: %s", requestID, outPath)
+
+	// --- timing starts here ---
+	logger.Printf("⏱️ [%s] Starting compression process...", requestID)
+	start := time.Now()
+
+	logger.Printf("🔧 [%s] Executing FFmpeg compression...", requestID)
+	var race []raceResult
+	if opts.Race != nil {
+		race, err = raceEncode(ctx, requestID, inPath, outPath, opts)
+		if race != nil {
+			warnings = append(warnings, raceNote(race, opts.Race))
+		}
+	} else if opts.SegmentSec > 0 {
+		var resumed int
+		resumed, err = segmentedEncode(ctx, requestID, inPath, outPath, opts)
+		if resumed > 0 {
+			warnings = append(warnings, fmt.Sprintf("resumed from checkpoint: %d segments reused", resumed))
+		}
+	} else {
+		err = runFFmpeg(ctx, inPath, outPath, opts, io.Discard)
+	}
+	if err != nil {
+		logger.Printf("❌ [%s] FFmpeg compression failed: %v", requestID, err)
+		return nil, fmt.Errorf("compression failed: %w", err)
+	}
+	if opts.ValidateFor != "" {
+		deviceNotes, err := enforceDevice(ctx, requestID, inPath, outPath, &opts)
+		if err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+		warnings = append(warnings, deviceNotes...)
+	}
+	if opts.MaxOutputBytes > 0 {
+		sizeNotes, err := enforceMaxOutput(ctx, requestID, inPath, outPath, &opts)
+		if err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+		warnings = append(warnings, sizeNotes...)
+	}
+	if err := applyCover(ctx, requestID, inPath, outPath, opts); err != nil {
+		logger.Printf("⚠️ [%s] Cover art not embedded: %v", requestID, err)
+	}
+	if n := sphericalNote(opts); n != "" {
+		warnings = append(warnings, n)
+	}
+	if opts.Projection != "" {
+		if err := injectSphericalV1(outPath, opts.Projection, opts.Stereo); err != nil {
+			logger.Printf("⚠️ [%s] Spherical metadata not injected: %v", requestID, err)
+			warnings = append(warnings, "spherical metadata not injected: "+err.Error())
+		} else {
+			logger.Printf("🌐 [%s] Tagged as %s 360° video (stereo: %s)", requestID, opts.Projection, opts.Stereo)
+		}
+	}
+
+	elapsed := time.Since(start)
+	elapsedMs := elapsed.Milliseconds()
+	logger.Printf("✅ [%s] Compression completed in %d ms", requestID, elapsedMs)
+
+	// validate output
+	logger.Printf("🔍 [%s] Validating compressed output...", requestID)
+	stat, err := os.Stat(outPath)
+	if err != nil || stat.Size() < 1024 {
+		logger.Printf("❌ [%s] Output validation failed: %v", requestID, err)
+		return nil, errOutputInvalid
+	}
+	outputBytes := stat.Size()
+	if !opts.SkipVerify {
+		logger.Printf("🔬 [%s] Verifying output (duration, streams, first/last GOP, audio)...", requestID)
+		if err := verifyOutput(ctx, inPath, outPath, opts); err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+	}
+	logger.Printf("✅ [%s] Output validated: %s (%d bytes)", requestID, humanBytes(outputBytes), outputBytes)
+
+	// throughput (MB/s) = input size / seconds
+	logger.Printf("📊 [%s] Calculating compression statistics...", requestID)
+	throughput := 0.0
+	sec := elapsed.Seconds()
+	if sec > 0 {
+		throughput = (float64(inputBytes) / (1024 * 1024)) / sec
+	}
+	
+	if opts.SpeedMode == "lossless" {
+		if n := losslessSizeNote(inputBytes, outputBytes); n != "" {
+			warnings = append(warnings, n)
+		}
+	}
 
-func uploadPage(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_ = uploadTpl.Execute(w, nil)
-}
+	compressionRatio := float64(outputBytes) / float64(inputBytes) * 100
+	logger.Printf("📈 [%s] Compression stats: %.2f MB/s throughput, %.1f%% size reduction", 
+		requestID, throughput, 100-compressionRatio)
 
-// Cleanly save a multipart file to disk (kept for completeness)
-func savePartToTemp(part *multipart.Part, suggested string) (string, error) {
-	tmpDir := os.TempDir()
-	name := filepath.Base(suggested)
-	if name == "" || name == "." || name == "/" {
-		name = "upload_" + randID(6)
+	entry := &resultEntry{
+		FilePath:    outPath,
+		ModeFinal:   opts.SpeedMode,
+		ModeDecider: modeDecider,
+		InputBytes:  inputBytes,
+		OutputBytes: outputBytes,
+		Resolution:  opts.Resolution,
+		Codec:       opts.Codec,
+		Audio:       opts.Audio,
+		HW:          opts.HW,
+		ElapsedMs:   elapsedMs,
+		Throughput:  throughput,
+		Artifacts:   map[string]string{},
+		Warnings:    warnings,
+		Client:      opts.Client,
+		Deliveries:  newDeliveries(append(slices.Clone(opts.Deliver), currentConfig().Deliver...)),
+		Race:        race,
+		Token:       opts.Token,
 	}
-	dst := filepath.Join(tmpDir, name)
-	f, err := os.Create(dst)
-	if err != nil {
-		return "", err
+	if opts.Compare != nil {
+		if err := makeComparison(ctx, requestID, inPath, outPath, opts, entry.Artifacts); err != nil {
+			logger.Printf("⚠️ [%s] Comparison clip skipped: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "compare skipped: "+err.Error())
+		}
 	}
-	defer f.Close()
-	_, err = io.Copy(f, part)
-	return dst, err
-}
+	entry.Usage = usage.snapshot()
+	countUsage(opts.SpeedMode, entry.Usage)
+	if race == nil {
+		recordHistory(historyRecord{Time: time.Now().UTC(), Mode: opts.SpeedMode, Codec: opts.Codec, HW: opts.HW,
+			InputBytes: inputBytes, OutputBytes: outputBytes, ElapsedMs: elapsedMs})
+	}
+	source := opts.SourceName
+	if source == "" {
+		source = inPath
+	}
+	if opts.KeepInput != nil {
+		if in, err := keepInput(requestID, inPath, opts.SourceName, opts.KeepInput); err != nil {
+			logger.Printf("⚠️ [%s] Input not kept: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "keep_input: input not kept: "+err.Error())
+		} else {
+			entry.InputID = in.ID
+		}
+	}
+	if keepOriginals() {
+		keepOriginal(requestID, inPath, entry)
+	}
+	entry.Name = renderOutputName(outputNameTemplate(opts.OutputName), source, opts.SpeedMode, opts.Resolution, opts.Codec, filepath.Ext(outPath))
 
-// Parse options (after ParseMultipartForm)
-func parseOpts(r *http.Request) (compressOpts, error) {
-	o := compressOpts{}
-	get := func(key, def string) string {
-		if v := r.FormValue(key); v != "" {
-			return v
+	if opts.Chapters == "export" && opts.Source != nil && len(opts.Source.Chapters) > 0 {
+		if err := exportChapters(opts.Source.Chapters, outPath, entry.Artifacts); err != nil {
+			logger.Printf("⚠️ [%s] Chapter export failed: %v", requestID, err)
+		} else {
+			logger.Printf("📑 [%s] Exported %d chapters", requestID, len(opts.Source.Chapters))
 		}
-		return def
 	}
-	o.Codec = get("codec", "h264")
-	o.Audio = get("audio", "aac")
-	o.AB = get("ab", "")
-	o.HW = get("hw", "none")
-	o.OutExt = get("outExt", ".mp4")
-	o.SpeedMode = get("speed", "ai")
-	o.Resolution = get("resolution", "original")
-	if fpsStr := get("fps", ""); fpsStr != "" {
-		if n, err := strconv.Atoi(fpsStr); err == nil && n > 0 && n <= 60 {
-			o.FPS = n
+	if opts.Captions == "extract" {
+		if !opts.Source.hasClosedCaptions() {
+			entry.Warnings = append(entry.Warnings, "captions=extract: no closed captions found in the source")
+		} else if err := extractCaptions(ctx, inPath, outPath, opts, entry.Artifacts); err != nil {
+			logger.Printf("⚠️ [%s] Caption extraction failed: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "captions not extracted: "+err.Error())
+		} else {
+			logger.Printf("💬 [%s] Extracted closed captions", requestID)
 		}
 	}
-	o.normalize()
-	return o, nil
+	if opts.Thumbnails != nil {
+		if err := makeThumbnails(ctx, requestID, outPath, opts, entry); err != nil {
+			logger.Printf("⚠️ [%s] Thumbnails skipped: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "thumbnails skipped: "+err.Error())
+		}
+	}
+	if opts.Preview != nil {
+		if err := makePreview(ctx, requestID, outPath, opts, entry.Artifacts); err != nil {
+			logger.Printf("⚠️ [%s] Preview skipped: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "preview skipped: "+err.Error())
+		}
+	}
+	if opts.Storyboard != nil {
+		if err := makeStoryboard(ctx, requestID, outPath, opts, entry); err != nil {
+			logger.Printf("⚠️ [%s] Storyboard skipped: %v", requestID, err)
+			entry.Warnings = append(entry.Warnings, "storyboard skipped: "+err.Error())
+		}
+	}
+	if opts.SplitMaxBytes > 0 || opts.SplitMaxSec > 0 {
+		stem := strings.TrimSuffix(entry.Name, filepath.Ext(entry.Name))
+		zipPath, n, err := splitOutput(ctx, requestID, outPath, stem, opts)
+		if err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+		os.Remove(outPath)
+		entry.FilePath, entry.Name = zipPath, stem+".zip"
+		if st, err := os.Stat(zipPath); err == nil {
+			entry.OutputBytes = st.Size()
+		}
+		entry.Warnings = append(entry.Warnings, fmt.Sprintf("split into %d parts", n))
+	}
+	var rends []renditionInfo
+	if len(opts.Renditions) > 1 {
+		rends, err = encodeRenditions(ctx, requestID, inPath, outPath, opts)
+		if err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+		entry.Renditions = rends
+		logger.Printf("🪜 [%s] Encoded %d renditions: %s", requestID, len(rends), renditionsHeader(rends))
+		if opts.Package == nil {
+			stem := strings.TrimSuffix(entry.Name, filepath.Ext(entry.Name))
+			zipPath := withExt(outPath, ".zip")
+			err := zipRenditions(zipPath, stem, rends)
+			removeRenditions(rends)
+			if err != nil {
+				logger.Printf("❌ [%s] %v", requestID, err)
+				os.Remove(zipPath)
+				return nil, err
+			}
+			entry.FilePath, entry.Name = zipPath, stem+".zip"
+			if st, err := os.Stat(zipPath); err == nil {
+				entry.OutputBytes = st.Size()
+			}
+		}
+	}
+	packageDir := ""
+	if opts.Package != nil {
+		stem := strings.TrimSuffix(entry.Name, filepath.Ext(entry.Name))
+		zipPath, dir, n, err := packageOutput(ctx, requestID, outPath, rends, opts)
+		removeRenditions(rends)
+		if err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			os.Remove(outPath)
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		os.Remove(outPath)
+		packageDir = dir
+		entry.FilePath, entry.Name = zipPath, stem+".zip"
+		if st, err := os.Stat(zipPath); err == nil {
+			entry.OutputBytes = st.Size()
+		}
+		entry.Warnings = append(entry.Warnings, fmt.Sprintf("packaged as %s: %d segments", strings.ToUpper(opts.Package.Format), n))
+	}
+	if opts.OutputS3 != nil {
+		upload := func() error { return uploadOutput(ctx, requestID, entry, opts.OutputS3) }
+		if packageDir != "" {
+			upload = func() error { return uploadPackage(ctx, requestID, packageDir, entry, opts.OutputS3) }
+		}
+		if err := upload(); err != nil {
+			logger.Printf("❌ [%s] %v", requestID, err)
+			return nil, err
+		}
+	}
+	return entry, nil
 }
 
+
 func compressHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := randID(8)
 	logger.Printf("📥 [%s] New compression request from %s", requestID, r.RemoteAddr)
@@ -1734,46 +3750,91 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Printf("📝 [%s] Parsing multipart form data...", requestID)
-	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
-		logger.Printf("❌ [%s] Failed to parse multipart form: %v", requestID, err)
-		http.Error(w, "expecting multipart/form-data: "+err.Error(), http.StatusBadRequest)
+	if !parseMediaUpload(w, r, requestID) {
 		return
 	}
 	logger.Printf("✅ [%s] Multipart form parsed successfully", requestID)
 
-	logger.Printf("📁 [%s] Extracting uploaded file...", requestID)
-	file, hdr, err := r.FormFile("file")
+	var inPath, sourceName string
+	remote, input, inputID, err := sourceFields(r)
 	if err != nil {
-		logger.Printf("❌ [%s] File field not found: %v", requestID, err)
-		http.Error(w, "file field required", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
-	defer file.Close()
+	if remote != "" || input != "" || inputID != "" {
+		given := 0
+		for _, v := range []string{remote, input, inputID} {
+			if v != "" {
+				given++
+			}
+		}
+		if _, _, err := r.FormFile("file"); err == nil || given > 1 {
+			http.Error(w, "give one of file, url, input or input_id", http.StatusBadRequest)
+			return
+		}
+		fetch := fetchSource
+		switch {
+		case input != "":
+			fetch, remote = fetchS3Source, input
+		case inputID != "":
+			owner := clientKey(r)
+			fetch = func(_ context.Context, requestID, id string, _ int64) (string, string, error) {
+				return referencedInput(requestID, id, owner)
+			}
+			remote = inputID
+		}
+		path, name, err := fetch(r.Context(), requestID, remote, uploadLimit(r))
+		if err != nil {
+			logger.Printf("❌ [%s] Fetching source failed: %v", requestID, err)
+			status := http.StatusBadGateway
+			var fe *fetchError
+			if errors.As(err, &fe) {
+				status = fe.status
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		inPath, sourceName = path, name
+	} else {
+		logger.Printf("📁 [%s] Extracting uploaded file...", requestID)
+		file, hdr, err := r.FormFile("file")
+		if err != nil {
+			logger.Printf("❌ [%s] File field not found: %v", requestID, err)
+			http.Error(w, "file field required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
 	
-	logger.Printf("📄 [%s] File received: %s (%s)", requestID, hdr.Filename, humanBytes(hdr.Size))
+		logger.Printf("📄 [%s] File received: %s (%s)", requestID, hdr.Filename, humanBytes(hdr.Size))
 
-	// Save upload to temp file
-	logger.Printf("💾 [%s] Saving uploaded file to temp directory...", requestID)
-	inPath := filepath.Join(os.TempDir(), filepath.Base(hdr.Filename))
-	logger.Printf("📂 [%s] Temp file path: %s", requestID, inPath)
+		// Save upload to temp file
+		logger.Printf("💾 [%s] Saving uploaded file to temp directory...", requestID)
+		inPath = filepath.Join(os.TempDir(), filepath.Base(hdr.Filename))
+		logger.Printf("📂 [%s] Temp file path: %s", requestID, inPath)
 	
-	outf, err := os.Create(inPath)
-	if err != nil {
-		logger.Printf("❌ [%s] Failed to create temp file: %v", requestID, err)
-		http.Error(w, "save error: "+err.Error(), 500)
-		return
-	}
+		outf, err := os.Create(inPath)
+		if err != nil {
+			logger.Printf("❌ [%s] Failed to create temp file: %v", requestID, err)
+			http.Error(w, "save error: "+err.Error(), 500)
+			return
+		}
 	
-	logger.Printf("📥 [%s] Copying file data to temp location...", requestID)
-	if _, err := io.Copy(outf, file); err != nil {
+		logger.Printf("📥 [%s] Copying file data to temp location...", requestID)
+		if _, err := io.Copy(outf, file); err != nil {
+			outf.Close()
+			logger.Printf("❌ [%s] Failed to copy file data: %v", requestID, err)
+			http.Error(w, "save error: "+err.Error(), 500)
+			return
+		}
 		outf.Close()
-		logger.Printf("❌ [%s] Failed to copy file data: %v", requestID, err)
-		http.Error(w, "save error: "+err.Error(), 500)
-		return
+		logger.Printf("✅ [%s] File saved to temp location successfully", requestID)
+		sourceName = hdr.Filename
 	}
-	outf.Close()
-	logger.Printf("✅ [%s] File saved to temp location successfully", requestID)
+	detached := false // the upload belongs to a background job
 	defer func() {
+		if detached {
+			return
+		}
 		logger.Printf("🧹 [%s] Cleaning up temp file: %s", requestID, inPath)
 		os.Remove(inPath)
 	}()
@@ -1783,104 +3844,113 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 	opts, err := parseOpts(r)
 	if err != nil {
 		logger.Printf("❌ [%s] Failed to parse options: %v", requestID, err)
-		http.Error(w, err.Error(), 400)
+		http.Error(w, err.Error(), optsErrorStatus(err))
 		return
 	}
+	opts.SourceName = sourceName
 	logger.Printf("✅ [%s] Options parsed: speed=%s, resolution=%s, codec=%s, audio=%s, hw=%s", 
 		requestID, opts.SpeedMode, opts.Resolution, opts.Codec, opts.Audio, opts.HW)
-
-	// File size
-	logger.Printf("📊 [%s] Calculating file statistics...", requestID)
-	st, _ := os.Stat(inPath)
-	var sizeMB int64
-	var inputBytes int64
-	if st != nil {
-		inputBytes = st.Size()
-		sizeMB = st.Size() / (1024 * 1024)
-		logger.Printf("📈 [%s] File size: %s (%d bytes, %d MB)", requestID, humanBytes(inputBytes), inputBytes, sizeMB)
-	} else {
-		logger.Printf("⚠️ [%s] Could not get file stats", requestID)
+	if !acceptsOutput(r.Header.Get("Accept"), opts.OutExt) {
+		notAcceptable(w, requestID, r.Header.Get("Accept"), opts.OutExt)
+		return
 	}
 
-	// Decide final mode if AI (size-only)
-	logger.Printf("🤖 [%s] Processing speed mode decision...", requestID)
-	modeDecider := "manual"
-	if opts.SpeedMode == "ai" {
-		modeDecider = "ai"
-		base := chooseSpeedBySize(sizeMB)
-		logger.Printf("🧠 [%s] AI selected base mode: %s (for %d MB file)", requestID, base, sizeMB)
-		
-		if sizeMB >= 200 && sizeMB < 2048 {
-			if sizeMB <= 250 {
-				base = "balanced"
-				logger.Printf("⚖️ [%s] Adjusted to balanced mode for medium file", requestID)
-			} else {
-				base = "ultra_fast"
-				logger.Printf("⚡ [%s] Adjusted to ultra_fast mode for large file", requestID)
+	if posterPath, _, err := saveFormFile(r, "poster"); err == nil {
+		opts.PosterPath = posterPath
+		defer func() {
+			if !detached {
+				os.Remove(posterPath)
 			}
-		}
-		opts.SpeedMode = base
-		logger.Printf("🎯 [%s] Final AI mode: %s", requestID, opts.SpeedMode)
-	} else {
-		logger.Printf("🎛️ [%s] Using manual mode: %s", requestID, opts.SpeedMode)
+		}()
+		logger.Printf("🖼️ [%s] Poster image received", requestID)
 	}
 
-	// Small-file safety
-	logger.Printf("🛡️ [%s] Applying small-file safety checks...", requestID)
-	opts.tinyInputSafety(inputBytes)
-	logger.Printf("✅ [%s] Safety checks applied", requestID)
-
-	// Apply profile params
-	logger.Printf("⚙️ [%s] Applying speed profile parameters...", requestID)
-	opts.applySpeedMode()
-	logger.Printf("✅ [%s] Profile applied: CRF=%d, Preset=%s, AB=%s", requestID, opts.CRF, opts.Preset, opts.AB)
-
-	outPath := withExt(inPath, "_compressed"+opts.OutExt)
-	logger.Printf("🎬 [%s] Output path: %s", requestID, outPath)
-
-	// --- timing starts here ---
-	logger.Printf("⏱️ [%s] Starting compression process...", requestID)
-	start := time.Now()
-
-	// Run ffmpeg synchronously (no timeouts)
-	logger.Printf("🔧 [%s] Executing FFmpeg compression...", requestID)
-	ctx := r.Context()
-	if err := runFFmpeg(ctx, inPath, outPath, opts, io.Discard); err != nil {
-		logger.Printf("❌ [%s] FFmpeg compression failed: %v", requestID, err)
-		http.Error(w, "compression failed: "+err.Error(), 500)
+	if opts.CallbackURL != "" && r.FormValue("async") != "1" && r.FormValue("detach_on_disconnect") != "1" {
+		http.Error(w, "callback_url needs async=1 or detach_on_disconnect=1", http.StatusBadRequest)
 		return
 	}
 
-	elapsed := time.Since(start)
-	elapsedMs := elapsed.Milliseconds()
-	logger.Printf("✅ [%s] Compression completed in %d ms", requestID, elapsedMs)
+	// ASYNC MODE: answer 202 with a job ID and encode in the background
+	if r.FormValue("async") == "1" {
+		// own copy: the upload name is shared by every upload of that file name
+		jobPath := filepath.Join(os.TempDir(), requestID+"_"+filepath.Base(inPath))
+		if err := os.Rename(inPath, jobPath); err != nil {
+			http.Error(w, "save error: "+err.Error(), 500)
+			return
+		}
+		detached = true
+		j := newJob(opts.Client)
+		j.setRequest(r, rerunParams(r), sourceName)
+		j.setCallback(opts.CallbackURL, requestBaseURL(r))
+		logger.Printf("📨 [%s] ASYNC MODE: queued as job %s", requestID, j.ID)
+		runBackground(j, requestID, jobPath, opts, keepSlot(r))
+		w.Header().Set("Location", "/jobs/"+j.ID)
+		snap, _ := j.snapshot()
+		v := snap.view()
+		v["status_url"] = "/jobs/" + j.ID
+		v["wait_url"] = "/jobs/" + j.ID + "/wait"
+		writeJSON(w, http.StatusAccepted, v)
+		return
+	}
 
-	// validate output
-	logger.Printf("🔍 [%s] Validating compressed output...", requestID)
-	stat, err := os.Stat(outPath)
-	if err != nil || stat.Size() < 1024 {
-		logger.Printf("❌ [%s] Output validation failed: %v, size: %d", requestID, err, stat.Size())
-		http.Error(w, "output seems empty or invalid", 500)
+	// the encode runs within the request from here on
+	if !holdSlot(w, r) {
 		return
 	}
-	outputBytes := stat.Size()
-	logger.Printf("✅ [%s] Output validated: %s (%d bytes)", requestID, humanBytes(outputBytes), outputBytes)
 
-	// throughput (MB/s) = input size / seconds
-	logger.Printf("📊 [%s] Calculating compression statistics...", requestID)
-	throughput := 0.0
-	sec := elapsed.Seconds()
-	if sec > 0 {
-		throughput = (float64(inputBytes) / (1024 * 1024)) / sec
+	// DETACH MODE: the encode outlives the connection. The job ID goes out
+	// in a 103 Early Hints response so a client that drops can still find
+	// the result through /jobs/{id}.
+	ctx := r.Context()
+	var j *job
+	if r.FormValue("detach_on_disconnect") == "1" {
+		j = newJob(opts.Client)
+		j.setRequest(r, rerunParams(r), sourceName)
+		j.setCallback(opts.CallbackURL, requestBaseURL(r))
+		j.start()
+		ctx = j.track(context.WithoutCancel(ctx))
+		w.Header().Set("X-Job-Id", j.ID)
+		w.WriteHeader(http.StatusEarlyHints)
+		logger.Printf("🪢 [%s] Detached from the connection as job %s", requestID, j.ID)
+	}
+
+	// Run the pipeline synchronously (no timeouts)
+	entry, err := compressFile(ctx, requestID, inPath, opts)
+	if j != nil {
+		id := ""
+		if err == nil {
+			id = storeResult(requestID, entry)
+		}
+		j.finish(id, entry, err)
+		if r.Context().Err() != nil {
+			logger.Printf("🪢 [%s] Client disconnected; job %s kept its result", requestID, j.ID)
+			return
+		}
+	}
+	if err != nil {
+		var ce *compatError
+		if errors.As(err, &ce) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": ce.Error(), "conflicts": ce.Conflicts})
+			return
+		}
+		var ve *verifyError
+		if errors.As(err, &ve) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "output verification failed", "problems": ve.Problems})
+			return
+		}
+		var se *sizeLimitError
+		if errors.As(err, &se) {
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": se.Error(), "code": "output_too_large",
+				"limit": se.Limit, "bytes": se.Bytes})
+			return
+		}
+		http.Error(w, err.Error(), 500)
+		return
 	}
-	
-	compressionRatio := float64(outputBytes) / float64(inputBytes) * 100
-	logger.Printf("📈 [%s] Compression stats: %.2f MB/s throughput, %.1f%% size reduction", 
-		requestID, throughput, 100-compressionRatio)
 
 	// API MODE: Return compressed file bytes directly
 	// To get file bytes instead of UI, use either:
-	// 1. Set header: Accept: application/octet-stream
+	// 1. Set header: Accept: application/octet-stream (or a video type such as video/webm)
 	// 2. Add parameter: api=1
 	accept := r.Header.Get("Accept")
 	apiParam := r.FormValue("api")
@@ -1889,33 +3959,22 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("📋 [%s] Accept header: %s", requestID, accept)
 	logger.Printf("🔧 [%s] API parameter: %s", requestID, apiParam)
 	
-	if strings.Contains(accept, "application/octet-stream") || apiParam == "1" {
+	if strings.Contains(accept, "application/octet-stream") || acceptedContainer(accept) != "" || apiParam == "1" {
+		if entry.OutputURL != "" {
+			// the bytes are in the bucket: answer with where they went
+			logger.Printf("☁️ [%s] API MODE: Output is in object storage, returning metadata", requestID)
+			id := storeResult(requestID, entry)
+			w.Header().Set("X-Result-Id", id)
+			w.Header().Set("X-Output-Url", entry.OutputURL)
+			if entry.InputID != "" {
+				w.Header().Set("X-Input-Id", entry.InputID)
+			}
+			writeJSON(w, http.StatusOK, resultMeta(id, entry))
+			return
+		}
 		logger.Printf("📤 [%s] API MODE: Returning compressed file directly", requestID)
 		
-		// add metadata headers
-		w.Header().Set("X-Mode", opts.SpeedMode)
-		w.Header().Set("X-Mode-Decider", modeDecider)
-		w.Header().Set("X-Encode-Duration-Ms", fmt.Sprintf("%d", elapsedMs))
-		w.Header().Set("X-Throughput-MBps", fmt.Sprintf("%.4f", throughput))
-		w.Header().Set("X-Input-Bytes", fmt.Sprintf("%d", inputBytes))
-		w.Header().Set("X-Output-Bytes", fmt.Sprintf("%d", outputBytes))
-		w.Header().Set("X-Resolution", opts.Resolution)
-		w.Header().Set("X-Video-Codec", opts.Codec)
-		w.Header().Set("X-Audio-Codec", opts.Audio)
-		w.Header().Set("X-HW", opts.HW)
-
-		ctype := "application/octet-stream"
-		switch strings.ToLower(filepath.Ext(outPath)) {
-		case ".mp4":
-			ctype = "video/mp4"
-		case ".mov":
-			ctype = "video/quicktime"
-		}
-		w.Header().Set("Content-Type", ctype)
-		w.Header().Set("Content-Disposition", "attachment; filename=\""+filepath.Base(outPath)+"\"")
-		
-		logger.Printf("📤 [%s] Serving compressed file: %s (%s)", requestID, filepath.Base(outPath), ctype)
-		http.ServeFile(w, r, outPath)
+		serveResultFile(w, r, requestID, entry)
 		logger.Printf("✅ [%s] API response completed successfully", requestID)
 		return
 	}
@@ -1923,26 +3982,7 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 	// UI MODE: Show result page with download links
 	logger.Printf("🌐 [%s] UI MODE: Preparing result page with download links", requestID)
 	
-	id := randID(12)
-	entry := &resultEntry{
-		FilePath:    outPath,
-		ModeFinal:   opts.SpeedMode,
-		ModeDecider: modeDecider,
-		InputBytes:  inputBytes,
-		OutputBytes: outputBytes,
-		Resolution:  opts.Resolution,
-		Codec:       opts.Codec,
-		Audio:       opts.Audio,
-		HW:          opts.HW,
-		ElapsedMs:   elapsedMs,
-		Throughput:  throughput,
-	}
-	
-	logger.Printf("💾 [%s] Storing result entry with ID: %s", requestID, id)
-	storeMu.Lock()
-	store[id] = entry
-	storeMu.Unlock()
-	logger.Printf("✅ [%s] Result stored successfully", requestID)
+	id := storeResult(requestID, entry)
 
 	// Render result HTML
 	logger.Printf("🎨 [%s] Rendering result HTML page...", requestID)
@@ -1959,53 +3999,116 @@ func compressHandler(w http.ResponseWriter, r *http.Request) {
 		"Codec":       entry.Codec,
 		"Audio":       entry.Audio,
 		"HW":          entry.HW,
-		"SuggestName": filepath.Base(outPath),
+		"SuggestName": entry.downloadName(),
+		"Poster":      posterable(entry) && posterPlayable[strings.ToLower(filepath.Ext(entry.FilePath))],
 		"Seconds":     float64(entry.ElapsedMs) / 1000.0,
 		"Throughput":  entry.Throughput,
+		"Site":        site,
+		"L":           uiText{Lang: requestLang(r)},
 	}
 	_ = resultTpl.Execute(w, data)
 	logger.Printf("✅ [%s] UI response completed successfully", requestID)
 }
 
+// serveResultFile stores the entry and streams its file with the metadata
+// headers of API mode.
+func serveResultFile(w http.ResponseWriter, r *http.Request, requestID string, entry *resultEntry) {
+	w.Header().Set("X-Mode", entry.ModeFinal)
+	w.Header().Set("X-Mode-Decider", entry.ModeDecider)
+	w.Header().Set("X-Encode-Duration-Ms", fmt.Sprintf("%d", entry.ElapsedMs))
+	w.Header().Set("X-Throughput-MBps", fmt.Sprintf("%.4f", entry.Throughput))
+	w.Header().Set("X-Input-Bytes", fmt.Sprintf("%d", entry.InputBytes))
+	w.Header().Set("X-Output-Bytes", fmt.Sprintf("%d", entry.OutputBytes))
+	w.Header().Set("X-Resolution", entry.Resolution)
+	w.Header().Set("X-Video-Codec", entry.Codec)
+	w.Header().Set("X-Audio-Codec", entry.Audio)
+	w.Header().Set("X-HW", entry.HW)
+	resultID := storeResult(requestID, entry)
+	w.Header().Set("X-Result-Id", resultID)
+	if len(entry.Artifacts) > 0 {
+		w.Header().Set("X-Artifacts", strings.Join(artifactNames(entry), ","))
+	}
+	if len(entry.Warnings) > 0 {
+		w.Header().Set("X-Warnings", strings.Join(entry.Warnings, "; "))
+	}
+	if len(entry.Renditions) > 0 {
+		w.Header().Set("X-Renditions", renditionsHeader(entry.Renditions))
+		if _, ok := getManifest(resultID); ok {
+			w.Header().Set("X-Manifest-Url", "/manifests/"+resultID)
+		}
+	}
+	if entry.Client.Tag != "" {
+		w.Header().Set("X-Job-Tag", entry.Client.Tag)
+	}
+	if entry.InputID != "" {
+		w.Header().Set("X-Input-Id", entry.InputID)
+	}
+
+	ctype := contentTypeFor(entry.FilePath)
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+entry.downloadName()+"\"")
+
+	logger.Printf("📤 [%s] Serving result file: %s (%s)", requestID, filepath.Base(entry.FilePath), ctype)
+	setValidators(w, entry, "", entry.FilePath)
+	http.ServeFile(throttle(w, r), r, entry.FilePath)
+}
+
 func dlHandler(w http.ResponseWriter, r *http.Request) {
 	requestID := randID(6)
 	logger.Printf("📥 [%s] Download request from %s", requestID, r.RemoteAddr)
 	
-	id := strings.TrimPrefix(r.URL.Path, "/dl/")
+	id, artifact, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/dl/"), "/")
 	logger.Printf("🔍 [%s] Looking for file ID: %s", requestID, id)
 	
-	storeMu.Lock()
-	e, ok := store[id]
-	storeMu.Unlock()
+	e, ok := getResult(id)
 	if !ok {
 		logger.Printf("❌ [%s] File ID not found: %s", requestID, id)
 		http.NotFound(w, r)
 		return
 	}
 	
-	logger.Printf("✅ [%s] File found: %s", requestID, e.FilePath)
+	filePath := e.FilePath
+	if artifact != "" {
+		p, ok := e.Artifacts[artifact]
+		if !ok {
+			logger.Printf("❌ [%s] Artifact not found: %s/%s", requestID, id, artifact)
+			http.NotFound(w, r)
+			return
+		}
+		filePath = p
+	}
+	logger.Printf("✅ [%s] File found: %s", requestID, filePath)
 	
-	name := r.URL.Query().Get("name")
+	name := sanitizeFilename(r.URL.Query().Get("name"))
 	if name == "" {
-		name = filepath.Base(e.FilePath)
+		name = e.downloadName()
+		if artifact != "" {
+			name = e.artifactDownloadName(artifact)
+		}
 		logger.Printf("📄 [%s] Using default filename: %s", requestID, name)
 	} else {
 		logger.Printf("📄 [%s] Using custom filename: %s", requestID, name)
 	}
 	
-	ctype := "application/octet-stream"
-	switch strings.ToLower(filepath.Ext(name)) {
-	case ".mp4":
-		ctype = "video/mp4"
-	case ".mov":
-		ctype = "video/quicktime"
-	}
+	ctype := contentTypeFor(name)
 	
 	w.Header().Set("Content-Type", ctype)
 	w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
 	
+	if bucket, key, ok := sharedObject(e, artifact); ok {
+		if dlRedirects() {
+			redirectShared(w, r, requestID, bucket, key, name, ctype)
+			return
+		}
+		if _, err := os.Stat(filePath); err != nil {
+			logger.Printf("📤 [%s] Serving shared file: %s (%s)", requestID, name, ctype)
+			serveShared(w, r, requestID, bucket, key)
+			return
+		}
+	}
 	logger.Printf("📤 [%s] Serving file: %s (%s)", requestID, name, ctype)
-	http.ServeFile(w, r, e.FilePath)
+	setValidators(w, e, artifact, filePath)
+	http.ServeFile(throttle(w, r), r, filePath)
 	logger.Printf("✅ [%s] Download completed successfully", requestID)
 }
 
@@ -2016,9 +4119,7 @@ func metaHandler(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/meta/")
 	logger.Printf("🔍 [%s] Looking for metadata for ID: %s", requestID, id)
 	
-	storeMu.Lock()
-	e, ok := store[id]
-	storeMu.Unlock()
+	e, ok := getResult(id)
 	if !ok {
 		logger.Printf("❌ [%s] File ID not found for metadata: %s", requestID, id)
 		http.NotFound(w, r)
@@ -2028,6 +4129,12 @@ func metaHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Printf("✅ [%s] Metadata found for file: %s", requestID, e.FilePath)
 	
 	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resultMeta(id, e))
+	logger.Printf("✅ [%s] Metadata response sent successfully", requestID)
+}
+
+// resultMeta is the /meta/{id} view of a result.
+func resultMeta(id string, e *resultEntry) map[string]any {
 	metadata := map[string]any{
 		"id":                 id,
 		"mode":               e.ModeFinal,
@@ -2040,9 +4147,41 @@ func metaHandler(w http.ResponseWriter, r *http.Request) {
 		"hw":                 e.HW,
 		"encode_duration_ms": e.ElapsedMs,
 		"throughput_mb_s":    e.Throughput,
+		"artifacts":          artifactNames(e),
+		"warnings":           e.Warnings,
 	}
-	_ = json.NewEncoder(w).Encode(metadata)
-	logger.Printf("✅ [%s] Metadata response sent successfully", requestID)
+	if e.Usage != nil {
+		metadata["usage"] = e.Usage
+	}
+	e.Client.addTo(metadata)
+	if len(e.Deliveries) > 0 {
+		metadata["deliveries"] = deliveriesView(e)
+	}
+	if len(e.Race) > 0 {
+		metadata["race"] = e.Race
+	}
+	if len(e.Pipeline) > 0 {
+		metadata["pipeline"] = e.Pipeline
+	}
+	if e.OutputURL != "" {
+		metadata["output_url"] = e.OutputURL
+	}
+	if len(e.Renditions) > 0 {
+		metadata["renditions"] = e.Renditions
+		if _, ok := getManifest(id); ok {
+			metadata["manifest_url"] = "/manifests/" + id
+		}
+	}
+	if len(e.Thumbnails) > 0 {
+		metadata["thumbnails"] = thumbnailsView(id, e)
+	}
+	if e.Storyboard != nil {
+		metadata["storyboard"] = storyboardView(id, e)
+	}
+	if e.InputID != "" {
+		metadata["input_id"] = e.InputID
+	}
+	return metadata
 }
 
 func health(w http.ResponseWriter, r *http.Request) {
@@ -2054,9 +4193,14 @@ func health(w http.ResponseWriter, r *http.Request) {
 		"ok":        true,
 		"service":   "videocompress",
 		"version":   "3.2.0-orientation",
-		"modes":     []string{"ai", "turbo", "max", "ultra_fast", "super_fast", "fast", "balanced", "quality"},
+		"modes":     speedModes,
+		"profiles":  profileNames(),
+		"mode_decider": activeModeDecider(),
+		"ai_size_rules": currentConfig().AISizeRules,
+		"ai_history":    summarizeHistory(),
+		"ffmpeg":        currentFFmpeg(),
 		"defaults":  map[string]any{"codec": "h264", "resolution": "original", "hw": "none"},
-		"ui_routes": []string{"/", "/compress (POST)", "/dl/{id}", "/meta/{id}"},
+		"ui_routes": []string{"/", "/compress (POST)", "/repair (POST)", "/slideshow (POST)", "/compress-image (POST)", "/measure-loudness (POST)", "/probe (POST)", "/frames (POST)", "/thumbnail (POST)", "/poster/{id} (POST)", "/clip (POST)", "/analyze-ladder (POST)", "/pipeline (POST)", "/jobspec (POST)", "/live (POST)", "/live/{id}", "/dl/{id}", "/meta/{id}", "/jobs/{id}", "/jobs/{id}/wait", "/jobs/{id}/rerun (POST)", "/inputs", "/inputs/{id}", "/progress/{id}", "/events/{id}", "/manifests/{id}", "/healthz", "/readyz", "/queue", "/metrics", "/upload-tokens (POST)"},
 	}
 	_ = json.NewEncoder(w).Encode(healthData)
 	logger.Printf("✅ [%s] Health check response sent", requestID)
@@ -2066,12 +4210,72 @@ func main() {
 	addr := envOr("PORT", "8080")
 	logger.Printf("🌐 [MAIN] Starting VideoCompress server on port %s", addr)
 
+	if err := loadConfig(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkFFmpeg(); err != nil {
+		log.Fatal(err)
+	}
+	if err := initOutputDir(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkResultStore(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadHistory(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadProfiles(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadBranding(); err != nil {
+		log.Fatal(err)
+	}
+	if err := loadLocales(); err != nil {
+		log.Fatal(err)
+	}
+	startScheduler()
+	reloadOnSignal()
+	logger.Printf("🗓️ [MAIN] Scheduler started with %d tasks", len(currentConfig().Tasks))
+	resumeCheckpoints()
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", uploadPage)
-	mux.HandleFunc("/compress", compressHandler)
-	mux.HandleFunc("/dl/", dlHandler)     // GET /dl/{id}?name=...
-	mux.HandleFunc("/meta/", metaHandler) // GET /meta/{id}
+	mux.HandleFunc("/compress", limitClientAsync(compressHandler))
+	mux.HandleFunc("/dl/", requireKey(dlHandler))     // GET /dl/{id}?name=...
+	mux.HandleFunc("/meta/", requireKey(metaHandler)) // GET /meta/{id}
+	mux.HandleFunc("/progress/", requireKey(progressHandler)) // GET /progress/{id}
+	mux.HandleFunc("/events/", requireKey(eventsHandler))     // GET /events/{id} (SSE)
+	mux.HandleFunc("/manifests/key", manifestsHandler)          // GET /manifests/key (public)
+	mux.HandleFunc("/manifests/", requireKey(manifestsHandler)) // GET /manifests/{id}
+	mux.HandleFunc("/jobs/", limitClientAsync(requireKey(jobsHandler))) // GET /jobs/{id}, /jobs/{id}/wait?timeout=60s, POST /jobs/{id}/rerun
+	mux.HandleFunc("/inputs", inputsHandler)  // GET /inputs
+	mux.HandleFunc("/inputs/", inputsHandler) // GET/DELETE /inputs/{id}
+	mux.HandleFunc("/repair", limitClient(repairHandler)) // POST /repair
+	mux.HandleFunc("/slideshow", limitClient(slideshowHandler)) // POST /slideshow
+	mux.HandleFunc("/compress-image", limitClient(compressImageHandler)) // POST /compress-image
+	mux.HandleFunc("/measure-loudness", limitClient(measureLoudnessHandler)) // POST /measure-loudness
+	mux.HandleFunc("/probe", limitClient(probeHandler))                      // POST /probe
+	mux.HandleFunc("/frames", limitClient(framesHandler))                    // POST /frames
+	mux.HandleFunc("/thumbnail", limitClient(thumbnailHandler))              // POST /thumbnail
+	mux.HandleFunc("/poster/", limitClient(posterHandler))                   // POST /poster/{id}
+	mux.HandleFunc("/clip", limitClient(clipHandler))                        // POST /clip
+	mux.HandleFunc("/analyze-ladder", limitClient(analyzeLadderHandler))     // POST /analyze-ladder
+	mux.HandleFunc("/pipeline", limitClient(pipelineHandler))                // POST /pipeline
+	mux.HandleFunc("/jobspec", limitClient(jobSpecHandler))                  // POST /jobspec
+	mux.HandleFunc("/live", limitClient(requireKey(liveHandler))) // POST/GET /live
+	mux.HandleFunc("/live/", liveHandler)                    // GET/DELETE /live/{id}
+	mux.HandleFunc("/admin/tasks", adminTasksHandler)  // GET /admin/tasks
+	mux.HandleFunc("/admin/tasks/", adminTasksHandler) // POST /admin/tasks/{name}/run
+	mux.HandleFunc("/admin/profiles", adminProfilesHandler) // GET export, PUT/POST import
+	mux.HandleFunc("/admin/reload", adminReloadHandler)     // POST reload CONFIG_FILE + PROFILES_FILE
+	mux.Handle("/brand/", brandHandler()) // BRANDING_DIR/static
 	mux.HandleFunc("/health", health)
+	mux.HandleFunc("/healthz", healthzHandler) // liveness
+	mux.HandleFunc("/readyz", readyzHandler)   // readiness (503 while draining)
+	mux.HandleFunc("/queue", queueHandler)     // GET queue depth for autoscalers
+	mux.HandleFunc("/metrics", metricsHandler) // GET Prometheus metrics
+	mux.HandleFunc("/upload-tokens", uploadTokensHandler) // POST one-time upload tokens
 	mux.HandleFunc("/api-docs", func(w http.ResponseWriter, r *http.Request) {
 		requestID := randID(6)
 		logger.Printf("📚 [%s] API docs request from %s", requestID, r.RemoteAddr)
@@ -2084,16 +4288,34 @@ func main() {
 		Addr:    ":" + addr,
 		Handler: logMiddleware(mux),
 	}
+	stopHTTP3, err := configureListeners(s, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var stopGRPC func(context.Context) error
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort != "" {
+		if stopGRPC, err = startGRPC(grpcPort); err != nil {
+			log.Fatal(err)
+		}
+	}
+	drained := drainOnSignal(s, stopHTTP3, stopGRPC)
 
-	logger.Printf("🚀 [MAIN] VideoCompress server listening on http://localhost:%s", addr)
-	logger.Printf("📖 [MAIN] API Documentation: http://localhost:%s/api-docs", addr)
-	logger.Printf("🌐 [MAIN] Web Interface: http://localhost:%s", addr)
-	logger.Printf("🏥 [MAIN] Health Check: http://localhost:%s/health", addr)
+	scheme := listenScheme()
+	logger.Printf("🚀 [MAIN] VideoCompress server listening on %s://localhost:%s", scheme, addr)
+	logger.Printf("📖 [MAIN] API Documentation: %s://localhost:%s/api-docs", scheme, addr)
+	logger.Printf("🌐 [MAIN] Web Interface: %s://localhost:%s", scheme, addr)
+	logger.Printf("🏥 [MAIN] Health Check: %s://localhost:%s/health", scheme, addr)
+
+	if grpcPort != "" {
+		logger.Printf("📡 [MAIN] gRPC API listening on :%s", grpcPort)
+	}
 	
-	if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if err := listenAndServe(s); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Printf("💥 [MAIN] Server error: %v", err)
 		log.Fatal(err)
 	}
+	<-drained
 }
 
 // enhanced request logger with timing and status
@@ -2122,3 +4344,9 @@ func (w *statusResponseWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer (flushes
+// and deadlines on HTTP/2 streams).
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Signed result manifests (GET /manifests/{id}, GET /manifests/key)
+// ======================
+
+// A batch job (POST /jobspec) answers with a manifest of every file it
+// produced (URL, size, SHA-256, codecs), signed with Ed25519 so downstream
+// systems can check they ingested the complete, untampered set. A result
+// with renditions gets one under its result ID, listing each rendition in
+// the ZIP as well, and POST /analyze-ladder signs the ladder it recommends.
+// The signature covers the manifest bytes exactly as GET /manifests/{id}
+// serves them; the public key is at GET /manifests/key.
+//
+// MANIFEST_SIGNING_KEY is the base64 Ed25519 seed (32 bytes) or private key
+// (64 bytes). Without it a key is generated per process, so signatures only
+// verify against this instance's key until it restarts. With OUTPUT_DIR the
+// manifests are kept in OUTPUT_DIR/.manifests.
+
+type manifestFile struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Bytes       int64  `json:"bytes"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+	VideoCodec  string `json:"video_codec,omitempty"`
+	AudioCodec  string `json:"audio_codec,omitempty"`
+	Resolution  string `json:"resolution,omitempty"`
+	ResultID    string `json:"result_id"`
+}
+
+type resultManifest struct {
+	ID      string          `json:"id"`
+	Created string          `json:"created_at"`
+	KeyID   string          `json:"key_id"`
+	Files   []manifestFile  `json:"files,omitempty"`
+	Failed  []string        `json:"failed,omitempty"` // outputs that produced nothing
+	Ladder  *ladderAnalysis `json:"ladder_analysis,omitempty"`
+}
+
+type signedManifest struct {
+	Body      json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"` // base64 Ed25519 over Body
+}
+
+var (
+	manifestKeyOnce sync.Once
+	manifestKey     ed25519.PrivateKey
+	manifestKeyErr  error
+)
+
+// manifestSigningKey loads MANIFEST_SIGNING_KEY, or makes a per-process key.
+func manifestSigningKey() (ed25519.PrivateKey, error) {
+	manifestKeyOnce.Do(func() {
+		s := os.Getenv("MANIFEST_SIGNING_KEY")
+		if s == "" {
+			_, manifestKey, manifestKeyErr = ed25519.GenerateKey(rand.Reader)
+			logger.Printf("⚠️ [MAIN] MANIFEST_SIGNING_KEY not set; manifests are signed with a per-process key")
+			return
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		switch {
+		case err != nil:
+			manifestKeyErr = errors.New("MANIFEST_SIGNING_KEY is not base64")
+		case len(raw) == ed25519.SeedSize:
+			manifestKey = ed25519.NewKeyFromSeed(raw)
+		case len(raw) == ed25519.PrivateKeySize:
+			manifestKey = ed25519.PrivateKey(raw)
+		default:
+			manifestKeyErr = errors.New("MANIFEST_SIGNING_KEY must be a 32-byte seed or 64-byte private key")
+		}
+	})
+	return manifestKey, manifestKeyErr
+}
+
+// manifestKeyID names the public key: the first 8 bytes of its SHA-256.
+func manifestKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// manifestFiles lists the output and artifacts of a stored result.
+func manifestFiles(name, id string, e *resultEntry) []manifestFile {
+	d := e.Digests[""]
+	files := []manifestFile{{
+		Name: name, URL: "/dl/" + id, Bytes: d.Bytes, SHA256: d.SHA256,
+		ContentType: contentTypeFor(e.FilePath),
+		VideoCodec:  e.Codec, AudioCodec: e.Audio, Resolution: e.Resolution,
+		ResultID: id,
+	}}
+	for _, a := range artifactNames(e) {
+		d := e.Digests[a]
+		files = append(files, manifestFile{
+			Name: name + "/" + a, URL: "/dl/" + id + "/" + a, Bytes: d.Bytes, SHA256: d.SHA256,
+			ContentType: contentTypeFor(a), ResultID: id,
+		})
+	}
+	return files
+}
+
+// renditionFiles lists the renditions zipped into the output of a result
+// (packaged renditions are only in the playlists).
+func renditionFiles(name, id string, e *resultEntry) []manifestFile {
+	var files []manifestFile
+	for _, r := range e.Renditions {
+		if r.File == "" {
+			continue
+		}
+		files = append(files, manifestFile{
+			Name: name + "/" + r.File, URL: "/dl/" + id, Bytes: r.Bytes, SHA256: r.SHA256,
+			ContentType: contentTypeFor(r.File),
+			VideoCodec:  e.Codec, AudioCodec: e.Audio, Resolution: fmt.Sprintf("%dx%d", r.Width, r.Height),
+			ResultID: id,
+		})
+	}
+	return files
+}
+
+// signResultManifest signs the manifest of a stored result with renditions
+// under the result's ID.
+func signResultManifest(requestID, id string, e *resultEntry) {
+	m := &resultManifest{ID: id, Files: manifestFiles(e.Name, id, e)}
+	m.Files = append(m.Files, renditionFiles(e.Name, id, e)...)
+	if _, err := signManifest(m); err != nil {
+		logger.Printf("⚠️ [%s] Could not sign manifest: %v", requestID, err)
+	}
+}
+
+var manifests = struct {
+	sync.Mutex
+	m map[string]*signedManifest
+}{m: map[string]*signedManifest{}}
+
+func manifestDir() string {
+	return filepath.Join(outputDir(), ".manifests")
+}
+
+// signManifest signs and stores m.
+func signManifest(m *resultManifest) (*signedManifest, error) {
+	key, err := manifestSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	m.KeyID = manifestKeyID(key.Public().(ed25519.PublicKey))
+	m.Created = time.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	sm := &signedManifest{Body: body, Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, body))}
+	manifests.Lock()
+	manifests.m[m.ID] = sm
+	manifests.Unlock()
+	if persistentOutputs() {
+		data, _ := json.Marshal(sm)
+		if err := os.MkdirAll(manifestDir(), 0o755); err == nil {
+			err = os.WriteFile(filepath.Join(manifestDir(), m.ID+".json"), data, 0o644)
+		}
+		if err != nil {
+			logger.Printf("⚠️ [RESULTS] Could not persist manifest %s: %v", m.ID, err)
+		}
+	}
+	return sm, nil
+}
+
+func getManifest(id string) (*signedManifest, bool) {
+	manifests.Lock()
+	sm, ok := manifests.m[id]
+	manifests.Unlock()
+	if ok || !persistentOutputs() || id != filepath.Base(id) {
+		return sm, ok
+	}
+	data, err := os.ReadFile(filepath.Join(manifestDir(), id+".json"))
+	if err != nil {
+		return nil, false
+	}
+	sm = &signedManifest{}
+	if json.Unmarshal(data, sm) != nil {
+		return nil, false
+	}
+	return sm, true
+}
+
+// manifestsHandler serves GET /manifests/{id} (the signed bytes, signature
+// in X-Manifest-Signature) and GET /manifests/key.
+func manifestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/manifests"), "/")
+	if id == "key" {
+		key, err := manifestSigningKey()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		pub := key.Public().(ed25519.PublicKey)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"alg":        "ed25519",
+			"key_id":     manifestKeyID(pub),
+			"public_key": base64.StdEncoding.EncodeToString(pub),
+		})
+		return
+	}
+	sm, ok := getManifest(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Manifest-Signature", sm.Signature)
+	w.Write(sm.Body)
+}
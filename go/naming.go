@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ======================
+// Output filename templates (output_name)
+// ======================
+
+// defaultOutputName is used when neither output_name nor
+// OUTPUT_NAME_TEMPLATE is set ("holiday.mov" → "holiday_fast.mp4").
+const defaultOutputName = "{basename}_{mode}"
+
+const maxOutputName = 200
+
+var (
+	nameTokenRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+	nameTokens  = map[string]bool{"basename": true, "mode": true, "resolution": true, "codec": true, "date": true}
+)
+
+func validateNameTemplate(t string) error {
+	if len(t) > maxOutputName {
+		return fmt.Errorf("output_name exceeds %d characters", maxOutputName)
+	}
+	for _, m := range nameTokenRe.FindAllStringSubmatch(t, -1) {
+		if !nameTokens[m[1]] {
+			return fmt.Errorf("unknown output_name token {%s} (basename, mode, resolution, codec, date)", m[1])
+		}
+	}
+	return nil
+}
+
+// outputNameTemplate picks the request template, then OUTPUT_NAME_TEMPLATE,
+// then the built-in default.
+func outputNameTemplate(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	return envOr("OUTPUT_NAME_TEMPLATE", defaultOutputName)
+}
+
+// renderOutputName expands the template and appends ext (unless the
+// template already ends with it). The result is safe for Content-Disposition.
+func renderOutputName(tmpl, source, mode, resolution, codec, ext string) string {
+	base := filepath.Base(source)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	vars := map[string]string{
+		"basename":   base,
+		"mode":       mode,
+		"resolution": resolution,
+		"codec":      codec,
+		"date":       time.Now().Format("2006-01-02"),
+	}
+	name := nameTokenRe.ReplaceAllStringFunc(tmpl, func(tok string) string {
+		return vars[tok[1:len(tok)-1]]
+	})
+	name = sanitizeFilename(strings.TrimSuffix(name, ext))
+	if name == "" {
+		name = "output"
+	}
+	return name + ext
+}
+
+// sanitizeFilename drops path separators, quotes and control characters.
+func sanitizeFilename(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\"<>:|?*`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	return strings.Trim(s, " .")
+}
+
+// formOutputName returns the validated output_name template of a form request.
+func formOutputName(r *http.Request) (string, error) {
+	t := r.FormValue("output_name")
+	return t, validateNameTemplate(t)
+}
+
+// downloadName is the Content-Disposition name of the result.
+func (e *resultEntry) downloadName() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return filepath.Base(e.FilePath)
+}
+
+// artifactDownloadName prefixes an artifact with the result's name stem,
+// e.g. "holiday_fast.chapters.json". Artifacts named without an extension
+// ("preview") take the stored file's: "holiday_fast.preview.gif".
+func (e *resultEntry) artifactDownloadName(artifact string) string {
+	name := e.downloadName()
+	if filepath.Ext(artifact) == "" {
+		artifact += filepath.Ext(e.Artifacts[artifact])
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "." + artifact
+}
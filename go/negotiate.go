@@ -0,0 +1,126 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Content negotiation (Accept → container)
+// ======================
+
+// A client that sends Accept: video/webm (or video/mp4, video/quicktime, ...)
+// and no outExt gets that container, and the file bytes instead of the
+// result page. When the Accept header rules out everything /compress could
+// answer with, the request fails with 406 and the list of supported types.
+
+type acceptRange struct {
+	Type, Sub string
+	Q         float64
+}
+
+func parseAccept(header string) []acceptRange {
+	var out []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, sub, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+				q = f
+			}
+		}
+		out = append(out, acceptRange{Type: typ, Sub: sub, Q: q})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Q > out[j].Q })
+	return out
+}
+
+// acceptQ is the quality the client gives media type mt: that of the most
+// specific matching range, or 0 when none matches.
+func acceptQ(ranges []acceptRange, mt string) float64 {
+	typ, sub, _ := strings.Cut(mt, "/")
+	best, q := -1, 0.0
+	for _, r := range ranges {
+		spec := -1
+		switch {
+		case r.Type == typ && r.Sub == sub:
+			spec = 2
+		case r.Type == typ && r.Sub == "*":
+			spec = 1
+		case r.Type == "*" && r.Sub == "*":
+			spec = 0
+		}
+		if spec > best {
+			best, q = spec, r.Q
+		}
+	}
+	return q
+}
+
+// acceptedContainer returns the output extension of the container the
+// Accept header names explicitly with the highest quality, or "".
+func acceptedContainer(header string) string {
+	ranges := parseAccept(header)
+	for _, r := range ranges {
+		if r.Q == 0 || r.Sub == "*" {
+			continue
+		}
+		if ext := containerForMime(r.Type + "/" + r.Sub); ext != "" && acceptQ(ranges, r.Type+"/"+r.Sub) > 0 {
+			return ext
+		}
+	}
+	return ""
+}
+
+func containerForMime(mt string) string {
+	for ext, c := range outputContainers {
+		if c.MimeType == mt {
+			return ext
+		}
+	}
+	return ""
+}
+
+// acceptsOutput reports whether a /compress answer with container ext is
+// acceptable: the video itself, the raw bytes, or the HTML result page.
+func acceptsOutput(header, ext string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+	ranges := parseAccept(header)
+	for _, mt := range []string{outputContainers[ext].MimeType, "application/octet-stream", "text/html"} {
+		if acceptQ(ranges, mt) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func supportedMimeTypes() []string {
+	out := make([]string, 0, len(outputContainers))
+	for _, c := range outputContainers {
+		out = append(out, c.MimeType)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// notAcceptable answers 406 with the media types /compress can produce.
+func notAcceptable(w http.ResponseWriter, requestID, accept, ext string) {
+	logger.Printf("🙅 [%s] Accept %q rules out %s output", requestID, accept, ext)
+	writeJSON(w, http.StatusNotAcceptable, map[string]any{
+		"error":     "requested media type cannot be produced",
+		"accept":    accept,
+		"supported": supportedMimeTypes(),
+	})
+}
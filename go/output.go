@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ======================
+// Output directory (OUTPUT_DIR) + persisted result index
+// ======================
+
+// outputDir is where results and artifacts are written: OUTPUT_DIR (e.g. a
+// mounted volume) or the OS temp dir. With OUTPUT_DIR, the result index is
+// persisted next to the files so /dl and /meta survive restarts.
+func outputDir() string {
+	return envOr("OUTPUT_DIR", os.TempDir())
+}
+
+func persistentOutputs() bool {
+	return os.Getenv("OUTPUT_DIR") != ""
+}
+
+// outputPath returns a result path in the output dir, prefixed with the
+// request ID so concurrent jobs with the same upload name never collide.
+func outputPath(requestID, name string) string {
+	return filepath.Join(outputDir(), requestID+"_"+filepath.Base(name))
+}
+
+func resultIndexDir() string {
+	return filepath.Join(outputDir(), ".results")
+}
+
+// initOutputDir creates the output dir and reloads persisted results.
+func initOutputDir() error {
+	if !persistentOutputs() {
+		return nil
+	}
+	if err := os.MkdirAll(resultIndexDir(), 0o755); err != nil {
+		return err
+	}
+	n, err := loadPersistedResults()
+	if err != nil {
+		return err
+	}
+	logger.Printf("📂 [MAIN] Output dir %s (%d stored results, %d kept inputs restored)", outputDir(), n, loadPersistedInputs())
+	return nil
+}
+
+// persistResult writes the entry's sidecar JSON (no-op without OUTPUT_DIR).
+func persistResult(id string, e *resultEntry) {
+	if !persistentOutputs() {
+		return
+	}
+	deliveryMu.Lock()
+	data, err := json.Marshal(e)
+	deliveryMu.Unlock()
+	if err == nil {
+		err = os.WriteFile(filepath.Join(resultIndexDir(), id+".json"), data, 0o644)
+	}
+	if err != nil {
+		logger.Printf("⚠️ [RESULTS] Could not persist %s: %v", id, err)
+	}
+}
+
+func forgetResult(id string) {
+	if persistentOutputs() {
+		os.Remove(filepath.Join(resultIndexDir(), id+".json"))
+	}
+}
+
+func loadPersistedResults() (int, error) {
+	paths, err := filepath.Glob(filepath.Join(resultIndexDir(), "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var e resultEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			continue
+		}
+		if _, err := os.Stat(e.FilePath); err != nil {
+			os.Remove(p) // output is gone; drop the stale index entry
+			continue
+		}
+		id := strings.TrimSuffix(filepath.Base(p), ".json")
+		storeMu.Lock()
+		store[id] = &e
+		storeMu.Unlock()
+		n++
+	}
+	return n, nil
+}
+
+// expiredIndexEntries removes persisted index entries created before cutoff
+// that are not in memory (results of other replicas sharing OUTPUT_DIR, or of
+// a replica that is gone) and returns them so their files can be deleted.
+func expiredIndexEntries(cutoff time.Time) []*resultEntry {
+	if !persistentOutputs() {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(resultIndexDir(), "*.json"))
+	var out []*resultEntry
+	for _, p := range paths {
+		id := strings.TrimSuffix(filepath.Base(p), ".json")
+		storeMu.Lock()
+		_, live := store[id]
+		storeMu.Unlock()
+		if live {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var e resultEntry
+		if json.Unmarshal(data, &e) != nil || !e.Created.Before(cutoff) {
+			continue
+		}
+		os.Remove(p)
+		out = append(out, &e)
+	}
+	return out
+}
+
+// keepOriginals reports whether inputs are kept next to their results
+// (KEEP_ORIGINALS=1) instead of being deleted after the job.
+func keepOriginals() bool {
+	return os.Getenv("KEEP_ORIGINALS") == "1"
+}
+
+// keepOriginal links the input into the output dir and registers it as the
+// "original<ext>" artifact of e. inPath stays in place for the rest of the
+// job (captions, renditions, further jobspec outputs) and its own cleanup.
+func keepOriginal(requestID, inPath string, e *resultEntry) {
+	dst := outputPath(requestID, "original"+filepath.Ext(inPath))
+	if err := linkOrCopy(inPath, dst); err != nil {
+		logger.Printf("⚠️ [%s] Could not keep original: %v", requestID, err)
+		return
+	}
+	e.Artifacts["original"+strings.ToLower(filepath.Ext(inPath))] = dst
+	logger.Printf("🗄️ [%s] Original kept at %s", requestID, dst)
+}
+
+// ======================
+// Download validators (resumable /dl)
+// ======================
+
+// Each stored result records the SHA-256 and length of its output and
+// artifacts. Downloads send the hash as a strong ETag (and Repr-Digest), so
+// a client that lost its connection can resume with Range + If-Range, also
+// after a restart with OUTPUT_DIR. A file whose length no longer matches is
+// served without the ETag, which makes If-Range fall back to a full body.
+
+type fileDigest struct {
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+}
+
+func digestFile(path string) (fileDigest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileDigest{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return fileDigest{}, err
+	}
+	return fileDigest{SHA256: hex.EncodeToString(h.Sum(nil)), Bytes: n}, nil
+}
+
+// digestFiles hashes the output and artifacts of e (artifact "" is the
+// output).
+func (e *resultEntry) digestFiles(requestID string) {
+	files := map[string]string{"": e.FilePath}
+	for name, p := range e.Artifacts {
+		files[name] = p
+	}
+	digests := make(map[string]fileDigest, len(files))
+	for name, p := range files {
+		d, err := digestFile(p)
+		if err != nil {
+			logger.Printf("⚠️ [%s] Could not hash %s: %v", requestID, filepath.Base(p), err)
+			continue
+		}
+		digests[name] = d
+	}
+	e.Digests = digests
+}
+
+// setValidators adds the ETag and Repr-Digest of a result file, unless the
+// file on disk no longer has the recorded length.
+func setValidators(w http.ResponseWriter, e *resultEntry, artifact, path string) {
+	d, ok := e.Digests[artifact]
+	if !ok {
+		return
+	}
+	if st, err := os.Stat(path); err != nil || st.Size() != d.Bytes {
+		return
+	}
+	w.Header().Set("ETag", `"`+d.SHA256+`"`)
+	if sum, err := hex.DecodeString(d.SHA256); err == nil {
+		w.Header().Set("Repr-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ======================
+// Output size guard (max_output_bytes=, on_oversize=)
+// ======================
+
+// An output over max_output_bytes is either re-encoded once at a higher CRF
+// (on_oversize=reencode, the default) or rejected (on_oversize=fail). Either
+// way an output that is still too big fails with a sizeLimitError, which
+// the handlers report as code "output_too_large".
+
+const (
+	oversizeMinStep = 2
+	oversizeMaxStep = 12
+)
+
+// sizeLimitError is returned when the output exceeds max_output_bytes.
+type sizeLimitError struct {
+	Limit, Bytes int64
+	Retried      bool
+}
+
+func (e *sizeLimitError) Error() string {
+	s := fmt.Sprintf("output is %s, over max_output_bytes %s", humanBytes(e.Bytes), humanBytes(e.Limit))
+	if e.Retried {
+		s += " (after a more aggressive re-encode)"
+	}
+	return s
+}
+
+func parseMaxOutput(maxBytes, onOversize string) (int64, error) {
+	if onOversize != "reencode" && onOversize != "fail" {
+		return 0, fmt.Errorf("invalid on_oversize %q (reencode|fail)", onOversize)
+	}
+	if maxBytes == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(maxBytes, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid max_output_bytes %q", maxBytes)
+	}
+	return n, nil
+}
+
+// oversizeCRF picks the retry CRF: bitrate roughly halves every 6 CRF, so
+// step by the halvings needed plus a margin.
+func oversizeCRF(crf int, bytes, limit int64) int {
+	step := int(math.Ceil(6*math.Log2(float64(bytes)/float64(limit)))) + 1
+	step = min(max(step, oversizeMinStep), oversizeMaxStep)
+	return min(crf+step, 51)
+}
+
+// enforceMaxOutput checks outPath against o.MaxOutputBytes and re-encodes
+// it once more aggressively if allowed.
+func enforceMaxOutput(ctx context.Context, requestID, inPath, outPath string, o *compressOpts) ([]string, error) {
+	st, err := os.Stat(outPath)
+	if err != nil || st.Size() <= o.MaxOutputBytes {
+		return nil, nil
+	}
+	over := &sizeLimitError{Limit: o.MaxOutputBytes, Bytes: st.Size()}
+	if o.OnOversize == "fail" || o.Codec == "copy" || o.SpeedMode == "lossless" || o.CRF >= 51 {
+		return nil, over
+	}
+
+	retry := *o
+	retry.CRF = oversizeCRF(o.CRF, st.Size(), o.MaxOutputBytes)
+	logger.Printf("📏 [%s] Output %s exceeds %s; re-encoding at CRF %d (was %d)",
+		requestID, humanBytes(st.Size()), humanBytes(o.MaxOutputBytes), retry.CRF, o.CRF)
+	if err := runFFmpeg(ctx, inPath, outPath, retry, io.Discard); err != nil {
+		return nil, fmt.Errorf("oversize re-encode failed: %w", err)
+	}
+	st2, err := os.Stat(outPath)
+	if err != nil {
+		return nil, err
+	}
+	if st2.Size() > o.MaxOutputBytes {
+		return nil, &sizeLimitError{Limit: o.MaxOutputBytes, Bytes: st2.Size(), Retried: true}
+	}
+	*o = retry
+	return []string{fmt.Sprintf("output was %s, over max_output_bytes; re-encoded at CRF %d (%s)",
+		humanBytes(st.Size()), retry.CRF, humanBytes(st2.Size()))}, nil
+}
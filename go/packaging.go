@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Streaming packages (format=hls|dash)
+// ======================
+
+// format=hls or format=dash packages the finished encode for streaming
+// playback instead of returning one file: a playlist or manifest plus
+// segments of about hls_time / seg_duration seconds (default 6), cut without
+// re-encoding on keyframes the encode forces at every segment boundary (see
+// hls.go and dash.go for the layouts). With renditions=... every rendition
+// goes into the one package.
+//
+// The result is a ZIP of the package (stored, like split parts). With
+// output=s3://bucket/prefix/ the files are uploaded under prefix/<name>/
+// instead and "output_url" is the top-level playlist or manifest.
+
+const (
+	packageDefaultSec = 6
+	packageMaxSec     = 30
+)
+
+type packageSpec struct {
+	Format     string // hls|dash
+	SegmentSec float64
+	Segment    string // HLS: ts|fmp4 ("" = by codec)
+}
+
+// packageCodecs are the video and audio codecs each format takes.
+var packageCodecs = map[string]struct{ Video, Audio map[string]bool }{
+	"hls":  {codecSet("h264", "h265", "av1", "copy"), codecSet("aac", "copy", "")},
+	"dash": {codecSet("h264", "h265", "av1", "vp9", "copy"), codecSet("aac", "opus", "ac3", "eac3", "copy", "")},
+}
+
+// packagePlaylists are the top-level files, in listing order.
+var packagePlaylists = []string{"master.m3u8", "index.m3u8", "manifest.mpd"}
+
+func parsePackage(format, hlsTime, hlsSegment, segDuration, outExt string, o compressOpts) (*packageSpec, error) {
+	switch format {
+	case "":
+		if hlsTime != "" || hlsSegment != "" || segDuration != "" {
+			return nil, errors.New("hls_time, hls_segment and seg_duration need format=hls or format=dash")
+		}
+		return nil, nil
+	case "hls":
+		if segDuration != "" {
+			return nil, errors.New("seg_duration is for format=dash (use hls_time)")
+		}
+	case "dash":
+		if hlsTime != "" || hlsSegment != "" {
+			return nil, errors.New("hls_time and hls_segment are for format=hls (use seg_duration)")
+		}
+		hlsTime = segDuration
+	default:
+		return nil, fmt.Errorf("invalid format %q (hls|dash)", format)
+	}
+	p := &packageSpec{Format: format, SegmentSec: packageDefaultSec, Segment: hlsSegment}
+	if hlsTime != "" {
+		f, err := strconv.ParseFloat(hlsTime, 64)
+		if err != nil || f < 1 || f > packageMaxSec {
+			return nil, fmt.Errorf("invalid segment duration %q (1-%d seconds)", hlsTime, packageMaxSec)
+		}
+		p.SegmentSec = f
+	}
+	codec := strings.ToLower(o.Codec)
+	switch p.Segment {
+	case "":
+	case "ts", "fmp4":
+		if !hlsSegmentCodecs[p.Segment][codec] {
+			return nil, fmt.Errorf("hls_segment=%s cannot carry codec %s", p.Segment, codec)
+		}
+	default:
+		return nil, fmt.Errorf("invalid hls_segment %q (ts|fmp4)", p.Segment)
+	}
+	pc := packageCodecs[format]
+	if !pc.Video[codec] {
+		return nil, fmt.Errorf("format=%s needs codec=%s (got %s)", format, strings.Join(sortedList(pc.Video), ", "), codec)
+	}
+	if a := strings.ToLower(o.Audio); !pc.Audio[a] {
+		return nil, fmt.Errorf("format=%s needs audio=%s (got %s)", format, strings.Join(sortedList(pc.Audio), ", "), a)
+	}
+	if outExt != "" {
+		return nil, fmt.Errorf("outExt does not apply to format=%s (the result is a package of segments)", format)
+	}
+	var bad []string
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{o.AudioOut != nil, "output=audio"},
+		{o.SplitMaxBytes > 0 || o.SplitMaxSec > 0, "segment_max_size/segment_max_sec"},
+		{o.Interlace != "", "interlace"},
+		{o.Alpha == "keep", "alpha=keep"},
+		{o.KeepAllAudio, "keep_all_audio"},
+	} {
+		if c.set {
+			bad = append(bad, c.name)
+		}
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("format=%s cannot be combined with %s", format, strings.Join(bad, ", "))
+	}
+	return p, nil
+}
+
+// sortedList returns the non-empty keys of m in order.
+func sortedList(m map[string]bool) []string {
+	var out []string
+	for k := range m {
+		if k != "" {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// packageOutput packages outPath (and the lower renditions) in
+// <outPath>.<format> and zips it as <outPath>.zip. It returns the ZIP path,
+// the package directory (removed by the caller) and the number of segments.
+func packageOutput(ctx context.Context, requestID, outPath string, rends []renditionInfo, o compressOpts) (string, string, int, error) {
+	dir := outPath + "." + o.Package.Format
+	os.RemoveAll(dir)
+	fail := func(err error) (string, string, int, error) {
+		os.RemoveAll(dir)
+		return "", "", 0, err
+	}
+	var err error
+	if o.Package.Format == "dash" {
+		err = packageDASH(ctx, outPath, dir, rends, o)
+	} else {
+		err = packageHLS(ctx, outPath, dir, rends, o)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	files, names, segments, err := packageFiles(dir)
+	if err != nil {
+		return fail(err)
+	}
+	zipPath := withExt(outPath, ".zip")
+	f, err := os.Create(zipPath)
+	if err == nil {
+		err = writeZip(f, names, files)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		os.Remove(zipPath)
+		return fail(err)
+	}
+	logger.Printf("📺 [%s] Packaged %s: %d segments of %gs", requestID, strings.ToUpper(o.Package.Format), segments, o.Package.SegmentSec)
+	return zipPath, dir, segments, nil
+}
+
+// packageFiles lists a package: the top-level playlist or manifest first,
+// then init segments and segments in order, then each rendition directory
+// the same way, with their slash-separated names inside the package.
+func packageFiles(dir string) (files, names []string, segments int, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	var inits, segs, subdirs []string
+	for _, e := range entries {
+		n := e.Name()
+		switch {
+		case e.IsDir():
+			subdirs = append(subdirs, n)
+		case strings.HasSuffix(n, ".m3u8"), strings.HasSuffix(n, ".mpd"):
+		case strings.HasPrefix(n, "init"):
+			inits = append(inits, n)
+		default:
+			segs = append(segs, n)
+		}
+	}
+	for _, n := range packagePlaylists {
+		if _, err := os.Stat(filepath.Join(dir, n)); err == nil {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(inits)
+	sort.Strings(segs)
+	names = append(append(names, inits...), segs...)
+	segments = len(segs)
+	for _, sd := range subdirs {
+		_, sub, n, err := packageFiles(filepath.Join(dir, sd))
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		for _, s := range sub {
+			names = append(names, sd+"/"+s)
+		}
+		segments += n
+	}
+	if segments == 0 {
+		return nil, nil, 0, errors.New("packaging: no segments written")
+	}
+	for _, n := range names {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(n)))
+	}
+	return files, names, segments, nil
+}
+
+// uploadPackage puts the package under the output prefix (the download
+// name's stem for a bucket-level or "/"-terminated key) and records the URL
+// of the top-level playlist or manifest.
+func uploadPackage(ctx context.Context, requestID, dir string, e *resultEntry, l *s3Location) error {
+	c, err := s3FromEnv()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, outputUploadTimeout)
+	defer cancel()
+	prefix := l.objectKey(strings.TrimSuffix(e.downloadName(), filepath.Ext(e.downloadName())))
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	files, names, _, err := packageFiles(dir)
+	if err != nil {
+		return err
+	}
+	logger.Printf("☁️ [%s] Uploading package to s3://%s/%s (%d files)", requestID, l.Bucket, prefix, len(files))
+	// back to front: segments before their playlist, the top-level one
+	// last, so no playlist ever points at missing objects
+	for i := len(files) - 1; i >= 0; i-- {
+		u, err := s3PutFile(ctx, c, l.Bucket, prefix+names[i], files[i], contentTypeFor(names[i]))
+		if err != nil {
+			return errors.New("uploading output: " + err.Error())
+		}
+		if i == 0 {
+			e.OutputURL = u
+		}
+	}
+	logger.Printf("✅ [%s] Package uploaded: %s", requestID, e.OutputURL)
+	return nil
+}
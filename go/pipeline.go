@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ======================
+// Job pipelines (POST /pipeline)
+// ======================
+
+// A pipeline runs ordered steps on one upload so clients don't have to
+// orchestrate round-trips:
+//
+//	trim       cut start/end (applied inside the compress encode, frame-accurate)
+//	compress   the /compress options as "params"
+//	thumbnail  JPEG of the output at "at" seconds, stored as an artifact
+//	upload     push the output to a deliver target (local|s3|http)
+//
+// Steps run in order; the first failure marks the rest skipped. The result
+// (with per-step status) is stored like any compress result.
+
+const maxPipelineSteps = 20
+
+type pipelineStep struct {
+	Step   string          `json:"step"`
+	Start  float64         `json:"start,omitempty"`  // trim
+	End    float64         `json:"end,omitempty"`    // trim
+	Params map[string]any  `json:"params,omitempty"` // compress
+	At     float64         `json:"at,omitempty"`     // thumbnail
+	Width  int             `json:"width,omitempty"`  // thumbnail
+	Target *deliveryTarget `json:"target,omitempty"` // upload
+
+	State     string `json:"state"` // pending|running|done|failed|skipped
+	ElapsedMs int64  `json:"elapsed_ms,omitempty"`
+	Artifact  string `json:"artifact,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Note      string `json:"note,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// stringParams turns JSON params (strings or numbers) into form values.
+func stringParams(m map[string]any) (map[string]string, error) {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			out[k] = v
+		case float64:
+			out[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			out[k] = "0"
+			if v {
+				out[k] = "1"
+			}
+		default:
+			return nil, fmt.Errorf("%s must be a string or number", k)
+		}
+	}
+	return out, nil
+}
+
+// parsePipeline validates the steps and returns them with the compress
+// options (trim folded in).
+func parsePipeline(s string) ([]*pipelineStep, compressOpts, error) {
+	var steps []*pipelineStep
+	if err := json.Unmarshal([]byte(s), &steps); err != nil {
+		return nil, compressOpts{}, errors.New(`steps must be a JSON array like [{"step":"compress","params":{"speed":"fast"}}]`)
+	}
+	if len(steps) == 0 || len(steps) > maxPipelineSteps {
+		return nil, compressOpts{}, fmt.Errorf("steps needs 1-%d entries", maxPipelineSteps)
+	}
+	var (
+		opts     compressOpts
+		compress = -1
+		trim     *pipelineStep
+	)
+	for i, st := range steps {
+		st.State = "pending"
+		switch st.Step {
+		case "trim":
+			if compress >= 0 || trim != nil {
+				return nil, opts, fmt.Errorf("steps[%d]: one trim, before compress", i)
+			}
+			if st.Start < 0 || (st.End != 0 && st.End <= st.Start) {
+				return nil, opts, fmt.Errorf("steps[%d]: trim needs 0 <= start < end", i)
+			}
+			trim = st
+		case "compress":
+			if compress >= 0 {
+				return nil, opts, fmt.Errorf("steps[%d]: only one compress step", i)
+			}
+			params, err := stringParams(st.Params)
+			if err != nil {
+				return nil, opts, fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			if opts, err = parseOptsFrom(func(k string) string { return params[k] }); err != nil {
+				return nil, opts, fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			compress = i
+		case "thumbnail", "upload":
+			if compress < 0 {
+				return nil, opts, fmt.Errorf("steps[%d]: %s needs an earlier compress step", i, st.Step)
+			}
+			if st.Step == "thumbnail" && (st.At < 0 || st.Width < 0 || st.Width > 3840) {
+				return nil, opts, fmt.Errorf("steps[%d]: thumbnail needs at >= 0 and width 0-3840", i)
+			}
+			if st.Step == "upload" {
+				if st.Target == nil {
+					return nil, opts, fmt.Errorf("steps[%d]: upload needs a target", i)
+				}
+				if err := st.Target.validate(); err != nil {
+					return nil, opts, fmt.Errorf("steps[%d]: %w", i, err)
+				}
+			}
+		default:
+			return nil, opts, fmt.Errorf("steps[%d]: unknown step %q (trim|compress|thumbnail|upload)", i, st.Step)
+		}
+	}
+	if compress < 0 {
+		return nil, opts, errors.New("a pipeline needs a compress step")
+	}
+	if trim != nil {
+		if opts.TrimDead != "" {
+			return nil, opts, errors.New("trim cannot be combined with trim_dead")
+		}
+		opts.TrimStart, opts.TrimEnd = trim.Start, trim.End
+	}
+	return steps, opts, nil
+}
+
+// pipelineThumbnail grabs one frame of the output as JPEG.
+func pipelineThumbnail(ctx context.Context, e *resultEntry, st *pipelineStep) error {
+	name := "thumbnail.jpg"
+	for n := 2; e.Artifacts[name] != ""; n++ {
+		name = fmt.Sprintf("thumbnail-%d.jpg", n)
+	}
+	path := withExt(e.FilePath, "_"+name)
+	vf := "scale=iw*sar:ih"
+	if st.Width > 0 {
+		vf = fmt.Sprintf("scale=%d:-2", st.Width)
+	}
+	if err := runFF(ctx, "-ss", strconv.FormatFloat(st.At, 'f', 3, 64), "-i", e.FilePath,
+		"-frames:v", "1", "-vf", vf, "-q:v", "3", path); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errors.New("no frame at that time")
+	}
+	e.Artifacts[name] = path
+	st.Artifact = name
+	return nil
+}
+
+// runPipeline executes the steps; it returns the stored result ID (empty if
+// compress failed) and the error of the failed step.
+func runPipeline(ctx context.Context, requestID, inPath string, steps []*pipelineStep, opts compressOpts) (string, error) {
+	var (
+		entry  *resultEntry
+		failed error
+	)
+	for i, st := range steps {
+		if failed != nil {
+			st.State = "skipped"
+			continue
+		}
+		st.State = "running"
+		start := time.Now()
+		logger.Printf("🪜 [%s] Step %d/%d: %s", requestID, i+1, len(steps), st.Step)
+		var err error
+		switch st.Step {
+		case "trim":
+			st.Note = "applied during the compress encode"
+		case "compress":
+			entry, err = compressFile(ctx, requestID, inPath, opts)
+		case "thumbnail":
+			err = pipelineThumbnail(ctx, entry, st)
+		case "upload":
+			st.Location, err = deliver(ctx, entry, *st.Target)
+		}
+		st.ElapsedMs = time.Since(start).Milliseconds()
+		if err != nil {
+			st.State, st.Error, failed = "failed", err.Error(), err
+			logger.Printf("❌ [%s] Step %s failed: %v", requestID, st.Step, err)
+			continue
+		}
+		st.State = "done"
+	}
+	if entry == nil {
+		return "", failed
+	}
+	for _, st := range steps {
+		entry.Pipeline = append(entry.Pipeline, *st)
+	}
+	return storeResult(requestID, entry), failed
+}
+
+func pipelineHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🪜 [%s] New pipeline request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseMediaUpload(w, r, requestID) {
+		return
+	}
+	steps, opts, err := parsePipeline(r.FormValue("steps"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	inPath, _, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file field required", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(inPath)
+	if fh := r.MultipartForm.File["file"]; len(fh) > 0 {
+		opts.SourceName = fh[0].Filename
+	}
+
+	id, err := runPipeline(r.Context(), requestID, inPath, steps, opts)
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		var ce *compatError
+		var ve *verifyError
+		var se *sizeLimitError
+		if errors.As(err, &ce) || errors.As(err, &ve) || errors.As(err, &se) {
+			status = http.StatusUnprocessableEntity
+		}
+	}
+	resp := map[string]any{"steps": steps}
+	if id != "" {
+		resp["result_id"] = id
+		resp["download_url"] = "/dl/" + id
+		resp["meta_url"] = "/meta/" + id
+	}
+	logger.Printf("✅ [%s] Pipeline finished (HTTP %d, result %q)", requestID, status, id)
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ======================
+// Pixel format / chroma subsampling (pix_fmt=)
+// ======================
+
+// By default the encoder's pixel format follows bit_depth, alpha and the
+// container (yuv420p for .mp4/.m4v/.webm, the source's format elsewhere).
+// pix_fmt picks it explicitly, e.g. yuv422p10le for a broadcast master or
+// yuv444p for screen recordings with fine coloured text. Each encoder only
+// takes some formats, so the combination is checked before encoding.
+
+var pixFmtNames = []string{"yuv420p", "yuv422p", "yuv444p", "yuv420p10le", "yuv422p10le", "yuv444p10le"}
+
+// pixFmtCodecs are the formats each codec's encoder accepts.
+var pixFmtCodecs = map[string][]string{
+	"h264":   {"yuv420p", "yuv422p", "yuv444p"},
+	"h265":   pixFmtNames,
+	"vp9":    pixFmtNames,
+	"av1":    {"yuv420p", "yuv420p10le"},
+	"ffv1":   pixFmtNames,
+	"prores": {"yuv422p10le", "yuv444p10le"},
+}
+
+func parsePixFmt(s string, o compressOpts) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	s = strings.ToLower(s)
+	if !slices.Contains(pixFmtNames, s) {
+		if slices.Contains(pixFmtNames, s+"le") {
+			s += "le" // yuv420p10 → yuv420p10le
+		} else {
+			return "", fmt.Errorf("invalid pix_fmt %q (%s)", s, strings.Join(pixFmtNames, "|"))
+		}
+	}
+	codec := strings.ToLower(o.Codec)
+	if codec == "copy" {
+		return "", errors.New("pix_fmt needs a video re-encode (codec=copy)")
+	}
+	if ok := pixFmtCodecs[codec]; !slices.Contains(ok, s) {
+		return "", fmt.Errorf("pix_fmt %s is not supported by codec %s (supported: %s)", s, codec, strings.Join(ok, ", "))
+	}
+	if strings.EqualFold(o.HW, "videotoolbox") && s != "yuv420p" && !(codec == "h265" && s == "yuv420p10le") {
+		return "", fmt.Errorf("pix_fmt %s is not supported by hw=videotoolbox", s)
+	}
+	if ext := strings.ToLower(o.OutExt); ext == ".avi" && s != "yuv420p" {
+		return "", fmt.Errorf("pix_fmt %s is not supported in .avi (yuv420p only)", s)
+	}
+	switch {
+	case o.BitDepth == 8 && highBitDepthRe.MatchString(s), o.BitDepth == 10 && !highBitDepthRe.MatchString(s):
+		return "", fmt.Errorf("pix_fmt %s contradicts bit_depth=%d", s, o.BitDepth)
+	case o.Alpha == "keep":
+		return "", fmt.Errorf("pix_fmt %s has no alpha plane (alpha=keep)", s)
+	}
+	return s, nil
+}
+
+// settlePixFmt rechecks pix_fmt against the codec finally chosen (small-file
+// safety, compat and AI planning can change it) and returns a note for the
+// warnings, or "".
+func (o *compressOpts) settlePixFmt() string {
+	if o.PixFmt == "" {
+		return ""
+	}
+	codec := strings.ToLower(o.Codec)
+	if codec == "copy" {
+		o.PixFmt = ""
+		return "pix_fmt ignored: the video is stream-copied"
+	}
+	if !slices.Contains(pixFmtCodecs[codec], o.PixFmt) {
+		note := fmt.Sprintf("pix_fmt %s ignored: not supported by %s", o.PixFmt, codec)
+		o.PixFmt = ""
+		return note
+	}
+	switch strings.ToLower(o.OutExt) {
+	case ".mp4", ".m4v", ".webm":
+		// 4:2:0 plays in current browsers at 8 and 10 bits
+		if !strings.HasPrefix(o.PixFmt, "yuv420p") {
+			return fmt.Sprintf("pix_fmt %s in %s will not play in most browsers", o.PixFmt, o.OutExt)
+		}
+	}
+	return ""
+}
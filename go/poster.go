@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Poster frame selection (POST /poster/{id})
+// ======================
+
+// The result page shows the compressed video with a scrubber so the user can
+// pick the frame to use as its poster. POST /poster/{id} with "time"
+// (seconds) takes that frame from the stored result, a seek and one decoded
+// frame with no re-encode of the video, and stores it as the poster.jpg
+// artifact, replacing an earlier pick. "format" (jpg|png) and "width"
+// (default: the video's own size) work as for /thumbnail.
+
+// posterPlayable are the result containers the result page can scrub in a
+// browser <video>.
+var posterPlayable = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".webm": true}
+
+// posterable reports whether the result is a single video a poster can be
+// taken from (not a ZIP of parts, a package or an audio file).
+func posterable(e *resultEntry) bool {
+	_, ok := outputContainers[strings.ToLower(filepath.Ext(e.FilePath))]
+	return ok
+}
+
+func posterHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/poster/")
+	logger.Printf("🖼️ [%s] Poster request for %s from %s", requestID, id, r.RemoteAddr)
+	e, ok := getResult(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if !posterable(e) {
+		http.Error(w, "the result is not a single video", http.StatusConflict)
+		return
+	}
+	if _, err := os.Stat(e.FilePath); err != nil {
+		http.Error(w, "the result file is not available on this server", http.StatusConflict)
+		return
+	}
+	t, err := strconv.ParseFloat(r.FormValue("time"), 64)
+	if err != nil || t < 0 || math.IsInf(t, 0) {
+		http.Error(w, fmt.Sprintf("invalid time %q (seconds)", r.FormValue("time")), http.StatusBadRequest)
+		return
+	}
+	ts, err := parseThumbSpec("", strconv.FormatFloat(t, 'f', 3, 64), r.FormValue("format"), r.FormValue("width"), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("width") == "" {
+		ts.frame.Width = 0 // full size
+	}
+	dur := 0.0
+	if p, err := probeFile(r.Context(), e.FilePath); err == nil {
+		dur = p.durationSec()
+	}
+	if dur > 0 && t >= dur {
+		http.Error(w, fmt.Sprintf("time %.3fs is past the end (%.3fs)", t, dur), http.StatusBadRequest)
+		return
+	}
+
+	prefix := strings.TrimSuffix(e.FilePath, filepath.Ext(e.FilePath)) + "_" + requestID
+	thumbs, err := extractThumbnails(r.Context(), e.FilePath, prefix, ts, dur)
+	if err != nil {
+		logger.Printf("❌ [%s] Poster: %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	name := "poster." + ts.frame.Format
+	if err := setPoster(e, name, thumbs[0].path); err != nil {
+		os.Remove(thumbs[0].path)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	persistResult(id, e)
+	logger.Printf("✅ [%s] Poster of %s set at %.3fs", requestID, id, t)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"artifact": name,
+		"time_sec": t,
+		"url":      "/dl/" + id + "/" + name,
+	})
+}
+
+// setPoster makes path the result's poster artifact, removing an earlier
+// one. The artifact and digest maps are replaced rather than written in
+// place, as downloads read them without a lock.
+func setPoster(e *resultEntry, name, path string) error {
+	d, err := digestFile(path)
+	if err != nil {
+		return err
+	}
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	artifacts := maps.Clone(e.Artifacts)
+	digests := maps.Clone(e.Digests)
+	if artifacts == nil {
+		artifacts = map[string]string{}
+	}
+	if digests == nil {
+		digests = map[string]fileDigest{}
+	}
+	for _, old := range []string{"poster.jpg", "poster.png"} {
+		if p, ok := artifacts[old]; ok {
+			os.Remove(p)
+			delete(artifacts, old)
+			delete(digests, old)
+		}
+	}
+	artifacts[name] = path
+	digests[name] = d
+	e.Artifacts, e.Digests = artifacts, digests
+	if e.Shared != nil && len(e.Shared.Artifacts) > 0 {
+		// the shared copy of an earlier poster is stale now
+		shared := *e.Shared
+		shared.Artifacts = maps.Clone(e.Shared.Artifacts)
+		delete(shared.Artifacts, "poster.jpg")
+		delete(shared.Artifacts, "poster.png")
+		e.Shared = &shared
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// ======================
+// Animated previews (preview=gif|webp)
+// ======================
+
+// preview=gif|webp stores a short, small, looping animation of the
+// compressed output as the "preview" artifact (GET /dl/{id}/preview), for
+// gallery hover previews. It covers preview_sec seconds (default 3) from the
+// middle of the video at preview_fps (default 10) and preview_width pixels
+// wide (default 320). GIFs get a palette generated from the clip itself, so
+// they keep the colours a fixed web palette would band; WebP is smaller
+// still and plays in every current browser.
+
+const (
+	previewDefaultSec   = 3.0
+	previewMaxSec       = 10.0
+	previewDefaultFPS   = 10
+	previewMaxFPS       = 30
+	previewDefaultWidth = 320
+	previewMaxWidth     = 1280
+)
+
+type previewSpec struct {
+	Format string // gif|webp
+	Sec    float64
+	FPS    int
+	Width  int
+}
+
+func parsePreview(format, sec, fps, width string) (*previewSpec, error) {
+	switch format {
+	case "":
+		if sec != "" || fps != "" || width != "" {
+			return nil, errors.New("preview_sec, preview_fps and preview_width need preview=gif|webp")
+		}
+		return nil, nil
+	case "gif", "webp":
+	default:
+		return nil, fmt.Errorf("invalid preview %q (gif|webp)", format)
+	}
+	p := &previewSpec{Format: format, Sec: previewDefaultSec, FPS: previewDefaultFPS, Width: previewDefaultWidth}
+	if sec != "" {
+		v, err := strconv.ParseFloat(sec, 64)
+		if err != nil || v < 0.5 || v > previewMaxSec {
+			return nil, fmt.Errorf("invalid preview_sec %q (0.5-%g)", sec, previewMaxSec)
+		}
+		p.Sec = v
+	}
+	if fps != "" {
+		n, err := strconv.Atoi(fps)
+		if err != nil || n < 1 || n > previewMaxFPS {
+			return nil, fmt.Errorf("invalid preview_fps %q (1-%d)", fps, previewMaxFPS)
+		}
+		p.FPS = n
+	}
+	if width != "" {
+		n, err := strconv.Atoi(width)
+		if err != nil || n < 32 || n > previewMaxWidth {
+			return nil, fmt.Errorf("invalid preview_width %q (32-%d)", width, previewMaxWidth)
+		}
+		p.Width = n
+	}
+	return p, nil
+}
+
+// makePreview renders the animation of outPath and adds it to artifacts.
+func makePreview(ctx context.Context, requestID, outPath string, o compressOpts, artifacts map[string]string) error {
+	out, err := probeFile(ctx, outPath)
+	if err != nil {
+		return err
+	}
+	if out.firstStream("video") == nil {
+		return errors.New("no video stream")
+	}
+	p := o.Preview
+	length, at := p.Sec, 0.0
+	if dur := out.durationSec(); dur > 0 {
+		length = math.Min(p.Sec, dur)
+		at = (dur - length) / 2
+	}
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	vf := fmt.Sprintf("fps=%d,scale=%d:-2:flags=lanczos", p.FPS, p.Width)
+	args := []string{"-ss", f(at), "-t", f(length), "-i", outPath, "-an", "-sn", "-dn"}
+	if p.Format == "gif" {
+		args = append(args, "-filter_complex",
+			"[0:v:0]"+vf+",split[a][b];[a]palettegen=stats_mode=diff[p];[b][p]paletteuse=dither=bayer:bayer_scale=4")
+	} else {
+		args = append(args, "-vf", vf, "-c:v", "libwebp", "-quality", "70", "-compression_level", "4")
+	}
+	path := withExt(outPath, "_preview."+p.Format)
+	if err := runFF(ctx, append(args, "-loop", "0", path)...); err != nil {
+		os.Remove(path)
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return errors.New("no preview written")
+	}
+	artifacts["preview"] = path
+	logger.Printf("🎞️ [%s] %s preview: %.1fs from %.1fs at %d fps", requestID, p.Format, length, at, p.FPS)
+	return nil
+}
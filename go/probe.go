@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// ffprobe
+// ======================
+
+type probeFormat struct {
+	Filename   string            `json:"filename"`
+	FormatName string            `json:"format_name"`
+	Duration   string            `json:"duration"`
+	Size       string            `json:"size"`
+	BitRate    string            `json:"bit_rate"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+type probeStream struct {
+	Index          int               `json:"index"`
+	CodecType      string            `json:"codec_type"`
+	CodecName      string            `json:"codec_name"`
+	Profile        string            `json:"profile,omitempty"`
+	Level          int               `json:"level,omitempty"`
+	CodecTag       string            `json:"codec_tag_string,omitempty"`
+	Width          int               `json:"width,omitempty"`
+	Height         int               `json:"height,omitempty"`
+	PixFmt         string            `json:"pix_fmt,omitempty"`
+	FieldOrder     string            `json:"field_order,omitempty"`
+	ClosedCaptions int               `json:"closed_captions,omitempty"` // CEA-608/708 (A/53) in the video
+	AvgFrameRate   string            `json:"avg_frame_rate,omitempty"`
+	StartTime      string            `json:"start_time,omitempty"`
+	Duration       string            `json:"duration,omitempty"`
+	BitRate        string            `json:"bit_rate,omitempty"`
+	Channels       int               `json:"channels,omitempty"`
+	SampleRate     string            `json:"sample_rate,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	Disposition    map[string]int    `json:"disposition,omitempty"`
+	SideDataList   []map[string]any  `json:"side_data_list,omitempty"`
+}
+
+// rotation returns the clockwise display rotation in degrees (0, 90, 180, 270),
+// from either the legacy "rotate" tag or the display matrix side data.
+func (s *probeStream) rotation() int {
+	if v, err := strconv.Atoi(s.Tags["rotate"]); err == nil {
+		return ((v % 360) + 360) % 360
+	}
+	for _, sd := range s.SideDataList {
+		if r, ok := sd["rotation"].(float64); ok {
+			// display matrix rotation is counter-clockwise
+			return ((-int(r) % 360) + 360) % 360
+		}
+	}
+	return 0
+}
+
+type probeChapter struct {
+	ID        int64             `json:"id"`
+	TimeBase  string            `json:"time_base"`
+	Start     int64             `json:"start"`
+	StartTime string            `json:"start_time"`
+	End       int64             `json:"end"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+type probeResult struct {
+	Format   probeFormat    `json:"format"`
+	Streams  []probeStream  `json:"streams"`
+	Chapters []probeChapter `json:"chapters,omitempty"`
+}
+
+// probeFile runs ffprobe on a local file and decodes its JSON report.
+func probeFile(ctx context.Context, path string) (*probeResult, error) {
+	cmd := exec.CommandContext(ctx, ffprobeBin(),
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", "-show_chapters", path)
+	out, err := cmd.Output()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("ffprobe failed: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	var p probeResult
+	if err := json.Unmarshal(out, &p); err != nil {
+		return nil, fmt.Errorf("ffprobe output: %w", err)
+	}
+	return &p, nil
+}
+
+func (p *probeResult) durationSec() float64 {
+	f, _ := strconv.ParseFloat(p.Format.Duration, 64)
+	return f
+}
+
+func (p *probeResult) bitRate() int64 {
+	n, _ := strconv.ParseInt(p.Format.BitRate, 10, 64)
+	return n
+}
+
+func (p *probeResult) sizeBytes() int64 {
+	n, _ := strconv.ParseInt(p.Format.Size, 10, 64)
+	return n
+}
+
+// firstStream returns the first stream of the given type (video|audio|subtitle|data).
+// Embedded cover art is not considered a video stream.
+func (p *probeResult) firstStream(codecType string) *probeStream {
+	for i := range p.Streams {
+		if p.Streams[i].CodecType == codecType && !p.Streams[i].isAttachedPic() {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+// attachedPic returns the first cover art stream, if any.
+func (p *probeResult) attachedPic() *probeStream {
+	for i := range p.Streams {
+		if p.Streams[i].isAttachedPic() {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+func (s *probeStream) isAttachedPic() bool {
+	return s.Disposition["attached_pic"] == 1
+}
+
+// isGIF reports an (animated) GIF input.
+func (p *probeResult) isGIF() bool {
+	if vs := p.firstStream("video"); vs != nil && vs.CodecName == "gif" {
+		return true
+	}
+	return p.Format.FormatName == "gif"
+}
+
+// frameRate parses avg_frame_rate ("30000/1001"); 0 if unknown.
+func (s *probeStream) frameRate() float64 {
+	if s == nil {
+		return 0
+	}
+	num, den, ok := strings.Cut(s.AvgFrameRate, "/")
+	n, _ := strconv.ParseFloat(num, 64)
+	if !ok {
+		return n
+	}
+	d, _ := strconv.ParseFloat(den, 64)
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
+
+// ======================
+// Probe endpoint (POST /probe)
+// ======================
+
+// POST /probe reports what a file is before a client picks compression
+// options: an uploaded "file", or a stored result by "id" (its output, or an
+// artifact such as original.mp4 with "artifact"). Nothing is encoded.
+
+type streamInfo struct {
+	Index       int     `json:"index"`
+	Type        string  `json:"type"`
+	Codec       string  `json:"codec"`
+	Profile     string  `json:"profile,omitempty"`
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	Resolution  string  `json:"resolution,omitempty"` // as displayed, after rotation
+	FPS         float64 `json:"fps,omitempty"`
+	PixFmt      string  `json:"pix_fmt,omitempty"`
+	FieldOrder  string  `json:"field_order,omitempty"`     // tt/bb/tb/bt = interlaced
+	Captions    bool    `json:"closed_captions,omitempty"` // CEA-608/708 in the video
+	Rotation    int     `json:"rotation,omitempty"`
+	BitRate     int64   `json:"bit_rate,omitempty"`
+	Channels    int     `json:"channels,omitempty"`
+	SampleRate  int     `json:"sample_rate,omitempty"`
+	Language    string  `json:"language,omitempty"`
+	Default     bool    `json:"default,omitempty"`
+	AttachedPic bool    `json:"attached_pic,omitempty"`
+}
+
+type mediaInfo struct {
+	Source      string       `json:"source"` // upload|result|input
+	ID          string       `json:"id,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Container   string       `json:"container"`
+	DurationSec float64      `json:"duration_sec"`
+	SizeBytes   int64        `json:"size_bytes"`
+	BitRate     int64        `json:"bit_rate"`
+	Video       *streamInfo  `json:"video,omitempty"` // first video stream (not cover art)
+	Audio       *streamInfo  `json:"audio,omitempty"` // first audio stream
+	Streams     []streamInfo `json:"streams"`
+	Chapters    int          `json:"chapters"`
+}
+
+func newStreamInfo(s *probeStream) streamInfo {
+	si := streamInfo{
+		Index: s.Index, Type: s.CodecType, Codec: s.CodecName, Profile: s.Profile,
+		Width: s.Width, Height: s.Height, PixFmt: s.PixFmt, FieldOrder: s.FieldOrder, Channels: s.Channels,
+		Language:    s.Tags["language"],
+		Default:     s.Disposition["default"] == 1,
+		AttachedPic: s.isAttachedPic(),
+		Captions:    s.ClosedCaptions > 0,
+	}
+	si.BitRate, _ = strconv.ParseInt(s.BitRate, 10, 64)
+	si.SampleRate, _ = strconv.Atoi(s.SampleRate)
+	if s.CodecType == "video" {
+		si.FPS = math.Round(s.frameRate()*1000) / 1000
+		si.Rotation = s.rotation()
+		w, h := s.Width, s.Height
+		if si.Rotation == 90 || si.Rotation == 270 {
+			w, h = h, w
+		}
+		if w > 0 && h > 0 {
+			si.Resolution = fmt.Sprintf("%dx%d", w, h)
+		}
+	}
+	return si
+}
+
+func newMediaInfo(p *probeResult) mediaInfo {
+	mi := mediaInfo{
+		Container:   p.Format.FormatName,
+		DurationSec: p.durationSec(),
+		SizeBytes:   p.sizeBytes(),
+		BitRate:     p.bitRate(),
+		Streams:     make([]streamInfo, 0, len(p.Streams)),
+		Chapters:    len(p.Chapters),
+	}
+	for i := range p.Streams {
+		mi.Streams = append(mi.Streams, newStreamInfo(&p.Streams[i]))
+	}
+	if vs := p.firstStream("video"); vs != nil {
+		si := newStreamInfo(vs)
+		mi.Video = &si
+	}
+	if as := p.firstStream("audio"); as != nil {
+		si := newStreamInfo(as)
+		mi.Audio = &si
+	}
+	return mi
+}
+
+// resultProbeTarget returns what ffprobe should read for a stored result: the
+// local file, or a presigned URL when only the shared bucket has it.
+func resultProbeTarget(e *resultEntry, artifact string) (string, bool) {
+	p := e.FilePath
+	if artifact != "" {
+		var ok bool
+		if p, ok = e.Artifacts[artifact]; !ok {
+			return "", false
+		}
+	}
+	if _, err := os.Stat(p); err == nil {
+		return p, true
+	}
+	bucket, key, ok := sharedObject(e, artifact)
+	if !ok {
+		return "", false
+	}
+	c, err := s3FromEnv()
+	if err != nil {
+		return "", false
+	}
+	return c.presignGet(bucket, key, 5*time.Minute, nil, time.Now()), true
+}
+
+// requestMedia resolves the media a /probe-style request names: an uploaded
+// "file", a stored result by "id" (plus optional "artifact"), or a retained
+// upload by "input_id" (keep_input=1). It answers
+// the request itself and returns ok=false on failure; cleanup removes the
+// upload.
+func requestMedia(w http.ResponseWriter, r *http.Request, requestID string) (target, source, name string, cleanup func(), ok bool) {
+	cleanup = func() {}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if !parseMediaUpload(w, r, requestID) {
+			return "", "", "", cleanup, false
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", "", "", cleanup, false
+	}
+
+	if id := r.FormValue("id"); id != "" {
+		e, found := getResult(id)
+		if !found {
+			http.NotFound(w, r)
+			return "", "", "", cleanup, false
+		}
+		artifact := r.FormValue("artifact")
+		if target, found = resultProbeTarget(e, artifact); !found {
+			http.NotFound(w, r)
+			return "", "", "", cleanup, false
+		}
+		name = e.downloadName()
+		if artifact != "" {
+			name = e.artifactDownloadName(artifact)
+		}
+		return target, "result", name, cleanup, true
+	}
+	if id := r.FormValue("input_id"); id != "" {
+		in, found := getInput(id, clientKey(r))
+		if !found {
+			http.NotFound(w, r)
+			return "", "", "", cleanup, false
+		}
+		return in.Path, "input", in.Name, cleanup, true
+	}
+	inPath, _, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file, id or input_id field required", http.StatusBadRequest)
+		return "", "", "", cleanup, false
+	}
+	if fh := r.MultipartForm.File["file"]; len(fh) > 0 {
+		name = filepath.Base(fh[0].Filename)
+	}
+	return inPath, "upload", name, func() { os.Remove(inPath) }, true
+}
+
+func probeHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🔎 [%s] New probe request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target, source, name, cleanup, ok := requestMedia(w, r, requestID)
+	if !ok {
+		return
+	}
+	defer cleanup()
+
+	src, err := probeFile(r.Context(), target)
+	if err != nil {
+		logger.Printf("❌ [%s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	mi := newMediaInfo(src)
+	mi.Source, mi.ID, mi.Name = source, r.FormValue("id"), name
+	if source == "input" {
+		mi.ID = r.FormValue("input_id")
+	}
+	logger.Printf("🔎 [%s] %s: %s, %.1fs, %d streams", requestID, mi.Source, mi.Container, mi.DurationSec, len(mi.Streams))
+	writeJSON(w, http.StatusOK, mi)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ======================
+// Named encoding profiles + mode overrides (export/import)
+// ======================
+
+const profilesVersion = 1
+
+// encodingProfile is a named set of /compress fields applied with
+// profile=<name>; fields sent with the request still win.
+type encodingProfile struct {
+	Description string            `json:"description,omitempty"`
+	Params      map[string]string `json:"params"`
+}
+
+// modeOverride replaces the built-in CRF/preset/audio bitrate of a speed mode.
+type modeOverride struct {
+	CRF    int    `json:"crf,omitempty"`
+	Preset string `json:"preset,omitempty"`
+	AB     string `json:"ab,omitempty"`
+}
+
+// profileSet is the document exchanged by GET/PUT /admin/profiles.
+type profileSet struct {
+	Version  int                        `json:"version"`
+	Profiles map[string]encodingProfile `json:"profiles"`
+	Modes    map[string]modeOverride    `json:"modes"`
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = profileSet{Version: profilesVersion, Profiles: map[string]encodingProfile{}, Modes: map[string]modeOverride{}}
+
+	profileNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+	x264Presets   = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+	bitrateRe     = regexp.MustCompile(`^\d+[kKmM]?$`)
+)
+
+func (ps *profileSet) validate() error {
+	if ps.Version != 0 && ps.Version != profilesVersion {
+		return fmt.Errorf("unsupported profiles version %d", ps.Version)
+	}
+	for name, p := range ps.Profiles {
+		if !profileNameRe.MatchString(name) {
+			return fmt.Errorf("invalid profile name %q", name)
+		}
+		if p.Params["profile"] != "" {
+			return fmt.Errorf("profile %q: profiles cannot reference other profiles", name)
+		}
+		if _, err := parseOptsWith(mapValue(p.Params), encodingProfile{}); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	for mode, ov := range ps.Modes {
+		if !slices.Contains(speedModes, mode) || mode == "ai" || mode == "lossless" {
+			return fmt.Errorf("mode override for unknown mode %q", mode)
+		}
+		if ov.CRF < 0 || ov.CRF > 51 {
+			return fmt.Errorf("mode %q: crf must be 0-51", mode)
+		}
+		if ov.Preset != "" && !slices.Contains(x264Presets, ov.Preset) {
+			return fmt.Errorf("mode %q: unknown preset %q", mode, ov.Preset)
+		}
+		if ov.AB != "" && !bitrateRe.MatchString(ov.AB) {
+			return fmt.Errorf("mode %q: invalid ab %q", mode, ov.AB)
+		}
+	}
+	return nil
+}
+
+func mapValue(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+// lookupProfile returns the named profile ("" = none).
+func lookupProfile(name string) (encodingProfile, error) {
+	if name == "" {
+		return encodingProfile{}, nil
+	}
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	p, ok := profiles.Profiles[name]
+	if !ok {
+		return p, fmt.Errorf("unknown profile %q", name)
+	}
+	return p, nil
+}
+
+func lookupModeOverride(mode string) (modeOverride, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	ov, ok := profiles.Modes[mode]
+	return ov, ok
+}
+
+func profileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, 0, len(profiles.Profiles))
+	for n := range profiles.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadProfiles reads PROFILES_FILE if it exists.
+func loadProfiles() error {
+	path := os.Getenv("PROFILES_FILE")
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var ps profileSet
+	if err := json.Unmarshal(data, &ps); err != nil {
+		return fmt.Errorf("profiles %s: %w", path, err)
+	}
+	return installProfiles(ps, false)
+}
+
+// installProfiles validates ps and replaces (or merges into) the active set,
+// saving it to PROFILES_FILE when configured.
+func installProfiles(ps profileSet, merge bool) error {
+	if err := ps.validate(); err != nil {
+		return err
+	}
+	profilesMu.Lock()
+	next := profileSet{Version: profilesVersion, Profiles: map[string]encodingProfile{}, Modes: map[string]modeOverride{}}
+	if merge {
+		for k, v := range profiles.Profiles {
+			next.Profiles[k] = v
+		}
+		for k, v := range profiles.Modes {
+			next.Modes[k] = v
+		}
+	}
+	for k, v := range ps.Profiles {
+		next.Profiles[k] = v
+	}
+	for k, v := range ps.Modes {
+		next.Modes[k] = v
+	}
+	profiles = next
+	profilesMu.Unlock()
+	return saveProfiles(next)
+}
+
+func saveProfiles(ps profileSet) error {
+	path := os.Getenv("PROFILES_FILE")
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(ps, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// adminProfilesHandler exports (GET) or imports (PUT replaces, POST merges)
+// the full profile set.
+func adminProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(6)
+	if !adminAuthorized(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		profilesMu.RLock()
+		data, _ := json.MarshalIndent(profiles, "", "  ")
+		profilesMu.RUnlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"videocompress-profiles.json\"")
+		_, _ = w.Write(data)
+	case http.MethodPut, http.MethodPost:
+		var ps profileSet
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&ps); err != nil {
+			http.Error(w, "invalid profiles JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		merge := r.Method == http.MethodPost
+		if err := ps.validate(); err != nil {
+			logger.Printf("❌ [%s] Profile import rejected: %v", requestID, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := installProfiles(ps, merge); err != nil {
+			logger.Printf("❌ [%s] Profile import failed: %v", requestID, err)
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		logger.Printf("📥 [%s] Imported %d profiles, %d mode overrides (merge=%t)", requestID, len(ps.Profiles), len(ps.Modes), merge)
+		writeJSON(w, http.StatusOK, map[string]any{"profiles": profileNames(), "merged": merge})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
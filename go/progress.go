@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Encode progress (ffmpeg -progress, GET /progress/{id})
+// ======================
+
+// When the context of an encode carries a progress meter (jobs: async=1,
+// detach_on_disconnect=1 and gRPC), runFFmpeg adds -progress pipe:1 and
+// parses the key=value blocks ffmpeg writes to stdout about twice a second.
+// The percentage compares out_time with the probed duration of the part
+// being encoded, so it needs ffprobe; without it only frame, out_time and
+// speed are known.
+
+type encodeProgress struct {
+	Frame      int64   `json:"frame"`
+	FPS        float64 `json:"fps"`
+	Bitrate    string  `json:"bitrate,omitempty"` // as ffmpeg prints it, e.g. "1534.2kbits/s"
+	OutTimeSec float64 `json:"out_time_sec"`
+	Speed      float64 `json:"speed"`             // multiple of real time
+	Percent    float64 `json:"percent,omitempty"` // 0 when the duration is unknown
+	ETASec     float64 `json:"eta_sec,omitempty"`
+}
+
+// progressMeter forwards the progress of the encodes of one job. A
+// segmented encode narrows it to the span each segment covers.
+type progressMeter struct {
+	report func(encodeProgress)
+	offset float64 // seconds of the job done before this encode
+	total  float64 // seconds the whole job encodes (0 = this encode's own length)
+}
+
+type progressCtxKey struct{}
+
+// withProgress reports the progress of the encodes run under ctx to report
+// (nil turns reporting off, e.g. for parallel race candidates).
+func withProgress(ctx context.Context, report func(encodeProgress)) context.Context {
+	return context.WithValue(ctx, progressCtxKey{}, &progressMeter{report: report})
+}
+
+// withProgressSpan places the next encode at offset within a job of total
+// seconds.
+func withProgressSpan(ctx context.Context, offset, total float64) context.Context {
+	m := progressOf(ctx)
+	if m == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressCtxKey{}, &progressMeter{report: m.report, offset: offset, total: total})
+}
+
+func progressOf(ctx context.Context) *progressMeter {
+	m, _ := ctx.Value(progressCtxKey{}).(*progressMeter)
+	if m == nil || m.report == nil {
+		return nil
+	}
+	return m
+}
+
+// expectedSec is the length of the encode of o: the probed duration, less
+// any trim. 0 when unknown.
+func (o *compressOpts) expectedSec() float64 {
+	end := o.TrimEnd
+	if end == 0 && o.Source != nil {
+		end = o.Source.durationSec()
+	}
+	return math.Max(end-o.TrimStart, 0)
+}
+
+// withProgressArgs makes ffmpeg write progress to stdout when ctx has a
+// meter.
+func withProgressArgs(ctx context.Context, args []string) []string {
+	if progressOf(ctx) == nil {
+		return args
+	}
+	return append([]string{"-progress", "pipe:1", "-nostats"}, args...)
+}
+
+// progressOut is the stdout of an encode of o: the progress parser when ctx
+// has a meter, else def.
+func progressOut(ctx context.Context, o *compressOpts, def io.Writer) io.Writer {
+	if m := progressOf(ctx); m != nil {
+		return newProgressWriter(m, o)
+	}
+	return def
+}
+
+// progressWriter parses ffmpeg -progress output written to it.
+type progressWriter struct {
+	m     *progressMeter
+	total float64
+	buf   []byte
+	cur   encodeProgress
+}
+
+func newProgressWriter(m *progressMeter, o *compressOpts) *progressWriter {
+	total := m.total
+	if total == 0 {
+		total = o.expectedSec()
+	}
+	return &progressWriter{m: m, total: total}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(strings.TrimSpace(string(w.buf[:i])))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) line(s string) {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return
+	}
+	switch k {
+	case "frame":
+		w.cur.Frame, _ = strconv.ParseInt(v, 10, 64)
+	case "fps":
+		w.cur.FPS, _ = strconv.ParseFloat(v, 64)
+	case "bitrate":
+		if v != "N/A" {
+			w.cur.Bitrate = v
+		}
+	case "out_time_us", "out_time_ms": // both are microseconds
+		if us, err := strconv.ParseInt(v, 10, 64); err == nil && us >= 0 {
+			w.cur.OutTimeSec = float64(us) / 1e6
+		}
+	case "speed":
+		w.cur.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(v, "x"), 64)
+	case "progress": // ends a block: "continue" or "end"
+		p := w.cur
+		p.OutTimeSec = math.Round(p.OutTimeSec*100) / 100
+		if w.total > 0 {
+			done := w.m.offset + w.cur.OutTimeSec
+			p.Percent = math.Round(math.Min(done/w.total*100, 99.9)*10) / 10
+			if p.Speed > 0 {
+				p.ETASec = math.Round(math.Max(w.total-done, 0) / p.Speed)
+			}
+		}
+		w.m.report(p)
+	}
+}
+
+// progressHandler serves GET /progress/{id}, the latest encode progress of
+// a job.
+func progressHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	j, ok := getJob(strings.Trim(strings.TrimPrefix(r.URL.Path, "/progress"), "/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	snap, _ := j.snapshot()
+	v := map[string]any{
+		"id":      snap.ID,
+		"state":   snap.State,
+		"percent": snap.Progress,
+	}
+	if snap.Encode != nil {
+		v["frame"] = snap.Encode.Frame
+		v["fps"] = snap.Encode.FPS
+		v["bitrate"] = snap.Encode.Bitrate
+		v["out_time_sec"] = snap.Encode.OutTimeSec
+		v["speed"] = snap.Encode.Speed
+		if snap.State == jobRunning && snap.Encode.ETASec > 0 {
+			v["eta_sec"] = snap.Encode.ETASec
+		}
+	}
+	writeJSON(w, http.StatusOK, v)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+)
+
+// ======================
+// Objective quality metrics (SSIM, PSNR, VMAF)
+// ======================
+
+// qualityMetrics maps a metric name to its lavfi filter and the regexp that
+// pulls the overall score out of ffmpeg's log.
+var qualityMetrics = map[string]struct {
+	filter string
+	score  *regexp.Regexp
+}{
+	"ssim": {"ssim", regexp.MustCompile(`SSIM .*All:([\d.]+)`)},
+	"psnr": {"psnr", regexp.MustCompile(`PSNR .*average:([\d.]+|inf)`)},
+	"vmaf": {"libvmaf", regexp.MustCompile(`VMAF score:\s*([\d.]+)`)},
+}
+
+func validMetric(m string) error {
+	if _, ok := qualityMetrics[m]; !ok {
+		return fmt.Errorf("invalid metric %q (ssim|psnr|vmaf)", m)
+	}
+	return nil
+}
+
+// measureQuality compares distorted against reference, scaling the
+// distorted video to the reference size first (lower ladder rungs are
+// judged as viewers see them: upscaled to the display).
+func measureQuality(ctx context.Context, distorted, reference, metric string) (float64, error) {
+	m, ok := qualityMetrics[metric]
+	if !ok {
+		return 0, validMetric(metric)
+	}
+	graph := "[0:v][1:v]scale2ref=flags=bicubic[d][r];[d][r]" + m.filter
+	args := []string{"-hide_banner", "-nostats",
+		"-i", distorted, "-i", reference, "-lavfi", graph, "-f", "null", "-"}
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+	out, err := cmd.CombinedOutput()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %s", metric, lastLine(string(out)))
+	}
+	sm := m.score.FindAllSubmatch(out, -1)
+	if len(sm) == 0 {
+		return 0, fmt.Errorf("%s: no score in ffmpeg output", metric)
+	}
+	s := string(sm[len(sm)-1][1])
+	if s == "inf" {
+		return 100, nil // identical frames (PSNR)
+	}
+	return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ======================
+// Queue depth (GET /queue)
+// ======================
+
+// Every job request (HTTP uploads behind limitClient, gRPC Compress) holds a
+// ticket from the moment it asks for a job slot until it finishes, so the
+// queue shows both jobs waiting for a slot and jobs encoding. /queue turns
+// that into numbers an autoscaler can act on; the drain estimate uses a
+// moving average of recent job durations and assumes the current number of
+// running jobs stays the parallelism.
+
+// queueAvgWeight is the weight of the newest job in the moving average.
+const queueAvgWeight = 0.2
+
+type queueTicket struct {
+	queued  time.Time
+	started time.Time
+}
+
+type jobQueue struct {
+	mu      sync.Mutex
+	tickets map[*queueTicket]struct{}
+	avgSec  float64 // moving average of job durations (0 = no job finished yet)
+	done    int64
+}
+
+var queue = &jobQueue{tickets: map[*queueTicket]struct{}{}}
+
+// enter registers a job that is about to wait for a slot.
+func (q *jobQueue) enter() *queueTicket {
+	t := &queueTicket{queued: time.Now()}
+	q.mu.Lock()
+	q.tickets[t] = struct{}{}
+	q.mu.Unlock()
+	return t
+}
+
+// run marks the job as holding a slot.
+func (q *jobQueue) run(t *queueTicket) {
+	q.mu.Lock()
+	t.started = time.Now()
+	q.mu.Unlock()
+}
+
+// leave removes the job; jobs that ran feed the duration average.
+func (q *jobQueue) leave(t *queueTicket) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.tickets, t)
+	if t.started.IsZero() {
+		return
+	}
+	sec := time.Since(t.started).Seconds()
+	if q.done == 0 {
+		q.avgSec = sec
+	} else {
+		q.avgSec += queueAvgWeight * (sec - q.avgSec)
+	}
+	q.done++
+}
+
+// depth is the number of jobs queued or running.
+func (q *jobQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.tickets)
+}
+
+type queueStats struct {
+	Depth             int      `json:"depth"`
+	Queued            int      `json:"queued"`
+	Running           int      `json:"running"`
+	OldestJobAgeSec   float64  `json:"oldest_job_age_sec"`
+	OldestQueuedSec   float64  `json:"oldest_queued_sec"`
+	AvgJobSec         float64  `json:"avg_job_sec"`
+	JobsFinished      int64    `json:"jobs_finished"`
+	EstimatedDrainSec *float64 `json:"estimated_drain_sec"` // null until a job has finished
+	Draining          bool     `json:"draining"`
+}
+
+func (q *jobQueue) stats() queueStats {
+	now := time.Now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := queueStats{Depth: len(q.tickets), JobsFinished: q.done, Draining: draining.Load()}
+	for t := range q.tickets {
+		age := now.Sub(t.queued).Seconds()
+		s.OldestJobAgeSec = math.Max(s.OldestJobAgeSec, age)
+		if t.started.IsZero() {
+			s.Queued++
+			s.OldestQueuedSec = math.Max(s.OldestQueuedSec, age)
+		} else {
+			s.Running++
+		}
+	}
+	if q.done > 0 {
+		s.AvgJobSec = math.Round(q.avgSec*10) / 10
+		est := q.avgSec * float64(s.Depth) / math.Max(float64(s.Running), 1)
+		est = math.Round(est)
+		s.EstimatedDrainSec = &est
+	}
+	s.OldestJobAgeSec = math.Round(s.OldestJobAgeSec*10) / 10
+	s.OldestQueuedSec = math.Round(s.OldestQueuedSec*10) / 10
+	return s
+}
+
+func queueHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, queue.stats())
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Candidate encode race (race=[...])
+// ======================
+
+// A race runs 2-3 variants of the encode at once (e.g. VideoToolbox vs
+// libx264, or two CRFs) and keeps the one that best meets race_goal:
+//
+//	smallest  smallest output meeting race_min_score / race_max_bytes (default)
+//	quality   best score within race_max_bytes
+//	fastest   first candidate that meets the constraints; the others are cancelled
+
+const (
+	maxRaceCandidates = 3
+	raceMetric        = "ssim"
+)
+
+// raceCandidate overrides a few encoder settings of the request.
+type raceCandidate map[string]string
+
+var raceKeys = []string{"crf", "preset", "hw", "hwdecode"}
+
+type raceSpec struct {
+	Candidates []raceCandidate
+	Goal       string  // smallest|quality|fastest
+	MinScore   float64 // SSIM floor (0 = none)
+	MaxBytes   int64   // size ceiling (0 = none)
+}
+
+// raceResult is one candidate's outcome, reported in /meta.
+type raceResult struct {
+	Candidate raceCandidate `json:"candidate"`
+	Bytes     int64         `json:"bytes,omitempty"`
+	Score     float64       `json:"score,omitempty"`
+	ElapsedMs int64         `json:"elapsed_ms,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Winner    bool          `json:"winner,omitempty"`
+
+	path string
+	ok   bool // met the constraints
+}
+
+func parseRace(s, goal, minScore, maxBytes string) (*raceSpec, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var raw []map[string]any
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return nil, errors.New(`race must be a JSON array like [{"crf":24},{"crf":28}]`)
+	}
+	if len(raw) < 2 || len(raw) > maxRaceCandidates {
+		return nil, fmt.Errorf("race needs 2-%d candidates", maxRaceCandidates)
+	}
+	var rs raceSpec
+	for i, m := range raw {
+		c := raceCandidate{}
+		for k, v := range m {
+			switch v := v.(type) {
+			case string:
+				c[k] = v
+			case float64:
+				c[k] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return nil, fmt.Errorf("race[%d]: %s must be a string or number", i, k)
+			}
+		}
+		rs.Candidates = append(rs.Candidates, c)
+		for k, v := range c {
+			if !slices.Contains(raceKeys, k) {
+				return nil, fmt.Errorf("race[%d]: unsupported key %q (%s)", i, k, strings.Join(raceKeys, "|"))
+			}
+			if k == "crf" {
+				if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 51 {
+					return nil, fmt.Errorf("race[%d]: invalid crf %q", i, v)
+				}
+			}
+			if k == "preset" && !slices.Contains(x264Presets, v) {
+				return nil, fmt.Errorf("race[%d]: unknown preset %q", i, v)
+			}
+			if k == "hw" || k == "hwdecode" {
+				if err := checkRaceHW(k, v); err != nil {
+					return nil, fmt.Errorf("race[%d]: %w", i, err)
+				}
+			}
+		}
+	}
+	rs.Goal = goal
+	if rs.Goal == "" {
+		rs.Goal = "smallest"
+	}
+	if rs.Goal != "smallest" && rs.Goal != "quality" && rs.Goal != "fastest" {
+		return nil, fmt.Errorf("invalid race_goal %q (smallest|quality|fastest)", goal)
+	}
+	if minScore != "" {
+		v, err := strconv.ParseFloat(minScore, 64)
+		if err != nil || v <= 0 || v > 1 {
+			return nil, fmt.Errorf("invalid race_min_score %q (SSIM 0-1)", minScore)
+		}
+		rs.MinScore = v
+	}
+	if maxBytes != "" {
+		v, err := strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid race_max_bytes %q", maxBytes)
+		}
+		rs.MaxBytes = v
+	}
+	return &rs, nil
+}
+
+// checkRaceHW rejects hw/hwdecode values this server cannot run, so a bad
+// candidate is refused up front instead of failing its encode. Before
+// ffmpeg was detected only the value itself is checked.
+func checkRaceHW(k, v string) error {
+	info := currentFFmpeg()
+	detected := info.Version != ""
+	v = strings.ToLower(v)
+	switch {
+	case k == "hw" && v == "none", k == "hwdecode" && (v == "none" || v == "auto"):
+		return nil
+	case k == "hw" && v == "videotoolbox":
+		if detected && !slices.Contains(info.Encoders, "h264_videotoolbox") && !slices.Contains(info.Encoders, "hevc_videotoolbox") {
+			return errors.New("hw=videotoolbox is not available on this server")
+		}
+		return nil
+	case k == "hwdecode" && slices.Contains([]string{"videotoolbox", "cuda", "vaapi", "qsv"}, v):
+		if detected && !info.hasHWAccel(v) {
+			return fmt.Errorf("hwdecode=%s is not available on this server", v)
+		}
+		return nil
+	case k == "hw":
+		return fmt.Errorf("invalid hw %q (none|videotoolbox)", v)
+	}
+	return fmt.Errorf("invalid hwdecode %q (none|auto|videotoolbox|cuda|vaapi|qsv)", v)
+}
+
+func (c raceCandidate) String() string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+c[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// apply sets the candidate's overrides on a copy of the final options.
+func (c raceCandidate) apply(o compressOpts) compressOpts {
+	if v, ok := c["crf"]; ok {
+		o.CRF, _ = strconv.Atoi(v)
+	}
+	if v, ok := c["preset"]; ok {
+		o.Preset = v
+	}
+	if v, ok := c["hw"]; ok {
+		o.HW = v
+		if _, dec := c["hwdecode"]; !dec {
+			o.HWDecode = "" // re-derive from the new hw
+		}
+	}
+	if v, ok := c["hwdecode"]; ok {
+		o.HWDecode = v
+	}
+	return o
+}
+
+// raceEncode runs the candidates concurrently and moves the winner to
+// outPath. It fails only if no candidate produced an output.
+func raceEncode(ctx context.Context, requestID, inPath, outPath string, opts compressOpts) ([]raceResult, error) {
+	rs := opts.Race
+	needScore := rs.Goal == "quality" || rs.MinScore > 0
+	ctx, cancel := context.WithCancel(withProgress(ctx, nil)) // candidates run side by side
+	defer cancel()
+
+	results := make([]raceResult, len(rs.Candidates))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		finished = -1 // fastest: first candidate that met the constraints
+	)
+	for i, c := range rs.Candidates {
+		results[i] = raceResult{Candidate: c, path: withExt(outPath, "_race"+strconv.Itoa(i)+filepath.Ext(outPath))}
+		wg.Add(1)
+		go func(i int, c raceCandidate) {
+			defer wg.Done()
+			res := &results[i]
+			start := time.Now()
+			logger.Printf("🏁 [%s] Race candidate %d (%s) started", requestID, i, c)
+			if err := runFFmpeg(ctx, inPath, res.path, c.apply(opts), io.Discard); err != nil {
+				res.Error = err.Error()
+				os.Remove(res.path)
+				return
+			}
+			res.ElapsedMs = time.Since(start).Milliseconds()
+			if st, err := os.Stat(res.path); err == nil {
+				res.Bytes = st.Size()
+			}
+			if needScore {
+				s, err := measureQuality(ctx, res.path, inPath, raceMetric)
+				if err != nil {
+					res.Error = "score: " + err.Error()
+					return
+				}
+				res.Score = s
+			}
+			res.ok = res.Bytes > 0 && (rs.MaxBytes == 0 || res.Bytes <= rs.MaxBytes) &&
+				(rs.MinScore == 0 || res.Score >= rs.MinScore)
+			logger.Printf("🏁 [%s] Race candidate %d done: %s, %s %.4f, %d ms (meets goal: %t)",
+				requestID, i, humanBytes(res.Bytes), raceMetric, res.Score, res.ElapsedMs, res.ok)
+			if rs.Goal == "fastest" && res.ok {
+				mu.Lock()
+				if finished < 0 {
+					finished = i
+					cancel() // stop the others
+				}
+				mu.Unlock()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	win := finished
+	if win < 0 {
+		win = pickRaceWinner(results, rs.Goal)
+	}
+	for i := range results {
+		if i != win {
+			os.Remove(results[i].path)
+			if finished >= 0 && results[i].Error != "" {
+				results[i].Error = "cancelled"
+			}
+		}
+	}
+	if win < 0 {
+		return results, errors.New("every race candidate failed: " + results[0].Error)
+	}
+	results[win].Winner = true
+	if err := os.Rename(results[win].path, outPath); err != nil {
+		return results, err
+	}
+	logger.Printf("🏆 [%s] Race won by candidate %d (%s)", requestID, win, results[win].Candidate)
+	return results, nil
+}
+
+// pickRaceWinner prefers candidates that met the constraints; among those
+// (or, failing that, all that produced output) it picks by goal.
+func pickRaceWinner(results []raceResult, goal string) int {
+	better := func(a, b raceResult) bool {
+		if goal == "quality" {
+			return a.Score > b.Score || (a.Score == b.Score && a.Bytes < b.Bytes)
+		}
+		return a.Bytes < b.Bytes // smallest; also the fallback for fastest
+	}
+	win := -1
+	for pass := 0; pass < 2 && win < 0; pass++ {
+		for i, r := range results {
+			if r.Bytes == 0 || r.Error != "" || (pass == 0 && !r.ok) {
+				continue
+			}
+			if win < 0 || better(r, results[win]) {
+				win = i
+			}
+		}
+	}
+	return win
+}
+
+// raceNote summarizes the race for the warnings list.
+func raceNote(results []raceResult, rs *raceSpec) string {
+	var parts []string
+	for i, r := range results {
+		s := fmt.Sprintf("#%d %s: ", i, r.Candidate)
+		switch {
+		case r.Error != "":
+			s += r.Error
+		default:
+			s += humanBytes(r.Bytes)
+			if r.Score > 0 {
+				s += fmt.Sprintf(" %s %.4f", raceMetric, r.Score)
+			}
+		}
+		if r.Winner {
+			s += " (winner)"
+			if !r.ok && (rs.MinScore > 0 || rs.MaxBytes > 0) {
+				s += " — no candidate met the constraints"
+			}
+		}
+		parts = append(parts, s)
+	}
+	return "race (" + rs.Goal + "): " + strings.Join(parts, "; ")
+}
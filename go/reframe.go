@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Reframing to another aspect ratio (reframe=9:16)
+// ======================
+
+// reframe=9:16 turns landscape video into Stories/Shorts-ready vertical video
+// by cropping the full height to the target aspect. The window sits at
+// reframe_x (0 = left edge, 0.5 = centre, 1 = right edge), or with
+// reframe_x=auto follows the motion: frames sampled across the video are
+// differenced, the moving region's centre is found with cropdetect, and the
+// window pans linearly between those points.
+
+const (
+	reframeSampleEvery = 3.0 // seconds between motion samples
+	reframeMaxSamples  = 20
+	reframeFramesEach  = 8
+)
+
+type reframeSpec struct {
+	W, H  int     // target aspect, e.g. 9:16
+	X     float64 // window position 0..1 (ignored when Auto)
+	Auto  bool    // follow motion
+	Track []reframePoint
+}
+
+// reframePoint places the window at X (0..1) at source time T.
+type reframePoint struct {
+	T, X float64
+}
+
+func parseReframe(ratio, x, codec string) (*reframeSpec, error) {
+	if ratio == "" {
+		if x != "" {
+			return nil, errors.New("reframe_x needs reframe")
+		}
+		return nil, nil
+	}
+	ws, hs, ok := strings.Cut(ratio, ":")
+	w, err1 := strconv.Atoi(ws)
+	h, err2 := strconv.Atoi(hs)
+	if !ok || err1 != nil || err2 != nil || w < 1 || h < 1 || w > 100 || h > 100 {
+		return nil, fmt.Errorf("invalid reframe %q (an aspect ratio like 9:16)", ratio)
+	}
+	if strings.ToLower(codec) == "copy" {
+		return nil, errors.New("reframe needs a video re-encode (codec=copy)")
+	}
+	rf := &reframeSpec{W: w, H: h, X: 0.5}
+	switch x {
+	case "", "center", "centre":
+	case "auto":
+		rf.Auto = true
+	default:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("invalid reframe_x %q (0-1 or auto)", x)
+		}
+		rf.X = f
+	}
+	return rf, nil
+}
+
+// filter crops the largest rf.W:rf.H window out of the frame. Track times are
+// source times; the encode's clock starts at trimStart.
+func (rf *reframeSpec) filter(trimStart float64) string {
+	x := strconv.FormatFloat(rf.X, 'f', 3, 64)
+	if len(rf.Track) > 0 {
+		x = rf.trackExpr(trimStart)
+	}
+	return fmt.Sprintf("crop=w='trunc(min(iw,ih*%d/%d)/2)*2':h='trunc(min(ih,iw*%d/%d)/2)*2':x='(iw-ow)*(%s)':y='(ih-oh)/2'",
+		rf.W, rf.H, rf.H, rf.W, x)
+}
+
+// trackExpr interpolates the window position between track points.
+func (rf *reframeSpec) trackExpr(trimStart float64) string {
+	pts := rf.Track
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	expr := f(pts[len(pts)-1].X)
+	for i := len(pts) - 2; i >= 0; i-- {
+		a, b := pts[i], pts[i+1]
+		ta, tb := a.T-trimStart, b.T-trimStart
+		lerp := fmt.Sprintf("%s+(%s)*(t-%s)/%s", f(a.X), f(b.X-a.X), f(ta), f(math.Max(tb-ta, 0.001)))
+		expr = fmt.Sprintf("if(lt(t,%s),%s,%s)", f(tb), lerp, expr)
+	}
+	return fmt.Sprintf("if(lt(t,%s),%s,%s)", f(pts[0].T-trimStart), f(pts[0].X), expr)
+}
+
+// scale maps a resolution's landscape W:H (e.g. 1280:720) onto the target
+// aspect, keeping its short edge: 720p at 9:16 is 720x1280.
+func (rf *reframeSpec) scale(s string) string {
+	ws, hs, ok := strings.Cut(s, ":")
+	w, _ := strconv.Atoi(ws)
+	h, _ := strconv.Atoi(hs)
+	if !ok || w <= 0 || h <= 0 {
+		return s
+	}
+	short := min(w, h)
+	even := func(v float64) int { return max(2, int(math.Round(v/2))*2) }
+	if rf.W < rf.H {
+		return fmt.Sprintf("%d:%d", short, even(float64(short)*float64(rf.H)/float64(rf.W)))
+	}
+	return fmt.Sprintf("%d:%d", even(float64(short)*float64(rf.W)/float64(rf.H)), short)
+}
+
+// trackMotion samples the source and returns where the window should sit
+// over time for reframe_x=auto. Samples without motion keep the previous
+// position; a source with no motion anywhere stays centred.
+func trackMotion(ctx context.Context, inPath string, src *probeResult, rf *reframeSpec, preserveCapture bool) ([]reframePoint, error) {
+	if src == nil {
+		return nil, errors.New("source not probed")
+	}
+	vs := src.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return nil, errors.New("no video stream")
+	}
+	dur := src.durationSec()
+	if dur <= 0 {
+		return nil, errors.New("source duration unknown")
+	}
+	noAutorotate := preserveCapture && vs.rotation() != 0
+	frameW, frameH := vs.Width, vs.Height
+	if r := vs.rotation(); !noAutorotate && (r == 90 || r == 270) {
+		frameW, frameH = frameH, frameW
+	}
+	cropW := math.Min(float64(frameW), float64(frameH)*float64(rf.W)/float64(rf.H))
+	slack := float64(frameW) - cropW
+	if slack < 2 {
+		return nil, nil // the window spans the full width: nothing to pan
+	}
+
+	n := min(reframeMaxSamples, max(2, int(dur/reframeSampleEvery)))
+	var pts []reframePoint
+	prev := 0.5
+	for i := 0; i < n; i++ {
+		t := dur * (float64(i) + 0.5) / float64(n)
+		args := []string{"-hide_banner", "-nostats"}
+		if noAutorotate {
+			args = append(args, "-noautorotate")
+		}
+		args = append(args, "-ss", strconv.FormatFloat(t, 'f', 2, 64), "-i", inPath, "-map", "0:V:0",
+			"-frames:v", strconv.Itoa(reframeFramesEach+1),
+			"-vf", "tblend=all_mode=difference,cropdetect=limit=24:round=2:reset=1", "-f", "null", "-")
+		cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+		out, err := cmd.CombinedOutput()
+		recordUsage(ctx, cmd.ProcessState)
+		if err != nil {
+			return nil, fmt.Errorf("motion sample: %w", err)
+		}
+		var centres []float64
+		for _, m := range cropDetectRe.FindAllSubmatch(out, -1) {
+			w, _ := strconv.Atoi(string(m[1]))
+			x, _ := strconv.Atoi(string(m[3]))
+			if w <= 0 || float64(w) > 0.9*float64(frameW) {
+				continue // no motion, or everything moves (a cut or camera pan)
+			}
+			centres = append(centres, float64(x)+float64(w)/2)
+		}
+		if len(centres) > 0 {
+			sort.Float64s(centres)
+			cx := centres[len(centres)/2]
+			prev = math.Max(0, math.Min(1, (cx-cropW/2)/slack))
+		}
+		pts = append(pts, reframePoint{T: t, X: prev})
+	}
+	return pts, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import "strings"
+
+// ======================
+// Stream-copy bitstream filters
+// ======================
+
+// isAnnexBContainer reports whether the container carries H.264/HEVC as
+// Annex-B start codes and AAC as ADTS (MPEG-TS, raw elementary streams).
+func isAnnexBContainer(formatName string) bool {
+	for _, f := range strings.Split(formatName, ",") {
+		switch f {
+		case "mpegts", "h264", "hevc", "aac", "mpegtsraw":
+			return true
+		}
+	}
+	return false
+}
+
+// copyBitstreamArgs returns extra input and output arguments needed when
+// stream-copying between container families; plain -c copy fails or
+// produces unplayable files for these combinations.
+func copyBitstreamArgs(o compressOpts) (inArgs, outArgs []string) {
+	if o.Source == nil {
+		return nil, nil
+	}
+	srcAnnexB := isAnnexBContainer(o.Source.Format.FormatName)
+	dstExt := strings.ToLower(o.OutExt)
+	dstAnnexB := dstExt == ".ts" || dstExt == ".m2ts"
+
+	if strings.ToLower(o.Codec) == "copy" {
+		if vs := o.Source.firstStream("video"); vs != nil {
+			switch {
+			case srcAnnexB && !dstAnnexB:
+				// raw elementary streams have no timestamps
+				if f := o.Source.Format.FormatName; f == "h264" || f == "hevc" {
+					inArgs = append(inArgs, "-fflags", "+genpts")
+				}
+			case !srcAnnexB && dstAnnexB:
+				switch vs.CodecName {
+				case "h264":
+					outArgs = append(outArgs, "-bsf:v", "h264_mp4toannexb")
+				case "hevc":
+					outArgs = append(outArgs, "-bsf:v", "hevc_mp4toannexb")
+				}
+			}
+		}
+	}
+
+	if strings.ToLower(o.Audio) == "copy" {
+		if as := o.Source.firstStream("audio"); as != nil && as.CodecName == "aac" && srcAnnexB && !dstAnnexB {
+			outArgs = append(outArgs, "-bsf:a", "aac_adtstoasc")
+		}
+	}
+	return inArgs, outArgs
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Multi-rendition (ABR ladder) encoding (renditions=1080p,720p,480p)
+// ======================
+
+// renditions encodes the upload once per listed height in one job, for
+// adaptive streaming. The highest rendition is the job's main encode (so
+// verification, race and the rest apply to it); the others follow from the
+// source one after another with the same settings at their own size. Heights
+// are the short edge, so portrait sources get 720x1280 for 720p, and
+// renditions above the source's size are dropped rather than upscaled. Each
+// rendition's bitrate is capped for its height (renditionMaxKbps) so players
+// can switch between them predictably.
+//
+// With format=hls|dash the renditions are packaged together (an HLS master
+// playlist or one DASH adaptation set, keyframes aligned at segment
+// boundaries); otherwise the result is a ZIP of <stem>_<name><ext> files.
+// Either way X-Renditions and "renditions" in the metadata describe each one.
+
+const renditionsMax = 6
+
+// renditionMaxKbps caps each height's video bitrate (the nearest height at
+// or below applies).
+var renditionMaxKbps = []struct{ Height, Kbps int }{
+	{2160, 16000}, {1440, 9000}, {1080, 5000}, {720, 2800}, {540, 1800}, {480, 1400}, {360, 800}, {240, 400},
+}
+
+type rendition struct {
+	Name   string // 720p
+	Height int    // short edge
+	Scale  string // scale filter size, set by settleRenditions
+}
+
+// renditionInfo describes one encoded rendition.
+type renditionInfo struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Kbps   int64  `json:"kbps"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+	File   string `json:"file,omitempty"` // name in the ZIP
+
+	path string
+}
+
+func parseRenditions(s string, o compressOpts) ([]rendition, error) {
+	if s == "" {
+		return nil, nil
+	}
+	heights, err := parseIntList(s, 144, 4320)
+	if err != nil {
+		return nil, fmt.Errorf("renditions: %w", err)
+	}
+	slices.Sort(heights)
+	slices.Reverse(heights)
+	heights = slices.Compact(heights)
+	if len(heights) < 2 || len(heights) > renditionsMax {
+		return nil, fmt.Errorf("renditions needs 2-%d different heights (e.g. 1080p,720p,480p)", renditionsMax)
+	}
+	if strings.EqualFold(o.Codec, "copy") {
+		return nil, errors.New("renditions needs a video re-encode (codec=copy)")
+	}
+	var bad []string
+	switch o.SpeedMode {
+	case "turbo", "max", "proxy", "screen":
+		bad = append(bad, "speed="+o.SpeedMode)
+	}
+	for _, c := range []struct {
+		set  bool
+		name string
+	}{
+		{o.Resolution != "" && o.Resolution != "original", "resolution"},
+		{o.MaxLandscape != (resCap{}) || o.MaxPortrait != (resCap{}), "max_landscape/max_portrait"},
+		{o.Reframe != nil, "reframe"},
+		{o.Crop != "" || o.quarterTurn(), "crop/rotate=90|270"},
+		{o.Race != nil, "race"},
+		{o.AudioOut != nil, "output=audio"},
+		{o.SplitMaxBytes > 0 || o.SplitMaxSec > 0, "segment_max_size/segment_max_sec"},
+		{o.Interlace != "", "interlace"},
+	} {
+		if c.set {
+			bad = append(bad, c.name)
+		}
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("renditions cannot be combined with %s", strings.Join(bad, ", "))
+	}
+	out := make([]rendition, len(heights))
+	for i, h := range heights {
+		out[i] = rendition{Name: strconv.Itoa(h) + "p", Height: h}
+	}
+	return out, nil
+}
+
+// settleRenditions drops renditions above the source's size, sizes the
+// scale filter of each for the source's orientation and makes the highest
+// one the main encode. It returns notes for the warnings.
+func (o *compressOpts) settleRenditions() []string {
+	if len(o.Renditions) == 0 {
+		return nil
+	}
+	var notes []string
+	portrait := false
+	if vs := o.Source.firstStream("video"); vs != nil && vs.Width > 0 && vs.Height > 0 {
+		w, h := vs.Width, vs.Height
+		if r := vs.rotation(); r == 90 || r == 270 {
+			w, h = h, w
+		}
+		portrait = h > w
+		short := min(w, h)
+		var kept, dropped []string
+		rs := o.Renditions[:0]
+		for _, r := range o.Renditions {
+			if r.Height > short {
+				dropped = append(dropped, r.Name)
+				continue
+			}
+			rs = append(rs, r)
+			kept = append(kept, r.Name)
+		}
+		if len(rs) == 0 {
+			rs = append(rs, rendition{Name: strconv.Itoa(short) + "p", Height: short})
+		}
+		if len(dropped) > 0 {
+			notes = append(notes, fmt.Sprintf("renditions %s skipped: the source is only %dp", strings.Join(dropped, ", "), short))
+		}
+		o.Renditions = rs
+	}
+	for i := range o.Renditions {
+		r := &o.Renditions[i]
+		r.Scale = "-2:" + strconv.Itoa(r.Height)
+		if portrait {
+			r.Scale = strconv.Itoa(r.Height) + ":-2"
+		}
+	}
+	o.Scale, o.Resolution = o.Renditions[0].Scale, o.Renditions[0].Name
+	return notes
+}
+
+func renditionKbps(height int) int {
+	for _, c := range renditionMaxKbps {
+		if height >= c.Height {
+			return c.Kbps
+		}
+	}
+	return renditionMaxKbps[len(renditionMaxKbps)-1].Kbps
+}
+
+// renditionArgs caps the bitrate of the rendition being encoded (the first
+// of o.Renditions).
+func renditionArgs(o compressOpts, vcodec string) []string {
+	if len(o.Renditions) == 0 {
+		return nil
+	}
+	switch vcodec {
+	case "libx264", "libx265", "libvpx-vp9":
+		kbps := renditionKbps(o.Renditions[0].Height)
+		return []string{"-maxrate", strconv.Itoa(kbps) + "k", "-bufsize", strconv.Itoa(kbps*2) + "k"}
+	}
+	return nil
+}
+
+// encodeRenditions encodes the renditions below the main one next to
+// outPath and describes all of them, highest first.
+func encodeRenditions(ctx context.Context, requestID, inPath, outPath string, o compressOpts) ([]renditionInfo, error) {
+	infos := make([]renditionInfo, 0, len(o.Renditions))
+	for i, r := range o.Renditions {
+		path := outPath
+		if i > 0 {
+			path = withExt(outPath, "_"+r.Name+o.OutExt)
+			ro := o
+			ro.Renditions = o.Renditions[i:]
+			ro.Scale, ro.Resolution = r.Scale, r.Name
+			logger.Printf("🪜 [%s] Encoding rendition %s (%d/%d)", requestID, r.Name, i+1, len(o.Renditions))
+			if err := runFFmpeg(ctx, inPath, path, ro, io.Discard); err != nil {
+				removeRenditions(infos[1:])
+				os.Remove(path)
+				return nil, fmt.Errorf("rendition %s: %w", r.Name, err)
+			}
+		}
+		info := renditionInfo{Name: r.Name, path: path}
+		if d, err := digestFile(path); err == nil {
+			info.Bytes, info.SHA256 = d.Bytes, d.SHA256
+		}
+		if p, err := probeFile(ctx, path); err == nil {
+			if vs := p.firstStream("video"); vs != nil {
+				info.Width, info.Height = vs.Width, vs.Height
+			}
+			info.Kbps = p.bitRate() / 1000
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// removeRenditions deletes rendition files other than the main output.
+func removeRenditions(infos []renditionInfo) {
+	for _, r := range infos {
+		os.Remove(r.path)
+	}
+}
+
+// zipRenditions stores the renditions as <stem>_<name><ext> in zipPath.
+func zipRenditions(zipPath, stem string, infos []renditionInfo) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(infos))
+	paths := make([]string, len(infos))
+	for i, r := range infos {
+		names[i] = stem + "_" + r.Name + filepath.Ext(r.path)
+		paths[i] = r.path
+		infos[i].File = names[i]
+	}
+	if err := writeZip(f, names, paths); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// renditionsHeader is the X-Renditions value: name=WxH@kbps per rendition.
+func renditionsHeader(infos []renditionInfo) string {
+	parts := make([]string, len(infos))
+	for i, r := range infos {
+		parts[i] = fmt.Sprintf("%s=%dx%d@%dk", r.Name, r.Width, r.Height, r.Kbps)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ======================
+// Repair (POST /repair)
+// ======================
+
+// repairStrategy is one attempt at turning a broken upload into a playable MP4.
+type repairStrategy struct {
+	Name string
+	// args builds the command line; ref is the optional reference file.
+	args func(in, out, ref string) (bin string, args []string)
+}
+
+// ffmpegCmd is the binary and arguments of an ffmpeg strategy.
+func ffmpegCmd(args ...string) (string, []string) {
+	return ffmpegFor(args), args
+}
+
+var repairStrategies = []repairStrategy{
+	{
+		// Plain remux: fixes bad interleaving, missing faststart and many
+		// container-level inconsistencies.
+		Name: "remux",
+		args: func(in, out, _ string) (string, []string) {
+			return ffmpegCmd("-y", "-hide_banner", "-loglevel", "error",
+				"-err_detect", "ignore_err", "-i", in,
+				"-map", "0:v?", "-map", "0:a?", "-c", "copy", "-movflags", "+faststart", out)
+		},
+	},
+	{
+		// Regenerate timestamps and drop corrupt packets (cut-off recordings).
+		Name: "remux_genpts",
+		args: func(in, out, _ string) (string, []string) {
+			return ffmpegCmd("-y", "-hide_banner", "-loglevel", "error",
+				"-fflags", "+genpts+igndts+discardcorrupt", "-err_detect", "ignore_err", "-i", in,
+				"-map", "0:v?", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero",
+				"-movflags", "+faststart", out)
+		},
+	},
+	{
+		// untrunc-style recovery: rebuild the moov atom from a healthy
+		// reference recorded by the same device. Needs the untrunc binary.
+		Name: "untrunc",
+		args: func(in, out, ref string) (string, []string) {
+			if ref == "" {
+				return "", nil
+			}
+			if _, err := exec.LookPath("untrunc"); err != nil {
+				return "", nil
+			}
+			return "untrunc", []string{"-dst", out, ref, in}
+		},
+	},
+	{
+		// Last resort: decode whatever survives and re-encode it.
+		Name: "reencode",
+		args: func(in, out, _ string) (string, []string) {
+			return ffmpegCmd("-y", "-hide_banner", "-loglevel", "error",
+				"-fflags", "+genpts+discardcorrupt", "-err_detect", "ignore_err", "-i", in,
+				"-map", "0:v?", "-map", "0:a?", "-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+				"-pix_fmt", "yuv420p", "-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart", out)
+		},
+	},
+}
+
+type repairAttempt struct {
+	Strategy string `json:"strategy"`
+	OK       bool   `json:"ok"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// diagnoseProbeError turns common ffprobe failures into a readable cause.
+func diagnoseProbeError(msg string) string {
+	m := strings.ToLower(msg)
+	switch {
+	case strings.Contains(m, "moov atom not found"):
+		return "missing moov atom: the recording was not finalized (camera/app stopped or crashed mid-write)"
+	case strings.Contains(m, "invalid data found"):
+		return "no recognizable media container in the file"
+	case strings.Contains(m, "truncat") || strings.Contains(m, "end of file"):
+		return "file is truncated"
+	case msg == "":
+		return ""
+	default:
+		return msg
+	}
+}
+
+// playable reports whether ffprobe sees a decodable stream with a duration.
+func playable(ctx context.Context, path string) (bool, string) {
+	st, err := os.Stat(path)
+	if err != nil || st.Size() < 1024 {
+		return false, "output empty"
+	}
+	p, err := probeFile(ctx, path)
+	if err != nil {
+		return false, err.Error()
+	}
+	if p.firstStream("video") == nil && p.firstStream("audio") == nil {
+		return false, "no audio or video streams in output"
+	}
+	if p.durationSec() <= 0 {
+		return false, "output has no duration"
+	}
+	return true, ""
+}
+
+func repairHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🩹 [%s] New repair request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseMediaUpload(w, r, requestID) {
+		return
+	}
+	inPath, _, err := saveFormFile(r, "file")
+	if err != nil {
+		http.Error(w, "file field required", http.StatusBadRequest)
+		return
+	}
+	defer os.Remove(inPath)
+
+	refPath := ""
+	if p, _, err := saveFormFile(r, "reference"); err == nil {
+		refPath = p
+		defer os.Remove(refPath)
+	}
+
+	diagnosis := ""
+	if _, err := probeFile(r.Context(), inPath); err != nil {
+		diagnosis = diagnoseProbeError(strings.TrimPrefix(err.Error(), "ffprobe failed: "))
+		logger.Printf("🔍 [%s] Input diagnosis: %s", requestID, diagnosis)
+	}
+
+	outPath := withExt(inPath, "_repaired.mp4")
+	var attempts []repairAttempt
+	for _, s := range repairStrategies {
+		bin, args := s.args(inPath, outPath, refPath)
+		if bin == "" {
+			continue
+		}
+		logger.Printf("🔧 [%s] Trying repair strategy: %s", requestID, s.Name)
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(r.Context(), bin, args...)
+		cmd.Stdout = &stderr
+		cmd.Stderr = &stderr
+		a := repairAttempt{Strategy: s.Name}
+		if err := cmd.Run(); err != nil {
+			a.Detail = strings.TrimSpace(err.Error() + ": " + lastLine(stderr.String()))
+		} else if ok, why := playable(r.Context(), outPath); !ok {
+			a.Detail = why
+		} else {
+			a.OK = true
+		}
+		attempts = append(attempts, a)
+		if a.OK {
+			defer os.Remove(outPath)
+			logger.Printf("✅ [%s] Repaired with strategy: %s", requestID, s.Name)
+			w.Header().Set("X-Repair-Strategy", s.Name)
+			if diagnosis != "" {
+				w.Header().Set("X-Repair-Diagnosis", diagnosis)
+			}
+			w.Header().Set("Content-Type", "video/mp4")
+			name := "repaired.mp4"
+			if fh := r.MultipartForm.File["file"]; len(fh) > 0 {
+				name = withExt(filepath.Base(fh[0].Filename), "_repaired.mp4")
+			}
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+name+"\"")
+			http.ServeFile(w, r, outPath)
+			return
+		}
+		os.Remove(outPath)
+	}
+
+	logger.Printf("❌ [%s] All repair strategies failed", requestID)
+	hint := ""
+	if refPath == "" {
+		hint = "upload a healthy clip from the same device as `reference` to enable moov reconstruction"
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":     "file could not be repaired",
+		"diagnosis": diagnosis,
+		"attempts":  attempts,
+		"hint":      hint,
+	})
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
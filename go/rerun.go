@@ -0,0 +1,143 @@
+package main
+
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ======================
+// Job re-runs (POST /jobs/{id}/rerun)
+// ======================
+
+// Iterating on quality means encoding the same upload again with one option
+// changed. POST /jobs/{id}/rerun encodes the original input of a finished
+// job again, as a new async job, with the job's options overridden by the
+// request's fields (crf=20, speed=quality...; an empty value clears an
+// option), so a 2 GB source is not uploaded again. It needs the original
+// kept, either for the whole server (KEEP_ORIGINALS=1, while the job's
+// result is retained) or by the job itself (keep_input=1, for its TTL);
+// after that it answers 410. The new result keeps the original as well, so
+// re-runs chain. An uploaded poster image is not kept and does not carry
+// over. Only the client that submitted the job may re-run it; others get 404.
+
+// rerunMaxBody caps a re-run request: it carries option fields only.
+const rerunMaxBody = 1 << 20
+
+// rerunSkip are request fields that pick the source or the response mode
+// rather than encode options, and are not replayed.
+var rerunSkip = map[string]bool{"async": true, "detach_on_disconnect": true, "url": true, "input": true, "input_id": true, "api": true}
+
+// rerunParams records the options of a request for later re-runs.
+func rerunParams(r *http.Request) url.Values {
+	params := url.Values{}
+	for k, v := range r.Form {
+		if !rerunSkip[k] {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// originalPath is the kept original input of a result ("" if none).
+func originalPath(e *resultEntry) string {
+	for name, p := range e.Artifacts {
+		if strings.HasPrefix(name, "original") {
+			return p
+		}
+	}
+	if e.InputID != "" {
+		return keptInputPath(e.InputID)
+	}
+	return ""
+}
+
+// linkOrCopy hard-links src to dst, copying across filesystems.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+func rerunJob(w http.ResponseWriter, r *http.Request, j *job) {
+	requestID := randID(8)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	snap, _ := j.snapshot()
+	logger.Printf("🔁 [%s] Re-run of job %s requested from %s", requestID, j.ID, r.RemoteAddr)
+	// a re-run replays the job's options (callback_url, output, deliver) on
+	// its original: only the submitter may ask for one
+	if snap.owner != clientKey(r) {
+		http.NotFound(w, r)
+		return
+	}
+	if snap.State != jobDone || snap.Result == nil {
+		http.Error(w, "job "+j.ID+" is "+snap.State+"; only finished jobs can be re-run", http.StatusConflict)
+		return
+	}
+	if snap.params == nil {
+		http.Error(w, "job "+j.ID+" has no recorded options to re-run", http.StatusConflict)
+		return
+	}
+	orig := originalPath(snap.Result)
+	if _, err := os.Stat(orig); orig == "" || err != nil {
+		http.Error(w, "the original input of job "+j.ID+" is no longer kept (KEEP_ORIGINALS=1 or keep_input=1 keep it)", http.StatusGone)
+		return
+	}
+	// only option fields are read: cap the body so nothing large is spooled
+	r.Body = http.MaxBytesReader(w, r.Body, rerunMaxBody)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		err := r.ParseMultipartForm(rerunMaxBody)
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := maps.Clone(snap.params)
+	maps.Copy(params, rerunParams(r))
+	opts, err := parseRequestOpts(r, params.Get)
+	if err != nil {
+		logger.Printf("❌ [%s] Re-run options: %v", requestID, err)
+		http.Error(w, err.Error(), optsErrorStatus(err))
+		return
+	}
+	opts.SourceName = snap.sourceName
+
+	name := filepath.Base(snap.sourceName)
+	if name == "" || name == "." || name == "/" {
+		name = filepath.Base(orig)
+	}
+	inPath := filepath.Join(os.TempDir(), requestID+"_"+name)
+	if err := linkOrCopy(orig, inPath); err != nil {
+		http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nj := newJob(opts.Client)
+	nj.setRequest(r, params, snap.sourceName)
+	nj.setCallback(opts.CallbackURL, requestBaseURL(r))
+	logger.Printf("📨 [%s] Re-run of job %s queued as job %s", requestID, j.ID, nj.ID)
+	runBackground(nj, requestID, inPath, opts, keepSlot(r))
+	w.Header().Set("Location", "/jobs/"+nj.ID)
+	s, _ := nj.snapshot()
+	v := s.view()
+	v["status_url"] = "/jobs/" + nj.ID
+	v["wait_url"] = "/jobs/" + nj.ID + "/wait"
+	v["rerun_of"] = j.ID
+	writeJSON(w, http.StatusAccepted, v)
+}
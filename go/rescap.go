@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Orientation-specific resolution caps (max_landscape=, max_portrait=)
+// ======================
+
+// resCap is a bounding box; zero means "no cap" for that orientation.
+type resCap struct{ W, H int }
+
+// parseResCap accepts WxH (e.g. 720x1280) or a preset like 1080p, which is
+// read as the short edge for the given orientation.
+func parseResCap(s string, portrait bool) (resCap, error) {
+	if s == "" {
+		return resCap{}, nil
+	}
+	if n, ok := strings.CutSuffix(strings.ToLower(s), "p"); ok {
+		short, err := strconv.Atoi(n)
+		if err != nil || short < 144 || short > 4320 {
+			return resCap{}, fmt.Errorf("invalid resolution cap %q", s)
+		}
+		long := (short*16/9 + 1) &^ 1
+		if portrait {
+			return resCap{W: short, H: long}, nil
+		}
+		return resCap{W: long, H: short}, nil
+	}
+	ws, hs, ok := strings.Cut(strings.ToLower(s), "x")
+	w, errW := strconv.Atoi(ws)
+	h, errH := strconv.Atoi(hs)
+	if !ok || errW != nil || errH != nil || w < 16 || h < 16 || w > 8192 || h > 8192 {
+		return resCap{}, fmt.Errorf("invalid resolution cap %q (WxH or e.g. 1080p)", s)
+	}
+	return resCap{W: w &^ 1, H: h &^ 1}, nil
+}
+
+// capFilter fits the frame inside the landscape or portrait box (square
+// counts as landscape), never upscaling and keeping the aspect ratio.
+func capFilter(land, port resCap) string {
+	if land == (resCap{}) && port == (resCap{}) {
+		return ""
+	}
+	dim := func(in string, l, p int) string {
+		lv, pv := in, in
+		if l > 0 {
+			lv = fmt.Sprintf("min(%s,%d)", in, l)
+		}
+		if p > 0 {
+			pv = fmt.Sprintf("min(%s,%d)", in, p)
+		}
+		return fmt.Sprintf("'if(gte(iw,ih),%s,%s)'", lv, pv)
+	}
+	return "scale=" + dim("iw", land.W, port.W) + ":" + dim("ih", land.H, port.H) +
+		":force_original_aspect_ratio=decrease:force_divisible_by=2,setsar=1"
+}
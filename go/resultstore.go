@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ======================
+// Shared result storage (multiple replicas)
+// ======================
+
+// Results live on the disk of the replica that encoded them. Behind a load
+// balancer, /dl and /meta can land on another replica, so results can be
+// shared two ways:
+//
+//   - a shared volume: point OUTPUT_DIR at the same mount (NFS, EFS, ...) on
+//     every replica. Lookups that miss the in-memory store read the
+//     persisted index from .results/ on the volume.
+//   - object storage: RESULT_STORE=s3 with RESULT_S3_BUCKET (and optionally
+//     RESULT_S3_PREFIX, default "results/"). After storing a result the
+//     replica uploads the output, its artifacts and then the index entry
+//     (<prefix><id>/entry.json); other replicas load the entry on a miss
+//     and stream the files from the bucket, or (DL_REDIRECT) redirect the
+//     client to a short-lived presigned URL so the bytes skip this server.
+
+// sharedCopy records where a result was uploaded (guarded by deliveryMu).
+type sharedCopy struct {
+	Bucket    string            `json:"bucket"`
+	Key       string            `json:"key"`
+	Artifacts map[string]string `json:"artifacts,omitempty"` // name → key
+}
+
+func resultStoreS3() bool {
+	return os.Getenv("RESULT_STORE") == "s3"
+}
+
+func resultBucket() string {
+	return os.Getenv("RESULT_S3_BUCKET")
+}
+
+func resultPrefix() string {
+	return envOr("RESULT_S3_PREFIX", "results/")
+}
+
+// checkResultStore validates the RESULT_STORE settings at startup.
+func checkResultStore() error {
+	switch os.Getenv("RESULT_STORE") {
+	case "", "local":
+		return nil
+	case "s3":
+		if resultBucket() == "" {
+			return errors.New("RESULT_STORE=s3 needs RESULT_S3_BUCKET")
+		}
+		if _, err := s3FromEnv(); err != nil {
+			return err
+		}
+		logger.Printf("🗄️ [MAIN] Sharing results via s3://%s/%s", resultBucket(), resultPrefix())
+		return nil
+	}
+	return fmt.Errorf("invalid RESULT_STORE %q (local|s3)", os.Getenv("RESULT_STORE"))
+}
+
+// shareResult uploads the result files and then the index entry so other
+// replicas only see complete results.
+func shareResult(requestID, id string, e *resultEntry) {
+	c, err := s3FromEnv()
+	if err != nil {
+		logger.Printf("⚠️ [%s] Result %s not shared: %v", requestID, id, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	start := time.Now()
+	bucket, dir := resultBucket(), resultPrefix()+id+"/"
+	sc := &sharedCopy{Bucket: bucket, Key: dir + filepath.Base(e.FilePath), Artifacts: map[string]string{}}
+	if _, err := s3PutFile(ctx, c, bucket, sc.Key, e.FilePath, contentTypeFor(e.FilePath)); err != nil {
+		logger.Printf("❌ [%s] Sharing result %s failed: %v", requestID, id, err)
+		return
+	}
+	for name, p := range e.Artifacts {
+		key := dir + "artifacts/" + name
+		if _, err := s3PutFile(ctx, c, bucket, key, p, contentTypeFor(p)); err != nil {
+			logger.Printf("⚠️ [%s] Sharing artifact %s failed: %v", requestID, name, err)
+			continue
+		}
+		sc.Artifacts[name] = key
+	}
+
+	deliveryMu.Lock()
+	e.Shared = sc
+	data, err := json.Marshal(e)
+	deliveryMu.Unlock()
+	if err == nil {
+		_, err = s3Put(ctx, c, bucket, dir+"entry.json", bytes.NewReader(data), int64(len(data)), "application/json")
+	}
+	if err != nil {
+		logger.Printf("❌ [%s] Sharing result index %s failed: %v", requestID, id, err)
+		return
+	}
+	persistResult(id, e)
+	logger.Printf("🗄️ [%s] Result %s shared to s3://%s/%s in %v", requestID, id, bucket, dir, time.Since(start))
+}
+
+// loadSharedResult looks up a result stored by another replica.
+func loadSharedResult(id string) (*resultEntry, bool) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return nil, false
+	}
+	var data []byte
+	switch {
+	case resultStoreS3():
+		c, err := s3FromEnv()
+		if err != nil {
+			return nil, false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		resp, err := s3Get(ctx, c, resultBucket(), resultPrefix()+id+"/entry.json", nil)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				logger.Printf("⚠️ [RESULTS] Shared lookup of %s failed: %v", id, err)
+			}
+			return nil, false
+		}
+		defer resp.Body.Close()
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, false
+		}
+	case persistentOutputs():
+		var err error
+		if data, err = os.ReadFile(filepath.Join(resultIndexDir(), id+".json")); err != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	var e resultEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, false
+	}
+	logger.Printf("🗄️ [RESULTS] Loaded result %s stored by another replica", id)
+	return &e, true
+}
+
+// sharedObject returns the bucket and key of the output (artifact "") or
+// an artifact once the result has been shared.
+func sharedObject(e *resultEntry, artifact string) (string, string, bool) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	if e.Shared == nil {
+		return "", "", false
+	}
+	if artifact == "" {
+		return e.Shared.Bucket, e.Shared.Key, true
+	}
+	key, ok := e.Shared.Artifacts[artifact]
+	return e.Shared.Bucket, key, ok
+}
+
+// dlRedirects reports whether /dl answers shared results with a 302 to a
+// presigned URL (DL_REDIRECT, default on with RESULT_STORE=s3).
+func dlRedirects() bool {
+	return resultStoreS3() && envOr("DL_REDIRECT", "1") == "1"
+}
+
+// redirectShared sends the client to a presigned URL for bucket/key that
+// expires after DL_URL_TTL (default 5m). The filename and content type are
+// carried as response overrides so the browser saves the right name.
+func redirectShared(w http.ResponseWriter, r *http.Request, requestID, bucket, key, name, ctype string) {
+	c, err := s3FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ttl := envDuration("DL_URL_TTL", 5*time.Minute)
+	if ttl < time.Second || ttl > 7*24*time.Hour {
+		ttl = 5 * time.Minute
+	}
+	u := c.presignGet(bucket, key, ttl, url.Values{
+		"response-content-disposition": {`attachment; filename="` + name + `"`},
+		"response-content-type":        {ctype},
+	}, time.Now())
+	logger.Printf("↪️ [%s] Redirecting download to presigned URL for %s (valid %v)", requestID, path.Base(key), ttl)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// serveShared streams an object from the result bucket, passing Range
+// through so players can seek.
+func serveShared(w http.ResponseWriter, r *http.Request, requestID, bucket, key string) {
+	c, err := s3FromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hdr := http.Header{}
+	if rg := r.Header.Get("Range"); rg != "" {
+		hdr.Set("Range", rg)
+	}
+	resp, err := s3Get(r.Context(), c, bucket, key, hdr)
+	if err != nil {
+		logger.Printf("❌ [%s] Shared download of %s failed: %v", requestID, path.Base(key), err)
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "shared storage unavailable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for _, h := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method != http.MethodHead {
+		io.Copy(throttle(w, r), resp.Body)
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// processUsage reads the rusage of an exited process. Linux reports
+// ru_maxrss in KiB and block I/O in 512-byte units.
+func processUsage(ps *os.ProcessState) resourceUsage {
+	u := resourceUsage{CPUUserSec: ps.UserTime().Seconds(), CPUSysSec: ps.SystemTime().Seconds(), Processes: 1}
+	if ru, ok := ps.SysUsage().(*syscall.Rusage); ok {
+		u.MaxRSS = int64(ru.Maxrss) << 10
+		u.ReadBytes = int64(ru.Inblock) * 512
+		u.WriteBytes = int64(ru.Oublock) * 512
+	}
+	return u
+}
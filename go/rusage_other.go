@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// processUsage reports the CPU time of an exited process; peak RSS and
+// I/O units differ across platforms and are left out.
+func processUsage(ps *os.ProcessState) resourceUsage {
+	return resourceUsage{CPUUserSec: ps.UserTime().Seconds(), CPUSysSec: ps.SystemTime().Seconds(), Processes: 1}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Minimal S3 client (SigV4, stdlib only)
+// ======================
+
+// s3Config comes from the usual AWS variables; S3_ENDPOINT switches to
+// path-style URLs for MinIO/R2/Wasabi-style endpoints.
+type s3Config struct {
+	Endpoint     string // e.g. http://minio:9000 (empty = AWS)
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+func s3FromEnv() (s3Config, error) {
+	c := s3Config{
+		Endpoint:     strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+		Region:       envOr("S3_REGION", envOr("AWS_REGION", "us-east-1")),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return c, errors.New("S3 credentials not configured (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)")
+	}
+	return c, nil
+}
+
+// objectURL returns the request URL and the canonical (encoded) path.
+func (c s3Config) objectURL(bucket, key string) (string, string) {
+	if c.Endpoint != "" {
+		path := "/" + bucket + "/" + awsURIEncode(key, false)
+		return c.Endpoint + path, path
+	}
+	path := "/" + awsURIEncode(key, false)
+	return "https://" + bucket + ".s3." + c.Region + ".amazonaws.com" + path, path
+}
+
+// awsURIEncode encodes everything but unreserved characters (and '/' in
+// paths unless encodeSlash).
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds SigV4 headers for service s3. canonicalPath must be the encoded
+// path used in the URL; payloadHash may be UNSIGNED-PAYLOAD.
+func (c s3Config) sign(req *http.Request, canonicalPath, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if c.SessionToken != "" {
+		headers["x-amz-security-token"] = c.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, canonicalPath, canonicalQuery(req.URL.Query()),
+		canonHeaders.String(), signed, payloadHash}, "\n")
+	scope := day + "/" + c.Region + "/s3/aws4_request"
+	sig := c.signature(day, amzDate, scope, canonical)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.AccessKey+"/"+scope+
+		", SignedHeaders="+signed+", Signature="+sig)
+}
+
+// canonicalQuery sorts and encodes query parameters the SigV4 way.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var qs []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			qs = append(qs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(qs, "&")
+}
+
+// signature signs a canonical request for the given day and scope.
+func (c s3Config) signature(day, amzDate, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+c.SecretKey), day)
+	key = hmacSHA256(key, c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// presignGet returns a GET URL for bucket/key valid for ttl (SigV4 query
+// auth). extra adds response overrides such as response-content-disposition.
+func (c s3Config) presignGet(bucket, key string, ttl time.Duration, extra url.Values, now time.Time) string {
+	u, canonicalPath := c.objectURL(bucket, key)
+	parsed, _ := url.Parse(u)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	scope := day + "/" + c.Region + "/s3/aws4_request"
+
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", c.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if c.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", c.SessionToken)
+	}
+	qs := canonicalQuery(q)
+	canonical := strings.Join([]string{http.MethodGet, canonicalPath, qs,
+		"host:" + parsed.Host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	return u + "?" + qs + "&X-Amz-Signature=" + c.signature(day, amzDate, scope, canonical)
+}
+
+// s3PutFile uploads path as bucket/key (single PUT, up to 5 GB) and returns
+// the object URL.
+func s3PutFile(ctx context.Context, c s3Config, bucket, key, path, contentType string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	return s3Put(ctx, c, bucket, key, f, st.Size(), contentType)
+}
+
+// s3Put uploads size bytes from content as bucket/key.
+func s3Put(ctx context.Context, c s3Config, bucket, key string, content io.Reader, size int64, contentType string) (string, error) {
+	u, canonicalPath := c.objectURL(bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, content)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	c.sign(req, canonicalPath, "UNSIGNED-PAYLOAD", time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("s3 put: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return u, nil
+}
+
+// s3Get fetches bucket/key. Extra request headers (e.g. Range) are passed
+// through; the caller closes the body. A missing object is os.ErrNotExist.
+func s3Get(ctx context.Context, c s3Config, bucket, key string, header http.Header) (*http.Response, error) {
+	u, canonicalPath := c.objectURL(bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req, canonicalPath, "UNSIGNED-PAYLOAD", time.Now())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, os.ErrNotExist
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("s3 get: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
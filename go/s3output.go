@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ======================
+// Object storage output (output=s3://bucket/key)
+// ======================
+
+// output=s3://bucket/key uploads the finished file to an S3-compatible bucket
+// before the request returns, and API mode answers with the result metadata
+// (including "output_url") instead of the bytes. A key ending in "/" is a
+// prefix: the download name is appended. OUTPUT_S3_URL sets a default for
+// every request, so a fleet of stateless workers can write straight to the
+// bucket; output=local opts a request out. Credentials and endpoint come from
+// the usual AWS_*/S3_ENDPOINT variables (see s3.go).
+//
+// Clients may only name buckets listed in OUTPUT_S3_BUCKETS (comma-separated)
+// or the bucket of OUTPUT_S3_URL; anything else is refused with 403, so a
+// caller cannot overwrite objects elsewhere with the server's credentials.
+//
+// Unlike deliver=[...], which copies the result in the background, a failed
+// upload fails the job: the object is the result.
+
+const outputUploadTimeout = 30 * time.Minute
+
+type s3Location struct {
+	Bucket string
+	Key    string // "" or ending in "/" = prefix for the download name
+}
+
+// parseS3URL reads s3://bucket/key.
+func parseS3URL(s string) (*s3Location, error) {
+	rest, ok := strings.CutPrefix(s, "s3://")
+	bucket, key, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" || strings.ContainsAny(bucket, " ?#") {
+		return nil, fmt.Errorf("invalid S3 URL %q (s3://bucket/key)", s)
+	}
+	return &s3Location{Bucket: bucket, Key: key}, nil
+}
+
+// parseOutputTarget reads output=, falling back to OUTPUT_S3_URL. nil means
+// the result is only kept locally.
+func parseOutputTarget(s string) (*s3Location, error) {
+	if s == "" {
+		s = os.Getenv("OUTPUT_S3_URL")
+	}
+	switch {
+	case s == "", s == "local":
+		return nil, nil
+	case !strings.HasPrefix(s, "s3://"):
+		return nil, fmt.Errorf("invalid output %q (s3://bucket/key, local or audio)", s)
+	}
+	l, err := parseS3URL(s)
+	if err != nil {
+		return nil, err
+	}
+	if !outputBucketAllowed(l.Bucket) {
+		return nil, &fetchError{http.StatusForbidden, fmt.Sprintf("bucket %q is not allowed for output (OUTPUT_S3_BUCKETS)", l.Bucket)}
+	}
+	if _, err := s3FromEnv(); err != nil {
+		return nil, fmt.Errorf("output=s3: %w", err)
+	}
+	return l, nil
+}
+
+// bucketListed reports whether bucket is in the comma-separated list.
+func bucketListed(list, bucket string) bool {
+	for _, b := range strings.Split(list, ",") {
+		if strings.TrimSpace(b) == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// outputBucketAllowed reports whether results may be written to bucket:
+// it must be listed in OUTPUT_S3_BUCKETS or be OUTPUT_S3_URL's bucket.
+func outputBucketAllowed(bucket string) bool {
+	if bucketListed(os.Getenv("OUTPUT_S3_BUCKETS"), bucket) {
+		return true
+	}
+	def, err := parseS3URL(os.Getenv("OUTPUT_S3_URL"))
+	return err == nil && def.Bucket == bucket
+}
+
+// optsErrorStatus is the HTTP status for an option error: 400, or the one a
+// *fetchError carries (403 for a bucket outside the allow-list).
+func optsErrorStatus(err error) int {
+	var fe *fetchError
+	if errors.As(err, &fe) {
+		return fe.status
+	}
+	return http.StatusBadRequest
+}
+
+// objectKey is the key for a file downloaded as name.
+func (l *s3Location) objectKey(name string) string {
+	if l.Key == "" || strings.HasSuffix(l.Key, "/") {
+		return l.Key + name
+	}
+	return l.Key
+}
+
+// uploadOutput puts the entry's file in the bucket and records its URL.
+func uploadOutput(ctx context.Context, requestID string, e *resultEntry, l *s3Location) error {
+	c, err := s3FromEnv()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, outputUploadTimeout)
+	defer cancel()
+	key := l.objectKey(e.downloadName())
+	logger.Printf("☁️ [%s] Uploading output to s3://%s/%s (%s)", requestID, l.Bucket, key, humanBytes(e.OutputBytes))
+	u, err := s3PutFile(ctx, c, l.Bucket, key, e.FilePath, contentTypeFor(e.FilePath))
+	if err != nil {
+		return errors.New("uploading output: " + err.Error())
+	}
+	e.OutputURL = u
+	logger.Printf("✅ [%s] Output uploaded: %s", requestID, u)
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseOutputTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		env        map[string]string
+		want       *s3Location // nil: kept locally
+		wantStatus int         // of an error (optsErrorStatus)
+	}{
+		{name: "local by default"},
+		{name: "local", output: "local", env: map[string]string{"OUTPUT_S3_URL": "s3://media/out/"}},
+		{name: "listed bucket", output: "s3://media/renders/", env: map[string]string{"OUTPUT_S3_BUCKETS": "other, media"},
+			want: &s3Location{Bucket: "media", Key: "renders/"}},
+		{name: "default bucket", env: map[string]string{"OUTPUT_S3_URL": "s3://media/out/"},
+			want: &s3Location{Bucket: "media", Key: "out/"}},
+		{name: "bucket of the default", output: "s3://media/x.mp4", env: map[string]string{"OUTPUT_S3_URL": "s3://media/out/"},
+			want: &s3Location{Bucket: "media", Key: "x.mp4"}},
+		{name: "unlisted bucket", output: "s3://elsewhere/x.mp4", env: map[string]string{"OUTPUT_S3_BUCKETS": "media"}, wantStatus: http.StatusForbidden},
+		{name: "no allow-list", output: "s3://media/x.mp4", wantStatus: http.StatusForbidden},
+		{name: "not s3", output: "ftp://media/x.mp4", wantStatus: http.StatusBadRequest},
+		{name: "no bucket", output: "s3:///x.mp4", wantStatus: http.StatusBadRequest},
+		{name: "bad bucket", output: "s3://me dia/x.mp4", wantStatus: http.StatusBadRequest},
+		{name: "no credentials", output: "s3://media/x.mp4",
+			env: map[string]string{"OUTPUT_S3_BUCKETS": "media", "AWS_ACCESS_KEY_ID": ""}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OUTPUT_S3_URL", "")
+			t.Setenv("OUTPUT_S3_BUCKETS", "")
+			t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := parseOutputTarget(tt.output)
+			if err != nil {
+				if status := optsErrorStatus(err); status != tt.wantStatus {
+					t.Fatalf("parseOutputTarget(%q) error = %v (status %d), want status %d", tt.output, err, status, tt.wantStatus)
+				}
+				return
+			}
+			if tt.wantStatus != 0 {
+				t.Fatalf("parseOutputTarget(%q) = %+v, want status %d", tt.output, got, tt.wantStatus)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("parseOutputTarget(%q) = %+v, want %+v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectKey(t *testing.T) {
+	tests := []struct {
+		key, name, want string
+	}{
+		{"", "clip.mp4", "clip.mp4"},
+		{"renders/", "clip.mp4", "renders/clip.mp4"},
+		{"renders/final.mp4", "clip.mp4", "renders/final.mp4"},
+	}
+	for _, tt := range tests {
+		l := &s3Location{Bucket: "media", Key: tt.key}
+		if got := l.objectKey(tt.name); got != tt.want {
+			t.Errorf("objectKey(%q) with key %q = %q, want %q", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
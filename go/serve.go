@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ======================
+// Listeners (HTTPS, HTTP/2, HTTP/3)
+// ======================
+
+// With TLS_CERT_FILE and TLS_KEY_FILE the server speaks HTTPS, and browsers
+// negotiate HTTP/2 on it: one connection carries the upload and every
+// parallel API call, and a lost packet no longer stalls the other requests
+// queued behind it on HTTP/1.1 connections. H2C=1 offers cleartext HTTP/2
+// instead, for TLS-terminating proxies that speak h2c to their backends.
+//
+// HTTP3=1 adds an HTTP/3 (QUIC) listener on the same port over UDP and
+// advertises it with Alt-Svc. QUIC recovers from loss per stream and
+// survives client address changes, which helps long uploads on mobile
+// networks. It needs TLS and a binary built with -tags http3 (see
+// http3_on.go).
+
+func tlsFiles() (cert, key string) {
+	return os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+}
+
+// configureListeners sets up protocols on s and starts HTTP/3 when asked.
+// It returns the shutdown function of the HTTP/3 listener (nil without).
+func configureListeners(s *http.Server, port string) (func(context.Context) error, error) {
+	cert, key := tlsFiles()
+	if (cert == "") != (key == "") {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	s.Protocols = new(http.Protocols)
+	s.Protocols.SetHTTP1(true)
+	if cert != "" {
+		s.Protocols.SetHTTP2(true)
+	} else if os.Getenv("H2C") == "1" {
+		s.Protocols.SetUnencryptedHTTP2(true)
+		logger.Printf("⚡ [MAIN] Cleartext HTTP/2 (h2c) enabled")
+	}
+	if os.Getenv("HTTP3") != "1" {
+		return nil, nil
+	}
+	if cert == "" {
+		return nil, errors.New("HTTP3=1 needs TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	h3, err := startHTTP3(":"+port, s.Handler, cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("http/3: %w", err)
+	}
+	s.Handler = h3.advertise(s.Handler)
+	logger.Printf("⚡ [MAIN] HTTP/3 (QUIC) listening on udp :%s", port)
+	return h3.shutdown, nil
+}
+
+// listenAndServe serves s over TLS when certificates are configured.
+func listenAndServe(s *http.Server) error {
+	if cert, key := tlsFiles(); cert != "" {
+		return s.ListenAndServeTLS(cert, key)
+	}
+	return s.ListenAndServe()
+}
+
+func listenScheme() string {
+	if cert, _ := tlsFiles(); cert != "" {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Slideshow (POST /slideshow)
+// ======================
+
+const (
+	maxSlideshowImages = 500
+	// maxZipEntries bounds the archive directory read before any image is
+	// picked; the extracted images together stay under maxUploadSize.
+	maxZipEntries = 10000
+)
+
+var slideshowImageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".bmp": true,
+}
+
+// extractZipImages unpacks the images of a ZIP into dir (flattened, so
+// archive paths can never escape it) and returns them sorted by name.
+func extractZipImages(zipPath, dir string) ([]string, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip: %w", err)
+	}
+	defer zr.Close()
+	if len(zr.File) > maxZipEntries {
+		return nil, fmt.Errorf("too many zip entries (%d > %d)", len(zr.File), maxZipEntries)
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !slideshowImageExts[strings.ToLower(filepath.Ext(f.Name))] {
+			continue
+		}
+		if strings.HasPrefix(filepath.Base(f.Name), ".") { // __MACOSX/._foo.jpg etc.
+			continue
+		}
+		names = append(names, f.Name)
+	}
+	if len(names) > maxSlideshowImages {
+		return nil, fmt.Errorf("too many images (%d > %d)", len(names), maxSlideshowImages)
+	}
+	sort.Strings(names)
+
+	byName := map[string]*zip.File{}
+	for _, f := range zr.File {
+		byName[f.Name] = f
+	}
+	var paths []string
+	budget := int64(maxUploadSize)
+	for i, name := range names {
+		dst := filepath.Join(dir, fmt.Sprintf("%04d%s", i, strings.ToLower(filepath.Ext(name))))
+		n, err := extractZipFile(byName[name], dst, budget)
+		if err != nil {
+			return nil, err
+		}
+		budget -= n
+		paths = append(paths, dst)
+	}
+	return paths, nil
+}
+
+// extractZipFile writes f to dst, failing once it would pass budget bytes
+// (the declared size is not trusted).
+func extractZipFile(f *zip.File, dst string, budget int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+	n, err := io.Copy(out, io.LimitReader(rc, budget+1))
+	if err == nil && n > budget {
+		err = fmt.Errorf("zip images are over the %s limit uncompressed", humanBytes(maxUploadSize))
+	}
+	return n, err
+}
+
+// writeConcatList writes a concat-demuxer script showing each image for its
+// duration. The last image is repeated because concat ignores the final
+// duration directive.
+func writeConcatList(path string, images []string, durations []float64) error {
+	var b strings.Builder
+	for i, img := range images {
+		fmt.Fprintf(&b, "file '%s'\nduration %.3f\n", strings.ReplaceAll(img, "'", `'\''`), durations[i])
+	}
+	fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(images[len(images)-1], "'", `'\''`))
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
+
+// parseDurations expands "duration" (default for all) and "durations"
+// (comma separated, per image) into one value per image.
+func parseDurations(def, list string, n int) ([]float64, error) {
+	d := 3.0
+	if def != "" {
+		v, err := strconv.ParseFloat(def, 64)
+		if err != nil || v <= 0 || v > 3600 {
+			return nil, fmt.Errorf("invalid duration %q", def)
+		}
+		d = v
+	}
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = d
+	}
+	if list == "" {
+		return out, nil
+	}
+	for i, part := range strings.Split(list, ",") {
+		if i >= n {
+			break
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v <= 0 || v > 3600 {
+			return nil, fmt.Errorf("invalid durations entry %q", part)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func slideshowHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🖼️ [%s] New slideshow request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !parseUploadForm(w, r, requestID) {
+		return
+	}
+
+	workDir, err := os.MkdirTemp("", "slideshow_"+requestID+"_")
+	if err != nil {
+		http.Error(w, "save error: "+err.Error(), 500)
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	// Images come either as one ZIP ("images") or as repeated "image" parts.
+	var images []string
+	if zipPath, _, err := saveFormFile(r, "images"); err == nil {
+		defer os.Remove(zipPath)
+		if images, err = extractZipImages(zipPath, workDir); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		for i, fh := range r.MultipartForm.File["image"] {
+			if i >= maxSlideshowImages {
+				http.Error(w, "too many images", http.StatusBadRequest)
+				return
+			}
+			ext := strings.ToLower(filepath.Ext(fh.Filename))
+			if !slideshowImageExts[ext] {
+				http.Error(w, "unsupported image type: "+fh.Filename, http.StatusBadRequest)
+				return
+			}
+			dst := filepath.Join(workDir, fmt.Sprintf("%04d%s", i, ext))
+			if err := saveFileHeader(fh, dst); err != nil {
+				http.Error(w, "save error: "+err.Error(), 500)
+				return
+			}
+			images = append(images, dst)
+		}
+	}
+	if len(images) == 0 {
+		http.Error(w, "images (zip) or image fields required", http.StatusBadRequest)
+		return
+	}
+
+	client, err := clientMetaFromRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	nameTmpl, err := formOutputName(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	durations, err := parseDurations(r.FormValue("duration"), r.FormValue("durations"), len(images))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	o := compressOpts{Resolution: r.FormValue("resolution")}
+	if o.Resolution == "" || o.Resolution == "original" {
+		o.Resolution = "1080p"
+	}
+	o.applyResolution()
+	if o.Scale == "" {
+		http.Error(w, "invalid resolution", http.StatusBadRequest)
+		return
+	}
+	fps := 30
+	if n, err := strconv.Atoi(r.FormValue("fps")); err == nil && n > 0 && n <= 60 {
+		fps = n
+	}
+
+	listPath := filepath.Join(workDir, "list.txt")
+	if err := writeConcatList(listPath, images, durations); err != nil {
+		http.Error(w, "save error: "+err.Error(), 500)
+		return
+	}
+
+	wh := o.Scale
+	outPath := outputPath(requestID, "slideshow.mp4")
+	args := []string{"-y", "-hide_banner", "-loglevel", "error",
+		"-f", "concat", "-safe", "0", "-i", listPath}
+	audioPath, _, audioErr := saveFormFile(r, "audio")
+	if audioErr == nil {
+		defer os.Remove(audioPath)
+		args = append(args, "-i", audioPath)
+	}
+	args = append(args,
+		"-vf", fmt.Sprintf("scale=%s:force_original_aspect_ratio=decrease,pad=%s:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%d,format=yuv420p", wh, wh, fps),
+		"-map", "0:v")
+	if audioErr == nil {
+		args = append(args, "-map", "1:a:0", "-c:a", "aac", "-b:a", "128k", "-shortest")
+	}
+	args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "23",
+		"-movflags", "+faststart", outPath)
+
+	logger.Printf("⚙️ [%s] FFmpeg command: ffmpeg %s", requestID, strings.Join(args, " "))
+	start := time.Now()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(r.Context(), ffmpegFor(args), args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(outPath)
+		logger.Printf("❌ [%s] Slideshow failed: %v", requestID, err)
+		http.Error(w, "slideshow failed: "+lastLine(stderr.String()), 500)
+		return
+	}
+	st, err := os.Stat(outPath)
+	if err != nil || st.Size() < 1024 {
+		http.Error(w, errOutputInvalid.Error(), 500)
+		return
+	}
+
+	var inputBytes int64
+	for _, img := range images {
+		if fi, err := os.Stat(img); err == nil {
+			inputBytes += fi.Size()
+		}
+	}
+	entry := &resultEntry{
+		FilePath:    outPath,
+		ModeFinal:   "slideshow",
+		ModeDecider: "manual",
+		InputBytes:  inputBytes,
+		OutputBytes: st.Size(),
+		Resolution:  o.Resolution,
+		Codec:       "h264",
+		Audio:       "none",
+		HW:          "none",
+		ElapsedMs:   time.Since(start).Milliseconds(),
+		Client:      client,
+		Name:        renderOutputName(outputNameTemplate(nameTmpl), "slideshow", "slideshow", o.Resolution, "h264", ".mp4"),
+	}
+	if audioErr == nil {
+		entry.Audio = "aac"
+	}
+	logger.Printf("✅ [%s] Slideshow of %d images created (%s)", requestID, len(images), humanBytes(st.Size()))
+	serveResultFile(w, r, requestID, entry)
+}
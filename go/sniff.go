@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// ======================
+// Upload sniffing (415 for non-media files)
+// ======================
+
+// Video endpoints look at the first bytes of the "file" part while the
+// multipart stream is still arriving. Files that are clearly not media (the
+// signatures below) abort the upload with 415 right away. Anything unknown
+// is let through: ffmpeg reads far more formats than a list could name.
+
+var notMediaSignatures = []struct {
+	magic []byte
+	kind  string
+}{
+	{[]byte("MZ"), "Windows executable"},
+	{[]byte("\x7fELF"), "ELF executable"},
+	{[]byte("\xcf\xfa\xed\xfe"), "Mach-O executable"},
+	{[]byte("\xca\xfe\xba\xbe"), "Mach-O/Java binary"},
+	{[]byte("PK\x03\x04"), "ZIP archive"},
+	{[]byte("Rar!\x1a\x07"), "RAR archive"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "7-Zip archive"},
+	{[]byte("\x1f\x8b"), "gzip archive"},
+	{[]byte("BZh"), "bzip2 archive"},
+	{[]byte("\xfd7zXZ\x00"), "xz archive"},
+	{[]byte("%PDF-"), "PDF document"},
+	{[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"), "Office document"},
+}
+
+// sniffLimit bounds how far into the body the file part is looked for;
+// form fields before it are small.
+const sniffLimit = 1 << 20
+
+var filePartHeader = regexp.MustCompile(`(?i)content-disposition:[^\r\n]*\bname="file"[^\r\n]*\r\n(?:[^\r\n]+\r\n)*\r\n`)
+
+// notMediaError aborts an upload whose file part is clearly not media.
+type notMediaError struct {
+	Kind string
+}
+
+func (e *notMediaError) Error() string {
+	return fmt.Sprintf("upload is not a video or audio file (looks like: %s)", e.Kind)
+}
+
+// sniffReader passes the body through, buffering the start until the file
+// part's first bytes have been seen.
+type sniffReader struct {
+	io.ReadCloser
+	buf  []byte
+	done bool
+	err  error
+}
+
+func (s *sniffReader) Read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	n, err := s.ReadCloser.Read(p)
+	if !s.done && n > 0 {
+		s.buf = append(s.buf, p[:n]...)
+		s.check(err != nil)
+		if s.err != nil {
+			return 0, s.err
+		}
+	}
+	return n, err
+}
+
+// check inspects the buffered start; eof means no more bytes will come.
+func (s *sniffReader) check(eof bool) {
+	loc := filePartHeader.FindIndex(s.buf)
+	if loc == nil {
+		if eof || len(s.buf) > sniffLimit {
+			s.done, s.buf = true, nil
+		}
+		return
+	}
+	head := s.buf[loc[1]:]
+	if len(head) < 8 && !eof {
+		return
+	}
+	for _, sig := range notMediaSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			s.err = &notMediaError{Kind: sig.kind}
+			break
+		}
+	}
+	s.done, s.buf = true, nil
+}
+
+// parseMediaUpload is parseUploadForm for endpoints whose "file" is a video
+// or audio file; it answers 415 as soon as the file is clearly neither.
+func parseMediaUpload(w http.ResponseWriter, r *http.Request, requestID string) bool {
+	r.Body = &sniffReader{ReadCloser: r.Body}
+	return parseUploadForm(w, r, requestID)
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ======================
+// 360°/VR spherical metadata (projection=, stereo=)
+// ======================
+
+// ffmpeg carries the Spherical Mapping / Stereo 3D side data of the source
+// through the encode, but the mp4/mov muxer only writes it (sv3d/st3d) with
+// -strict unofficial. Sources without it can be tagged with projection= and
+// stereo=, which injects Google's Spherical Video V1 box into the video
+// track — the form every VR player and YouTube understand.
+
+// sphericalV1UUID identifies the Spherical Video V1 uuid box.
+var sphericalV1UUID = []byte{0xff, 0xcc, 0x82, 0x63, 0xf8, 0x55, 0x4a, 0x93, 0x88, 0x14, 0x58, 0x7a, 0x02, 0x52, 0x1f, 0xdd}
+
+var stereoModes = map[string]bool{"mono": true, "top-bottom": true, "left-right": true}
+
+// sphericalContainers can store projection metadata.
+var sphericalContainers = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true}
+
+func parseSpherical(projection, stereo, ext string) error {
+	if projection == "" {
+		if stereo != "" {
+			return errors.New("stereo needs projection=equirectangular")
+		}
+		return nil
+	}
+	if projection != "equirectangular" {
+		return fmt.Errorf("invalid projection %q (equirectangular)", projection)
+	}
+	if stereo != "" && !stereoModes[stereo] {
+		return fmt.Errorf("invalid stereo %q (mono|top-bottom|left-right)", stereo)
+	}
+	if !outputContainers[strings.ToLower(ext)].MovFlags {
+		return fmt.Errorf("projection injection needs an mp4/m4v/mov output (got %s)", ext)
+	}
+	return nil
+}
+
+// sphericalProjection returns the source projection ("" if not 360°).
+func (p *probeResult) sphericalProjection() string {
+	if p == nil {
+		return ""
+	}
+	vs := p.firstStream("video")
+	if vs == nil {
+		return ""
+	}
+	for _, sd := range vs.SideDataList {
+		if sd["side_data_type"] == "Spherical Mapping" {
+			if proj, _ := sd["projection"].(string); proj != "" {
+				return proj
+			}
+			return "equirectangular"
+		}
+	}
+	return ""
+}
+
+// sphericalOut reports whether the output carries spherical metadata and
+// therefore needs -strict unofficial in the mp4/mov muxer.
+func sphericalOut(o compressOpts) bool {
+	return o.Projection != "" || o.Source.sphericalProjection() != ""
+}
+
+// sphericalNote warns when a 360° source goes into a container that drops
+// its projection.
+func sphericalNote(o compressOpts) string {
+	proj := o.Source.sphericalProjection()
+	if proj == "" || sphericalContainers[strings.ToLower(o.OutExt)] {
+		return ""
+	}
+	return fmt.Sprintf("source is a %s 360° video; %s cannot store its projection metadata", proj, o.OutExt)
+}
+
+func sphericalV1XML(projection, stereo string) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0"?><rdf:SphericalVideo xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:GSpherical="http://ns.google.com/videos/1.0/spherical/">`)
+	b.WriteString(`<GSpherical:Spherical>true</GSpherical:Spherical>`)
+	b.WriteString(`<GSpherical:Stitched>true</GSpherical:Stitched>`)
+	b.WriteString(`<GSpherical:StitchingSoftware>videocompress-http</GSpherical:StitchingSoftware>`)
+	b.WriteString(`<GSpherical:ProjectionType>` + projection + `</GSpherical:ProjectionType>`)
+	if stereo != "" {
+		b.WriteString(`<GSpherical:StereoMode>` + stereo + `</GSpherical:StereoMode>`)
+	}
+	b.WriteString(`</rdf:SphericalVideo>`)
+	return b.Bytes()
+}
+
+// ---------------------------
+// MP4 box surgery
+// ---------------------------
+
+type mp4Box struct {
+	typ              string
+	start, hdr, size int64
+}
+
+func (b mp4Box) end() int64 { return b.start + b.size }
+
+// parseBoxHeader reads the box header at off; fileSize bounds size==0 boxes.
+func parseBoxHeader(r io.ReaderAt, off, fileSize int64) (mp4Box, error) {
+	var h [16]byte
+	if _, err := r.ReadAt(h[:8], off); err != nil {
+		return mp4Box{}, err
+	}
+	b := mp4Box{typ: string(h[4:8]), start: off, hdr: 8, size: int64(binary.BigEndian.Uint32(h[:4]))}
+	switch b.size {
+	case 1:
+		if _, err := r.ReadAt(h[8:16], off+8); err != nil {
+			return b, err
+		}
+		b.hdr, b.size = 16, int64(binary.BigEndian.Uint64(h[8:16]))
+	case 0:
+		b.size = fileSize - off
+	}
+	if b.size < b.hdr || off+b.size > fileSize {
+		return b, fmt.Errorf("malformed %q box at %d", b.typ, off)
+	}
+	return b, nil
+}
+
+func listBoxes(r io.ReaderAt, from, to int64) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for off := from; off+8 <= to; {
+		b, err := parseBoxHeader(r, off, to)
+		if err != nil {
+			return nil, err
+		}
+		boxes = append(boxes, b)
+		off = b.end()
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []mp4Box, typ string) (mp4Box, bool) {
+	for _, b := range boxes {
+		if b.typ == typ {
+			return b, true
+		}
+	}
+	return mp4Box{}, false
+}
+
+// videoTrak returns the first trak of moov whose handler is 'vide'
+// (offsets relative to moov).
+func videoTrak(moov []byte, m mp4Box) (mp4Box, error) {
+	r := bytes.NewReader(moov)
+	traks, err := listBoxes(r, m.hdr, int64(len(moov)))
+	if err != nil {
+		return mp4Box{}, err
+	}
+	for _, t := range traks {
+		if t.typ != "trak" {
+			continue
+		}
+		kids, err := listBoxes(r, t.start+t.hdr, t.end())
+		if err != nil {
+			return mp4Box{}, err
+		}
+		mdia, ok := findBox(kids, "mdia")
+		if !ok {
+			continue
+		}
+		kids, err = listBoxes(r, mdia.start+mdia.hdr, mdia.end())
+		if err != nil {
+			return mp4Box{}, err
+		}
+		if hdlr, ok := findBox(kids, "hdlr"); ok && hdlr.size >= hdlr.hdr+12 {
+			off := hdlr.start + hdlr.hdr + 8 // version/flags, pre_defined
+			if string(moov[off:off+4]) == "vide" {
+				return t, nil
+			}
+		}
+	}
+	return mp4Box{}, errors.New("no video track")
+}
+
+// shiftChunkOffsets adds delta to every stco/co64 entry >= from inside the
+// container boxes of b[start:end].
+func shiftChunkOffsets(b []byte, start, end, from, delta int64) error {
+	kids, err := listBoxes(bytes.NewReader(b), start, end)
+	if err != nil {
+		return err
+	}
+	for _, k := range kids {
+		body := k.start + k.hdr
+		switch k.typ {
+		case "moov", "trak", "mdia", "minf", "stbl":
+			if err := shiftChunkOffsets(b, body, k.end(), from, delta); err != nil {
+				return err
+			}
+		case "stco":
+			n := int64(binary.BigEndian.Uint32(b[body+4:]))
+			for i := int64(0); i < n; i++ {
+				p := body + 8 + i*4
+				v := int64(binary.BigEndian.Uint32(b[p:]))
+				if v < from {
+					continue
+				}
+				if v+delta > 0xffffffff {
+					return errors.New("chunk offset overflow (stco)")
+				}
+				binary.BigEndian.PutUint32(b[p:], uint32(v+delta))
+			}
+		case "co64":
+			n := int64(binary.BigEndian.Uint32(b[body+4:]))
+			for i := int64(0); i < n; i++ {
+				p := body + 8 + i*8
+				if v := int64(binary.BigEndian.Uint64(b[p:])); v >= from {
+					binary.BigEndian.PutUint64(b[p:], uint64(v+delta))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// injectSphericalV1 adds the Spherical Video V1 uuid box to the video trak
+// of the mp4/mov at path, rewriting the file in place.
+func injectSphericalV1(path, projection, stereo string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	top, err := listBoxes(f, 0, st.Size())
+	if err != nil {
+		return err
+	}
+	m, ok := findBox(top, "moov")
+	if !ok {
+		return errors.New("no moov box")
+	}
+	if m.size > 64<<20 {
+		return errors.New("moov box too large")
+	}
+	moov := make([]byte, m.size)
+	if _, err := f.ReadAt(moov, m.start); err != nil {
+		return err
+	}
+	local := m
+	local.start = 0
+	trak, err := videoTrak(moov, local)
+	if err != nil {
+		return err
+	}
+	if m.hdr != 8 || trak.hdr != 8 {
+		return errors.New("64-bit moov/trak sizes are not supported")
+	}
+
+	xml := sphericalV1XML(projection, stereo)
+	box := make([]byte, 8, 8+16+len(xml))
+	binary.BigEndian.PutUint32(box, uint32(8+16+len(xml)))
+	copy(box[4:], "uuid")
+	box = append(append(box, sphericalV1UUID...), xml...)
+	delta := int64(len(box))
+
+	out := make([]byte, 0, int64(len(moov))+delta)
+	out = append(out, moov[:trak.end()]...)
+	out = append(out, box...)
+	out = append(out, moov[trak.end():]...)
+	binary.BigEndian.PutUint32(out[trak.start:], uint32(trak.size+delta))
+	binary.BigEndian.PutUint32(out, uint32(m.size+delta))
+	// faststart: media after moov moves by delta
+	if err := shiftChunkOffsets(out, 0, int64(len(out)), m.end(), delta); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".sph")
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, io.NewSectionReader(f, 0, m.start))
+	if err == nil {
+		_, err = w.Write(out)
+	}
+	if err == nil {
+		_, err = io.Copy(w, io.NewSectionReader(f, m.end(), st.Size()-m.end()))
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
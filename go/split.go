@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ======================
+// Output splitting for messaging apps (segment_max_size=, segment_max_sec=)
+// ======================
+
+// Messaging apps cap attachments (WhatsApp 16 MB, Telegram bots 50 MB) or
+// clip length. With segment_max_size and/or segment_max_sec the finished
+// output is cut, without re-encoding, into sequential parts that each stay
+// under the caps, and the result is a ZIP of the parts (stored, not
+// deflated: video does not compress further).
+//
+// Cuts land on keyframes. With segment_max_sec the encode forces a keyframe
+// every segment_max_sec so parts are exactly that long; for the size cap the
+// cut interval is estimated from the bitrate and tightened until every part
+// fits.
+
+const (
+	splitMinBytes = 256 << 10
+	splitMinSec   = 1.0
+	splitAttempts = 5
+)
+
+// parseByteSize reads "16MB", "50M", "1.5GiB" or plain bytes. KB/MB/GB are
+// decimal (what messaging apps state), K/M/G and KiB/MiB/GiB binary.
+func parseByteSize(s string) (int64, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	mult := 1.0
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1},
+	}
+	for _, u := range units {
+		if v, ok := strings.CutSuffix(strings.ToUpper(s), u.suffix); ok {
+			s, mult = strings.TrimSpace(v), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid size %q (e.g. 16MB)", orig)
+	}
+	return int64(n * mult), nil
+}
+
+func parseSplit(maxSize, maxSec string) (int64, float64, error) {
+	var bytes int64
+	var sec float64
+	if maxSize != "" {
+		n, err := parseByteSize(maxSize)
+		if err != nil {
+			return 0, 0, fmt.Errorf("segment_max_size: %w", err)
+		}
+		if n < splitMinBytes {
+			return 0, 0, fmt.Errorf("segment_max_size must be at least %s", humanBytes(splitMinBytes))
+		}
+		bytes = n
+	}
+	if maxSec != "" {
+		f, err := strconv.ParseFloat(maxSec, 64)
+		if err != nil || f < splitMinSec {
+			return 0, 0, fmt.Errorf("invalid segment_max_sec %q (at least %g)", maxSec, splitMinSec)
+		}
+		sec = f
+	}
+	return bytes, sec, nil
+}
+
+// splitInterval is the first cut interval to try.
+func splitInterval(o compressOpts, bytes int64, dur float64) float64 {
+	t := dur
+	if o.SplitMaxSec > 0 {
+		t = math.Min(t, o.SplitMaxSec)
+	}
+	if o.SplitMaxBytes > 0 && bytes > 0 {
+		// 8% headroom for keyframe placement and container overhead
+		t = math.Min(t, float64(o.SplitMaxBytes)*0.92/(float64(bytes)/dur))
+	}
+	return t
+}
+
+// splitOutput cuts outPath into parts under the caps and zips them as
+// <stem>.zip next to it. It returns the ZIP path and the number of parts.
+func splitOutput(ctx context.Context, requestID, outPath, stem string, o compressOpts) (string, int, error) {
+	st, err := os.Stat(outPath)
+	if err != nil {
+		return "", 0, err
+	}
+	src, err := probeFile(ctx, outPath)
+	if err != nil {
+		return "", 0, fmt.Errorf("probing output: %w", err)
+	}
+	dur := src.durationSec()
+	if dur <= 0 {
+		return "", 0, errors.New("output duration unknown")
+	}
+	ext := filepath.Ext(outPath)
+	dir := outPath + ".parts"
+	defer os.RemoveAll(dir)
+
+	t := splitInterval(o, st.Size(), dur)
+	var parts []string
+	for attempt := 1; ; attempt++ {
+		if t < splitMinSec {
+			return "", 0, fmt.Errorf("cannot cut parts under %s: keyframes are too far apart", humanBytes(o.SplitMaxBytes))
+		}
+		os.RemoveAll(dir)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", 0, err
+		}
+		args := []string{"-i", outPath, "-map", "0", "-c", "copy", "-f", "segment",
+			"-segment_time", strconv.FormatFloat(t, 'f', 3, 64), "-reset_timestamps", "1"}
+		if outputContainers[strings.ToLower(ext)].MovFlags {
+			args = append(args, "-segment_format_options", "movflags=+faststart")
+		}
+		if err := runFF(ctx, append(args, filepath.Join(dir, "part%03d"+ext))...); err != nil {
+			return "", 0, fmt.Errorf("splitting output: %w", err)
+		}
+		parts, _ = filepath.Glob(filepath.Join(dir, "part*"+ext))
+		sort.Strings(parts)
+		var largest int64
+		for _, p := range parts {
+			if fi, err := os.Stat(p); err == nil {
+				largest = max(largest, fi.Size())
+			}
+		}
+		if o.SplitMaxBytes == 0 || largest <= o.SplitMaxBytes {
+			break
+		}
+		if attempt == splitAttempts {
+			return "", 0, fmt.Errorf("a part is still %s after %d attempts, over segment_max_size %s",
+				humanBytes(largest), attempt, humanBytes(o.SplitMaxBytes))
+		}
+		logger.Printf("✂️ [%s] Largest part %s is over %s, cutting every %.1fs instead of %.1fs",
+			requestID, humanBytes(largest), humanBytes(o.SplitMaxBytes), t*float64(o.SplitMaxBytes)/float64(largest)*0.95, t)
+		t *= float64(o.SplitMaxBytes) / float64(largest) * 0.95
+	}
+
+	zipPath := withExt(outPath, ".zip")
+	if err := zipParts(zipPath, stem, ext, parts); err != nil {
+		os.Remove(zipPath)
+		return "", 0, err
+	}
+	logger.Printf("🗜️ [%s] Split into %d parts of up to %.1fs", requestID, len(parts), t)
+	return zipPath, len(parts), nil
+}
+
+// zipParts stores the parts as <stem>.part01<ext>, ... in zipPath.
+func zipParts(zipPath, stem, ext string, parts []string) error {
+	f, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+	width := max(2, len(strconv.Itoa(len(parts))))
+	names := make([]string, len(parts))
+	for i := range parts {
+		names[i] = fmt.Sprintf("%s.part%0*d%s", stem, width, i+1, ext)
+	}
+	if err := writeZip(f, names, parts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeZip stores paths[i] as names[i]. Media is stored, not deflated: it
+// does not compress further.
+func writeZip(w io.Writer, names, paths []string) error {
+	zw := zip.NewWriter(w)
+	for i, p := range paths {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     names[i],
+			Method:   zip.Store,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(fw, in)
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Storyboards (storyboard=1)
+// ======================
+
+// storyboard=1 stores sprite sheets of small frames of the compressed output
+// (storyboard_01.jpg...) and a WebVTT thumbnails track, storyboard.vtt, whose
+// cues point at one tile each (storyboard_01.jpg#xywh=x,y,w,h) for player
+// scrubber previews (Video.js, JW Player, Plyr, Shaka...). The sheets come
+// from one ffmpeg pass with the fps and tile filters. A frame is taken every
+// storyboard_interval seconds (default: one per second up to 100 frames,
+// spread wider for longer videos), storyboard_width pixels wide (default
+// 160), laid out storyboard_columns across (default 10) and 10 rows down per
+// sheet. The cue image names are relative, so the track works from
+// /dl/{id}/storyboard.vtt as it is.
+
+const (
+	storyboardDefaultWidth   = 160
+	storyboardDefaultColumns = 10
+	storyboardRows           = 10
+	storyboardTargetTiles    = 100
+	storyboardMaxTiles       = 1000
+)
+
+type storyboardSpec struct {
+	Interval float64 // seconds between frames, 0 = auto
+	Width    int
+	Columns  int
+}
+
+// storyboardInfo describes a stored storyboard for /meta.
+type storyboardInfo struct {
+	IntervalSec float64  `json:"interval_sec"`
+	TileWidth   int      `json:"tile_width"`
+	TileHeight  int      `json:"tile_height"`
+	Columns     int      `json:"columns"`
+	Rows        int      `json:"rows"`
+	Tiles       int      `json:"tiles"`
+	Sheets      []string `json:"sheets"`
+	VTTURL      string   `json:"vtt_url,omitempty"`
+	SheetURLs   []string `json:"sheet_urls,omitempty"`
+}
+
+func parseStoryboard(on, interval, width, columns string) (*storyboardSpec, error) {
+	switch strings.ToLower(on) {
+	case "", "0", "false", "no":
+		if interval != "" || width != "" || columns != "" {
+			return nil, errors.New("storyboard_interval, storyboard_width and storyboard_columns need storyboard=1")
+		}
+		return nil, nil
+	case "1", "true", "yes":
+	default:
+		return nil, fmt.Errorf("invalid storyboard %q (0|1)", on)
+	}
+	s := &storyboardSpec{Width: storyboardDefaultWidth, Columns: storyboardDefaultColumns}
+	if interval != "" {
+		v, err := strconv.ParseFloat(interval, 64)
+		if err != nil || v < 0.5 || v > 600 {
+			return nil, fmt.Errorf("invalid storyboard_interval %q (0.5-600 seconds)", interval)
+		}
+		s.Interval = v
+	}
+	if width != "" {
+		n, err := strconv.Atoi(width)
+		if err != nil || n < 32 || n > 480 {
+			return nil, fmt.Errorf("invalid storyboard_width %q (32-480)", width)
+		}
+		s.Width = n
+	}
+	if columns != "" {
+		n, err := strconv.Atoi(columns)
+		if err != nil || n < 1 || n > 20 {
+			return nil, fmt.Errorf("invalid storyboard_columns %q (1-20)", columns)
+		}
+		s.Columns = n
+	}
+	return s, nil
+}
+
+// interval returns the seconds between frames for a video of dur seconds,
+// widened so a storyboard never exceeds storyboardMaxTiles.
+func (s *storyboardSpec) interval(dur float64) float64 {
+	iv := s.Interval
+	if iv == 0 {
+		iv = math.Max(1, math.Ceil(dur/storyboardTargetTiles))
+	}
+	if dur/iv > storyboardMaxTiles {
+		iv = math.Ceil(dur / storyboardMaxTiles)
+	}
+	return iv
+}
+
+// makeStoryboard renders the sprite sheets and WebVTT track of outPath and
+// adds them to the entry's artifacts.
+func makeStoryboard(ctx context.Context, requestID, outPath string, o compressOpts, e *resultEntry) error {
+	out, err := probeFile(ctx, outPath)
+	if err != nil {
+		return err
+	}
+	vs := out.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return errors.New("no video stream")
+	}
+	dur := out.durationSec()
+	if dur <= 0 {
+		return errors.New("unknown duration")
+	}
+	s := o.Storyboard
+	w, h := vs.Width, vs.Height
+	if r := vs.rotation(); r == 90 || r == 270 {
+		w, h = h, w
+	}
+	tw := s.Width
+	th := max(2, int(math.Round(float64(tw)*float64(h)/float64(w)/2))*2)
+	iv := s.interval(dur)
+
+	stem := strings.TrimSuffix(outPath, filepath.Ext(outPath)) + "_storyboard_"
+	vf := fmt.Sprintf("fps=1/%s,scale=%d:%d:flags=lanczos,tile=%dx%d",
+		strconv.FormatFloat(iv, 'f', -1, 64), tw, th, s.Columns, storyboardRows)
+	if err := runFF(ctx, "-i", outPath, "-an", "-sn", "-dn", "-vf", vf,
+		"-q:v", "4", "-start_number", "1", stem+"%02d.jpg"); err != nil {
+		partial, _ := filepath.Glob(stem + "*.jpg")
+		for _, p := range partial {
+			os.Remove(p)
+		}
+		return err
+	}
+
+	perSheet := s.Columns * storyboardRows
+	tiles := int(math.Ceil(dur / iv))
+	var sheets []string
+	for i := 1; (i-1)*perSheet < tiles; i++ {
+		path := fmt.Sprintf("%s%02d.jpg", stem, i)
+		if _, err := os.Stat(path); err != nil {
+			break
+		}
+		sheets = append(sheets, path)
+	}
+	if len(sheets) == 0 {
+		return errors.New("no sprite sheets written")
+	}
+	tiles = min(tiles, len(sheets)*perSheet)
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := range tiles {
+		sheet, n := i/perSheet, i%perSheet
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTime(float64(i)*iv), vttTime(math.Min(float64(i+1)*iv, dur)),
+			storyboardSheetName(sheet+1), n%s.Columns*tw, n/s.Columns*th, tw, th)
+	}
+	vttPath := withExt(outPath, "_storyboard.vtt")
+	if err := os.WriteFile(vttPath, []byte(b.String()), 0o644); err != nil {
+		for _, p := range sheets {
+			os.Remove(p)
+		}
+		return err
+	}
+
+	info := &storyboardInfo{IntervalSec: iv, TileWidth: tw, TileHeight: th, Columns: s.Columns, Rows: storyboardRows, Tiles: tiles}
+	for i, p := range sheets {
+		name := storyboardSheetName(i + 1)
+		e.Artifacts[name] = p
+		info.Sheets = append(info.Sheets, name)
+	}
+	e.Artifacts["storyboard.vtt"] = vttPath
+	e.Storyboard = info
+	logger.Printf("🧩 [%s] Storyboard: %d tiles of %dx%d every %gs on %d sheets", requestID, tiles, tw, th, iv, len(sheets))
+	return nil
+}
+
+func storyboardSheetName(n int) string {
+	return fmt.Sprintf("storyboard_%02d.jpg", n)
+}
+
+// vttTime formats seconds as a WebVTT timestamp (HH:MM:SS.mmm).
+func vttTime(sec float64) string {
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// storyboardView adds the download URLs for /meta.
+func storyboardView(id string, e *resultEntry) *storyboardInfo {
+	v := *e.Storyboard
+	v.VTTURL = "/dl/" + id + "/storyboard.vtt"
+	v.SheetURLs = nil
+	for _, name := range v.Sheets {
+		v.SheetURLs = append(v.SheetURLs, "/dl/"+id+"/"+name)
+	}
+	return &v
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseStoryboard(t *testing.T) {
+	tests := []struct {
+		name                         string
+		on, interval, width, columns string
+		want                         *storyboardSpec // nil: no storyboard
+		wantErr                      bool
+	}{
+		{name: "off"},
+		{name: "explicitly off", on: "0"},
+		{name: "defaults", on: "1", want: &storyboardSpec{Width: 160, Columns: 10}},
+		{name: "true", on: "TRUE", want: &storyboardSpec{Width: 160, Columns: 10}},
+		{name: "all set", on: "yes", interval: "2.5", width: "240", columns: "5", want: &storyboardSpec{Interval: 2.5, Width: 240, Columns: 5}},
+		{name: "bad switch", on: "maybe", wantErr: true},
+		{name: "options while off", width: "240", wantErr: true},
+		{name: "interval too short", on: "1", interval: "0.1", wantErr: true},
+		{name: "interval too long", on: "1", interval: "601", wantErr: true},
+		{name: "interval not a number", on: "1", interval: "1s", wantErr: true},
+		{name: "width too small", on: "1", width: "16", wantErr: true},
+		{name: "width too large", on: "1", width: "500", wantErr: true},
+		{name: "no columns", on: "1", columns: "0", wantErr: true},
+		{name: "too many columns", on: "1", columns: "21", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStoryboard(tt.on, tt.interval, tt.width, tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStoryboard error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("parseStoryboard = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoryboardInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval float64
+		dur      float64
+		want     float64
+	}{
+		{name: "short video", dur: 50, want: 1},
+		{name: "auto spreads to about 100 frames", dur: 3600, want: 36},
+		{name: "explicit", interval: 2, dur: 600, want: 2},
+		{name: "explicit widened to the tile cap", interval: 0.5, dur: 3600, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := storyboardSpec{Interval: tt.interval}
+			if got := s.interval(tt.dur); got != tt.want {
+				t.Errorf("interval(%g) = %g, want %g", tt.dur, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Maintenance scheduler (+ /admin/tasks)
+// ======================
+
+// taskConfig is one scheduled task from the config file.
+type taskConfig struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"` // cron expression, @daily, "@every 15m"
+	Action   string `json:"action"`   // purge_outputs|compact_jobs|rotate_logs|purge_checkpoints|purge_inputs
+	MaxAge   string `json:"max_age,omitempty"`
+	Keep     int    `json:"keep,omitempty"` // rotate_logs: rotated files to keep
+
+	sched  cronSchedule
+	maxAge time.Duration
+}
+
+var taskActions = map[string]func(t *taskConfig) (string, error){
+	"purge_outputs":     purgeOutputs,
+	"compact_jobs":      compactJobs,
+	"rotate_logs":       rotateLogs,
+	"purge_checkpoints": purgeCheckpoints,
+	"purge_inputs":      purgeInputs,
+}
+
+func defaultTasks() []taskConfig {
+	return []taskConfig{
+		{Name: "purge-outputs", Schedule: "*/15 * * * *", Action: "purge_outputs", MaxAge: "24h"},
+		{Name: "compact-jobs", Schedule: "@hourly", Action: "compact_jobs", MaxAge: "24h"},
+		{Name: "rotate-logs", Schedule: "@daily", Action: "rotate_logs", Keep: 7},
+		{Name: "purge-checkpoints", Schedule: "@hourly", Action: "purge_checkpoints", MaxAge: "72h"},
+		{Name: "purge-inputs", Schedule: "*/5 * * * *", Action: "purge_inputs"},
+	}
+}
+
+func (t *taskConfig) validate() error {
+	if t.Name == "" {
+		return errors.New("name required")
+	}
+	if _, ok := taskActions[t.Action]; !ok {
+		return fmt.Errorf("unknown action %q (purge_outputs|compact_jobs|rotate_logs|purge_checkpoints|purge_inputs)", t.Action)
+	}
+	s, err := parseCron(t.Schedule)
+	if err != nil {
+		return err
+	}
+	t.sched = s
+	if t.MaxAge != "" {
+		if t.maxAge, err = time.ParseDuration(t.MaxAge); err != nil || t.maxAge <= 0 {
+			return fmt.Errorf("invalid max_age %q", t.MaxAge)
+		}
+	} else if t.Action != "rotate_logs" && t.Action != "purge_inputs" {
+		return errors.New("max_age required")
+	}
+	if t.Keep <= 0 {
+		t.Keep = 7
+	}
+	return nil
+}
+
+// taskState is the run history shown in /admin/tasks.
+type taskState struct {
+	Running    bool
+	Runs       int
+	LastRun    time.Time
+	LastMs     int64
+	LastResult string
+	LastError  string
+}
+
+var (
+	taskMu     sync.Mutex
+	taskStates = map[string]*taskState{}
+)
+
+func stateOf(name string) *taskState {
+	st, ok := taskStates[name]
+	if !ok {
+		st = &taskState{}
+		taskStates[name] = st
+	}
+	return st
+}
+
+// runTask executes t unless it is still running from a previous trigger.
+func runTask(t taskConfig, trigger string) bool {
+	taskMu.Lock()
+	st := stateOf(t.Name)
+	if st.Running {
+		taskMu.Unlock()
+		return false
+	}
+	st.Running = true
+	taskMu.Unlock()
+
+	start := time.Now()
+	logger.Printf("🗓️ [TASK] %s (%s) started by %s", t.Name, t.Action, trigger)
+	result, err := taskActions[t.Action](&t)
+
+	taskMu.Lock()
+	st.Running = false
+	st.Runs++
+	st.LastRun = start
+	st.LastMs = time.Since(start).Milliseconds()
+	st.LastResult = result
+	st.LastError = ""
+	if err != nil {
+		st.LastError = err.Error()
+	}
+	taskMu.Unlock()
+	if err != nil {
+		logger.Printf("❌ [TASK] %s failed: %v", t.Name, err)
+	} else {
+		logger.Printf("✅ [TASK] %s: %s", t.Name, result)
+	}
+	return true
+}
+
+// startScheduler checks the configured tasks at every minute boundary.
+func startScheduler() {
+	go func() {
+		for {
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+			tick := time.Now().Truncate(time.Minute)
+			for _, t := range currentConfig().Tasks {
+				taskMu.Lock()
+				last := stateOf(t.Name).LastRun
+				taskMu.Unlock()
+				if t.sched.matches(tick, last) {
+					go runTask(t, "schedule")
+				}
+			}
+		}
+	}()
+}
+
+// ---------------------------
+// Actions
+// ---------------------------
+
+// purgeOutputs deletes stored results (file + artifacts) older than max_age.
+func purgeOutputs(t *taskConfig) (string, error) {
+	cutoff := time.Now().Add(-t.maxAge)
+	var victims []*resultEntry
+	storeMu.Lock()
+	for id, e := range store {
+		if e.Created.Before(cutoff) {
+			victims = append(victims, e)
+			delete(store, id)
+			forgetResult(id)
+		}
+	}
+	storeMu.Unlock()
+	victims = append(victims, expiredIndexEntries(cutoff)...)
+	var freed int64
+	for _, e := range victims {
+		if st, err := os.Stat(e.FilePath); err == nil {
+			freed += st.Size()
+		}
+		os.Remove(e.FilePath)
+		for _, p := range e.Artifacts {
+			os.Remove(p)
+		}
+	}
+	return fmt.Sprintf("purged %d results (%s)", len(victims), humanBytes(freed)), nil
+}
+
+// compactJobs drops finished jobs older than max_age from the registry.
+func compactJobs(t *taskConfig) (string, error) {
+	cutoff := time.Now().Add(-t.maxAge)
+	n := 0
+	jobsMu.Lock()
+	for id, j := range jobs {
+		if j.terminal() && j.Finished.Before(cutoff) {
+			delete(jobs, id)
+			n++
+		}
+	}
+	left := len(jobs)
+	jobsMu.Unlock()
+	liveMu.Lock()
+	for id, s := range liveSessions {
+		if s.State != liveListening && s.Ended.Before(cutoff) {
+			delete(liveSessions, id)
+			n++
+		}
+	}
+	liveMu.Unlock()
+	return fmt.Sprintf("removed %d finished entries, %d jobs left", n, left), nil
+}
+
+func rotateLogs(t *taskConfig) (string, error) {
+	if logFile == nil {
+		return "skipped (LOG_FILE not set)", nil
+	}
+	rotated, err := logFile.rotate(t.Keep)
+	if err != nil {
+		return "", err
+	}
+	return "rotated to " + rotated, nil
+}
+
+// ---------------------------
+// /admin/tasks
+// ---------------------------
+
+// adminAuthorized checks X-Admin-Token against ADMIN_TOKEN in constant time.
+// Without ADMIN_TOKEN the admin routes do not exist.
+func adminAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Token")), []byte(token)) == 1 {
+		return true
+	}
+	http.Error(w, "admin token required", http.StatusUnauthorized)
+	return false
+}
+
+// adminTasksHandler serves GET /admin/tasks and POST /admin/tasks/{name}/run.
+func adminTasksHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(6)
+	if !adminAuthorized(w, r) {
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/tasks"), "/")
+	tasks := currentConfig().Tasks
+
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		now := time.Now()
+		list := make([]map[string]any, 0, len(tasks))
+		taskMu.Lock()
+		for _, t := range tasks {
+			st := stateOf(t.Name)
+			v := map[string]any{
+				"name":     t.Name,
+				"action":   t.Action,
+				"schedule": t.Schedule,
+				"running":  st.Running,
+				"runs":     st.Runs,
+				"next_run": t.sched.next(now, st.LastRun).UTC().Format(time.RFC3339),
+			}
+			if t.MaxAge != "" {
+				v["max_age"] = t.MaxAge
+			}
+			if !st.LastRun.IsZero() {
+				v["last_run"] = st.LastRun.UTC().Format(time.RFC3339)
+				v["last_duration_ms"] = st.LastMs
+				v["last_result"] = st.LastResult
+			}
+			if st.LastError != "" {
+				v["last_error"] = st.LastError
+			}
+			list = append(list, v)
+		}
+		taskMu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i]["name"].(string) < list[j]["name"].(string) })
+		writeJSON(w, http.StatusOK, map[string]any{"tasks": list})
+		return
+	}
+
+	name, action, _ := strings.Cut(rest, "/")
+	if action != "run" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	for _, t := range tasks {
+		if t.Name == name {
+			logger.Printf("🗓️ [%s] Manual run of task %s from %s", requestID, name, r.RemoteAddr)
+			if !runTask(t, "admin") {
+				http.Error(w, "task is already running", http.StatusConflict)
+				return
+			}
+			taskMu.Lock()
+			st := *stateOf(name)
+			taskMu.Unlock()
+			writeJSON(w, http.StatusOK, map[string]any{"name": name, "result": st.LastResult, "error": st.LastError, "duration_ms": st.LastMs})
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
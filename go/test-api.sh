@@ -9,7 +9,7 @@ echo ""
 # Check if server is running
 if ! curl -s http://localhost:8080/health > /dev/null; then
     echo "❌ Server is not running. Please start the server first:"
-    echo "   cd go && go run main.go"
+    echo "   cd go && go run ."
     exit 1
 fi
 
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// Download bandwidth limits
+// ======================
+
+// DL_RATE_LIMIT caps each download connection, DL_GLOBAL_LIMIT caps all of
+// them together (bytes/s, k/M/G suffixes, empty or 0 = unlimited). Keeps big
+// result downloads from saturating the uplink while uploads are running.
+
+const throttleChunk = 32 << 10
+
+// rateLimiter is a token bucket refilled at rate bytes/s.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: float64(rate), burst: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// wait reserves n bytes and sleeps until they are covered.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var d time.Duration
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if d == 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRate parses "500k", "5M", "1G" or plain bytes per second.
+func parseRate(s string) (int64, error) {
+	orig := s
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q", orig)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+var (
+	globalEgressOnce sync.Once
+	globalEgress     *rateLimiter
+)
+
+func globalEgressLimiter() *rateLimiter {
+	globalEgressOnce.Do(func() {
+		rate, err := parseRate(envOr("DL_GLOBAL_LIMIT", ""))
+		if err != nil {
+			logger.Printf("⚠️ [MAIN] DL_GLOBAL_LIMIT: %v (ignored)", err)
+		}
+		if rate > 0 {
+			globalEgress = newRateLimiter(rate)
+		}
+	})
+	return globalEgress
+}
+
+// throttledWriter paces body writes through the connection and global
+// limiters. It deliberately hides io.ReaderFrom so http.ServeFile cannot
+// bypass it with sendfile.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	limiters []*rateLimiter
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), throttleChunk)
+		for _, l := range t.limiters {
+			if err := l.wait(t.ctx, n); err != nil {
+				return written, err
+			}
+		}
+		m, err := t.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// throttle wraps w with the configured download limits (w itself when none).
+func throttle(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	var limiters []*rateLimiter
+	if rate, err := parseRate(envOr("DL_RATE_LIMIT", "")); err == nil && rate > 0 {
+		limiters = append(limiters, newRateLimiter(rate))
+	}
+	if g := globalEgressLimiter(); g != nil {
+		limiters = append(limiters, g)
+	}
+	if len(limiters) == 0 {
+		return w
+	}
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), limiters: limiters}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Thumbnails (POST /thumbnail, thumbnails=N on /compress)
+// ======================
+
+// POST /thumbnail extracts a few small stills from an upload ("file") or a
+// stored result ("id"): "count" evenly spaced ones (default 1), or one per
+// "times" timestamp. A single thumbnail comes back as the image itself; more
+// than one (or Accept: application/json) as JSON with the images inline as
+// data URIs. thumbnails=N on /compress takes N from the compressed output
+// and stores them as thumb_01.jpg... artifacts, listed with their download
+// URLs under "thumbnails" in the result metadata. For many frames or ZIPs
+// use /frames.
+
+const (
+	thumbnailsMax         = 20
+	thumbnailDefaultWidth = 320
+)
+
+type thumbSpec struct {
+	Count int       // evenly spaced stills (when Times is empty)
+	Times []float64 // explicit timestamps
+	frame frameRequest
+}
+
+// thumbnailInfo describes one extracted thumbnail.
+type thumbnailInfo struct {
+	Name    string  `json:"name"`
+	TimeSec float64 `json:"time_sec"`
+	URL     string  `json:"url,omitempty"`  // /compress: the artifact download
+	Data    string  `json:"data,omitempty"` // /thumbnail: data URI
+
+	path string
+}
+
+// parseThumbSpec reads a count or a list of timestamps plus the image
+// format and width. def is the count when neither is given (0 = none).
+func parseThumbSpec(count, times, format, width string, def int) (*thumbSpec, error) {
+	ts := &thumbSpec{Count: def, frame: frameRequest{Format: "jpg", Width: thumbnailDefaultWidth}}
+	switch {
+	case count != "" && times != "":
+		return nil, errors.New("give a thumbnail count or times, not both")
+	case count != "":
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || n > thumbnailsMax {
+			return nil, fmt.Errorf("invalid thumbnail count %q (1-%d)", count, thumbnailsMax)
+		}
+		ts.Count = n
+	case times != "":
+		for _, s := range strings.Split(times, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil || v < 0 || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("invalid thumbnail time %q (seconds)", s)
+			}
+			ts.Times = append(ts.Times, v)
+		}
+		if len(ts.Times) > thumbnailsMax {
+			return nil, fmt.Errorf("at most %d thumbnails", thumbnailsMax)
+		}
+	}
+	if ts.Count == 0 && ts.Times == nil {
+		if format != "" || width != "" {
+			return nil, errors.New("thumbnail_format and thumbnail_width need thumbnails=N")
+		}
+		return nil, nil
+	}
+	switch f := strings.ToLower(format); f {
+	case "", "jpg", "jpeg":
+	case "png":
+		ts.frame.Format = "png"
+	default:
+		return nil, fmt.Errorf("invalid thumbnail format %q (jpg|png)", f)
+	}
+	if width != "" {
+		n, err := strconv.Atoi(width)
+		if err != nil || n < 16 || n > 3840 {
+			return nil, fmt.Errorf("invalid thumbnail width %q (16-3840)", width)
+		}
+		ts.frame.Width = n
+	}
+	return ts, nil
+}
+
+// times returns the timestamps to grab in a video of dur seconds: the given
+// ones inside it, or Count points splitting it evenly (skipping the very
+// start and end, which are often black).
+func (ts *thumbSpec) times(dur float64) []float64 {
+	if ts.Times != nil {
+		var out []float64
+		for _, t := range ts.Times {
+			if dur <= 0 || t < dur {
+				out = append(out, t)
+			}
+		}
+		return out
+	}
+	if dur <= 0 {
+		return []float64{0}
+	}
+	out := make([]float64, ts.Count)
+	for i := range out {
+		out[i] = math.Round(dur*float64(i+1)/float64(ts.Count+1)*1000) / 1000
+	}
+	return out
+}
+
+// extractThumbnails writes the thumbnails of src as <prefix>_NN.<ext>.
+func extractThumbnails(ctx context.Context, src, prefix string, ts *thumbSpec, dur float64) ([]thumbnailInfo, error) {
+	var out []thumbnailInfo
+	for _, t := range ts.times(dur) {
+		name := fmt.Sprintf("thumb_%02d.%s", len(out)+1, ts.frame.Format)
+		path := prefix + "_" + name
+		args := append([]string{"-ss", strconv.FormatFloat(t, 'f', 3, 64), "-i", src, "-frames:v", "1"}, ts.frame.outArgs("")...)
+		if err := runFF(ctx, append(args, path)...); err != nil {
+			removeThumbnails(out)
+			return nil, fmt.Errorf("thumbnail at %.3fs: %w", t, err)
+		}
+		if _, err := os.Stat(path); err == nil {
+			out = append(out, thumbnailInfo{Name: name, TimeSec: t, path: path})
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no frames at those times")
+	}
+	return out, nil
+}
+
+func removeThumbnails(thumbs []thumbnailInfo) {
+	for _, t := range thumbs {
+		os.Remove(t.path)
+	}
+}
+
+// makeThumbnails stores thumbnails=N of the output as artifacts.
+func makeThumbnails(ctx context.Context, requestID, outPath string, o compressOpts, e *resultEntry) error {
+	dur := 0.0
+	if p, err := probeFile(ctx, outPath); err == nil {
+		dur = p.durationSec()
+	}
+	thumbs, err := extractThumbnails(ctx, outPath, strings.TrimSuffix(outPath, filepath.Ext(outPath)), o.Thumbnails, dur)
+	if err != nil {
+		return err
+	}
+	for _, t := range thumbs {
+		e.Artifacts[t.Name] = t.path
+	}
+	e.Thumbnails = thumbs
+	logger.Printf("🖼️ [%s] Extracted %d thumbnails", requestID, len(thumbs))
+	return nil
+}
+
+// thumbnailsView adds the download URLs for /meta.
+func thumbnailsView(id string, e *resultEntry) []thumbnailInfo {
+	out := make([]thumbnailInfo, len(e.Thumbnails))
+	for i, t := range e.Thumbnails {
+		t.URL = "/dl/" + id + "/" + t.Name
+		out[i] = t
+	}
+	return out
+}
+
+func thumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(8)
+	logger.Printf("🖼️ [%s] New thumbnail request from %s", requestID, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	target, _, name, cleanup, ok := requestMedia(w, r, requestID)
+	if !ok {
+		return
+	}
+	defer cleanup()
+	ts, err := parseThumbSpec(r.FormValue("count"), r.FormValue("times"), r.FormValue("format"), r.FormValue("width"), 1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	src, err := probeFile(r.Context(), target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if src.firstStream("video") == nil {
+		http.Error(w, "no video stream", http.StatusUnprocessableEntity)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "thumbs_"+requestID+"_")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	thumbs, err := extractThumbnails(r.Context(), target, filepath.Join(dir, "t"), ts, src.durationSec())
+	if err != nil {
+		logger.Printf("❌ [%s] Thumbnails: %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if stem == "" {
+		stem = "video"
+	}
+	mime := contentTypeFor(thumbs[0].Name)
+
+	if len(thumbs) == 1 && !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", mime)
+		w.Header().Set("Content-Disposition", `inline; filename="`+sanitizeFilename(stem+"_"+thumbs[0].Name)+`"`)
+		w.Header().Set("X-Thumbnail-Time", strconv.FormatFloat(thumbs[0].TimeSec, 'f', 3, 64))
+		http.ServeFile(w, r, thumbs[0].path)
+		logger.Printf("✅ [%s] Sent thumbnail at %.3fs", requestID, thumbs[0].TimeSec)
+		return
+	}
+	for i := range thumbs {
+		b, err := os.ReadFile(thumbs[i].path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		thumbs[i].Name = stem + "_" + thumbs[i].Name
+		thumbs[i].Data = "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(b)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"thumbnails": thumbs})
+	logger.Printf("✅ [%s] Sent %d thumbnails", requestID, len(thumbs))
+}
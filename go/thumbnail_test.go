@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseThumbSpec(t *testing.T) {
+	tests := []struct {
+		name                        string
+		count, times, format, width string
+		def                         int
+		want                        *thumbSpec // nil: no thumbnails
+		wantErr                     bool
+	}{
+		{name: "none"},
+		{name: "default count", def: 1, want: &thumbSpec{Count: 1, frame: frameRequest{Format: "jpg", Width: 320}}},
+		{name: "count", count: "3", want: &thumbSpec{Count: 3, frame: frameRequest{Format: "jpg", Width: 320}}},
+		{name: "times", times: "1, 2.5,0", want: &thumbSpec{Times: []float64{1, 2.5, 0}, frame: frameRequest{Format: "jpg", Width: 320}}},
+		{name: "png and width", count: "2", format: "PNG", width: "640", want: &thumbSpec{Count: 2, frame: frameRequest{Format: "png", Width: 640}}},
+		{name: "jpeg", count: "2", format: "jpeg", want: &thumbSpec{Count: 2, frame: frameRequest{Format: "jpg", Width: 320}}},
+		{name: "count and times", count: "2", times: "1", wantErr: true},
+		{name: "zero count", count: "0", wantErr: true},
+		{name: "count over max", count: "21", wantErr: true},
+		{name: "count not a number", count: "two", wantErr: true},
+		{name: "negative time", times: "1,-1", wantErr: true},
+		{name: "infinite time", times: "inf", wantErr: true},
+		{name: "empty time", times: "1,,2", wantErr: true},
+		{name: "too many times", times: "0,1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20", wantErr: true},
+		{name: "format without count", format: "png", wantErr: true},
+		{name: "width without count", width: "640", wantErr: true},
+		{name: "bad format", count: "1", format: "gif", wantErr: true},
+		{name: "width too small", count: "1", width: "8", wantErr: true},
+		{name: "width too large", count: "1", width: "4000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseThumbSpec(tt.count, tt.times, tt.format, tt.width, tt.def)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseThumbSpec error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if (got == nil) != (tt.want == nil) {
+				t.Fatalf("parseThumbSpec = %+v, want %+v", got, tt.want)
+			}
+			if got != nil && (got.Count != tt.want.Count || !slices.Equal(got.Times, tt.want.Times) || got.frame.Format != tt.want.frame.Format || got.frame.Width != tt.want.frame.Width) {
+				t.Errorf("parseThumbSpec = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThumbSpecTimes(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   thumbSpec
+		dur  float64
+		want []float64
+	}{
+		{name: "evenly spaced", ts: thumbSpec{Count: 3}, dur: 100, want: []float64{25, 50, 75}},
+		{name: "unknown duration", ts: thumbSpec{Count: 3}, want: []float64{0}},
+		{name: "times past the end dropped", ts: thumbSpec{Times: []float64{1, 10, 30}}, dur: 20, want: []float64{1, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ts.times(tt.dur); !slices.Equal(got, tt.want) {
+				t.Errorf("times(%g) = %v, want %v", tt.dur, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ======================
+// API keys + one-time upload tokens (POST /upload-tokens)
+// ======================
+
+// With API_KEYS (comma-separated) set, the upload endpoints need an
+// X-API-Key from that list. A backend holding a key can instead hand an
+// end user's browser a token from POST /upload-tokens: it is signed with
+// UPLOAD_TOKEN_SECRET, valid for one /compress upload before it expires,
+// and carries the limits of that upload: a size cap, options forced to
+// fixed values, and the options the browser may still choose. The token is
+// sent as X-Upload-Token or ?upload_token= so it is checked before the body
+// is read.
+//
+// Used tokens are remembered in memory until they expire, so with several
+// replicas a token could be replayed once per replica; share
+// UPLOAD_TOKEN_SECRET and keep the TTL short.
+
+const (
+	uploadTokenDefaultTTL = 15 * time.Minute
+	uploadTokenMaxTTL     = 24 * time.Hour
+)
+
+// uploadGrant is the signed token payload.
+type uploadGrant struct {
+	ID       string            `json:"id"`
+	Issuer   string            `json:"iss"` // fingerprint of the issuing API key
+	Expires  int64             `json:"exp"` // unix seconds
+	MaxBytes int64             `json:"max_bytes,omitempty"`
+	Params   map[string]string `json:"params,omitempty"` // forced option values
+	Allow    []string          `json:"allow,omitempty"`  // options the uploader may set
+}
+
+// apiKeys are API_KEYS plus the api_keys of the config file.
+func apiKeys() []string {
+	keys := slices.Clone(currentConfig().APIKeys)
+	for _, k := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// validAPIKey compares k against API_KEYS in constant time.
+func validAPIKey(k string) bool {
+	ok := false
+	for _, want := range apiKeys() {
+		if hmac.Equal([]byte(k), []byte(want)) {
+			ok = true
+		}
+	}
+	return k != "" && ok
+}
+
+func keyFingerprint(k string) string {
+	sum := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(sum[:6])
+}
+
+var (
+	tokenSecretOnce sync.Once
+	tokenSecret     []byte
+)
+
+// uploadTokenSecret is UPLOAD_TOKEN_SECRET, or a random per-process secret
+// (tokens then only work on the replica that issued them, until restart).
+func uploadTokenSecret() []byte {
+	tokenSecretOnce.Do(func() {
+		if s := os.Getenv("UPLOAD_TOKEN_SECRET"); s != "" {
+			tokenSecret = []byte(s)
+			return
+		}
+		tokenSecret = make([]byte, 32)
+		rand.Read(tokenSecret)
+		logger.Printf("⚠️ [MAIN] UPLOAD_TOKEN_SECRET not set; upload tokens only work on this instance until restart")
+	})
+	return tokenSecret
+}
+
+func signToken(g *uploadGrant) string {
+	payload, _ := json.Marshal(g)
+	p := base64.RawURLEncoding.EncodeToString(payload)
+	sig := hmacSHA256(uploadTokenSecret(), p)
+	return p + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+var errBadToken = errors.New("invalid upload token")
+
+// parseToken verifies the signature and expiry of a token.
+func parseToken(tok string, now time.Time) (*uploadGrant, error) {
+	p, s, ok := strings.Cut(tok, ".")
+	if !ok {
+		return nil, errBadToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || !hmac.Equal(sig, hmacSHA256(uploadTokenSecret(), p)) {
+		return nil, errBadToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return nil, errBadToken
+	}
+	var g uploadGrant
+	if err := json.Unmarshal(payload, &g); err != nil || g.ID == "" {
+		return nil, errBadToken
+	}
+	if now.Unix() >= g.Expires {
+		return nil, errors.New("upload token expired")
+	}
+	return &g, nil
+}
+
+// usedTokens remembers consumed token IDs until they expire.
+var usedTokens = struct {
+	sync.Mutex
+	m map[string]int64
+}{m: map[string]int64{}}
+
+// consumeToken marks g used; false when it already was.
+func consumeToken(g *uploadGrant, now time.Time) bool {
+	usedTokens.Lock()
+	defer usedTokens.Unlock()
+	for id, exp := range usedTokens.m {
+		if now.Unix() >= exp {
+			delete(usedTokens.m, id)
+		}
+	}
+	if _, used := usedTokens.m[g.ID]; used {
+		return false
+	}
+	usedTokens.m[g.ID] = g.Expires
+	return true
+}
+
+type grantCtxKey struct{}
+
+// grantOf returns the upload token the request was admitted with, or nil.
+func grantOf(r *http.Request) *uploadGrant {
+	g, _ := r.Context().Value(grantCtxKey{}).(*uploadGrant)
+	return g
+}
+
+func requestToken(r *http.Request) string {
+	if t := r.Header.Get("X-Upload-Token"); t != "" {
+		return t
+	}
+	return r.URL.Query().Get("upload_token")
+}
+
+// authorizeUpload admits an upload request with a valid API key or upload
+// token (open when API_KEYS is unset and no token is sent). On failure it
+// has answered the request.
+func authorizeUpload(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if tok := requestToken(r); tok != "" {
+		g, err := parseToken(tok, time.Now())
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return r, false
+		case r.URL.Path != "/compress":
+			http.Error(w, "upload tokens are only valid for /compress", http.StatusForbidden)
+			return r, false
+		case !consumeToken(g, time.Now()):
+			http.Error(w, "upload token already used", http.StatusUnauthorized)
+			return r, false
+		}
+		logger.Printf("🎟️ [%s] Upload admitted with token from %s", g.ID, g.Issuer)
+		return r.WithContext(context.WithValue(r.Context(), grantCtxKey{}, g)), true
+	}
+	if len(apiKeys()) == 0 || validAPIKey(r.Header.Get("X-API-Key")) {
+		return r, true
+	}
+	http.Error(w, "API key or upload token required", http.StatusUnauthorized)
+	return r, false
+}
+
+// requireKey guards the routes that expose jobs and results: with API_KEYS
+// set they need a valid X-API-Key, or the unexpired upload token that made
+// the job or result, so a browser can follow its own upload. Reading does
+// not consume the token.
+func requireKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorizeRead(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// authorizeRead is the check of requireKey. On failure it has answered the
+// request.
+func authorizeRead(w http.ResponseWriter, r *http.Request) bool {
+	if len(apiKeys()) == 0 || validAPIKey(r.Header.Get("X-API-Key")) {
+		return true
+	}
+	if tok := requestToken(r); tok != "" {
+		g, err := parseToken(tok, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return false
+		}
+		if !tokenReads(g, r) {
+			http.Error(w, "upload tokens only read the jobs and results they created", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	http.Error(w, "API key or upload token required", http.StatusUnauthorized)
+	return false
+}
+
+// tokenReads reports whether the upload token created what r reads: the job
+// of /jobs, /events and /progress or the result of /dl and /meta. Other
+// routes are API-key only.
+func tokenReads(g *uploadGrant, r *http.Request) bool {
+	route, rest, _ := strings.Cut(strings.Trim(r.URL.Path, "/"), "/")
+	id, _, _ := strings.Cut(rest, "/")
+	switch route {
+	case "jobs", "events", "progress":
+		j, ok := getJob(id)
+		if !ok {
+			return false
+		}
+		snap, _ := j.snapshot()
+		return snap.grant != nil && snap.grant.ID == g.ID
+	case "dl", "meta":
+		e, ok := getResult(id)
+		return ok && e.Token == g.ID
+	}
+	return false
+}
+
+// sourceFields reads url, input and input_id through the upload token like
+// options, so a token only fetches the sources it forces or allows.
+func sourceFields(r *http.Request) (remote, input, inputID string, err error) {
+	value := r.FormValue
+	var denied []string
+	if g := grantOf(r); g != nil {
+		value = g.value(value, &denied)
+	}
+	remote, input, inputID = value("url"), value("input"), value("input_id")
+	if len(denied) > 0 {
+		err = fmt.Errorf("sources not allowed by the upload token: %s", strings.Join(denied, ", "))
+	}
+	return remote, input, inputID, err
+}
+
+// uploadLimit is the body size cap for r: maxUploadSize, or less when the
+// upload token says so.
+func uploadLimit(r *http.Request) int64 {
+	if g := grantOf(r); g != nil && g.MaxBytes > 0 && g.MaxBytes < maxUploadSize {
+		return g.MaxBytes
+	}
+	return maxUploadSize
+}
+
+// value applies the token to option lookups: forced options win,
+// options outside the allow list are recorded in denied.
+func (g *uploadGrant) value(next func(string) string, denied *[]string) func(string) string {
+	return func(k string) string {
+		if v, ok := g.Params[k]; ok {
+			return v
+		}
+		v := next(k)
+		if v != "" && !slices.Contains(g.Allow, k) {
+			*denied = append(*denied, k)
+			return ""
+		}
+		return v
+	}
+}
+
+type uploadTokenRequest struct {
+	TTL      string            `json:"ttl"`
+	MaxBytes int64             `json:"max_bytes"`
+	Params   map[string]string `json:"params"`
+	Allow    []string          `json:"allow"`
+}
+
+// uploadTokensHandler serves POST /upload-tokens for API key holders.
+func uploadTokensHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := randID(6)
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if len(apiKeys()) == 0 {
+		http.Error(w, "upload tokens need API_KEYS to be configured", http.StatusNotFound)
+		return
+	}
+	key := r.Header.Get("X-API-Key")
+	if !validAPIKey(key) {
+		http.Error(w, "API key required", http.StatusUnauthorized)
+		return
+	}
+	var req uploadTokenRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ttl := uploadTokenDefaultTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > uploadTokenMaxTTL {
+			http.Error(w, fmt.Sprintf("invalid ttl %q (max %v)", req.TTL, uploadTokenMaxTTL), http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+	if req.MaxBytes < 0 || req.MaxBytes > maxUploadSize {
+		http.Error(w, fmt.Sprintf("max_bytes must be between 0 and %d", int64(maxUploadSize)), http.StatusBadRequest)
+		return
+	}
+	// the forced options must form a valid job on their own
+	if _, err := parseOptsFrom(func(k string) string { return req.Params[k] }); err != nil {
+		http.Error(w, "invalid params: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	expires := time.Now().Add(ttl)
+	g := &uploadGrant{ID: randID(12), Issuer: keyFingerprint(key), Expires: expires.Unix(),
+		MaxBytes: req.MaxBytes, Params: req.Params, Allow: req.Allow}
+	logger.Printf("🎟️ [%s] Issued upload token %s for %s (ttl %v)", requestID, g.ID, g.Issuer, ttl)
+	w.Header().Set("Cache-Control", "no-store")
+	tok := signToken(g)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"token":      tok,
+		"id":         g.ID,
+		"expires_at": expires.UTC().Format(time.RFC3339),
+		"upload_url": "/compress?upload_token=" + tok,
+	})
+}
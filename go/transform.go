@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Crop, rotate and flip (crop=w:h:x:y, rotate=90|180|270, flip=h|v)
+// ======================
+
+// crop=w:h:x:y keeps a w×h window whose top-left corner is at x,y (odd
+// sizes are rounded down to even, which 4:2:0 needs). rotate turns the
+// picture clockwise and flip mirrors it (h = left-right, v = upside down),
+// after the crop and before any scaling, so the resolution presets and
+// the max_landscape/max_portrait caps size the turned picture.
+//
+// All three work on the picture as it is displayed: phone footage stored
+// sideways with a display-matrix rotation is turned upright first (ffmpeg's
+// autorotation), and crop coordinates refer to the upright picture. With
+// preserve_capture the encode normally keeps that matrix instead of turning
+// the pixels; a crop, rotate or flip applies it to the pixels, as the two
+// would otherwise compound in players.
+
+var cropParamRe = regexp.MustCompile(`^(\d+):(\d+):(\d+):(\d+)$`)
+
+// parseTransform reads crop, rotate and flip into o.
+func parseTransform(crop, rotate, flip string, o *compressOpts) error {
+	if crop == "" && (rotate == "" || rotate == "0") && flip == "" {
+		return nil
+	}
+	if strings.ToLower(o.Codec) == "copy" {
+		return errors.New("crop, rotate and flip need a video re-encode (codec=copy keeps the bitstream as it is)")
+	}
+	if crop != "" {
+		if o.AutoCrop {
+			return errors.New("crop cannot be combined with autocrop=1")
+		}
+		m := cropParamRe.FindStringSubmatch(crop)
+		if m == nil {
+			return fmt.Errorf("invalid crop %q (w:h:x:y)", crop)
+		}
+		w, _ := strconv.Atoi(m[1])
+		h, _ := strconv.Atoi(m[2])
+		x, _ := strconv.Atoi(m[3])
+		y, _ := strconv.Atoi(m[4])
+		w, h = w&^1, h&^1
+		if w < 16 || h < 16 {
+			return fmt.Errorf("invalid crop %q (at least 16x16)", crop)
+		}
+		o.Crop = fmt.Sprintf("%d:%d:%d:%d", w, h, x, y)
+	}
+	switch rotate {
+	case "", "0":
+	case "90", "180", "270":
+		o.Rotate, _ = strconv.Atoi(rotate)
+	default:
+		return fmt.Errorf("invalid rotate %q (90|180|270)", rotate)
+	}
+	switch flip {
+	case "", "h", "v":
+		o.Flip = flip
+	default:
+		return fmt.Errorf("invalid flip %q (h|v)", flip)
+	}
+	if o.Reframe != nil && o.quarterTurn() {
+		return errors.New("rotate=90/270 cannot be combined with reframe (reframe to the turned aspect instead)")
+	}
+	return nil
+}
+
+// quarterTurn reports whether rotate swaps width and height.
+func (o *compressOpts) quarterTurn() bool {
+	return o.Rotate == 90 || o.Rotate == 270
+}
+
+// transformed reports whether crop=, rotate or flip is set (autocrop's crop
+// is found later and does not count).
+func (o *compressOpts) transformed() bool {
+	return (o.Crop != "" && !o.AutoCrop) || o.Rotate != 0 || o.Flip != ""
+}
+
+// transformFilters returns the rotate and flip filters.
+func (o *compressOpts) transformFilters() []string {
+	var vf []string
+	switch o.Rotate {
+	case 90:
+		vf = append(vf, "transpose=clock")
+	case 180:
+		vf = append(vf, "hflip", "vflip")
+	case 270:
+		vf = append(vf, "transpose=cclock")
+	}
+	switch o.Flip {
+	case "h":
+		vf = append(vf, "hflip")
+	case "v":
+		vf = append(vf, "vflip")
+	}
+	return vf
+}
+
+// uprightSize is the size of the displayed source after crop= and rotate.
+func (o *compressOpts) uprightSize() (w, h int, ok bool) {
+	if o.Source == nil {
+		return 0, 0, false
+	}
+	vs := o.Source.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return 0, 0, false
+	}
+	w, h = vs.Width, vs.Height
+	if r := vs.rotation(); r == 90 || r == 270 {
+		w, h = h, w
+	}
+	if o.Crop != "" && !o.AutoCrop {
+		fmt.Sscanf(o.Crop, "%d:%d", &w, &h)
+	}
+	if o.quarterTurn() {
+		w, h = h, w
+	}
+	return w, h, true
+}
+
+// turnScale swaps a fixed WxH scale (the resolution presets are landscape)
+// when a quarter turn leaves the picture in the other orientation: 720p of
+// a landscape video turned 90° is 720x1280.
+func (o *compressOpts) turnScale(s string) string {
+	w, h, ok := o.uprightSize()
+	sw, sh, cut := strings.Cut(s, ":")
+	if !ok || !cut || !o.quarterTurn() {
+		return s
+	}
+	tw, err1 := strconv.Atoi(sw)
+	th, err2 := strconv.Atoi(sh)
+	if err1 != nil || err2 != nil || tw <= 0 || th <= 0 || tw == th || (tw > th) == (w > h) {
+		return s
+	}
+	return sh + ":" + sw
+}
+
+// settleTransform checks crop= against the upright source. It returns notes
+// for the warnings.
+func (o *compressOpts) settleTransform() ([]string, error) {
+	if !o.transformed() || o.Source == nil {
+		return nil, nil
+	}
+	vs := o.Source.firstStream("video")
+	if vs == nil || vs.Width == 0 || vs.Height == 0 {
+		return nil, nil
+	}
+	var notes []string
+	r := vs.rotation()
+	if r != 0 && o.PreserveCapture {
+		notes = append(notes, fmt.Sprintf("preserve_capture: the source's %d° display rotation is applied to the pixels (crop/rotate/flip)", r))
+	}
+	if o.Crop != "" && !o.AutoCrop {
+		w, h := vs.Width, vs.Height
+		if r == 90 || r == 270 {
+			w, h = h, w
+		}
+		var cw, ch, cx, cy int
+		fmt.Sscanf(o.Crop, "%d:%d:%d:%d", &cw, &ch, &cx, &cy)
+		if cx+cw > w || cy+ch > h {
+			return nil, fmt.Errorf("crop %s does not fit the %dx%d picture", o.Crop, w, h)
+		}
+	}
+	if t := o.turnScale(o.Scale); t != o.Scale {
+		notes = append(notes, fmt.Sprintf("%s scaled to %s to match the turned picture", o.Resolution, strings.Replace(t, ":", "x", 1)))
+	}
+	return notes, nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestParseTransform(t *testing.T) {
+	tests := []struct {
+		name               string
+		crop, rotate, flip string
+		opts               compressOpts
+		wantCrop           string
+		wantRotate         int
+		wantFlip           string
+		wantErr            bool
+	}{
+		{name: "none"},
+		{name: "rotate 0 is none", rotate: "0", opts: compressOpts{Codec: "copy"}},
+		{name: "crop", crop: "1280:720:0:180", wantCrop: "1280:720:0:180"},
+		{name: "odd crop rounded down", crop: "101:57:10:20", wantCrop: "100:56:10:20"},
+		{name: "crop too small", crop: "17:8:0:0", wantErr: true},
+		{name: "crop not w:h:x:y", crop: "1280:720", wantErr: true},
+		{name: "negative crop", crop: "-1280:720:0:0", wantErr: true},
+		{name: "crop with autocrop", crop: "640:480:0:0", opts: compressOpts{AutoCrop: true}, wantErr: true},
+		{name: "rotate", rotate: "270", wantRotate: 270},
+		{name: "bad rotate", rotate: "45", wantErr: true},
+		{name: "flip", flip: "v", wantFlip: "v"},
+		{name: "bad flip", flip: "x", wantErr: true},
+		{name: "all three", crop: "640:480:8:8", rotate: "90", flip: "h", wantCrop: "640:480:8:8", wantRotate: 90, wantFlip: "h"},
+		{name: "stream copy", flip: "h", opts: compressOpts{Codec: "copy"}, wantErr: true},
+		{name: "quarter turn with reframe", rotate: "90", opts: compressOpts{Reframe: &reframeSpec{}}, wantErr: true},
+		{name: "half turn with reframe", rotate: "180", opts: compressOpts{Reframe: &reframeSpec{}}, wantRotate: 180},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.opts
+			err := parseTransform(tt.crop, tt.rotate, tt.flip, &o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTransform(%q, %q, %q) error = %v, want error %v", tt.crop, tt.rotate, tt.flip, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if o.Crop != tt.wantCrop || o.Rotate != tt.wantRotate || o.Flip != tt.wantFlip {
+				t.Errorf("parseTransform(%q, %q, %q) = crop %q rotate %d flip %q, want %q %d %q",
+					tt.crop, tt.rotate, tt.flip, o.Crop, o.Rotate, o.Flip, tt.wantCrop, tt.wantRotate, tt.wantFlip)
+			}
+		})
+	}
+}
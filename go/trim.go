@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ======================
+// Trimming (start/end/duration)
+// ======================
+
+// start, end and duration on /compress encode only part of the upload:
+// seconds ("75.5") or [HH:]MM:SS[.mmm] ("1:15.5"), as for /clip. end and
+// duration are alternatives; either ends the clip, else it runs to the end.
+// They set the same TrimStart/TrimEnd as a pipeline trim step, so the seek
+// (-ss before -i) and the length (-t) are built in one place. A re-encode
+// cuts on the exact frame. codec=copy cannot cut between keyframes, so the
+// start is moved back to the keyframe at or before it and the result says
+// where it really starts.
+
+// parseTrim reads start/end/duration into o.TrimStart and o.TrimEnd.
+func parseTrim(start, end, duration string, o *compressOpts) error {
+	if start == "" && end == "" && duration == "" {
+		return nil
+	}
+	if o.TrimDead != "" {
+		return errors.New("start/end/duration cannot be combined with trim_dead")
+	}
+	if end != "" && duration != "" {
+		return errors.New("give end or duration, not both")
+	}
+	var err error
+	if start != "" {
+		if o.TrimStart, err = parseTimecode(start); err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+	}
+	switch {
+	case end != "":
+		if o.TrimEnd, err = parseTimecode(end); err != nil {
+			return fmt.Errorf("end: %w", err)
+		}
+		if o.TrimEnd <= o.TrimStart {
+			return errors.New("end must be after start")
+		}
+	case duration != "":
+		d, err := parseTimecode(duration)
+		if err != nil {
+			return fmt.Errorf("duration: %w", err)
+		}
+		if d == 0 {
+			return errors.New("duration must be positive")
+		}
+		o.TrimEnd = o.TrimStart + d
+	}
+	return nil
+}
+
+// settleTrim checks the trim against the probed source: a start past the
+// end is an error, an end past it means "to the end", and a stream copy
+// starts on a keyframe. It returns notes for the warnings.
+func (o *compressOpts) settleTrim(ctx context.Context, inPath string) ([]string, error) {
+	if o.TrimDead != "" || (o.TrimStart == 0 && o.TrimEnd == 0) {
+		return nil, nil
+	}
+	var notes []string
+	dur := 0.0
+	if o.Source != nil {
+		dur = o.Source.durationSec()
+	}
+	if dur > 0 {
+		if o.TrimStart >= dur {
+			return nil, fmt.Errorf("start %.3fs is past the end of the video (%.3fs)", o.TrimStart, dur)
+		}
+		if o.TrimEnd >= dur {
+			o.TrimEnd = 0
+			notes = append(notes, fmt.Sprintf("end is past the end of the video (%.3fs); encoded to the end", dur))
+		}
+	}
+	if strings.ToLower(o.Codec) == "copy" && o.TrimStart > 0 {
+		if kf := keyframeBefore(ctx, inPath, o.TrimStart); kf < o.TrimStart {
+			notes = append(notes, fmt.Sprintf("codec=copy: starts at the keyframe at %.3fs (start=%.3fs)", kf, o.TrimStart))
+			o.TrimStart = kf
+		}
+	}
+	return notes, nil
+}
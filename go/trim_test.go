@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseTrim(t *testing.T) {
+	tests := []struct {
+		name                 string
+		start, end, duration string
+		trimDead             string
+		wantStart, wantEnd   float64
+		wantErr              bool
+	}{
+		{name: "none"},
+		{name: "start only", start: "75.5", wantStart: 75.5},
+		{name: "timecodes", start: "1:15.5", end: "01:02:03", wantStart: 75.5, wantEnd: 3723},
+		{name: "duration", start: "10", duration: "5", wantStart: 10, wantEnd: 15},
+		{name: "duration from 0", duration: "0:30", wantEnd: 30},
+		{name: "end and duration", end: "20", duration: "5", wantErr: true},
+		{name: "end before start", start: "20", end: "10", wantErr: true},
+		{name: "end at start", start: "20", end: "20", wantErr: true},
+		{name: "zero duration", duration: "0", wantErr: true},
+		{name: "bad start", start: "abc", wantErr: true},
+		{name: "negative start", start: "-5", wantErr: true},
+		{name: "minutes over 59", start: "1:60", wantErr: true},
+		{name: "too many fields", end: "1:2:3:4", wantErr: true},
+		{name: "with trim_dead", start: "5", trimDead: "silence", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := compressOpts{TrimDead: tt.trimDead}
+			err := parseTrim(tt.start, tt.end, tt.duration, &o)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrim(%q, %q, %q) error = %v, want error %v", tt.start, tt.end, tt.duration, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if o.TrimStart != tt.wantStart || o.TrimEnd != tt.wantEnd {
+				t.Errorf("parseTrim(%q, %q, %q) = %g-%g, want %g-%g", tt.start, tt.end, tt.duration, o.TrimStart, o.TrimEnd, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
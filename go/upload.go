@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ======================
+// Upload size limit
+// ======================
+
+// Uploads are capped at maxUploadSize (or an upload token's max_bytes) for
+// the whole request body. A
+// Content-Length over the cap is refused before anything is read; otherwise
+// the body is counted while the multipart stream is parsed and the request
+// fails with 413 as soon as the cap is crossed, instead of after gigabytes
+// have been spooled to disk. Form parts beyond uploadMemory spill to temp
+// files rather than memory.
+
+const uploadMemory = 32 << 20
+
+// parseUploadForm limits and parses a multipart request. On failure it has
+// answered the request (400 or 413) and returns false.
+func parseUploadForm(w http.ResponseWriter, r *http.Request, requestID string) bool {
+	limit := uploadLimit(r)
+	if r.ContentLength > limit {
+		logger.Printf("🚫 [%s] Upload of %s refused, limit %s", requestID, humanBytes(r.ContentLength), humanBytes(limit))
+		uploadTooLarge(w, limit)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	err := r.ParseMultipartForm(uploadMemory)
+	if err == nil {
+		return true
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		logger.Printf("🚫 [%s] Upload aborted at the %s limit", requestID, humanBytes(mbe.Limit))
+		if r.MultipartForm != nil {
+			r.MultipartForm.RemoveAll()
+		}
+		uploadTooLarge(w, limit)
+		return false
+	}
+	var nme *notMediaError
+	if errors.As(err, &nme) {
+		logger.Printf("🚫 [%s] Upload aborted: %v", requestID, nme)
+		if r.MultipartForm != nil {
+			r.MultipartForm.RemoveAll()
+		}
+		w.Header().Set("Connection", "close")
+		http.Error(w, nme.Error(), http.StatusUnsupportedMediaType)
+		return false
+	}
+	logger.Printf("❌ [%s] Failed to parse multipart form: %v", requestID, err)
+	http.Error(w, "expecting multipart/form-data: "+err.Error(), http.StatusBadRequest)
+	return false
+}
+
+func uploadTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Connection", "close")
+	http.Error(w, fmt.Sprintf("upload too large (limit %s)", humanBytes(limit)), http.StatusRequestEntityTooLarge)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ======================
+// Per-job resource usage (+ GET /metrics)
+// ======================
+
+// Every ffmpeg/ffprobe process a job starts adds its CPU time, peak RSS and
+// disk I/O (from the process's rusage once it exits) to the job's usage, which
+// is carried in the context. /meta reports the totals per result and /metrics
+// sums them per mode in Prometheus text format for cost attribution and
+// capacity planning. Peak RSS and I/O are only known on Linux.
+
+type resourceUsage struct {
+	CPUUserSec float64 `json:"cpu_user_sec"`
+	CPUSysSec  float64 `json:"cpu_sys_sec"`
+	MaxRSS     int64   `json:"max_rss_bytes"` // largest single process
+	ReadBytes  int64   `json:"read_bytes"`
+	WriteBytes int64   `json:"write_bytes"`
+	Processes  int     `json:"processes"`
+}
+
+func (u *resourceUsage) add(p resourceUsage) {
+	u.CPUUserSec += p.CPUUserSec
+	u.CPUSysSec += p.CPUSysSec
+	u.MaxRSS = max(u.MaxRSS, p.MaxRSS)
+	u.ReadBytes += p.ReadBytes
+	u.WriteBytes += p.WriteBytes
+	u.Processes += p.Processes
+}
+
+func (u resourceUsage) rounded() resourceUsage {
+	u.CPUUserSec = math.Round(u.CPUUserSec*1000) / 1000
+	u.CPUSysSec = math.Round(u.CPUSysSec*1000) / 1000
+	return u
+}
+
+// usageMeter accumulates the usage of one job.
+type usageMeter struct {
+	mu sync.Mutex
+	u  resourceUsage
+}
+
+func (m *usageMeter) snapshot() *resourceUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.u.rounded()
+	return &u
+}
+
+type usageCtxKey struct{}
+
+// withUsage starts metering the processes run under ctx.
+func withUsage(ctx context.Context) (context.Context, *usageMeter) {
+	m := &usageMeter{}
+	return context.WithValue(ctx, usageCtxKey{}, m), m
+}
+
+// recordUsage adds a finished process to the job metered by ctx, if any.
+func recordUsage(ctx context.Context, ps *os.ProcessState) {
+	if ps == nil {
+		return
+	}
+	m, _ := ctx.Value(usageCtxKey{}).(*usageMeter)
+	if m == nil {
+		return
+	}
+	p := processUsage(ps)
+	m.mu.Lock()
+	m.u.add(p)
+	m.mu.Unlock()
+}
+
+// usageTotals sums finished jobs per mode for /metrics.
+var usageTotals = struct {
+	sync.Mutex
+	jobs   map[string]int64
+	byMode map[string]*resourceUsage
+}{jobs: map[string]int64{}, byMode: map[string]*resourceUsage{}}
+
+func countUsage(mode string, u *resourceUsage) {
+	usageTotals.Lock()
+	defer usageTotals.Unlock()
+	usageTotals.jobs[mode]++
+	if usageTotals.byMode[mode] == nil {
+		usageTotals.byMode[mode] = &resourceUsage{}
+	}
+	usageTotals.byMode[mode].add(*u)
+}
+
+// metricsHandler serves GET /metrics (Prometheus text format).
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var b strings.Builder
+	series := func(name, typ, help string, value func(mode string, u *resourceUsage) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		modes := make([]string, 0, len(usageTotals.byMode))
+		for m := range usageTotals.byMode {
+			modes = append(modes, m)
+		}
+		sort.Strings(modes)
+		for _, m := range modes {
+			fmt.Fprintf(&b, "%s{mode=%q} %s\n", name, m, strconv.FormatFloat(value(m, usageTotals.byMode[m]), 'f', -1, 64))
+		}
+	}
+	usageTotals.Lock()
+	series("videocompress_jobs_total", "counter", "Finished encode jobs.",
+		func(m string, _ *resourceUsage) float64 { return float64(usageTotals.jobs[m]) })
+	series("videocompress_ffmpeg_cpu_seconds_total", "counter", "CPU time (user+system) of ffmpeg/ffprobe processes.",
+		func(_ string, u *resourceUsage) float64 { return u.CPUUserSec + u.CPUSysSec })
+	series("videocompress_ffmpeg_read_bytes_total", "counter", "Bytes read from disk by ffmpeg/ffprobe processes.",
+		func(_ string, u *resourceUsage) float64 { return float64(u.ReadBytes) })
+	series("videocompress_ffmpeg_write_bytes_total", "counter", "Bytes written to disk by ffmpeg/ffprobe processes.",
+		func(_ string, u *resourceUsage) float64 { return float64(u.WriteBytes) })
+	series("videocompress_ffmpeg_max_rss_bytes", "gauge", "Largest peak RSS of a single ffmpeg/ffprobe process.",
+		func(_ string, u *resourceUsage) float64 { return float64(u.MaxRSS) })
+	usageTotals.Unlock()
+	s := queue.stats()
+	fmt.Fprintf(&b, "# HELP videocompress_queue_depth Jobs queued or running.\n# TYPE videocompress_queue_depth gauge\nvideocompress_queue_depth %d\n", s.Depth)
+	fmt.Fprintf(&b, "# HELP videocompress_jobs_running Jobs holding a slot.\n# TYPE videocompress_jobs_running gauge\nvideocompress_jobs_running %d\n", s.Running)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
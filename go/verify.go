@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ======================
+// Post-encode verification (verify=0 to skip)
+// ======================
+
+// verifyOutput catches outputs that exist but are broken: truncated,
+// missing a stream, undecodable at either end, silent, or with audio and
+// video of different lengths.
+
+const (
+	verifyEdgeSec      = 2.0
+	verifyMinTolerance = 0.5  // seconds
+	verifyRelTolerance = 0.02 // of the expected duration
+	verifyMaxAVSkew    = 1.0  // seconds between audio and video stream ends
+	silenceDB          = -90.0
+)
+
+var maxVolumeRe = regexp.MustCompile(`max_volume:\s*(-?[\d.]+|-inf) dB`)
+
+// verifyError lists what is wrong with an output.
+type verifyError struct {
+	Problems []string
+}
+
+func (e *verifyError) Error() string {
+	return "output verification failed: " + strings.Join(e.Problems, "; ")
+}
+
+func streamSpan(s *probeStream) (start, end float64, ok bool) {
+	if s == nil {
+		return 0, 0, false
+	}
+	d, err := strconv.ParseFloat(s.Duration, 64)
+	if err != nil || d <= 0 {
+		return 0, 0, false
+	}
+	start, _ = strconv.ParseFloat(s.StartTime, 64)
+	return start, start + d, true
+}
+
+// decodeErrors decodes a few seconds at the start (or end) of path and
+// returns the decoder's complaints.
+func decodeErrors(ctx context.Context, path string, atEnd bool) string {
+	args := []string{"-hide_banner", "-nostats", "-v", "error"}
+	if atEnd {
+		args = append(args, "-sseof", "-"+strconv.FormatFloat(verifyEdgeSec*2, 'f', 1, 64))
+	}
+	args = append(args, "-i", path, "-t", strconv.FormatFloat(verifyEdgeSec, 'f', 1, 64), "-f", "null", "-")
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+	out, err := cmd.CombinedOutput()
+	recordUsage(ctx, cmd.ProcessState)
+	msg := strings.TrimSpace(string(out))
+	if err != nil && msg == "" {
+		msg = err.Error()
+	}
+	return msg
+}
+
+// maxVolume returns the peak level of the first audio stream in dB.
+func maxVolume(ctx context.Context, path string) (float64, error) {
+	args := []string{"-hide_banner", "-nostats", "-i", path,
+		"-map", "0:a:0", "-af", "volumedetect", "-f", "null", "-"}
+	cmd := exec.CommandContext(ctx, ffmpegFor(args), args...)
+	out, err := cmd.CombinedOutput()
+	recordUsage(ctx, cmd.ProcessState)
+	if err != nil {
+		return 0, fmt.Errorf("volumedetect: %s", lastLine(string(out)))
+	}
+	m := maxVolumeRe.FindSubmatch(out)
+	if m == nil {
+		return 0, fmt.Errorf("volumedetect: no result")
+	}
+	if string(m[1]) == "-inf" {
+		return math.Inf(-1), nil
+	}
+	return strconv.ParseFloat(string(m[1]), 64)
+}
+
+// verifyOutput checks outPath against what the options promised.
+func verifyOutput(ctx context.Context, inPath, outPath string, o compressOpts) error {
+	out, err := probeFile(ctx, outPath)
+	if err != nil {
+		return &verifyError{Problems: []string{"output does not probe: " + err.Error()}}
+	}
+	var problems []string
+	src := o.Source
+
+	// Streams the source had must still be there
+	ov, oa := out.firstStream("video"), out.firstStream("audio")
+	if src != nil && src.firstStream("video") != nil && ov == nil && o.AudioOut == nil {
+		problems = append(problems, "video stream missing")
+	}
+	if src != nil && src.firstStream("audio") != nil && oa == nil {
+		problems = append(problems, "audio stream missing")
+	}
+
+	// Duration within tolerance of the (trimmed) input
+	if src != nil && src.durationSec() > 0 && out.durationSec() > 0 {
+		want := src.durationSec()
+		if o.TrimEnd > 0 {
+			want = o.TrimEnd
+		}
+		want -= o.TrimStart
+		tol := math.Max(verifyMinTolerance, want*verifyRelTolerance)
+		if got := out.durationSec(); math.Abs(got-want) > tol {
+			problems = append(problems, fmt.Sprintf("duration %.2fs, expected %.2fs (±%.2fs): truncated or padded", got, want, tol))
+		}
+	}
+
+	// A/V end skew (a desync symptom) unless the source already had it
+	if _, vEnd, ok := streamSpan(ov); ok {
+		if _, aEnd, ok := streamSpan(oa); ok && math.Abs(vEnd-aEnd) > verifyMaxAVSkew {
+			srcSkew := 0.0
+			if src != nil {
+				_, sv, ok1 := streamSpan(src.firstStream("video"))
+				_, sa, ok2 := streamSpan(src.firstStream("audio"))
+				if ok1 && ok2 {
+					srcSkew = math.Abs(sv - sa)
+				}
+			}
+			if math.Abs(vEnd-aEnd) > srcSkew+verifyMaxAVSkew {
+				problems = append(problems, fmt.Sprintf("audio ends at %.2fs but video at %.2fs", aEnd, vEnd))
+			}
+		}
+	}
+
+	// First and last GOP must decode cleanly
+	if msg := decodeErrors(ctx, outPath, false); msg != "" {
+		problems = append(problems, "start does not decode: "+lastLine(msg))
+	}
+	if msg := decodeErrors(ctx, outPath, true); msg != "" {
+		problems = append(problems, "end does not decode: "+lastLine(msg))
+	}
+
+	// Audio that went silent in the encode
+	if oa != nil {
+		if v, err := maxVolume(ctx, outPath); err == nil && v <= silenceDB {
+			if sv, err := maxVolume(ctx, inPath); err == nil && sv > silenceDB {
+				problems = append(problems, fmt.Sprintf("audio is silent (source peaks at %.1f dB)", sv))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &verifyError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ======================
+// Watermark overlay (job spec filters.watermark)
+// ======================
+
+// watermarkSpec overlays an uploaded image (PNG with alpha works best) on
+// the video, sized relative to the video width.
+type watermarkSpec struct {
+	Input    string  `json:"input"`              // spec input name of the image
+	Position string  `json:"position,omitempty"` // top-left|top-right|bottom-left|bottom-right|center
+	Margin   int     `json:"margin,omitempty"`   // pixels from the edges
+	Scale    float64 `json:"scale,omitempty"`    // width as a fraction of the video width
+	Opacity  float64 `json:"opacity,omitempty"`  // 0-1
+
+	path string // saved upload
+}
+
+var watermarkPositions = map[string]string{
+	"top-left":     "%[1]d:%[1]d",
+	"top-right":    "W-w-%[1]d:%[1]d",
+	"bottom-left":  "%[1]d:H-h-%[1]d",
+	"bottom-right": "W-w-%[1]d:H-h-%[1]d",
+	"center":       "(W-w)/2:(H-h)/2",
+}
+
+// normalize fills defaults and validates the fields.
+func (wm *watermarkSpec) normalize() error {
+	if wm.Position == "" {
+		wm.Position = "bottom-right"
+	}
+	if _, ok := watermarkPositions[wm.Position]; !ok {
+		return fmt.Errorf("invalid watermark position %q (top-left|top-right|bottom-left|bottom-right|center)", wm.Position)
+	}
+	if wm.Margin == 0 {
+		wm.Margin = 16
+	}
+	if wm.Scale == 0 {
+		wm.Scale = 0.15
+	}
+	if wm.Opacity == 0 {
+		wm.Opacity = 1
+	}
+	if wm.Margin < 0 || wm.Scale <= 0 || wm.Scale > 1 || wm.Opacity < 0 || wm.Opacity > 1 {
+		return fmt.Errorf("invalid watermark (margin >= 0, scale 0-1, opacity 0-1)")
+	}
+	return nil
+}
+
+// watermarkGraph wraps the main video filters (vf, may be empty) into a
+// filter_complex that overlays input 1; the result is labelled [vout].
+func watermarkGraph(vf []string, wm *watermarkSpec) string {
+	base := "null"
+	if len(vf) > 0 {
+		base = strings.Join(vf, ",")
+	}
+	pos := watermarkPositions[wm.Position]
+	if strings.Contains(pos, "%") {
+		pos = fmt.Sprintf(pos, wm.Margin)
+	}
+	return fmt.Sprintf("[0:V:0]%s[b0];[1:v]format=rgba,colorchannelmixer=aa=%g[wm0];"+
+		"[wm0][b0]scale2ref=w=main_w*%g:h=ow/a[wm][b1];[b1][wm]overlay=%s:format=auto[vout]",
+		base, wm.Opacity, wm.Scale, pos)
+}